@@ -0,0 +1,93 @@
+package blueprints
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	shell "github.com/placer14/go-shell"
+)
+
+// BisectVerdict is the result of testing a candidate commit
+type BisectVerdict string
+
+const (
+	BisectGood BisectVerdict = "good"
+	BisectBad  BisectVerdict = "bad"
+	// BisectSkip marks a commit which could not be tested, such as one
+	// which does not build
+	BisectSkip BisectVerdict = "skip"
+)
+
+var firstBadCommitPattern = regexp.MustCompile(`(?m)^([0-9a-f]{40}) is the first bad commit`)
+
+// BisectStatus describes the progress of a bisect
+type BisectStatus struct {
+	// Candidate is the commit to test next
+	Candidate string
+	// FirstBad is set once the first bad commit has been found
+	FirstBad string
+}
+
+// BisectStart begins a git bisect of the commits between the good and
+// bad references and returns the first candidate. Any bisect already in
+// progress in the checkout is abandoned.
+func (s *OpenBazaarSource) BisectStart(good, bad string) (BisectStatus, error) {
+	if err := s.BisectReset(); err != nil {
+		return BisectStatus{}, err
+	}
+	return s.bisect("start", shell.Quote(bad), shell.Quote(good))
+}
+
+// BisectMark records the verdict for commit and returns the next
+// candidate, or the first bad commit once it is known
+func (s *OpenBazaarSource) BisectMark(commit string, verdict BisectVerdict) (BisectStatus, error) {
+	switch verdict {
+	case BisectGood, BisectBad, BisectSkip:
+	default:
+		return BisectStatus{}, fmt.Errorf("unknown bisect verdict (%s)", verdict)
+	}
+	return s.bisect(string(verdict), shell.Quote(commit))
+}
+
+// BisectReset ends any bisect in progress and restores the checkout
+func (s *OpenBazaarSource) BisectReset() error {
+	var proc = shell.Cmd("git bisect reset").SetWorkDir(s.packagePath()).Run()
+	if proc.ExitStatus != 0 {
+		return fmt.Errorf("resetting bisect: %s", proc.Error())
+	}
+	return nil
+}
+
+// Describe returns the abbreviated hash and subject of commit
+func (s *OpenBazaarSource) Describe(commit string) (string, error) {
+	var proc = shell.Cmd("git log -1 --format='%h %s'", shell.Quote(commit)).SetWorkDir(s.packagePath()).Run()
+	if proc.ExitStatus != 0 {
+		return "", fmt.Errorf("describing commit (%s): %s", commit, proc.Error())
+	}
+	return strings.TrimSpace(proc.String()), nil
+}
+
+func (s *OpenBazaarSource) bisect(args ...interface{}) (BisectStatus, error) {
+	var (
+		status BisectStatus
+		proc   = shell.Cmd(append([]interface{}{"git bisect"}, args...)...).SetWorkDir(s.packagePath()).Run()
+		output = proc.String()
+	)
+	if strings.Contains(output, "only 'skip'ped commits left") {
+		return status, fmt.Errorf("first bad commit cannot be determined: %s", output)
+	}
+	if proc.ExitStatus != 0 {
+		return status, fmt.Errorf("git bisect %s: %s", args[0], proc.Error())
+	}
+	if match := firstBadCommitPattern.FindStringSubmatch(output); match != nil {
+		status.FirstBad = match[1]
+		return status, nil
+	}
+	candidate, err := s.Commit()
+	if err != nil {
+		return status, fmt.Errorf("finding bisect candidate: %s", err.Error())
+	}
+	status.Candidate = candidate
+	return status, nil
+}
@@ -0,0 +1,142 @@
+package blueprints
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/OpenBazaar/mason/util"
+	shell "github.com/placer14/go-shell"
+)
+
+// mustCreateHistory creates a git repository with one commit per value
+// written to the file `value` and returns the repository path and the
+// hash of each commit
+func mustCreateHistory(t *testing.T, values ...int) (string, []string) {
+	var path = util.GenerateTempPath("bisect_origin")
+	if err := os.MkdirAll(path, 0755); err != nil {
+		t.Fatal(err)
+	}
+	var git = func(args ...interface{}) string {
+		var proc = shell.Cmd(append([]interface{}{"git -c user.name=mason -c user.email=mason@localhost"}, args...)...).SetWorkDir(path).Run()
+		if proc.ExitStatus != 0 {
+			t.Fatalf("git %v: %s", args, proc.Error())
+		}
+		return strings.TrimSpace(proc.String())
+	}
+	git("init -q")
+	var commits []string
+	for i, v := range values {
+		if err := ioutil.WriteFile(filepath.Join(path, "value"), []byte(fmt.Sprint(v)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		git("add value")
+		git("commit -q --allow-empty -m", shell.Quote(fmt.Sprintf("commit %d", i)))
+		commits = append(commits, git("rev-parse HEAD"))
+	}
+	return path, commits
+}
+
+func TestBisectFindsFirstBadCommit(t *testing.T) {
+	origin, commits := mustCreateHistory(t, 1, 1, 1, 1, 2, 2, 2, 2)
+	defer os.RemoveAll(origin)
+	var workDir = util.GenerateTempPath("bisect_mirror")
+	defer os.RemoveAll(workDir)
+
+	subject, err := InflateOpenBazaarDaemonFrom(workDir, origin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, err := subject.BisectStart(commits[0], commits[len(commits)-1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tested int
+	for status.FirstBad == "" {
+		value, err := ioutil.ReadFile(filepath.Join(subject.SourcePath(), "value"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var verdict = BisectGood
+		if string(value) != "1" {
+			verdict = BisectBad
+		}
+		if status, err = subject.BisectMark(status.Candidate, verdict); err != nil {
+			t.Fatal(err)
+		}
+		if tested++; tested > len(commits) {
+			t.Fatal("bisect did not finish")
+		}
+	}
+	if status.FirstBad != commits[4] {
+		t.Errorf("expected first bad commit (%s), but was (%s)", commits[4], status.FirstBad)
+	}
+	if description, err := subject.Describe(status.FirstBad); err != nil || !strings.HasSuffix(description, "commit 4") {
+		t.Errorf("expected description of commit 4, but was (%s, %v)", description, err)
+	}
+	if err := subject.BisectReset(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBisectReportsUntestableRange(t *testing.T) {
+	origin, commits := mustCreateHistory(t, 1, 1, 2, 2)
+	defer os.RemoveAll(origin)
+	var workDir = util.GenerateTempPath("bisect_skip")
+	defer os.RemoveAll(workDir)
+
+	subject, err := InflateOpenBazaarDaemonFrom(workDir, origin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer subject.BisectReset()
+	status, err := subject.BisectStart(commits[0], commits[3])
+	for err == nil && status.FirstBad == "" {
+		status, err = subject.BisectMark(status.Candidate, BisectSkip)
+	}
+	if err == nil {
+		t.Errorf("expected skipping every candidate to fail, but found (%s)", status.FirstBad)
+	}
+}
+
+func TestFetchMirrorsOriginBranches(t *testing.T) {
+	origin, commits := mustCreateHistory(t, 1, 2)
+	defer os.RemoveAll(origin)
+	var workDir = util.GenerateTempPath("bisect_fetch")
+	defer os.RemoveAll(workDir)
+
+	subject, err := InflateOpenBazaarDaemonFrom(workDir, origin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var git = func(args ...interface{}) string {
+		var proc = shell.Cmd(append([]interface{}{"git -c user.name=mason -c user.email=mason@localhost"}, args...)...).SetWorkDir(origin).Run()
+		if proc.ExitStatus != 0 {
+			t.Fatalf("git %v: %s", args, proc.Error())
+		}
+		return strings.TrimSpace(proc.String())
+	}
+	var branch = git("rev-parse --abbrev-ref HEAD")
+	git("commit -q --allow-empty -m", shell.Quote("advance"))
+	var head = git("rev-parse HEAD")
+	git("branch feature", commits[0])
+
+	if err := subject.Fetch(); err != nil {
+		t.Fatal(err)
+	}
+	for ref, expected := range map[string]string{branch: head, "feature": commits[0]} {
+		actual, err := subject.ResolveCommit(ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual != expected {
+			t.Errorf("expected (%s) to resolve to (%s), but was (%s)", ref, expected, actual)
+		}
+	}
+	if _, err := subject.ResolveCommit("missing"); err == nil {
+		t.Error("expected resolving an unknown ref to fail")
+	}
+}
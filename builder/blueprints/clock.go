@@ -0,0 +1,254 @@
+package blueprints
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	clockPackageName   = "masonclock"
+	clockPackageImport = "github.com/OpenBazaar/openbazaar-go/" + clockPackageName
+)
+
+// clockFuncs are the functions of package time which read the wall clock
+// and are replaced by the clock patch
+var clockFuncs = map[string]bool{"Now": true, "Since": true, "Until": true}
+
+// clockPackageSource is written into the patched source. It reads the
+// offset written by mason's faketime.Clock and only depends on the
+// standard library so any version of openbazaar-go can build it.
+const clockPackageSource = `// Package masonclock was added by mason's clock patch. Calls to time.Now,
+// time.Since and time.Until within openbazaar-go are replaced by the
+// functions here, which add the offset in the file named by
+// MASON_CLOCK_FILE so the node's clock can be advanced while it runs.
+package masonclock
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// refreshInterval is how often the clock file is checked for changes
+const refreshInterval = 100 * time.Millisecond
+
+var cache struct {
+	sync.Mutex
+	checked time.Time
+	file    os.FileInfo
+	offset  time.Duration
+}
+
+// Now returns the current time advanced by the clock offset
+func Now() time.Time {
+	return time.Now().Add(offset())
+}
+
+// Since returns the time elapsed since t
+func Since(t time.Time) time.Duration {
+	return Now().Sub(t)
+}
+
+// Until returns the duration until t
+func Until(t time.Time) time.Duration {
+	return t.Sub(Now())
+}
+
+// offset returns the cached offset, re-reading the clock file only when
+// it was replaced or modified since it was last read
+func offset() time.Duration {
+	var path = os.Getenv("MASON_CLOCK_FILE")
+	if path == "" {
+		return 0
+	}
+	cache.Lock()
+	defer cache.Unlock()
+	var now = time.Now()
+	if !cache.checked.IsZero() && now.Sub(cache.checked) < refreshInterval {
+		return cache.offset
+	}
+	cache.checked = now
+	fi, err := os.Stat(path)
+	if err != nil {
+		return cache.offset
+	}
+	if cache.file != nil && os.SameFile(cache.file, fi) && cache.file.ModTime().Equal(fi.ModTime()) {
+		return cache.offset
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return cache.offset
+	}
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(string(b)), 64)
+	if err != nil {
+		return cache.offset
+	}
+	cache.file = fi
+	cache.offset = time.Duration(seconds * float64(time.Second))
+	return cache.offset
+}
+`
+
+// ClockPatch has openbazaard read a clock which mason can advance. Every
+// call to time.Now, time.Since and time.Until outside of vendor and tests
+// is replaced with a package which adds the offset of the clock file
+// named by MASON_CLOCK_FILE. Timers and tickers still run in real time,
+// so expiry is noticed the next time a check runs.
+var ClockPatch = Patch{Name: "clock", Apply: patchClock}
+
+func patchClock(sourcePath string) error {
+	var rewritten int
+	var err = filepath.Walk(sourcePath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			switch info.Name() {
+			case "vendor", "testdata", ".git", clockPackageName:
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return nil
+		}
+		changed, err := rewriteClockCalls(path)
+		if changed {
+			rewritten++
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	var pkgPath = filepath.Join(sourcePath, clockPackageName)
+	if err := os.MkdirAll(pkgPath, 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(pkgPath, "clock.go"), []byte(clockPackageSource), 0644); err != nil {
+		return err
+	}
+	log.Infof("clock patch rewrote %d files", rewritten)
+	return nil
+}
+
+// rewriteClockCalls replaces uses of the wall clock within a single file,
+// returning true when the file was changed. The source is edited in place
+// rather than reprinted so comments stay where they were.
+func rewriteClockCalls(path string) (bool, error) {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	var fset = token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+	if err != nil {
+		return false, fmt.Errorf("parsing source: %s", err.Error())
+	}
+	var timeSpec *ast.ImportSpec
+	for _, spec := range f.Imports {
+		if spec.Path.Value == strconv.Quote("time") {
+			timeSpec = spec
+		}
+	}
+	if timeSpec == nil {
+		return false, nil
+	}
+	var timeName = "time"
+	if timeSpec.Name != nil {
+		timeName = timeSpec.Name.Name
+	}
+	if timeName == "_" || timeName == "." {
+		return false, nil
+	}
+
+	var (
+		offset    = func(p token.Pos) int { return fset.Position(p).Offset }
+		edits     []sourceEdit
+		remaining int
+	)
+	ast.Inspect(f, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		// identifiers which resolve to an object are local variables
+		// shadowing the package
+		x, ok := sel.X.(*ast.Ident)
+		if !ok || x.Name != timeName || x.Obj != nil {
+			return true
+		}
+		if clockFuncs[sel.Sel.Name] {
+			edits = append(edits, sourceEdit{offset(x.Pos()), offset(x.End()), clockPackageName})
+		} else {
+			remaining++
+		}
+		return true
+	})
+	if len(edits) == 0 {
+		return false, nil
+	}
+
+	var clockImport = strconv.Quote(clockPackageImport)
+	if remaining == 0 {
+		// time is no longer used, so its import becomes the clock's
+		edits = append(edits, sourceEdit{offset(timeSpec.Pos()), offset(timeSpec.End()), clockImport})
+	} else {
+		edits = append(edits, importEdit(f, fset, src, timeSpec, clockImport))
+	}
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start > edits[j].start })
+	for _, e := range edits {
+		src = append(src[:e.start], append([]byte(e.text), src[e.end:]...)...)
+	}
+	formatted, err := format.Source(src)
+	if err != nil {
+		return false, fmt.Errorf("formatting (%s): %s", path, err.Error())
+	}
+	return true, ioutil.WriteFile(path, formatted, 0644)
+}
+
+// sourceEdit replaces the bytes from start to end with text
+type sourceEdit struct {
+	start, end int
+	text       string
+}
+
+// importEdit adds an import of path on the line after spec, or beside it
+// when the import declaration is written on a single line
+func importEdit(f *ast.File, fset *token.FileSet, src []byte, spec *ast.ImportSpec, path string) sourceEdit {
+	var (
+		end    = fset.Position(spec.End()).Offset
+		parens bool
+		inline bool
+	)
+	for _, decl := range f.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT && gen.Pos() <= spec.Pos() && spec.End() <= gen.End() {
+			parens = gen.Lparen.IsValid()
+			inline = parens && fset.Position(gen.Rparen).Line == fset.Position(spec.End()).Line
+		}
+	}
+	if inline {
+		return sourceEdit{end, end, "; " + path}
+	}
+	var lineEnd = len(src)
+	if i := bytes.IndexByte(src[end:], '\n'); i >= 0 {
+		lineEnd = end + i + 1
+	}
+	if parens {
+		return sourceEdit{lineEnd, lineEnd, "\t" + path + "\n"}
+	}
+	return sourceEdit{lineEnd, lineEnd, "import " + path + "\n"}
+}
@@ -0,0 +1,187 @@
+package blueprints
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OpenBazaar/mason/util"
+)
+
+var clockPatchSources = map[string]string{
+	// time is still used after rewriting, so the clock is imported too
+	"main.go": `package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/core"
+)
+
+func main() {
+	var disputeWindow = 45 * 24 * time.Hour
+	fmt.Println(time.Now().Unix(), core.Stamp(), core.Expired(time.Now().Add(-disputeWindow), disputeWindow))
+	// the clock is read again once the test has advanced it
+	bufio.NewReader(os.Stdin).ReadString('\n')
+	fmt.Println(time.Now().Unix())
+}
+`,
+	// time is only read, so its import is replaced
+	"core/stamp.go": `package core
+
+import "time"
+
+// Stamp returns the current unix time
+func Stamp() int64 {
+	return time.Now().Unix()
+}
+`,
+	"core/expiry.go": `package core
+
+import gotime "time"
+
+// Expired returns true once the window since t has passed
+func Expired(t gotime.Time, window gotime.Duration) bool {
+	return gotime.Since(t) > window
+}
+`,
+	"core/expiry_test.go": `package core
+
+import "time"
+
+var testStarted = time.Now()
+`,
+	"vendor/github.com/example/lib/lib.go": `package lib
+
+import "time"
+
+var Started = time.Now()
+`,
+}
+
+func TestClockPatchRewritesWallClockCalls(t *testing.T) {
+	var gopath = util.GenerateTempPath("clock_patch")
+	defer os.RemoveAll(gopath)
+	var source = filepath.Join(gopath, "src", "github.com", "OpenBazaar", "openbazaar-go")
+	for name, content := range clockPatchSources {
+		var path = filepath.Join(source, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := ClockPatch.Apply(source); err != nil {
+		t.Fatal(err)
+	}
+
+	var read = func(name string) string {
+		b, err := ioutil.ReadFile(filepath.Join(source, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+	for name, expected := range map[string][]string{
+		"main.go":        {"\t\"time\"\n", "\"github.com/OpenBazaar/openbazaar-go/masonclock\"", "masonclock.Now().Unix()", "masonclock.Now().Add(-disputeWindow)", "45 * 24 * time.Hour"},
+		"core/stamp.go":  {"import \"github.com/OpenBazaar/openbazaar-go/masonclock\"\n", "return masonclock.Now().Unix()"},
+		"core/expiry.go": {"gotime \"time\"", "masonclock.Since(t)"},
+	} {
+		var content = read(name)
+		for _, e := range expected {
+			if !strings.Contains(content, e) {
+				t.Errorf("expected (%s) to contain (%s), but was:\n%s", name, e, content)
+			}
+		}
+	}
+	for _, name := range []string{"core/expiry_test.go", "vendor/github.com/example/lib/lib.go"} {
+		if read(name) != clockPatchSources[name] {
+			t.Errorf("expected (%s) to be left alone, but was:\n%s", name, read(name))
+		}
+	}
+
+	var clockFile = filepath.Join(gopath, "clock")
+	if err := ioutil.WriteFile(clockFile, []byte("+3888001\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var binary = filepath.Join(gopath, "patched")
+	var build = exec.Command("go", "build", "-o", binary, ".")
+	build.Dir = source
+	build.Env = append(os.Environ(), "GOPATH="+gopath, "GO111MODULE=off")
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("building patched source: %s\n%s", err, out)
+	}
+	var run = exec.Command(binary)
+	run.Env = append(os.Environ(), "MASON_CLOCK_FILE="+clockFile)
+	stdin, err := run.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, err := run.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := run.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer run.Wait()
+	defer stdin.Close()
+	var lines = bufio.NewReader(stdout)
+	out, err := lines.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields = strings.Fields(out)
+	if len(fields) != 3 {
+		t.Fatalf("unexpected output (%s)", out)
+	}
+	var real = time.Now().Unix()
+	for _, field := range fields[:2] {
+		if stamp, _ := strconv.ParseInt(field, 10, 64); stamp-real < 3888000 || stamp-real > 3888010 {
+			t.Errorf("expected the patched clock to be 45 days ahead, but was (%d) at (%d)", stamp, real)
+		}
+	}
+	if fields[2] != "true" {
+		t.Errorf("expected the dispute window to have expired, but was (%s)", fields[2])
+	}
+
+	// the offset is cached until the file is replaced, as faketime.Clock does
+	var advanced = clockFile + ".tmp"
+	if err := ioutil.WriteFile(advanced, []byte("+7776001\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(advanced, clockFile); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(150 * time.Millisecond)
+	if _, err := stdin.Write([]byte("\n")); err != nil {
+		t.Fatal(err)
+	}
+	out, err = lines.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stamp, _ := strconv.ParseInt(strings.TrimSpace(out), 10, 64); stamp-time.Now().Unix() < 7776000 || stamp-time.Now().Unix() > 7776010 {
+		t.Errorf("expected the advanced clock to be 90 days ahead, but was (%s)", strings.TrimSpace(out))
+	}
+}
+
+func TestLookupPatch(t *testing.T) {
+	if p, err := LookupPatch("clock"); err != nil || p.Name != "clock" {
+		t.Errorf("expected the clock patch, but was (%+v, %v)", p, err)
+	}
+	if _, err := LookupPatch("calendar"); err == nil {
+		t.Error("expected an unknown patch to be rejected")
+	}
+}
@@ -0,0 +1,43 @@
+package blueprints
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	shell "github.com/placer14/go-shell"
+)
+
+const fakeDaemonPackage = "github.com/OpenBazaar/mason/cmd/fakeobd"
+
+// FakeDaemonSource builds the fake openbazaard found in this repository,
+// which requires neither the network nor the openbazaar-go source
+type FakeDaemonSource struct {
+	workingDir string
+}
+
+// InflateFakeDaemon prepares targetDirectory to hold builds of the fake
+// daemon
+func InflateFakeDaemon(targetDirectory string) (*FakeDaemonSource, error) {
+	if err := os.MkdirAll(targetDirectory, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("making build path: %s", err.Error())
+	}
+	return &FakeDaemonSource{workingDir: targetDirectory}, nil
+}
+
+// Build compiles the fake daemon so it reports version and returns the
+// path to the binary
+func (s *FakeDaemonSource) Build(version string) (string, error) {
+	var binary = filepath.Join(s.workingDir, fmt.Sprintf("fakeobd_%s", version))
+	log.Infof("building fake openbazaard %s", version)
+	var proc = shell.Cmd(
+		"go", "build",
+		"-o", shell.Quote(binary),
+		"-ldflags", shell.Quote(fmt.Sprintf("-X main.version=%s", version)),
+		fakeDaemonPackage,
+	).SetWorkDir(s.workingDir).Run()
+	if proc.ExitStatus != 0 {
+		return "", fmt.Errorf("building fake daemon: %s", proc.Error())
+	}
+	return binary, nil
+}
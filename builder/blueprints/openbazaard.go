@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/op/go-logging"
 	shell "github.com/placer14/go-shell"
@@ -20,15 +21,23 @@ var log = logging.MustGetLogger("blueprints")
 type OpenBazaarSource struct {
 	workingDir          string
 	checkedoutReference string
+	origin              string
 }
 
 // InflateOpenBazaarDaemon creates a copy of the openbazaard source
 // at the specified targetDirectory. Default version is `master` and can
 // be set with *OpenBazaarSource.CheckoutVersion.
 func InflateOpenBazaarDaemon(targetDirectory string) (*OpenBazaarSource, error) {
+	return InflateOpenBazaarDaemonFrom(targetDirectory, openbazaardSource())
+}
+
+// InflateOpenBazaarDaemonFrom creates a copy of the openbazaard source
+// cloned from origin, such as a local mirror, at targetDirectory
+func InflateOpenBazaarDaemonFrom(targetDirectory, origin string) (*OpenBazaarSource, error) {
 	var source = &OpenBazaarSource{
 		workingDir:          targetDirectory,
 		checkedoutReference: "master",
+		origin:              origin,
 	}
 	if err := source.inflate(); err != nil {
 		return nil, err
@@ -42,7 +51,7 @@ func (s *OpenBazaarSource) inflate() error {
 		if mkerr := os.MkdirAll(s.packagePath(), os.ModePerm); mkerr != nil {
 			return fmt.Errorf("making source path: %s", mkerr.Error())
 		}
-		proc := shell.Cmd(openbazaardSource()).SetWorkDir(s.packagePath()).Run()
+		proc := shell.Cmd("git clone", s.origin, ".").SetWorkDir(s.packagePath()).Run()
 		if proc.ExitStatus != 0 {
 			return fmt.Errorf("cloning source: %s", proc.Error())
 		}
@@ -55,6 +64,9 @@ func (s *OpenBazaarSource) inflate() error {
 // WorkDir is the root of a GOPATH which contains the checked-out source
 func (s *OpenBazaarSource) WorkDir() string { return s.workingDir }
 
+// SourcePath is the git checkout of the openbazaard source
+func (s *OpenBazaarSource) SourcePath() string { return s.packagePath() }
+
 func (s *OpenBazaarSource) packagePath() string {
 	return filepath.Join(s.workingDir, "src", "github.com", "OpenBazaar", "openbazaar-go")
 }
@@ -82,7 +94,43 @@ func openbazaardSource() string {
 		}
 		source = altPath
 	}
-	return fmt.Sprintf("git clone %s .", source)
+	return source
+}
+
+// Commit returns the full hash of the checked-out commit
+func (s *OpenBazaarSource) Commit() (string, error) {
+	var proc = shell.Cmd("git rev-parse HEAD").SetWorkDir(s.packagePath()).Run()
+	if proc.ExitStatus != 0 {
+		return "", fmt.Errorf("resolving commit: %s", proc.Error())
+	}
+	return strings.TrimSpace(proc.String()), nil
+}
+
+// Fetch mirrors the branches and tags of the origin into the checkout's
+// own branches and tags, so branch names resolve to the origin's commits
+// and clones of the checkout contain every branch. HEAD is detached first
+// as git will not update the branch which is checked out.
+func (s *OpenBazaarSource) Fetch() error {
+	if proc := shell.Cmd("git checkout -q --detach").SetWorkDir(s.packagePath()).Run(); proc.ExitStatus != 0 {
+		return fmt.Errorf("detaching checkout: %s", proc.Error())
+	}
+	var proc = shell.Cmd("git fetch --force --prune origin",
+		shell.Quote("+refs/heads/*:refs/heads/*"),
+		shell.Quote("+refs/tags/*:refs/tags/*"),
+	).SetWorkDir(s.packagePath()).Run()
+	if proc.ExitStatus != 0 {
+		return fmt.Errorf("fetching source: %s", proc.Error())
+	}
+	return nil
+}
+
+// ResolveCommit returns the full hash of the commit ref names
+func (s *OpenBazaarSource) ResolveCommit(ref string) (string, error) {
+	var proc = shell.Cmd("git rev-parse --verify", shell.Quote(ref+"^{commit}")).SetWorkDir(s.packagePath()).Run()
+	if proc.ExitStatus != 0 {
+		return "", fmt.Errorf("resolving (%s): %s", ref, proc.Error())
+	}
+	return strings.TrimSpace(proc.String()), nil
 }
 
 func (s *OpenBazaarSource) BinaryPrefix() string {
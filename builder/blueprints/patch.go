@@ -0,0 +1,42 @@
+package blueprints
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Patch modifies checked-out source before it is built. Builds with
+// patches are cached separately under each patch's name.
+type Patch struct {
+	Name  string
+	Apply func(sourcePath string) error
+}
+
+var patches = map[string]Patch{
+	ClockPatch.Name: ClockPatch,
+}
+
+// LookupPatch returns the patch with the given name
+func LookupPatch(name string) (Patch, error) {
+	p, ok := patches[name]
+	if !ok {
+		var names = make([]string, 0, len(patches))
+		for n := range patches {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return Patch{}, fmt.Errorf("unknown patch (%s), expected one of %v", name, names)
+	}
+	return p, nil
+}
+
+// ApplyPatches applies each patch to the checked-out source in order
+func (s *OpenBazaarSource) ApplyPatches(patches []Patch) error {
+	for _, p := range patches {
+		log.Infof("applying %s patch to openbazaard %s", p.Name, s.checkedoutReference)
+		if err := p.Apply(s.packagePath()); err != nil {
+			return fmt.Errorf("applying %s patch: %s", p.Name, err.Error())
+		}
+	}
+	return nil
+}
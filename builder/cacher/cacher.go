@@ -9,6 +9,7 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
 
 	logging "github.com/op/go-logging"
 )
@@ -31,6 +32,7 @@ type cacherImpl struct {
 
 	sourcePath string
 	stores     map[string]cacherStore
+	metadata   map[string]cacherMetadata
 }
 
 type cacherStore map[string]string
@@ -104,12 +106,34 @@ func writeCacherStoreIndex(store cacherStore, path string) error {
 	if err != nil {
 		return fmt.Errorf("marshaling index: %s", err.Error())
 	}
-	if err := ioutil.WriteFile(filepath.Join(path, defaultCacheStoreFilename), sBytes, 0644); err != nil {
+	if err := writeFileAtomic(filepath.Join(path, defaultCacheStoreFilename), sBytes); err != nil {
 		return fmt.Errorf("writing index: %s", err.Error())
 	}
 	return nil
 }
 
+// writeFileAtomic writes b to a temporary file beside path and renames it
+// into place, so concurrent readers never observe a partial file
+func writeFileAtomic(path string, b []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(0644); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
 var (
 	ErrNoCacheFound = errors.New("cached version not found")
 	ErrNoStoreFound = errors.New("cache store not found")
@@ -133,6 +157,7 @@ func OpenOrCreate(path string) (*cacherImpl, error) {
 	var c = &cacherImpl{
 		sourcePath: path,
 		stores:     make(map[string]cacherStore),
+		metadata:   make(map[string]cacherMetadata),
 	}
 
 	for _, dir := range dirs {
@@ -141,6 +166,7 @@ func OpenOrCreate(path string) (*cacherImpl, error) {
 			return nil, fmt.Errorf("loading cache store: %s", err.Error())
 		}
 		c.stores[dir.Name()] = store
+		c.metadata[dir.Name()] = loadCacherMetadata(filepath.Join(path, dir.Name()))
 	}
 
 	return c, nil
@@ -148,10 +174,10 @@ func OpenOrCreate(path string) (*cacherImpl, error) {
 
 // Get accepts a store namespace and version string which it will use to
 // locate cached versions. These strings must match exactly to return
-// a cached binary
+// a cached binary. The version's last use is recorded.
 func (c *cacherImpl) Get(store, version string) (string, error) {
-	c.RLock()
-	defer c.RUnlock()
+	c.Lock()
+	defer c.Unlock()
 
 	s, sOK := c.stores[store]
 	if !sOK {
@@ -161,6 +187,7 @@ func (c *cacherImpl) Get(store, version string) (string, error) {
 	if !vOK {
 		return "", ErrNoCacheFound
 	}
+	c.touch(store, version)
 	return filepath.Join(c.sourcePath, store, path), nil
 }
 
@@ -169,6 +196,12 @@ func (c *cacherImpl) Get(store, version string) (string, error) {
 // not exist, it will be created. Any non-nil should expect the cache
 // is not persisting the binary and will be returned to the prior safe state
 func (c *cacherImpl) Cache(store, version, path string) error {
+	return c.CacheCommit(store, version, "", path)
+}
+
+// CacheCommit caches the binary at path as Cache does, recording the
+// commit which version resolved to when it was built
+func (c *cacherImpl) CacheCommit(store, version, commit, path string) error {
 	var (
 		baseFilename  = filepath.Base(path)
 		storePath     = filepath.Join(c.sourcePath, store)
@@ -203,5 +236,11 @@ func (c *cacherImpl) Cache(store, version, path string) error {
 		log.Infof("updated cache index with version (%s) at (%s)", version, cacheFilePath)
 	}
 
+	checksum, err := fileChecksum(cacheFilePath)
+	if err != nil {
+		log.Warningf("failed checksumming cached binary: %s", err.Error())
+	}
+	c.setMetadata(store, version, Metadata{Commit: commit, Checksum: checksum, Cached: time.Now()})
+
 	return nil
 }
@@ -0,0 +1,289 @@
+package cacher
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const defaultCacheMetadataFilename = ".cache_metadata"
+
+// Metadata describes how and when a cached version was produced and used.
+// It is kept beside each store's index so older indices remain readable.
+type Metadata struct {
+	// Commit is the git commit the version resolved to when built
+	Commit string `json:"commit,omitempty"`
+	// Checksum is the hex SHA-256 of the binary when it was cached
+	Checksum string    `json:"checksum,omitempty"`
+	Cached   time.Time `json:"cached"`
+	LastUsed time.Time `json:"lastUsed"`
+}
+
+type cacherMetadata map[string]Metadata
+
+// Entry describes a single cached version
+type Entry struct {
+	Store   string `json:"store"`
+	Version string `json:"version"`
+	Path    string `json:"path"`
+	// Size is the size of the binary in bytes, or -1 when it is missing
+	Size int64 `json:"size"`
+	Metadata
+}
+
+// LastActive returns when the version was last used, or cached if it has
+// never been used
+func (e Entry) LastActive() time.Time {
+	if e.LastUsed.After(e.Cached) {
+		return e.LastUsed
+	}
+	return e.Cached
+}
+
+// loadCacherMetadata reads the metadata kept beside a store's index. The
+// metadata is advisory, so a missing or unreadable file is treated as
+// empty rather than making the store unusable.
+func loadCacherMetadata(path string) cacherMetadata {
+	var metadata = make(cacherMetadata)
+	b, err := ioutil.ReadFile(filepath.Join(path, defaultCacheMetadataFilename))
+	if os.IsNotExist(err) {
+		return metadata
+	} else if err != nil {
+		log.Warningf("ignoring unreadable cache metadata (%s): %s", path, err.Error())
+		return metadata
+	}
+	if err := json.Unmarshal(b, &metadata); err != nil {
+		log.Warningf("ignoring unparseable cache metadata (%s): %s", path, err.Error())
+		return make(cacherMetadata)
+	}
+	return metadata
+}
+
+// updateCacherMetadata applies update to the metadata currently on disk
+// and replaces the file atomically, so changes written by other processes
+// sharing the cache are kept. The merged metadata is returned.
+func updateCacherMetadata(path string, update func(cacherMetadata)) (cacherMetadata, error) {
+	var metadata = loadCacherMetadata(path)
+	update(metadata)
+	var b, err = json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return metadata, fmt.Errorf("marshaling metadata: %s", err.Error())
+	}
+	if err := writeFileAtomic(filepath.Join(path, defaultCacheMetadataFilename), b); err != nil {
+		return metadata, fmt.Errorf("writing metadata: %s", err.Error())
+	}
+	return metadata, nil
+}
+
+// setMetadata records m for a version. The caller must hold the lock.
+func (c *cacherImpl) setMetadata(store, version string, m Metadata) {
+	metadata, err := updateCacherMetadata(filepath.Join(c.sourcePath, store), func(metadata cacherMetadata) {
+		metadata[version] = m
+	})
+	if err != nil {
+		log.Warningf("failed updating cache metadata: %s", err.Error())
+	}
+	c.metadata[store] = metadata
+}
+
+// touch records the use of a version. The caller must hold the lock.
+func (c *cacherImpl) touch(store, version string) {
+	metadata, err := updateCacherMetadata(filepath.Join(c.sourcePath, store), func(metadata cacherMetadata) {
+		m, ok := metadata[version]
+		if !ok {
+			m = c.metadata[store][version]
+		}
+		m.LastUsed = time.Now()
+		metadata[version] = m
+	})
+	if err != nil {
+		log.Warningf("failed recording cache use: %s", err.Error())
+	}
+	c.metadata[store] = metadata
+}
+
+func fileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	var h = sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// entry describes a version. The caller must hold the lock.
+func (c *cacherImpl) entry(store, version string) Entry {
+	var e = Entry{
+		Store:    store,
+		Version:  version,
+		Path:     filepath.Join(c.sourcePath, store, c.stores[store][version]),
+		Size:     -1,
+		Metadata: c.metadata[store][version],
+	}
+	if info, err := os.Stat(e.Path); err == nil {
+		e.Size = info.Size()
+		if e.Cached.IsZero() {
+			// versions cached before metadata was kept
+			e.Cached = info.ModTime()
+		}
+	}
+	return e
+}
+
+// Entries returns every cached version ordered by store and version
+func (c *cacherImpl) Entries() []Entry {
+	c.RLock()
+	defer c.RUnlock()
+	var entries []Entry
+	for store, versions := range c.stores {
+		for version := range versions {
+			entries = append(entries, c.entry(store, version))
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Store != entries[j].Store {
+			return entries[i].Store < entries[j].Store
+		}
+		return entries[i].Version < entries[j].Version
+	})
+	return entries
+}
+
+// Info describes a single cached version
+func (c *cacherImpl) Info(store, version string) (Entry, error) {
+	c.RLock()
+	defer c.RUnlock()
+	if _, ok := c.stores[store]; !ok {
+		return Entry{}, ErrNoStoreFound
+	}
+	if _, ok := c.stores[store][version]; !ok {
+		return Entry{}, ErrNoCacheFound
+	}
+	return c.entry(store, version), nil
+}
+
+// Remove deletes a cached version and its binary
+func (c *cacherImpl) Remove(store, version string) error {
+	c.Lock()
+	defer c.Unlock()
+	return c.remove(store, version)
+}
+
+// remove deletes a cached version. The caller must hold the lock.
+func (c *cacherImpl) remove(store, version string) error {
+	s, ok := c.stores[store]
+	if !ok {
+		return ErrNoStoreFound
+	}
+	filename, ok := s[version]
+	if !ok {
+		return ErrNoCacheFound
+	}
+	var storePath = filepath.Join(c.sourcePath, store)
+
+	delete(s, version)
+	if err := writeCacherStoreIndex(s, storePath); err != nil {
+		s[version] = filename
+		return fmt.Errorf("writing store cache: %s", err.Error())
+	}
+	metadata, err := updateCacherMetadata(storePath, func(metadata cacherMetadata) {
+		delete(metadata, version)
+	})
+	if err != nil {
+		log.Warningf("failed updating cache metadata: %s", err.Error())
+	}
+	c.metadata[store] = metadata
+
+	for _, other := range s {
+		if other == filename {
+			return nil
+		}
+	}
+	if err := os.Remove(filepath.Join(storePath, filename)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing cached binary: %s", err.Error())
+	}
+	log.Infof("removed version (%s) from store (%s)", version, store)
+	return nil
+}
+
+// Prune removes versions which have not been used since before the
+// cutoff and versions whose binary is missing. When dryRun is set
+// nothing is removed. The affected versions are returned.
+func (c *cacherImpl) Prune(cutoff time.Time, dryRun bool) ([]Entry, error) {
+	c.Lock()
+	defer c.Unlock()
+	var pruned []Entry
+	for store, versions := range c.stores {
+		for version := range versions {
+			var e = c.entry(store, version)
+			if e.Size >= 0 && !e.LastActive().Before(cutoff) {
+				continue
+			}
+			pruned = append(pruned, e)
+		}
+	}
+	sort.Slice(pruned, func(i, j int) bool { return pruned[i].LastActive().Before(pruned[j].LastActive()) })
+	if dryRun {
+		return pruned, nil
+	}
+	for _, e := range pruned {
+		if err := c.remove(e.Store, e.Version); err != nil {
+			return pruned, fmt.Errorf("pruning version (%s): %s", e.Version, err.Error())
+		}
+	}
+	return pruned, nil
+}
+
+// VerifyResult describes a cached version which failed verification
+type VerifyResult struct {
+	Entry
+	Problem string `json:"problem"`
+}
+
+// Verify checks that every cached binary exists, is executable and
+// matches the checksum recorded when it was cached. The versions which
+// failed are returned.
+func (c *cacherImpl) Verify() []VerifyResult {
+	var failed []VerifyResult
+	for _, e := range c.Entries() {
+		if problem := verifyEntry(e); problem != "" {
+			failed = append(failed, VerifyResult{Entry: e, Problem: problem})
+		}
+	}
+	return failed
+}
+
+func verifyEntry(e Entry) string {
+	info, err := os.Stat(e.Path)
+	if err != nil {
+		return fmt.Sprintf("binary unreadable: %s", err.Error())
+	}
+	if !info.Mode().IsRegular() {
+		return "binary is not a regular file"
+	}
+	if info.Mode().Perm()&0111 == 0 {
+		return "binary is not executable"
+	}
+	if e.Checksum == "" {
+		return ""
+	}
+	checksum, err := fileChecksum(e.Path)
+	if err != nil {
+		return fmt.Sprintf("checksumming binary: %s", err.Error())
+	}
+	if checksum != e.Checksum {
+		return fmt.Sprintf("checksum (%s) does not match (%s) recorded when cached", checksum, e.Checksum)
+	}
+	return ""
+}
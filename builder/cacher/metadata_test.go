@@ -0,0 +1,215 @@
+package cacher_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/OpenBazaar/mason/builder/cacher"
+)
+
+func TestCacherRecordsMetadata(t *testing.T) {
+	var (
+		buildPath, buildClean = mustGetCleanTempDir("cacher-buildpath")
+		p, clean              = mustGetCleanTempDir("cacher-metadata")
+		binaryPath            = filepath.Join(buildPath, "binary")
+	)
+	defer clean()
+	defer buildClean()
+	mustCreateTestBinary(binaryPath)
+
+	c, err := cacher.OpenOrCreate(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.CacheCommit("store", "v1", "abc123", binaryPath); err != nil {
+		t.Fatal(err)
+	}
+	info, err := c.Info("store", "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Commit != "abc123" || info.Size != int64(len("filebinary")) || info.Checksum == "" {
+		t.Errorf("expected commit, size and checksum to be recorded, but was (%+v)", info)
+	}
+	if !info.LastUsed.IsZero() {
+		t.Errorf("expected unused version to have no last use, but was (%s)", info.LastUsed)
+	}
+
+	if _, err := c.Get("store", "v1"); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := cacher.OpenOrCreate(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, err := reopened.Info("store", "v1"); err != nil || info.LastUsed.IsZero() || info.Commit != "abc123" {
+		t.Errorf("expected last use and commit to persist, but was (%+v, %v)", info, err)
+	}
+	if _, err := reopened.Info("store", "v2"); err != cacher.ErrNoCacheFound {
+		t.Errorf("expected ErrNoCacheFound, but was (%v)", err)
+	}
+}
+
+func TestCacherReadsIndexWithoutMetadata(t *testing.T) {
+	var p, clean = mustGetCleanTempDir("cacher-legacy")
+	defer clean()
+	var storePath = filepath.Join(p, "store")
+	if err := os.MkdirAll(storePath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	mustCreateTestBinary(filepath.Join(storePath, "binary"))
+	if err := ioutil.WriteFile(filepath.Join(storePath, ".cache_index"), []byte(`{"v1":"binary"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := cacher.OpenOrCreate(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries = c.Entries()
+	if len(entries) != 1 || entries[0].Version != "v1" || entries[0].Cached.IsZero() {
+		t.Errorf("expected legacy version with cached time from the binary, but was (%+v)", entries)
+	}
+	if failed := c.Verify(); len(failed) != 0 {
+		t.Errorf("expected legacy version without checksum to verify, but was (%+v)", failed)
+	}
+}
+
+func TestCacherRemovePruneAndVerify(t *testing.T) {
+	var (
+		buildPath, buildClean = mustGetCleanTempDir("cacher-buildpath")
+		p, clean              = mustGetCleanTempDir("cacher-prune")
+	)
+	defer clean()
+	defer buildClean()
+
+	c, err := cacher.OpenOrCreate(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, version := range []string{"v1", "v2", "v3"} {
+		var binaryPath = filepath.Join(buildPath, version)
+		mustCreateTestBinary(binaryPath)
+		if err := c.Cache("store", version, binaryPath); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := c.Remove("store", "v1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(p, "store", "v1")); !os.IsNotExist(err) {
+		t.Errorf("expected removed binary to be deleted, but was not (%v)", err)
+	}
+	if _, err := c.Get("store", "v1"); err != cacher.ErrNoCacheFound {
+		t.Errorf("expected removed version to be missing, but was (%v)", err)
+	}
+
+	var corrupted = filepath.Join(p, "store", "v2")
+	if err := os.Chmod(corrupted, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(corrupted, []byte("corrupted"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if failed := c.Verify(); len(failed) != 1 || failed[0].Version != "v2" {
+		t.Errorf("expected corrupted version to fail verification, but was (%+v)", failed)
+	}
+
+	if pruned, err := c.Prune(time.Now().Add(-time.Hour), false); err != nil || len(pruned) != 0 {
+		t.Errorf("expected recently cached versions to be kept, but pruned (%+v, %v)", pruned, err)
+	}
+	if err := os.Remove(filepath.Join(p, "store", "v3")); err != nil {
+		t.Fatal(err)
+	}
+	if pruned, err := c.Prune(time.Now().Add(-time.Hour), true); err != nil || len(pruned) != 1 || pruned[0].Version != "v3" {
+		t.Errorf("expected version with missing binary to be pruned, but was (%+v, %v)", pruned, err)
+	}
+	if len(c.Entries()) != 2 {
+		t.Error("expected dry run to keep every version, but did not")
+	}
+	if pruned, err := c.Prune(time.Now().Add(time.Hour), false); err != nil || len(pruned) != 2 {
+		t.Errorf("expected every version to be pruned, but was (%+v, %v)", pruned, err)
+	}
+	if entries := c.Entries(); len(entries) != 0 {
+		t.Errorf("expected empty cache, but was (%+v)", entries)
+	}
+}
+
+func TestCacherToleratesCorruptMetadata(t *testing.T) {
+	var p, clean = mustGetCleanTempDir("cacher-corrupt")
+	defer clean()
+	var storePath = filepath.Join(p, "store")
+	if err := os.MkdirAll(storePath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	mustCreateTestBinary(filepath.Join(storePath, "binary"))
+	if err := ioutil.WriteFile(filepath.Join(storePath, ".cache_index"), []byte(`{"v1":"binary"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(storePath, ".cache_metadata"), []byte(`{"v1":`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := cacher.OpenOrCreate(p)
+	if err != nil {
+		t.Fatalf("expected corrupt metadata to be ignored, but was (%s)", err)
+	}
+	if _, err := c.Get("store", "v1"); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := cacher.OpenOrCreate(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, err := reopened.Info("store", "v1"); err != nil || info.LastUsed.IsZero() {
+		t.Errorf("expected metadata to be rewritten on use, but was (%+v, %v)", info, err)
+	}
+}
+
+func TestCacherMergesMetadataWrittenByOthers(t *testing.T) {
+	var (
+		buildPath, buildClean = mustGetCleanTempDir("cacher-buildpath")
+		p, clean              = mustGetCleanTempDir("cacher-merge")
+	)
+	defer clean()
+	defer buildClean()
+
+	first, err := cacher.OpenOrCreate(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var binaryPath = filepath.Join(buildPath, "v1")
+	mustCreateTestBinary(binaryPath)
+	if err := first.CacheCommit("store", "v1", "abc123", binaryPath); err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := cacher.OpenOrCreate(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := second.Get("store", "v1"); err != nil {
+		t.Fatal(err)
+	}
+	binaryPath = filepath.Join(buildPath, "v2")
+	mustCreateTestBinary(binaryPath)
+	if err := first.CacheCommit("store", "v2", "def456", binaryPath); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := cacher.OpenOrCreate(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, err := reopened.Info("store", "v1"); err != nil || info.LastUsed.IsZero() || info.Commit != "abc123" {
+		t.Errorf("expected use recorded by another cacher to be kept, but was (%+v, %v)", info, err)
+	}
+	var matches, _ = filepath.Glob(filepath.Join(p, "store", ".cache_metadata.tmp*"))
+	if len(matches) != 0 {
+		t.Errorf("expected no temporary metadata to remain, but found (%v)", matches)
+	}
+}
@@ -27,29 +27,70 @@ type openBazaarBuilder struct {
 	friendlyLabel    string
 	versionReference string
 	workDir          string
+	binaryName       string
+	patches          []blueprints.Patch
+	compile          func(workDir, version string, patches []blueprints.Patch) (buildPath, commit string, err error)
 	targetOS         string
 	targetArch       string
 }
 
 func NewOpenBazaarDaemon(label, version string) *openBazaarBuilder {
+	return &openBazaarBuilder{
+		friendlyLabel:    label,
+		versionReference: version,
+		cachePath:        DefaultCachePath(),
+		binaryName:       "openbazaard",
+		compile:          compileOpenBazaarDaemon,
+	}
+}
+
+// NewFakeOpenBazaarDaemon returns a builder of the fake daemon in
+// cmd/fakeobd which reports version. It is built locally and cached
+// separately from openbazaard, so it can be used to test mason without
+// the network.
+func NewFakeOpenBazaarDaemon(label, version string) *openBazaarBuilder {
+	var b = NewOpenBazaarDaemon(label, version)
+	b.binaryName = "fakeobd"
+	b.compile = compileFakeDaemon
+	return b
+}
+
+// SetSource clones openbazaar-go from source, such as a local mirror,
+// instead of the default repository
+func (b *openBazaarBuilder) SetSource(source string) {
+	b.compile = func(workDir, version string, patches []blueprints.Patch) (string, string, error) {
+		return compileOpenBazaarDaemonFrom(workDir, version, source, patches)
+	}
+}
+
+// AddPatch applies p to the source once it is checked out. Patched builds
+// are cached under the patch's name so they never replace plain builds.
+func (b *openBazaarBuilder) AddPatch(p blueprints.Patch) {
+	b.patches = append(b.patches, p)
+	b.binaryName = fmt.Sprintf("%s_%s", b.binaryName, p.Name)
+}
+
+// DefaultCachePath is where built binaries are cached unless SetCachePath
+// is used
+func DefaultCachePath() string {
 	var homeDir = os.Getenv("HOME")
 	if homeDir == "" {
 		log.Warningf("HOME is unset, using current path")
 		homeDir = "."
 	}
-	return &openBazaarBuilder{
-		friendlyLabel:    label,
-		versionReference: version,
-		cachePath:        filepath.Join(homeDir, ".mason", "cache"),
-	}
+	return filepath.Join(homeDir, ".mason", "cache")
+}
+
+// SetCachePath changes where built binaries are cached
+func (b *openBazaarBuilder) SetCachePath(path string) {
+	b.cachePath = path
 }
 
 func (b *openBazaarBuilder) Build() (*runner.OpenBazaarRunner, error) {
 	c, err := cacher.OpenOrCreate(b.cachePath)
 	if err != nil {
-		log.Warningf("failed opening cache (%s): %s", b.cachePath, err.Error())
-	}
-	if runnerPath, err := c.Get("openbazaard", b.versionReference); err == nil {
+		log.Warningf("building without cache, failed opening cache (%s): %s", b.cachePath, err.Error())
+	} else if runnerPath, err := c.Get(b.binaryName, b.versionReference); err == nil {
 		return runner.FromBinaryPath(runnerPath)
 	}
 
@@ -59,30 +100,73 @@ func (b *openBazaarBuilder) Build() (*runner.OpenBazaarRunner, error) {
 	b.workDir = util.GenerateTempBuildPath(b.friendlyLabel)
 	log.Infof("building at %s", b.workDir)
 
-	src, err := blueprints.InflateOpenBazaarDaemon(b.workDir)
+	buildPath, commit, err := b.compile(b.workDir, b.versionReference, b.patches)
 	if err != nil {
-		return nil, fmt.Errorf("inflating source: %s", err.Error())
+		return nil, err
+	}
+	if c == nil {
+		return runner.FromBinaryPath(buildPath)
 	}
 
-	if err := src.CheckoutVersion(b.versionReference); err != nil {
-		return nil, fmt.Errorf("checkout version: %s", err.Error())
+	if err := c.CacheCommit(b.binaryName, b.versionReference, commit, buildPath); err != nil {
+		log.Warningf("failed caching build for %s (%s): %s", b.binaryName, b.versionReference, err.Error())
+		return nil, fmt.Errorf("caching build: %s", err.Error())
 	}
 
-	buildPath, err := generateOSSpecificBuild(src)
+	runnerPath, err := c.Get(b.binaryName, b.versionReference)
 	if err != nil {
-		return nil, fmt.Errorf("building for %s: %s", runtime.GOOS, err.Error())
+		return nil, fmt.Errorf("retrieving cached build: %s", err.Error())
 	}
+	return runner.FromBinaryPath(runnerPath)
+}
 
-	if err := c.Cache("openbazaard", b.versionReference, buildPath); err != nil {
-		log.Warningf("failed caching build for %s (%s): %s", "openbazaard", b.versionReference, err.Error())
-		return nil, fmt.Errorf("caching build: %s", err.Error())
+func compileOpenBazaarDaemon(workDir, version string, patches []blueprints.Patch) (string, string, error) {
+	src, err := blueprints.InflateOpenBazaarDaemon(workDir)
+	if err != nil {
+		return "", "", fmt.Errorf("inflating source: %s", err.Error())
 	}
+	return compileSource(src, version, patches)
+}
 
-	runnerPath, err := c.Get("openbazaard", b.versionReference)
+func compileOpenBazaarDaemonFrom(workDir, version, source string, patches []blueprints.Patch) (string, string, error) {
+	src, err := blueprints.InflateOpenBazaarDaemonFrom(workDir, source)
 	if err != nil {
-		return nil, fmt.Errorf("retrieving cached build: %s", err.Error())
+		return "", "", fmt.Errorf("inflating source: %s", err.Error())
 	}
-	return runner.FromBinaryPath(runnerPath)
+	return compileSource(src, version, patches)
+}
+
+func compileSource(src *blueprints.OpenBazaarSource, version string, patches []blueprints.Patch) (string, string, error) {
+	if err := src.CheckoutVersion(version); err != nil {
+		return "", "", fmt.Errorf("checkout version: %s", err.Error())
+	}
+	commit, err := src.Commit()
+	if err != nil {
+		return "", "", err
+	}
+	if err := src.ApplyPatches(patches); err != nil {
+		return "", "", err
+	}
+
+	buildPath, err := generateOSSpecificBuild(src)
+	if err != nil {
+		return "", "", fmt.Errorf("building for %s: %s", runtime.GOOS, err.Error())
+	}
+	return buildPath, commit, nil
+}
+
+// compileFakeDaemon builds the fake daemon, which has no commit of its own
+// and no source to patch
+func compileFakeDaemon(workDir, version string, patches []blueprints.Patch) (string, string, error) {
+	if len(patches) > 0 {
+		return "", "", fmt.Errorf("the fake daemon cannot be patched")
+	}
+	src, err := blueprints.InflateFakeDaemon(workDir)
+	if err != nil {
+		return "", "", fmt.Errorf("inflating fake daemon: %s", err.Error())
+	}
+	buildPath, err := src.Build(version)
+	return buildPath, "", err
 }
 
 func generateOSSpecificBuild(src *blueprints.OpenBazaarSource) (string, error) {
@@ -1,11 +1,20 @@
 package builder_test
 
 import (
+	"context"
 	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/OpenBazaar/mason/builder"
+	"github.com/OpenBazaar/mason/builder/blueprints"
+	"github.com/OpenBazaar/mason/builder/runner"
+	"github.com/OpenBazaar/mason/util"
 	shell "github.com/placer14/go-shell"
 )
 
@@ -14,6 +23,9 @@ func init() {
 }
 
 func TestOpenBazaarBuildsInParallel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds openbazaar-go from the network")
+	}
 	var wg sync.WaitGroup
 	wg.Add(2)
 
@@ -28,12 +40,12 @@ func TestOpenBazaarBuildsInParallel(t *testing.T) {
 			t.Fatal(err)
 		}
 
-		version, err := ob.Version()
+		version, err := ob.SemanticVersion()
 		if err != nil {
 			t.Fatal(err)
 		}
 
-		if version != expectedVersion {
+		if version != runner.MustParseSemVer(expectedVersion) {
 			t.Fatalf("expected version %s, got %s", expectedVersion, version)
 		}
 	}()
@@ -49,14 +61,130 @@ func TestOpenBazaarBuildsInParallel(t *testing.T) {
 			t.Fatal(err)
 		}
 
-		version, err := ob.Version()
+		version, err := ob.SemanticVersion()
 		if err != nil {
 			t.Fatal(err)
 		}
-		if version != expectedVersion {
+		if version != runner.MustParseSemVer(expectedVersion) {
 			t.Errorf("expected version %s, got %s", expectedVersion, version)
 		}
 	}()
 
 	wg.Wait()
 }
+
+func mustBuildFakeDaemon(t *testing.T, version, cachePath string) *runner.OpenBazaarRunner {
+	var node = builder.NewFakeOpenBazaarDaemon("fake", version)
+	defer node.MustClean()
+	node.SetCachePath(cachePath)
+	ob, err := node.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ob
+}
+
+func mustInitFakeDaemon(t *testing.T, ob *runner.OpenBazaarRunner, dataPath string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var port = l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	ob.SetCustomDataPath(dataPath)
+	ob.SetRunPath(filepath.Join(dataPath, "run"))
+	if err := ob.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ob.SetConfigValue("Addresses.Gateway", fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", port)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFakeOpenBazaarDaemonRunsEndToEnd(t *testing.T) {
+	var path = util.GenerateTempPath("fake_daemon")
+	defer os.RemoveAll(path)
+	var cachePath = filepath.Join(path, "cache")
+
+	var ob = mustBuildFakeDaemon(t, "v0.12.4", cachePath)
+	defer ob.Cleanup()
+	version, err := ob.SemanticVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != runner.MustParseSemVer("0.12.4") {
+		t.Errorf("expected version 0.12.4, got %s", version)
+	}
+	var cachedOB = mustBuildFakeDaemon(t, "v0.12.4", cachePath)
+	defer cachedOB.Cleanup()
+	cached, err := cachedOB.SemanticVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cached != version {
+		t.Errorf("expected cached build to report version %s, got %s", version, cached)
+	}
+
+	mustInitFakeDaemon(t, ob, filepath.Join(path, "data"))
+	if err := ob.AsyncStart(); err != nil {
+		t.Fatal(err)
+	}
+	defer ob.Kill()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := ob.WaitForReady(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := ob.APIRequest(ctx, "GET", "/ob/config", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := ob.PeerInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 || !strings.Contains(string(resp.Body), info.ID) {
+		t.Errorf("expected config to include peer ID (%s), but was (%d) %s", info.ID, resp.StatusCode, resp.Body)
+	}
+
+	if err := ob.Stop(5 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if s := ob.State(); s != runner.StateExited {
+		t.Errorf("expected interrupted node to exit cleanly, but was (%s)", s)
+	}
+}
+
+func TestFakeOpenBazaarDaemonCrashesOnRequest(t *testing.T) {
+	var path = util.GenerateTempPath("fake_daemon_crash")
+	defer os.RemoveAll(path)
+
+	var ob = mustBuildFakeDaemon(t, "v0.13.8", filepath.Join(path, "cache"))
+	defer ob.Cleanup()
+	if err := ob.SetEnvironment(runner.Environment{Vars: map[string]string{"FAKEOBD_CRASH_AFTER": "100ms"}}); err != nil {
+		t.Fatal(err)
+	}
+	mustInitFakeDaemon(t, ob, filepath.Join(path, "data"))
+	if err := ob.RunStart(); err == nil {
+		t.Error("expected crashed node to fail, but did not")
+	}
+	if code, _ := ob.ExitCodeAndErr(); code != 1 {
+		t.Errorf("expected exit code (1), but was (%d)", code)
+	}
+}
+
+func TestFakeOpenBazaarDaemonCannotBePatched(t *testing.T) {
+	var cachePath = util.GenerateTempPath("fake_patch_cache")
+	defer os.RemoveAll(cachePath)
+	mustBuildFakeDaemon(t, "v0.13.8", cachePath)
+
+	var node = builder.NewFakeOpenBazaarDaemon("fake", "v0.13.8")
+	defer node.MustClean()
+	node.SetCachePath(cachePath)
+	node.AddPatch(blueprints.ClockPatch)
+	if _, err := node.Build(); err == nil || !strings.Contains(err.Error(), "patched") {
+		t.Errorf("expected the patched build not to use the cached build, but error was (%v)", err)
+	}
+}
@@ -0,0 +1,146 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var ErrAPISSLUnsupported = errors.New("SSL-enabled JSON API is not supported")
+
+// apiClient bounds requests which are not otherwise cancelled, as some
+// API calls block on the network
+var apiClient = &http.Client{Timeout: 2 * time.Minute}
+
+// SetAPICredentials sets the basic auth credentials used when connecting
+// to the node's JSON API. These must match the username and (unhashed)
+// password the node was configured with.
+func (r *OpenBazaarRunner) SetAPICredentials(username, password string) {
+	r.apiUsername = username
+	r.apiPassword = password
+}
+
+// SetAPIAddress routes API requests and the event stream through a
+// host:port other than the gateway, such as a proxy in front of it. An
+// empty address restores the gateway. Readiness is always probed at the
+// gateway itself.
+func (r *OpenBazaarRunner) SetAPIAddress(addr string) {
+	r.apiAddress = addr
+}
+
+// dialAPIAddress returns the host:port API requests are sent to
+func (r *OpenBazaarRunner) dialAPIAddress() (string, error) {
+	if r.apiAddress != "" {
+		return r.apiAddress, nil
+	}
+	return r.GatewayAddress()
+}
+
+// GatewayAddress returns the host:port the node's JSON API gateway is
+// configured to listen on. Wildcard listen addresses are translated to
+// their loopback equivalent so the result can be dialed directly.
+func (r *OpenBazaarRunner) GatewayAddress() (string, error) {
+	config, err := r.readConfig()
+	if err != nil {
+		return "", err
+	}
+	if sslEnabled, _ := walkAndGetJSON([]string{"JSON-API", "SSL"}, config); sslEnabled == true {
+		return "", ErrAPISSLUnsupported
+	}
+	gateway, err := walkAndGetJSON([]string{"Addresses", "Gateway"}, config)
+	if err != nil {
+		return "", fmt.Errorf("finding gateway address: %s", err.Error())
+	}
+	gatewayAddr, ok := gateway.(string)
+	if !ok {
+		return "", fmt.Errorf("gateway address is not a string (%v)", gateway)
+	}
+	return multiaddrToDialAddress(gatewayAddr)
+}
+
+// multiaddrToDialAddress converts a /ip4/<host>/tcp/<port> style
+// multiaddr into a dialable host:port
+func multiaddrToDialAddress(maddr string) (string, error) {
+	var parts = strings.Split(strings.Trim(maddr, "/"), "/")
+	if len(parts) < 4 || parts[2] != "tcp" {
+		return "", fmt.Errorf("unsupported multiaddr (%s)", maddr)
+	}
+	var host = parts[1]
+	switch parts[0] {
+	case "ip4":
+		if host == "0.0.0.0" {
+			host = "127.0.0.1"
+		}
+	case "ip6":
+		if host == "::" {
+			host = "::1"
+		}
+	case "dns4", "dns6":
+	default:
+		return "", fmt.Errorf("unsupported multiaddr protocol (%s)", parts[0])
+	}
+	return net.JoinHostPort(host, parts[3]), nil
+}
+
+func walkAndGetJSON(path []string, jsonMap map[string]interface{}) (interface{}, error) {
+	value, ok := jsonMap[path[0]]
+	if !ok {
+		return nil, fmt.Errorf("path segment (%s) not found", path[0])
+	}
+	if len(path) == 1 {
+		return value, nil
+	}
+	newJSONMap, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unable to cast json fragment")
+	}
+	return walkAndGetJSON(path[1:], newJSONMap)
+}
+
+// APIResponse is the result of a request to the node's JSON API
+type APIResponse struct {
+	StatusCode int
+	Body       []byte
+}
+
+// APIRequest sends a request to the node's JSON API gateway using any
+// configured credentials. A non-nil body is sent as JSON.
+func (r *OpenBazaarRunner) APIRequest(ctx context.Context, method, path string, body []byte) (APIResponse, error) {
+	var resp APIResponse
+	addr, err := r.dialAPIAddress()
+	if err != nil {
+		return resp, err
+	}
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, fmt.Sprintf("http://%s%s", addr, path), reader)
+	if err != nil {
+		return resp, err
+	}
+	req = req.WithContext(ctx)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if r.apiUsername != "" || r.apiPassword != "" {
+		req.SetBasicAuth(r.apiUsername, r.apiPassword)
+	}
+	httpResp, err := apiClient.Do(req)
+	if err != nil {
+		return resp, fmt.Errorf("requesting %s %s: %s", method, path, err.Error())
+	}
+	defer httpResp.Body.Close()
+	resp.StatusCode = httpResp.StatusCode
+	if resp.Body, err = ioutil.ReadAll(httpResp.Body); err != nil {
+		return resp, fmt.Errorf("reading response: %s", err.Error())
+	}
+	return resp, nil
+}
@@ -0,0 +1,96 @@
+package runner
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const cgroupCPUPeriod = 100000
+
+var cgroupRoot = "/sys/fs/cgroup"
+
+// createCgroup prepares a new cgroup v2 beneath the delegated parent
+// with the memory and cpu limits applied
+func createCgroup(limits ResourceLimits, label string) (string, error) {
+	if _, err := os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers")); err != nil {
+		return "", &UnsupportedLimitError{Limit: "cgroup", Reason: fmt.Sprintf("cgroup v2 is not mounted at (%s)", cgroupRoot)}
+	}
+
+	// the caller's own cgroup is never used, as enabling controllers
+	// would require moving every process sharing it elsewhere
+	var parent = limits.CgroupParent
+	if parent == "" {
+		return "", &UnsupportedLimitError{Limit: "cgroup", Reason: "memory and cpu limits require CgroupParent to name a cgroup v2 delegated to mason which holds no processes"}
+	}
+
+	var required []string
+	if limits.MemoryBytes > 0 {
+		required = append(required, "memory")
+	}
+	if limits.CPUs > 0 {
+		required = append(required, "cpu")
+	}
+	if err := enableControllers(parent, required); err != nil {
+		return "", err
+	}
+
+	var (
+		r    = rand.New(rand.NewSource(time.Now().UnixNano()))
+		path = filepath.Join(parent, fmt.Sprintf("mason-%s-%d", label, r.Intn(99999)))
+	)
+	if err := os.Mkdir(path, 0755); err != nil {
+		return "", &UnsupportedLimitError{Limit: "cgroup", Reason: fmt.Sprintf("cgroup (%s) is not delegated: %s", parent, err.Error())}
+	}
+
+	var settings = make(map[string]string)
+	if limits.MemoryBytes > 0 {
+		settings["memory.max"] = fmt.Sprintf("%d", limits.MemoryBytes)
+	}
+	if limits.CPUs > 0 {
+		settings["cpu.max"] = fmt.Sprintf("%d %d", int64(limits.CPUs*cgroupCPUPeriod), cgroupCPUPeriod)
+	}
+	for file, value := range settings {
+		if err := ioutil.WriteFile(filepath.Join(path, file), []byte(value), 0644); err != nil {
+			os.Remove(path)
+			return "", fmt.Errorf("setting cgroup %s: %s", file, err.Error())
+		}
+	}
+	return path, nil
+}
+
+// enableControllers ensures each controller is available to children of
+// the parent cgroup
+func enableControllers(parent string, controllers []string) error {
+	b, err := ioutil.ReadFile(filepath.Join(parent, "cgroup.subtree_control"))
+	if err != nil {
+		return &UnsupportedLimitError{Limit: "cgroup", Reason: fmt.Sprintf("reading subtree controllers of (%s): %s", parent, err.Error())}
+	}
+	var enabled = strings.Fields(string(b))
+	for _, c := range controllers {
+		if containsString(enabled, c) {
+			continue
+		}
+		if err := ioutil.WriteFile(filepath.Join(parent, "cgroup.subtree_control"), []byte("+"+c), 0644); err != nil {
+			return &UnsupportedLimitError{Limit: c, Reason: fmt.Sprintf("controller is not delegated to (%s), which must not contain processes of its own: %s", parent, err.Error())}
+		}
+	}
+	return nil
+}
+
+func removeCgroup(path string) error {
+	return os.Remove(path)
+}
+
+func containsString(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,116 @@
+package runner
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/OpenBazaar/mason/util"
+)
+
+func withCgroupRoot(root string) func() {
+	var original = cgroupRoot
+	cgroupRoot = root
+	return func() { cgroupRoot = original }
+}
+
+func TestCgroupLimitsUnsupportedWithoutCgroupV2(t *testing.T) {
+	var root = util.GenerateTempPath("cgroup_missing")
+	if err := os.MkdirAll(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	defer withCgroupRoot(root)()
+
+	var subject = &OpenBazaarRunner{}
+	err := subject.SetResourceLimits(ResourceLimits{MemoryBytes: 64 << 20})
+	if _, ok := err.(*UnsupportedLimitError); !ok {
+		t.Fatalf("expected UnsupportedLimitError, but was (%v)", err)
+	}
+	if _, ok := subject.ResourceLimits(); ok {
+		t.Error("expected unsupported limits not to be recorded, but were")
+	}
+}
+
+func TestCgroupLimitsCreateDelegatedCgroup(t *testing.T) {
+	var (
+		root   = util.GenerateTempPath("cgroup_delegated")
+		parent = filepath.Join(root, "delegated")
+	)
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	defer withCgroupRoot(root)()
+	for path, content := range map[string]string{
+		filepath.Join(root, "cgroup.controllers"):       "cpu memory",
+		filepath.Join(parent, "cgroup.subtree_control"): "memory",
+	} {
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var subject, cleanup = mustCreateScriptRunner(t, "cgroup_run", `exit 0`)
+	defer cleanup()
+	if err := subject.SetResourceLimits(ResourceLimits{MemoryBytes: 1 << 20, CPUs: 0.5, CgroupParent: parent}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(subject.cgroupPath, parent) {
+		t.Fatalf("expected cgroup beneath (%s), but was (%s)", parent, subject.cgroupPath)
+	}
+
+	var expectedFiles = map[string]string{
+		"memory.max": "1048576",
+		"cpu.max":    "50000 100000",
+	}
+	for file, expected := range expectedFiles {
+		actual, err := ioutil.ReadFile(filepath.Join(subject.cgroupPath, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(actual) != expected {
+			t.Errorf("expected (%s) to be (%s), but was (%s)", file, expected, actual)
+		}
+	}
+	if controllers, _ := ioutil.ReadFile(filepath.Join(parent, "cgroup.subtree_control")); string(controllers) != "+cpu" {
+		t.Errorf("expected missing cpu controller to be enabled, but was (%s)", controllers)
+	}
+
+	if err := subject.RunStart(); err != nil {
+		t.Fatal(err)
+	}
+	if procs, err := ioutil.ReadFile(filepath.Join(subject.cgroupPath, "cgroup.procs")); err != nil || len(procs) == 0 {
+		t.Errorf("expected node to join its cgroup, but did not (%v)", err)
+	}
+	info, err := ReadRunInfo(subject.RunPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Cgroup != subject.cgroupPath {
+		t.Errorf("expected cgroup (%s) to be recorded, but was (%s)", subject.cgroupPath, info.Cgroup)
+	}
+}
+
+func TestCgroupLimitsRequireParent(t *testing.T) {
+	var root = util.GenerateTempPath("cgroup_parent")
+	if err := os.MkdirAll(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	defer withCgroupRoot(root)()
+	if err := ioutil.WriteFile(filepath.Join(root, "cgroup.controllers"), []byte("cpu memory"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var subject = &OpenBazaarRunner{}
+	err := subject.SetResourceLimits(ResourceLimits{CPUs: 0.5})
+	if limitErr, ok := err.(*UnsupportedLimitError); !ok || !strings.Contains(limitErr.Reason, "CgroupParent") {
+		t.Fatalf("expected UnsupportedLimitError naming CgroupParent, but was (%v)", err)
+	}
+	if entries, _ := ioutil.ReadDir(root); len(entries) != 1 {
+		t.Errorf("expected the cgroup root to be left alone, but had (%d) entries", len(entries))
+	}
+}
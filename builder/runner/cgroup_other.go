@@ -0,0 +1,12 @@
+//go:build !linux
+// +build !linux
+
+package runner
+
+func createCgroup(limits ResourceLimits, label string) (string, error) {
+	return "", &UnsupportedLimitError{Limit: "cgroup", Reason: "cgroups are only available on linux"}
+}
+
+func removeCgroup(path string) error {
+	return nil
+}
@@ -0,0 +1,198 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// nodeConfig holds the parts of the config which openbazaard requires
+// to start. Decoding into it catches values of the wrong type.
+type nodeConfig struct {
+	Addresses struct {
+		API     interface{} `json:"API"`
+		Gateway string      `json:"Gateway"`
+		Swarm   []string    `json:"Swarm"`
+	} `json:"Addresses"`
+	Bootstrap []string `json:"Bootstrap"`
+	Identity  struct {
+		PeerID  string `json:"PeerID"`
+		PrivKey string `json:"PrivKey"`
+	} `json:"Identity"`
+	JSONAPI struct {
+		Enabled       bool     `json:"Enabled"`
+		SSL           bool     `json:"SSL"`
+		Authenticated bool     `json:"Authenticated"`
+		Username      string   `json:"Username"`
+		Password      string   `json:"Password"`
+		AllowedIPs    []string `json:"AllowedIPs"`
+	} `json:"JSON-API"`
+	Wallet map[string]interface{} `json:"Wallet"`
+}
+
+// FromDataPath returns an OpenBazaarRunner for the node whose data is at
+// path so its config may be inspected and edited. It has no binary and
+// cannot be started.
+func FromDataPath(path string) (*OpenBazaarRunner, error) {
+	var r = &OpenBazaarRunner{}
+	r.SetCustomDataPath(path)
+	if _, err := os.Stat(r.configPath()); err != nil {
+		return nil, fmt.Errorf("finding config: %s", err.Error())
+	}
+	return r, nil
+}
+
+// ConfigValue returns the value at the dot-separated config key
+func (r *OpenBazaarRunner) ConfigValue(path string) (interface{}, error) {
+	config, err := r.readConfig()
+	if err != nil {
+		return nil, err
+	}
+	value, err := walkAndGetJSON(strings.Split(path, "."), config)
+	if err != nil {
+		return nil, fmt.Errorf("getting json value: %s", err.Error())
+	}
+	return value, nil
+}
+
+// PutConfigValue sets the dot-separated config key to value, creating
+// missing objects along the way. Unlike SetConfigValue, the change is not
+// written if the config would no longer load.
+func (r *OpenBazaarRunner) PutConfigValue(path string, value interface{}) error {
+	return r.editConfig(func(config map[string]interface{}) error {
+		var keys = strings.Split(path, ".")
+		for _, k := range keys[:len(keys)-1] {
+			next, ok := config[k]
+			if !ok {
+				next = make(map[string]interface{})
+				config[k] = next
+			}
+			nextMap, ok := next.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("path segment (%s) is not an object", k)
+			}
+			config = nextMap
+		}
+		config[keys[len(keys)-1]] = value
+		return nil
+	})
+}
+
+// UnsetConfigValue removes the dot-separated config key. The change is
+// not written if the config would no longer load.
+func (r *OpenBazaarRunner) UnsetConfigValue(path string) error {
+	return r.editConfig(func(config map[string]interface{}) error {
+		var keys = strings.Split(path, ".")
+		parent, err := walkToParentJSON(keys, config)
+		if err != nil {
+			return err
+		}
+		if _, ok := parent[keys[len(keys)-1]]; !ok {
+			return fmt.Errorf("path segment (%s) not found", keys[len(keys)-1])
+		}
+		delete(parent, keys[len(keys)-1])
+		return nil
+	})
+}
+
+// PatchConfig merges patch into the config as a JSON merge patch (RFC
+// 7396): objects are merged recursively, null removes a key and any other
+// value replaces it. The change is not written if the config would no
+// longer load.
+func (r *OpenBazaarRunner) PatchConfig(patch map[string]interface{}) error {
+	return r.editConfig(func(config map[string]interface{}) error {
+		mergePatchJSON(config, patch)
+		return nil
+	})
+}
+
+// ValidateConfig returns an error if the config is missing or would not
+// be accepted by openbazaard on start
+func (r *OpenBazaarRunner) ValidateConfig() error {
+	b, err := ioutil.ReadFile(r.configPath())
+	if err != nil {
+		return fmt.Errorf("reading config: %s", err.Error())
+	}
+	return validateConfig(b)
+}
+
+func validateConfig(b []byte) error {
+	var config nodeConfig
+	var decoder = json.NewDecoder(bytes.NewReader(b))
+	if err := decoder.Decode(&config); err != nil {
+		return fmt.Errorf("invalid config: %s", err.Error())
+	}
+	if config.Identity.PeerID == "" {
+		return fmt.Errorf("invalid config: Identity.PeerID is empty")
+	}
+	if _, err := multiaddrToDialAddress(config.Addresses.Gateway); err != nil {
+		return fmt.Errorf("invalid config: Addresses.Gateway: %s", err.Error())
+	}
+	return nil
+}
+
+// editConfig applies fn to the config and writes the result only if it
+// passes validation
+func (r *OpenBazaarRunner) editConfig(fn func(map[string]interface{}) error) error {
+	config, err := r.readConfig()
+	if err != nil {
+		return err
+	}
+	fi, err := os.Stat(r.configPath())
+	if err != nil {
+		return fmt.Errorf("stat config at (%s): %s", r.configPath(), err.Error())
+	}
+	if err := fn(config); err != nil {
+		return err
+	}
+	newBytes, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling config: %s", err.Error())
+	}
+	if err := validateConfig(newBytes); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(r.configPath(), newBytes, fi.Mode()); err != nil {
+		return fmt.Errorf("writing config changes: %s", err.Error())
+	}
+	return nil
+}
+
+// walkToParentJSON returns the object holding the last key of path
+func walkToParentJSON(path []string, jsonMap map[string]interface{}) (map[string]interface{}, error) {
+	if len(path) == 1 {
+		return jsonMap, nil
+	}
+	parent, err := walkAndGetJSON(path[:len(path)-1], jsonMap)
+	if err != nil {
+		return nil, err
+	}
+	parentMap, ok := parent.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unable to cast json fragment")
+	}
+	return parentMap, nil
+}
+
+func mergePatchJSON(target, patch map[string]interface{}) {
+	for k, v := range patch {
+		if v == nil {
+			delete(target, k)
+			continue
+		}
+		patchMap, ok := v.(map[string]interface{})
+		if !ok {
+			target[k] = v
+			continue
+		}
+		targetMap, ok := target[k].(map[string]interface{})
+		if !ok {
+			targetMap = make(map[string]interface{})
+			target[k] = targetMap
+		}
+		mergePatchJSON(targetMap, patchMap)
+	}
+}
@@ -0,0 +1,89 @@
+package runner
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/OpenBazaar/mason/util"
+)
+
+const testConfig = `{"Identity":{"PeerID":"QmPeer"},"Addresses":{"Gateway":"/ip4/127.0.0.1/tcp/4002","Swarm":["/ip4/0.0.0.0/tcp/4001"]},"Wallet":{"Type":"spvwallet","MaxFee":2000}}`
+
+func newConfigSubject(t *testing.T) (*OpenBazaarRunner, func()) {
+	var dataPath = util.GenerateTempPath("config_edit")
+	if err := os.MkdirAll(dataPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dataPath, "config"), []byte(testConfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+	subject, err := FromDataPath(dataPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return subject, func() { os.RemoveAll(dataPath) }
+}
+
+func TestConfigEditing(t *testing.T) {
+	subject, cleanup := newConfigSubject(t)
+	defer cleanup()
+
+	if err := subject.PutConfigValue("Wallet.MaxFee", 5000.0); err != nil {
+		t.Fatal(err)
+	}
+	if err := subject.PutConfigValue("Resolvers.eth", "https://resolver"); err != nil {
+		t.Fatal(err)
+	}
+	if err := subject.UnsetConfigValue("Wallet.Type"); err != nil {
+		t.Fatal(err)
+	}
+	if err := subject.PatchConfig(map[string]interface{}{
+		"Wallet":    map[string]interface{}{"LowFeeDefault": 20.0},
+		"Resolvers": nil,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	wallet, err := subject.ConfigValue("Wallet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var expected = map[string]interface{}{"MaxFee": 5000.0, "LowFeeDefault": 20.0}
+	if !reflect.DeepEqual(wallet, expected) {
+		t.Errorf("expected wallet to be (%v), but was (%v)", expected, wallet)
+	}
+	if _, err := subject.ConfigValue("Resolvers"); err == nil {
+		t.Error("expected Resolvers to be removed by the patch")
+	}
+	if fi, _ := os.Stat(subject.configPath()); fi.Mode().Perm() != 0600 {
+		t.Errorf("expected config mode to be preserved, but was (%s)", fi.Mode())
+	}
+}
+
+func TestConfigEditsWhichBreakConfigAreNotWritten(t *testing.T) {
+	subject, cleanup := newConfigSubject(t)
+	defer cleanup()
+
+	var invalid = []func() error{
+		func() error { return subject.PutConfigValue("Addresses.Swarm", "/ip4/0.0.0.0/tcp/4001") },
+		func() error { return subject.PutConfigValue("Addresses.Gateway", "localhost:4002") },
+		func() error { return subject.PutConfigValue("Wallet.Type.Name", "spvwallet") },
+		func() error { return subject.UnsetConfigValue("Identity.PeerID") },
+		func() error { return subject.UnsetConfigValue("Wallet.Missing") },
+		func() error { return subject.PatchConfig(map[string]interface{}{"Identity": nil}) },
+	}
+	for i, edit := range invalid {
+		if err := edit(); err == nil {
+			t.Errorf("expected edit (%d) to fail", i)
+		}
+	}
+	if b, _ := ioutil.ReadFile(subject.configPath()); string(b) != testConfig {
+		t.Errorf("expected config to be unchanged, but was (%s)", b)
+	}
+	if err := subject.ValidateConfig(); err != nil {
+		t.Errorf("expected config to be valid, but was (%s)", err.Error())
+	}
+}
@@ -0,0 +1,137 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	shell "github.com/placer14/go-shell"
+)
+
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// secretNamePattern matches variables whose values are redacted when the
+// environment is recorded
+var secretNamePattern = regexp.MustCompile(`(?i)secret|token|passw(or)?d|key|credential`)
+
+// Environment describes the environment variables provided to the node
+type Environment struct {
+	// Vars are set for the node, overriding any inherited value of the
+	// same name
+	Vars map[string]string `json:"vars,omitempty"`
+	// Replace starts the node with only Vars rather than merging them
+	// with the environment inherited from mason. PATH and HOME should be
+	// included in Vars when they are needed.
+	Replace bool `json:"replace,omitempty"`
+}
+
+// SetEnvironment sets the environment used when the node is initialized
+// or started
+func (r *OpenBazaarRunner) SetEnvironment(env Environment) error {
+	for name := range env.Vars {
+		if !envNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid environment variable name (%s)", name)
+		}
+	}
+	var vars = make(map[string]string, len(env.Vars))
+	for k, v := range env.Vars {
+		vars[k] = v
+	}
+	r.env = &Environment{Vars: vars, Replace: env.Replace}
+	return nil
+}
+
+// Environment returns the environment provided to the node, if any
+func (r *OpenBazaarRunner) Environment() (Environment, bool) {
+	if r.env == nil {
+		return Environment{}, false
+	}
+	return *r.env, true
+}
+
+// SetWorkDir sets the working directory of the node. An empty path
+// inherits the working directory of mason.
+func (r *OpenBazaarRunner) SetWorkDir(path string) error {
+	if path == "" {
+		r.workDir = ""
+		return nil
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("resolving work dir: %s", err.Error())
+	}
+	if fi, err := os.Stat(abs); err != nil {
+		return fmt.Errorf("work dir: %s", err.Error())
+	} else if !fi.IsDir() {
+		return fmt.Errorf("work dir (%s) is not a directory", abs)
+	}
+	r.workDir = abs
+	return nil
+}
+
+// WorkDir returns the working directory of the node, or an empty string
+// when it is inherited
+func (r *OpenBazaarRunner) WorkDir() string {
+	return r.workDir
+}
+
+// envCmd returns args prefixed so the shell replaces itself with the
+// command under the configured environment
+func (r *OpenBazaarRunner) envCmd(args []interface{}) []interface{} {
+	var cmd = []interface{}{"exec"}
+	if r.env == nil {
+		return append(cmd, args...)
+	}
+	cmd = append(cmd, "env")
+	if r.env.Replace {
+		cmd = append(cmd, "-i")
+	}
+	var names = make([]string, 0, len(r.env.Vars))
+	for name := range r.env.Vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		cmd = append(cmd, name+"="+shell.Quote(r.env.Vars[name]))
+	}
+	return append(cmd, args...)
+}
+
+// command builds the shell command for args honoring the configured work
+// dir. Paths are resolved beforehand so they remain valid from the work
+// dir.
+func (r *OpenBazaarRunner) command(args []interface{}) *shell.Command {
+	var cmd = shell.Cmd(args...).Tee(r.nodeOutput())
+	if r.workDir != "" {
+		cmd.SetWorkDir(r.workDir)
+	}
+	return cmd
+}
+
+// absPath resolves path against mason's working directory when the node
+// runs elsewhere
+func (r *OpenBazaarRunner) absPath(path string) string {
+	if r.workDir == "" || path == "" {
+		return path
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// withSecretsRedacted returns a copy of the environment whose variables
+// named like secrets, tokens, passwords or keys have their values redacted
+func (e Environment) withSecretsRedacted() Environment {
+	var vars = make(map[string]string, len(e.Vars))
+	for name, value := range e.Vars {
+		if secretNamePattern.MatchString(name) {
+			value = redacted
+		}
+		vars[name] = value
+	}
+	e.Vars = vars
+	return e
+}
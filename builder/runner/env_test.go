@@ -0,0 +1,77 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEnvironmentMergedWithInherited(t *testing.T) {
+	var subject, cleanup = mustCreateScriptRunner(t, "env_merged", `echo "$GOLOG_LOG_LEVEL|$MASON_TEST_INHERITED|$(pwd)"`)
+	defer cleanup()
+	os.Setenv("MASON_TEST_INHERITED", "inherited")
+	defer os.Unsetenv("MASON_TEST_INHERITED")
+
+	var workDir = filepath.Join(subject.RunPath(), "..")
+	if err := subject.SetWorkDir(workDir); err != nil {
+		t.Fatal(err)
+	}
+	if err := subject.SetEnvironment(Environment{Vars: map[string]string{"GOLOG_LOG_LEVEL": "debug it's", "EXCHANGE_API_KEY": "hunter2"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := subject.RunStart(); err != nil {
+		t.Fatal(err)
+	}
+
+	var expected = "debug it's|inherited|" + subject.WorkDir()
+	if tail := subject.Tail(1); len(tail) != 1 || tail[0] != expected {
+		t.Errorf("expected output (%s), but was (%v)", expected, tail)
+	}
+	info, err := ReadRunInfo(subject.RunPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Env == nil || info.Env.Replace || info.Env.Vars["GOLOG_LOG_LEVEL"] != "debug it's" {
+		t.Errorf("expected environment to be recorded, but was (%+v)", info.Env)
+	}
+	if info.Env != nil && info.Env.Vars["EXCHANGE_API_KEY"] != redacted {
+		t.Errorf("expected secret to be redacted, but was (%s)", info.Env.Vars["EXCHANGE_API_KEY"])
+	}
+	if info.WorkDir != subject.WorkDir() || !filepath.IsAbs(info.WorkDir) {
+		t.Errorf("expected absolute work dir (%s) to be recorded, but was (%s)", subject.WorkDir(), info.WorkDir)
+	}
+}
+
+func TestEnvironmentReplacesInherited(t *testing.T) {
+	var subject, cleanup = mustCreateScriptRunner(t, "env_replaced", `echo "$HOME|${MASON_TEST_INHERITED:-unset}"`)
+	defer cleanup()
+	os.Setenv("MASON_TEST_INHERITED", "inherited")
+	defer os.Unsetenv("MASON_TEST_INHERITED")
+
+	var env = Environment{Vars: map[string]string{"HOME": "/tmp/node"}, Replace: true}
+	if err := subject.SetEnvironment(env); err != nil {
+		t.Fatal(err)
+	}
+	if err := subject.RunStart(); err != nil {
+		t.Fatal(err)
+	}
+
+	if tail := subject.Tail(1); len(tail) != 1 || tail[0] != "/tmp/node|unset" {
+		t.Errorf("expected only provided environment, but output was (%v)", tail)
+	}
+	if actual, _ := subject.Environment(); !reflect.DeepEqual(actual, env) {
+		t.Errorf("expected environment (%+v), but was (%+v)", env, actual)
+	}
+}
+
+func TestSetEnvironmentAndWorkDirValidation(t *testing.T) {
+	var subject = &OpenBazaarRunner{}
+	if err := subject.SetEnvironment(Environment{Vars: map[string]string{"BAD NAME": "x"}}); err == nil {
+		t.Error("expected invalid variable name to error, but did not")
+	}
+	if err := subject.SetWorkDir("/does/not/exist"); err == nil || !strings.Contains(err.Error(), "work dir") {
+		t.Errorf("expected missing work dir to error, but was (%v)", err)
+	}
+}
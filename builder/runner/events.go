@@ -0,0 +1,175 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/op/go-logging"
+)
+
+var log = logging.MustGetLogger("runner")
+
+// EventKind describes the top-level category of a websocket message
+// pushed by the node
+type EventKind string
+
+const (
+	EventNotification EventKind = "notification"
+	EventChatMessage  EventKind = "message"
+	EventChatRead     EventKind = "messageRead"
+	EventChatTyping   EventKind = "messageTyping"
+	EventWallet       EventKind = "wallet"
+	EventStatus       EventKind = "status"
+	EventUnknown      EventKind = "unknown"
+)
+
+// Notification types which are carried in the Type of an
+// EventNotification
+const (
+	NotificationOrder             = "order"
+	NotificationPayment           = "payment"
+	NotificationOrderConfirmation = "orderConfirmation"
+	NotificationOrderDeclined     = "orderDeclined"
+	NotificationOrderCancel       = "cancel"
+	NotificationRefund            = "refund"
+	NotificationFulfillment       = "fulfillment"
+	NotificationProcessingError   = "processingError"
+	NotificationCompletion        = "orderComplete"
+	NotificationDisputeOpen       = "disputeOpen"
+	NotificationDisputeUpdate     = "disputeUpdate"
+	NotificationDisputeClose      = "disputeClose"
+	NotificationDisputeAccepted   = "disputeAccepted"
+	NotificationFollow            = "follow"
+	NotificationUnfollow          = "unfollow"
+	NotificationModeratorAdd      = "moderatorAdd"
+	NotificationModeratorRemove   = "moderatorRemove"
+)
+
+// eventKinds lists the known top-level keys in the order they are
+// checked while decoding
+var eventKinds = []EventKind{
+	EventNotification,
+	EventChatMessage,
+	EventChatRead,
+	EventChatTyping,
+	EventWallet,
+	EventStatus,
+}
+
+const (
+	eventsWebsocketPath    = "/ws"
+	eventsBufferSize       = 64
+	eventsMinReconnectWait = 250 * time.Millisecond
+	eventsMaxReconnectWait = 5 * time.Second
+)
+
+// Event is a single decoded message received from the node's
+// websocket API
+type Event struct {
+	Kind     EventKind
+	Type     string
+	Received time.Time
+	Payload  json.RawMessage
+}
+
+// Is returns true when the event is a notification of the provided type
+func (e Event) Is(notificationType string) bool {
+	return e.Kind == EventNotification && e.Type == notificationType
+}
+
+// Decode unmarshals the event payload into v
+func (e Event) Decode(v interface{}) error {
+	return json.Unmarshal(e.Payload, v)
+}
+
+func decodeEvent(msg []byte) Event {
+	var (
+		event    = Event{Kind: EventUnknown, Received: time.Now(), Payload: msg}
+		envelope map[string]json.RawMessage
+	)
+	if err := json.Unmarshal(msg, &envelope); err != nil {
+		return event
+	}
+	for _, kind := range eventKinds {
+		payload, ok := envelope[string(kind)]
+		if !ok {
+			continue
+		}
+		event.Kind = kind
+		event.Payload = payload
+
+		var typed struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(payload, &typed); err == nil {
+			event.Type = typed.Type
+		}
+		return event
+	}
+	return event
+}
+
+// Events connects to the node's websocket API and returns a channel of
+// decoded events. The connection is re-established whenever it is lost,
+// such as when the node is restarted, until the context is cancelled, at
+// which point the channel is closed.
+func (r *OpenBazaarRunner) Events(ctx context.Context) <-chan Event {
+	var events = make(chan Event, eventsBufferSize)
+	go func() {
+		defer close(events)
+		var wait = eventsMinReconnectWait
+		for {
+			if err := r.streamEvents(ctx, events); err != nil {
+				log.Debugf("event stream (%s): %s", r.dataPath, err.Error())
+			} else {
+				wait = eventsMinReconnectWait
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(wait):
+			}
+			if wait *= 2; wait > eventsMaxReconnectWait {
+				wait = eventsMaxReconnectWait
+			}
+		}
+	}()
+	return events
+}
+
+// streamEvents holds a single websocket connection open and forwards
+// events until the connection drops or the context is cancelled. A nil
+// error indicates a connection was successfully established.
+func (r *OpenBazaarRunner) streamEvents(ctx context.Context, events chan<- Event) error {
+	addr, err := r.dialAPIAddress()
+	if err != nil {
+		return err
+	}
+	conn, err := dialWebsocket(addr, eventsWebsocketPath, r.apiUsername, r.apiPassword)
+	if err != nil {
+		return err
+	}
+	var done = make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		conn.Close()
+	}()
+
+	for {
+		msg, err := conn.ReadMessage()
+		if err != nil {
+			log.Debugf("event stream (%s) disconnected: %s", r.dataPath, err.Error())
+			return nil
+		}
+		select {
+		case events <- decodeEvent(msg):
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package runner
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/OpenBazaar/mason/util"
+)
+
+// serveTestWebsocket completes the server side of the websocket handshake
+// and returns the hijacked connection
+func serveTestWebsocket(w http.ResponseWriter, r *http.Request) (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response cannot be hijacked")
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+		websocketAccept(r.Header.Get("Sec-WebSocket-Key")))
+	return conn, rw, rw.Flush()
+}
+
+// writeTestFrame writes an unmasked server-to-client text frame
+func writeTestFrame(rw *bufio.ReadWriter, msg string) error {
+	var frame = []byte{0x80 | wsOpText}
+	if len(msg) < 126 {
+		frame = append(frame, byte(len(msg)))
+	} else {
+		frame = append(frame, 126, byte(len(msg)>>8), byte(len(msg)))
+	}
+	if _, err := rw.Write(append(frame, msg...)); err != nil {
+		return err
+	}
+	return rw.Flush()
+}
+
+func mustWriteGatewayConfig(t *testing.T, label, addr string) (string, func()) {
+	var statePath = util.GenerateTempPath(label)
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(statePath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	var cleanup = func() { os.RemoveAll(statePath) }
+	var config = fmt.Sprintf(`{"Addresses":{"Gateway":"/ip4/%s/tcp/%s"},"JSON-API":{"SSL":false}}`, host, port)
+	if err := ioutil.WriteFile(filepath.Join(statePath, "config"), []byte(config), 0644); err != nil {
+		cleanup()
+		t.Fatal(err)
+	}
+	return statePath, cleanup
+}
+
+func TestDecodeEvent(t *testing.T) {
+	var examples = []struct {
+		input        string
+		expectedKind EventKind
+		expectedType string
+	}{
+		{
+			input:        `{"notification":{"type":"payment","orderId":"Qm123"}}`,
+			expectedKind: EventNotification,
+			expectedType: NotificationPayment,
+		},
+		{
+			input:        `{"message":{"peerId":"Qm456","message":"hello"}}`,
+			expectedKind: EventChatMessage,
+		},
+		{
+			input:        `{"wallet":{"txid":"abc","value":1000}}`,
+			expectedKind: EventWallet,
+		},
+		{
+			input:        `not json`,
+			expectedKind: EventUnknown,
+		},
+	}
+
+	for _, e := range examples {
+		var event = decodeEvent([]byte(e.input))
+		if event.Kind != e.expectedKind {
+			t.Errorf("expected (%s) to decode as kind (%s), but was (%s)", e.input, e.expectedKind, event.Kind)
+		}
+		if event.Type != e.expectedType {
+			t.Errorf("expected (%s) to decode as type (%s), but was (%s)", e.input, e.expectedType, event.Type)
+		}
+	}
+
+	var chat struct {
+		Message string `json:"message"`
+	}
+	if err := decodeEvent([]byte(examples[1].input)).Decode(&chat); err != nil {
+		t.Fatal(err)
+	}
+	if chat.Message != "hello" {
+		t.Errorf("expected decoded chat message to be (hello), but was (%s)", chat.Message)
+	}
+}
+
+func TestEventsReconnectsAfterDisconnect(t *testing.T) {
+	var connections int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != eventsWebsocketPath {
+			http.NotFound(w, r)
+			return
+		}
+		conn, rw, err := serveTestWebsocket(w, r)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		var n = atomic.AddInt32(&connections, 1)
+		if err := writeTestFrame(rw, fmt.Sprintf(`{"notification":{"type":"order","connection":%d}}`, n)); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+
+	var subject = &OpenBazaarRunner{}
+	var dataPath, cleanup = mustWriteGatewayConfig(t, "events_reconnect", server.Listener.Addr().String())
+	defer cleanup()
+	subject.SetCustomDataPath(dataPath)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var events = subject.Events(ctx)
+	for i := 1; i <= 2; i++ {
+		select {
+		case event := <-events:
+			if !event.Is(NotificationOrder) {
+				t.Errorf("expected order notification, but was (%s/%s)", event.Kind, event.Type)
+			}
+			var payload struct {
+				Connection int `json:"connection"`
+			}
+			if err := event.Decode(&payload); err != nil {
+				t.Fatal(err)
+			}
+			if payload.Connection != i {
+				t.Errorf("expected event from connection (%d), but was (%d)", i, payload.Connection)
+			}
+		case <-ctx.Done():
+			t.Fatalf("timed out waiting for event %d", i)
+		}
+	}
+
+	cancel()
+	for range events {
+	}
+}
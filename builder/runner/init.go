@@ -0,0 +1,214 @@
+package runner
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	shell "github.com/placer14/go-shell"
+)
+
+const (
+	// bitcoinCurrency is the default wallet of releases before multiwallet
+	bitcoinCurrency = "BTC"
+	// bitcoinCashCurrency is selected with a start flag before multiwallet
+	bitcoinCashCurrency = "BCH"
+)
+
+// InitOptions controls how Init creates the node's identity and wallet.
+// Initializing with the same options recreates the same identity.
+type InitOptions struct {
+	// Mnemonic is the BIP39 seed used to derive the node's identity and
+	// wallet keys. A random seed is generated when empty.
+	Mnemonic string `json:"mnemonic,omitempty"`
+	// WalletCurrency selects the coin of the node's wallet (ex: BTC, BCH,
+	// LTC, ZEC). Multiwallet releases run a wallet for each currency in
+	// their Wallets config, so the others are removed. Releases before
+	// 0.13.0 only have a bitcoin wallet, or bitcoin cash when started
+	// with --bitcoincash.
+	WalletCurrency string `json:"walletCurrency,omitempty"`
+	// WalletType is written after initialization to the Type of the
+	// selected currency's wallet on multiwallet releases (ex: SPV, API),
+	// or to Wallet.Type before 0.13.0 (ex: spvwallet, bitcoind)
+	WalletType string `json:"walletType,omitempty"`
+	// WalletCreationDate is when the seed was created so the wallet does
+	// not sync from the oldest checkpoint
+	WalletCreationDate time.Time `json:"walletCreationDate,omitempty"`
+	// Force initializes the node even if its data path already contains
+	// a node, overwriting its identity
+	Force bool `json:"force,omitempty"`
+}
+
+// SetInitOptions sets the options used by the next Init. An
+// UnsupportedCapabilityError is returned for options which the binary's
+// version cannot apply.
+func (r *OpenBazaarRunner) SetInitOptions(opts InitOptions) error {
+	if opts.Mnemonic != "" {
+		opts.Mnemonic = strings.Join(strings.Fields(opts.Mnemonic), " ")
+		if words := len(strings.Fields(opts.Mnemonic)); words < 12 || words > 24 || words%3 != 0 {
+			return fmt.Errorf("mnemonic must have 12, 15, 18, 21 or 24 words, but has (%d)", words)
+		}
+	}
+	if !opts.WalletCreationDate.IsZero() {
+		if err := r.requireCapability(CapabilityWalletCreationDate); err != nil {
+			return err
+		}
+	}
+	opts.WalletCurrency = strings.ToUpper(opts.WalletCurrency)
+	switch opts.WalletCurrency {
+	case "", bitcoinCurrency:
+	case bitcoinCashCurrency:
+		if !r.Supports(CapabilityMultiwallet) {
+			if err := r.requireCapability(CapabilityBitcoinCashFlag); err != nil {
+				return err
+			}
+		}
+	default:
+		if err := r.requireCapability(CapabilityMultiwallet); err != nil {
+			return err
+		}
+	}
+	r.initOpts = &opts
+	return nil
+}
+
+// InitOptions returns the options used to initialize the node, if any
+func (r *OpenBazaarRunner) InitOptions() (InitOptions, bool) {
+	if r.initOpts == nil {
+		return InitOptions{}, false
+	}
+	return *r.initOpts, true
+}
+
+func (r *OpenBazaarRunner) forceInit() bool {
+	return r.initOpts != nil && r.initOpts.Force
+}
+
+// ignoredInitOptions names the options which have no effect when an
+// existing node is not forcibly reinitialized
+func (r *OpenBazaarRunner) ignoredInitOptions() []string {
+	var ignored []string
+	if r.initOpts == nil {
+		return ignored
+	}
+	if r.initOpts.Mnemonic != "" {
+		ignored = append(ignored, "mnemonic")
+	}
+	if r.initOpts.WalletCurrency != "" {
+		ignored = append(ignored, "wallet currency")
+	}
+	if r.initOpts.WalletType != "" {
+		ignored = append(ignored, "wallet type")
+	}
+	if !r.initOpts.WalletCreationDate.IsZero() {
+		ignored = append(ignored, "wallet creation date")
+	}
+	return ignored
+}
+
+// initArgs returns the arguments passed to the daemon's init command for
+// the configured options
+func (r *OpenBazaarRunner) initArgs() []interface{} {
+	var args []interface{}
+	if r.initOpts == nil {
+		return args
+	}
+	if r.initOpts.Mnemonic != "" {
+		args = append(args, "-m", shell.Quote(r.initOpts.Mnemonic))
+	}
+	if !r.initOpts.WalletCreationDate.IsZero() {
+		args = append(args, "-w", r.initOpts.WalletCreationDate.UTC().Format(time.RFC3339))
+	}
+	if r.initOpts.Force {
+		args = append(args, "-f")
+	}
+	return args
+}
+
+// walletStartArgs returns the start flags which select the wallet of
+// releases before multiwallet
+func (r *OpenBazaarRunner) walletStartArgs() []interface{} {
+	if r.initOpts == nil || r.initOpts.WalletCurrency != bitcoinCashCurrency {
+		return nil
+	}
+	// binaries without a version claim every capability, and are treated
+	// as multiwallet releases
+	if r.Supports(CapabilityMultiwallet) || !r.Supports(CapabilityBitcoinCashFlag) {
+		return nil
+	}
+	return []interface{}{"--bitcoincash"}
+}
+
+// applyInitConfig writes the options which the daemon's init command
+// does not accept into the newly created config. Whether the node is a
+// multiwallet release is taken from the config, as development builds do
+// not report a version.
+func (r *OpenBazaarRunner) applyInitConfig() error {
+	if r.initOpts == nil || (r.initOpts.WalletType == "" && r.initOpts.WalletCurrency == "") {
+		return nil
+	}
+	config, err := r.readConfig()
+	if err != nil {
+		return err
+	}
+	wallets, ok := config["Wallets"].(map[string]interface{})
+	if !ok {
+		return r.applySingleWalletConfig()
+	}
+
+	var currency = r.initOpts.WalletCurrency
+	if currency != "" {
+		if _, ok := wallets[currency]; !ok {
+			var available = make([]string, 0, len(wallets))
+			for c := range wallets {
+				available = append(available, c)
+			}
+			sort.Strings(available)
+			return fmt.Errorf("node has no (%s) wallet, expected one of %v", currency, available)
+		}
+		wallets = map[string]interface{}{currency: wallets[currency]}
+	} else {
+		currency = bitcoinCurrency
+	}
+	if r.initOpts.WalletType != "" {
+		wallet, ok := wallets[currency].(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("node has no (%s) wallet to set the type of", currency)
+		}
+		wallet["Type"] = r.initOpts.WalletType
+	}
+	if err := r.SetConfigValue("Wallets", wallets); err != nil {
+		return fmt.Errorf("setting wallets: %s", err.Error())
+	}
+	return nil
+}
+
+// applySingleWalletConfig writes the options to the Wallet config of
+// releases before multiwallet
+func (r *OpenBazaarRunner) applySingleWalletConfig() error {
+	switch c := r.initOpts.WalletCurrency; c {
+	case "", bitcoinCurrency, bitcoinCashCurrency:
+	default:
+		return fmt.Errorf("node only has a %s or %s wallet, not (%s)", bitcoinCurrency, bitcoinCashCurrency, c)
+	}
+	if r.initOpts.WalletType == "" {
+		return nil
+	}
+	if err := r.SetConfigValue("Wallet.Type", r.initOpts.WalletType); err != nil {
+		return fmt.Errorf("setting wallet type: %s", err.Error())
+	}
+	return nil
+}
+
+// ParseWalletCreationDate parses a date given as YYYY-MM-DD or RFC3339
+func ParseWalletCreationDate(s string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("wallet creation date (%s) must be YYYY-MM-DD or RFC3339", s)
+	}
+	return t, nil
+}
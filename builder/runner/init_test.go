@@ -0,0 +1,143 @@
+package runner
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeInitScript records the init arguments it receives and writes a
+// minimal config to the data path
+const fakeInitScript = `[ "$1" = init ] || exit 1
+shift
+while [ $# -gt 0 ]; do
+	case "$1" in
+	-d) data="$2"; shift ;;
+	-m) echo "mnemonic=$2" ;;
+	-w) echo "created=$2" ;;
+	-f) echo "force" ;;
+	esac
+	shift
+done
+mkdir -p "$data"
+echo '{"Wallet":{"Type":"spvwallet"}}' > "$data/config"`
+
+func TestInitPassesOptionsToDaemon(t *testing.T) {
+	var subject, cleanup = mustCreateScriptRunner(t, "init_options", fakeInitScript)
+	defer cleanup()
+	subject.SetCustomDataPath(filepath.Join(subject.RunPath(), "..", "data"))
+
+	var opts = InitOptions{
+		Mnemonic:           "abandon abandon abandon abandon abandon abandon  abandon abandon abandon abandon abandon about",
+		WalletType:         "bitcoind",
+		WalletCreationDate: time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	if err := subject.SetInitOptions(opts); err != nil {
+		t.Fatal(err)
+	}
+	if err := subject.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	var output = strings.Join(subject.Tail(2), "\n")
+	if !strings.Contains(output, "mnemonic=abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about") {
+		t.Errorf("expected normalized mnemonic to be passed as one argument, but output was (%s)", output)
+	}
+	if !strings.Contains(output, "created=2019-01-02T03:04:05Z") {
+		t.Errorf("expected wallet creation date to be passed, but output was (%s)", output)
+	}
+	config, err := subject.readConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if walletType, _ := walkAndGetJSON([]string{"Wallet", "Type"}, config); walletType != "bitcoind" {
+		t.Errorf("expected wallet type (bitcoind), but was (%v)", walletType)
+	}
+}
+
+func TestInitSelectsMultiwalletCurrency(t *testing.T) {
+	var script = strings.Replace(fakeInitScript, `{"Wallet":{"Type":"spvwallet"}}`, `{"Wallets":{"BTC":{"Type":"API"},"LTC":{"Type":"API"}}}`, 1)
+	var subject, cleanup = mustCreateScriptRunner(t, "init_multiwallet", script)
+	defer cleanup()
+	subject.SetCustomDataPath(filepath.Join(subject.RunPath(), "..", "data"))
+
+	if err := subject.SetInitOptions(InitOptions{WalletCurrency: "ltc", WalletType: "SPV"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := subject.Init(); err != nil {
+		t.Fatal(err)
+	}
+	wallets, err := subject.ConfigValue("Wallets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var expected = map[string]interface{}{"LTC": map[string]interface{}{"Type": "SPV"}}
+	if !reflect.DeepEqual(wallets, expected) {
+		t.Errorf("expected only the litecoin wallet (%v), but was (%v)", expected, wallets)
+	}
+}
+
+func TestInitForceReinitializes(t *testing.T) {
+	var subject, cleanup = mustCreateScriptRunner(t, "init_force", fakeInitScript)
+	defer cleanup()
+	subject.SetCustomDataPath(filepath.Join(subject.RunPath(), "..", "data"))
+
+	if err := subject.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if err := subject.SetInitOptions(InitOptions{Force: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := subject.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if tail := subject.Tail(1); len(tail) != 1 || tail[0] != "force" {
+		t.Errorf("expected initialized node to be forcibly reinitialized, but output was (%v)", tail)
+	}
+}
+
+func TestInitWithoutForceIgnoresOptions(t *testing.T) {
+	var subject, cleanup = mustCreateScriptRunner(t, "init_ignored", fakeInitScript)
+	defer cleanup()
+	subject.SetCustomDataPath(filepath.Join(subject.RunPath(), "..", "data"))
+
+	if err := subject.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if err := subject.SetInitOptions(InitOptions{WalletType: "bitcoind"}); err != nil {
+		t.Fatal(err)
+	}
+	if ignored := subject.ignoredInitOptions(); len(ignored) != 1 || ignored[0] != "wallet type" {
+		t.Errorf("expected wallet type to be reported as ignored, but was (%v)", ignored)
+	}
+	if err := subject.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if walletType, _ := subject.ConfigValue("Wallet.Type"); walletType != "spvwallet" {
+		t.Errorf("expected initialized node to be left untouched, but wallet type was (%v)", walletType)
+	}
+}
+
+func TestRunInfoRedactsMnemonic(t *testing.T) {
+	var subject = &OpenBazaarRunner{}
+	var opts = InitOptions{Mnemonic: "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about", WalletType: "SPV"}
+	if err := subject.SetInitOptions(opts); err != nil {
+		t.Fatal(err)
+	}
+	var info = subject.runInfo(nil, nil)
+	if info.Init == nil || info.Init.Mnemonic != redacted || info.Init.WalletType != "SPV" {
+		t.Errorf("expected only the mnemonic to be redacted, but was (%+v)", info.Init)
+	}
+	if recorded, _ := subject.InitOptions(); recorded.Mnemonic != opts.Mnemonic {
+		t.Errorf("expected the runner to keep the mnemonic, but was (%s)", recorded.Mnemonic)
+	}
+}
+
+func TestSetInitOptionsValidatesMnemonic(t *testing.T) {
+	var subject = &OpenBazaarRunner{}
+	if err := subject.SetInitOptions(InitOptions{Mnemonic: "too few words"}); err == nil {
+		t.Error("expected short mnemonic to error, but did not")
+	}
+}
@@ -0,0 +1,159 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	shell "github.com/placer14/go-shell"
+)
+
+// ResourceLimits constrains the node process. Zero values leave the
+// corresponding resource unlimited.
+type ResourceLimits struct {
+	// AddressSpaceBytes is applied as RLIMIT_AS
+	AddressSpaceBytes uint64 `json:"addressSpaceBytes,omitempty"`
+	// OpenFiles is applied as RLIMIT_NOFILE
+	OpenFiles uint64 `json:"openFiles,omitempty"`
+	// MemoryBytes is applied as the cgroup v2 memory.max
+	MemoryBytes uint64 `json:"memoryBytes,omitempty"`
+	// CPUs is applied as the cgroup v2 cpu.max, where 0.5 allows half of
+	// one CPU
+	CPUs float64 `json:"cpus,omitempty"`
+	// CgroupParent is a cgroup v2 directory delegated to mason, without
+	// processes of its own, where the node's cgroup is created. Memory and
+	// CPU limits are unsupported without it.
+	CgroupParent string `json:"cgroupParent,omitempty"`
+}
+
+func (l ResourceLimits) needsCgroup() bool {
+	return l.MemoryBytes > 0 || l.CPUs > 0
+}
+
+// UnsupportedLimitError is returned when a requested limit cannot be
+// enforced on this system
+type UnsupportedLimitError struct {
+	Limit  string
+	Reason string
+}
+
+func (e *UnsupportedLimitError) Error() string {
+	return fmt.Sprintf("%s limit unsupported: %s", e.Limit, e.Reason)
+}
+
+// SetResourceLimits constrains the node on its next start. Limits which
+// require a cgroup are prepared immediately so an UnsupportedLimitError
+// is returned before the node is started.
+func (r *OpenBazaarRunner) SetResourceLimits(limits ResourceLimits) error {
+	if r.cgroupPath != "" {
+		if err := removeCgroup(r.cgroupPath); err != nil {
+			log.Warningf("removing cgroup (%s): %s", r.cgroupPath, err.Error())
+		}
+		r.cgroupPath = ""
+	}
+	if limits.needsCgroup() {
+		var label = "openbazaard"
+		if r.dataPath != "" {
+			label = filepath.Base(r.dataPath)
+		}
+		path, err := createCgroup(limits, label)
+		if err != nil {
+			return err
+		}
+		r.cgroupPath = path
+	}
+	r.limits = &limits
+	return nil
+}
+
+// ResourceLimits returns the limits applied to the node, if any
+func (r *OpenBazaarRunner) ResourceLimits() (ResourceLimits, bool) {
+	if r.limits == nil {
+		return ResourceLimits{}, false
+	}
+	return *r.limits, true
+}
+
+// Exit codes of the shell when a limit cannot be applied. They are only
+// meaningful before the pid file is written, as the node has not started.
+const (
+	limitExitAddressSpace = 121
+	limitExitOpenFiles    = 122
+	limitExitCgroup       = 123
+)
+
+// limitsPrelude returns the shell statements which apply the limits to
+// the shell before it is replaced by the node binary. The shell exits
+// with a limit's exit code instead of starting the node if it fails.
+func (r *OpenBazaarRunner) limitsPrelude() []interface{} {
+	var prelude []interface{}
+	if r.limits == nil {
+		return prelude
+	}
+	if r.limits.AddressSpaceBytes > 0 {
+		prelude = append(prelude, "ulimit", "-v", fmt.Sprint(r.limits.AddressSpaceBytes/1024), "||", "exit", fmt.Sprintf("%d;", limitExitAddressSpace))
+	}
+	if r.limits.OpenFiles > 0 {
+		prelude = append(prelude, "ulimit", "-n", fmt.Sprint(r.limits.OpenFiles), "||", "exit", fmt.Sprintf("%d;", limitExitOpenFiles))
+	}
+	if r.cgroupPath != "" {
+		prelude = append(prelude, "echo", "$$", ">", shell.Quote(filepath.Join(r.cgroupPath, "cgroup.procs")), "||", "exit", fmt.Sprintf("%d;", limitExitCgroup))
+	}
+	return prelude
+}
+
+// limitFailure returns the error for a shell which exited with code
+// before starting the node, and the limits applied before it failed
+func (r *OpenBazaarRunner) limitFailure(code int) (*UnsupportedLimitError, ResourceLimits) {
+	var applied = ResourceLimits{}
+	switch code {
+	case limitExitAddressSpace:
+		return &UnsupportedLimitError{Limit: "address space", Reason: "ulimit -v failed"}, applied
+	case limitExitOpenFiles:
+		applied.AddressSpaceBytes = r.limits.AddressSpaceBytes
+		return &UnsupportedLimitError{Limit: "open files", Reason: "ulimit -n failed"}, applied
+	case limitExitCgroup:
+		applied.AddressSpaceBytes = r.limits.AddressSpaceBytes
+		applied.OpenFiles = r.limits.OpenFiles
+		return &UnsupportedLimitError{Limit: "cgroup", Reason: fmt.Sprintf("joining (%s) failed", r.cgroupPath)}, applied
+	}
+	return nil, applied
+}
+
+// waitForLimits waits until the shell has applied the limits and written
+// the node's pid, returning the limits which were applied. An
+// UnsupportedLimitError is returned when the shell exits because a limit
+// failed.
+func (r *OpenBazaarRunner) waitForLimits(exited <-chan struct{}) (*ResourceLimits, error) {
+	if r.limits == nil {
+		return nil, nil
+	}
+	var limits = *r.limits
+	var deadline = time.Now().Add(pidWaitTimeout)
+	for {
+		if _, err := os.Stat(r.pidPath()); err == nil {
+			return &limits, nil
+		}
+		select {
+		case <-exited:
+			if _, err := os.Stat(r.pidPath()); err == nil {
+				return &limits, nil
+			}
+			var status, _ = r.LastExit()
+			if limitErr, applied := r.limitFailure(status.Code); limitErr != nil {
+				if len(status.Tail) > 0 {
+					limitErr.Reason = fmt.Sprintf("%s: %s", limitErr.Reason, strings.Join(status.Tail, "; "))
+				}
+				return &applied, limitErr
+			}
+			return &limits, nil
+		case <-time.After(10 * time.Millisecond):
+		}
+		if time.Now().After(deadline) {
+			log.Warningf("node (%s) did not confirm its limits were applied", r.dataPath)
+			return &limits, nil
+		}
+	}
+}
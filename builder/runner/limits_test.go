@@ -0,0 +1,61 @@
+package runner
+
+import (
+	"testing"
+)
+
+func TestResourceLimitsAppliedAndRecorded(t *testing.T) {
+	var subject, cleanup = mustCreateScriptRunner(t, "rlimits", `echo "nofile $(ulimit -n)"`)
+	defer cleanup()
+
+	if err := subject.SetResourceLimits(ResourceLimits{OpenFiles: 64}); err != nil {
+		t.Fatal(err)
+	}
+	if err := subject.RunStart(); err != nil {
+		t.Fatal(err)
+	}
+
+	if tail := subject.Tail(1); len(tail) != 1 || tail[0] != "nofile 64" {
+		t.Errorf("expected open file limit to be applied, but output was (%v)", tail)
+	}
+
+	info, err := ReadRunInfo(subject.RunPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Limits == nil || info.Limits.OpenFiles != 64 {
+		t.Errorf("expected applied limits to be recorded, but was (%+v)", info.Limits)
+	}
+	if info.Binary != subject.binaryPath {
+		t.Errorf("expected binary (%s) to be recorded, but was (%s)", subject.binaryPath, info.Binary)
+	}
+}
+
+func TestResourceLimitFailureIsAnError(t *testing.T) {
+	var subject, cleanup = mustCreateScriptRunner(t, "rlimits_fail", `echo "started"`)
+	defer cleanup()
+
+	// exceeds the kernel's maximum open files, even for root
+	if err := subject.SetResourceLimits(ResourceLimits{AddressSpaceBytes: 1 << 40, OpenFiles: 1 << 40}); err != nil {
+		t.Fatal(err)
+	}
+	var err = subject.AsyncStart()
+	limitErr, ok := err.(*UnsupportedLimitError)
+	if !ok || limitErr.Limit != "open files" {
+		t.Fatalf("expected the open files limit to be unsupported, but was (%v)", err)
+	}
+	<-subject.exited
+	for _, line := range subject.Tail(10) {
+		if line == "started" {
+			t.Error("expected the node not to start without its limits")
+		}
+	}
+
+	info, err := ReadRunInfo(subject.RunPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Limits == nil || info.Limits.AddressSpaceBytes != 1<<40 || info.Limits.OpenFiles != 0 {
+		t.Errorf("expected only the address space limit to be recorded, but was (%+v)", info.Limits)
+	}
+}
@@ -0,0 +1,167 @@
+package runner
+
+import (
+	"encoding/json"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/op/go-logging"
+)
+
+var (
+	ansiEscapePattern = regexp.MustCompile("\x1b\\[[0-9;]*m")
+	// logLinePattern matches openbazaard's go-logging format:
+	// `%{time:15:04:05.000} [%{level}] [%{module}/%{shortfunc}] %{message}`
+	// with an optionally prefixed date
+	logLinePattern = regexp.MustCompile(`^(?:(\d{4}-\d{2}-\d{2})[ T])?(\d{2}:\d{2}:\d{2}(?:\.\d+)?) \[(CRITICAL|ERROR|WARNING|NOTICE|INFO|DEBUG)\] \[([^/\]]*)(?:/([^\]]*))?\] ?(.*)$`)
+)
+
+// LogRecord is a single line of node output. Lines which do not match
+// the daemon's log format are kept with Parsed set to false, an INFO
+// level and the entire line as the message.
+type LogRecord struct {
+	// Node is an optional label of the node which produced the record
+	Node    string
+	Time    time.Time
+	Level   logging.Level
+	Module  string
+	Func    string
+	Message string
+	Raw     string
+	Parsed  bool
+}
+
+// ParseLogLine parses a single line of openbazaard output. The daemon
+// only logs a time of day, which is placed on the current date.
+func ParseLogLine(line string) LogRecord {
+	var (
+		clean  = ansiEscapePattern.ReplaceAllString(line, "")
+		record = LogRecord{Level: logging.INFO, Message: clean, Raw: line}
+		match  = logLinePattern.FindStringSubmatch(clean)
+	)
+	if match == nil {
+		return record
+	}
+	level, err := logging.LogLevel(match[3])
+	if err != nil {
+		return record
+	}
+
+	var (
+		now  = time.Now()
+		date = match[1]
+	)
+	if date == "" {
+		date = now.Format("2006-01-02")
+	}
+	if t, err := time.ParseInLocation("2006-01-02 15:04:05.999999999", date+" "+match[2], now.Location()); err == nil {
+		record.Time = t
+	}
+	record.Level = level
+	record.Module = match[4]
+	record.Func = match[5]
+	record.Message = match[6]
+	record.Parsed = true
+	return record
+}
+
+// Emit logs the record to logger at the record's own level. The message
+// is never interpreted as a format string.
+func (r LogRecord) Emit(logger *logging.Logger) {
+	var msg = r.Message
+	if r.Parsed {
+		msg = "[" + r.Module + "] " + r.Message
+	}
+	switch r.Level {
+	case logging.CRITICAL:
+		logger.Critical("%s", msg)
+	case logging.ERROR:
+		logger.Errorf("%s", msg)
+	case logging.WARNING:
+		logger.Warningf("%s", msg)
+	case logging.NOTICE:
+		logger.Noticef("%s", msg)
+	case logging.DEBUG:
+		logger.Debugf("%s", msg)
+	default:
+		logger.Infof("%s", msg)
+	}
+}
+
+type logRecordJSON struct {
+	Node    string `json:"node,omitempty"`
+	Time    string `json:"time,omitempty"`
+	Level   string `json:"level"`
+	Module  string `json:"module,omitempty"`
+	Func    string `json:"func,omitempty"`
+	Message string `json:"message"`
+}
+
+// MarshalJSON encodes the record with its level as a name
+func (r LogRecord) MarshalJSON() ([]byte, error) {
+	var j = logRecordJSON{
+		Node:    r.Node,
+		Level:   r.Level.String(),
+		Module:  r.Module,
+		Func:    r.Func,
+		Message: r.Message,
+	}
+	if !r.Time.IsZero() {
+		j.Time = r.Time.Format(time.RFC3339Nano)
+	}
+	return json.Marshal(j)
+}
+
+// WriteJSONLine writes the record to w as a single line of JSON
+func (r LogRecord) WriteJSONLine(w io.Writer) error {
+	return json.NewEncoder(w).Encode(r)
+}
+
+// LogFilter selects log records by severity and module. The zero value
+// matches only CRITICAL records, use NewLogFilter for a permissive
+// default.
+type LogFilter struct {
+	// Level is the least severe level which is matched
+	Level logging.Level
+	// Modules restricts matches to the listed modules when not empty
+	Modules []string
+}
+
+// NewLogFilter returns a filter matching records at or above level from
+// any of the modules provided, or from all modules if none are provided
+func NewLogFilter(level logging.Level, modules ...string) LogFilter {
+	return LogFilter{Level: level, Modules: modules}
+}
+
+// Match returns true if the record satisfies the filter
+func (f LogFilter) Match(r LogRecord) bool {
+	if r.Level > f.Level {
+		return false
+	}
+	if len(f.Modules) == 0 {
+		return true
+	}
+	for _, m := range f.Modules {
+		if strings.EqualFold(m, r.Module) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseLogs converts lines of output into log records which match the
+// filter. The returned channel is closed once the source is closed.
+func ParseLogs(lines <-chan string, filter LogFilter) <-chan LogRecord {
+	var records = make(chan LogRecord)
+	go func() {
+		defer close(records)
+		for line := range lines {
+			if record := ParseLogLine(line); filter.Match(record) {
+				records <- record
+			}
+		}
+	}()
+	return records
+}
@@ -0,0 +1,127 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/op/go-logging"
+)
+
+func TestParseLogLine(t *testing.T) {
+	var examples = []struct {
+		input          string
+		expectedParsed bool
+		expectedLevel  logging.Level
+		expectedModule string
+		expectedFunc   string
+		expectedMsg    string
+		expectedClock  string
+	}{
+		{ // colored stdout format
+			input:          "\x1b[0m\x1b[33m15:04:05.123 [WARNING] [core/Start] 100% of peers \x1b[0m",
+			expectedParsed: true,
+			expectedLevel:  logging.WARNING,
+			expectedModule: "core",
+			expectedFunc:   "Start",
+			expectedMsg:    "100% of peers ",
+			expectedClock:  "15:04:05.123",
+		},
+		{ // dated format without function
+			input:          "2019-01-02 03:04:05.000 [ERROR] [repo] migration failed",
+			expectedParsed: true,
+			expectedLevel:  logging.ERROR,
+			expectedModule: "repo",
+			expectedMsg:    "migration failed",
+			expectedClock:  "03:04:05.000",
+		},
+		{ // unstructured output
+			input:          "Gateway/API server listening on /ip4/127.0.0.1/tcp/4002",
+			expectedParsed: false,
+			expectedLevel:  logging.INFO,
+			expectedMsg:    "Gateway/API server listening on /ip4/127.0.0.1/tcp/4002",
+		},
+	}
+
+	for _, e := range examples {
+		var r = ParseLogLine(e.input)
+		if r.Parsed != e.expectedParsed {
+			t.Errorf("expected (%q) parsed to be (%t), but was (%t)", e.input, e.expectedParsed, r.Parsed)
+		}
+		if r.Level != e.expectedLevel {
+			t.Errorf("expected (%q) level to be (%s), but was (%s)", e.input, e.expectedLevel, r.Level)
+		}
+		if r.Module != e.expectedModule {
+			t.Errorf("expected (%q) module to be (%s), but was (%s)", e.input, e.expectedModule, r.Module)
+		}
+		if r.Func != e.expectedFunc {
+			t.Errorf("expected (%q) func to be (%s), but was (%s)", e.input, e.expectedFunc, r.Func)
+		}
+		if r.Message != e.expectedMsg {
+			t.Errorf("expected (%q) message to be (%q), but was (%q)", e.input, e.expectedMsg, r.Message)
+		}
+		if e.expectedClock != "" && r.Time.Format("15:04:05.000") != e.expectedClock {
+			t.Errorf("expected (%q) time to be (%s), but was (%s)", e.input, e.expectedClock, r.Time)
+		}
+		if r.Raw != e.input {
+			t.Errorf("expected raw line to be preserved, but was (%q)", r.Raw)
+		}
+	}
+}
+
+func TestLogFilterMatch(t *testing.T) {
+	var (
+		warning = LogRecord{Level: logging.WARNING, Module: "core", Parsed: true}
+		debug   = LogRecord{Level: logging.DEBUG, Module: "net", Parsed: true}
+	)
+	var examples = []struct {
+		filter        LogFilter
+		record        LogRecord
+		expectedMatch bool
+	}{
+		{NewLogFilter(logging.DEBUG), debug, true},
+		{NewLogFilter(logging.INFO), debug, false},
+		{NewLogFilter(logging.INFO), warning, true},
+		{NewLogFilter(logging.DEBUG, "CORE"), warning, true},
+		{NewLogFilter(logging.DEBUG, "core"), debug, false},
+	}
+	for i, e := range examples {
+		if actual := e.filter.Match(e.record); actual != e.expectedMatch {
+			t.Errorf("example %d: expected match to be (%t), but was (%t)", i, e.expectedMatch, actual)
+		}
+	}
+}
+
+func TestParseLogsFiltersAndEncodesJSON(t *testing.T) {
+	var lines = make(chan string, 3)
+	lines <- "10:00:00.000 [DEBUG] [net/dial] noise"
+	lines <- "10:00:01.000 [ERROR] [core/Start] broken"
+	lines <- "plain output"
+	close(lines)
+
+	var buf bytes.Buffer
+	for record := range ParseLogs(lines, NewLogFilter(logging.INFO)) {
+		record.Node = "ven"
+		if err := record.WriteJSONLine(&buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var decoded []map[string]string
+	for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+		var m map[string]string
+		if err := json.Unmarshal(line, &m); err != nil {
+			t.Fatalf("expected valid JSON line (%s): %s", line, err.Error())
+		}
+		decoded = append(decoded, m)
+	}
+	if len(decoded) != 2 {
+		t.Fatalf("expected (2) records to pass the filter, but got (%d)", len(decoded))
+	}
+	if decoded[0]["level"] != "ERROR" || decoded[0]["module"] != "core" || decoded[0]["node"] != "ven" {
+		t.Errorf("unexpected first record: %v", decoded[0])
+	}
+	if decoded[1]["message"] != "plain output" || decoded[1]["level"] != "INFO" {
+		t.Errorf("unexpected second record: %v", decoded[1])
+	}
+}
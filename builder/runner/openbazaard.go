@@ -9,6 +9,9 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/OpenBazaar/mason/util"
 	"github.com/jessevdk/go-flags"
@@ -25,24 +28,42 @@ var (
 // OpenBazaarRunner is reponsible for the runtime operations of the
 // openbazaar-go binary
 type (
-	runnerState      int
 	OpenBazaarRunner struct {
 		additionalArgs []string
 		binaryPath     string
 		proc           *shell.Process
-		state          runnerState
-		tee            io.WriteCloser
+		initialized    bool
+		output         *outputHub
+		runPath        string
+
+		stateMutex     sync.Mutex
+		state          State
+		stateCallbacks []func(StateChange)
+
+		procMutex     sync.Mutex
+		exited        chan struct{}
+		lastExit      *ExitStatus
+		stopRequested bool
+		supervisor    *Supervisor
+
+		resourceOpts    *ResourceSamplingOptions
+		resourceSamples []ResourceSample
+		limits          *ResourceLimits
+		startArgs       []interface{}
+		cgroupPath      string
+		env             *Environment
+		workDir         string
+		initOpts        *InitOptions
+		version         *SemVer
 
 		enableTestnet bool
 		dataPath      string
 		txDataPath    string
-	}
-)
 
-const (
-	stateReady runnerState = iota
-	stateInitialized
-	stateRunning
+		apiUsername string
+		apiPassword string
+		apiAddress  string
+	}
 )
 
 // FromBinaryPath will return an OpenBazaarRunner which uses the binary
@@ -57,23 +78,17 @@ func FromBinaryPath(path string) (*OpenBazaarRunner, error) {
 // SetConfigValue will follow a dot-separated string pointing to the nested
 // config key to change, and change it to the provided string value
 func (r *OpenBazaarRunner) SetConfigValue(path string, value interface{}) error {
-	if r.state < stateInitialized {
+	if !r.initialized {
 		return ErrInitNodeBeforeConfigValueSet
 	}
-	configPath := filepath.Join(r.dataPath, "config")
-	fi, err := os.Stat(configPath)
-	if os.IsNotExist(err) {
-		return fmt.Errorf("could not find config at (%s)", configPath)
-	} else if err != nil {
-		return fmt.Errorf("stat config at (%s): %s", configPath, err.Error())
-	}
-	b, err := ioutil.ReadFile(configPath)
+	config, err := r.readConfig()
 	if err != nil {
-		return fmt.Errorf("reading config: %s", err.Error())
+		return err
 	}
-	var config map[string]interface{}
-	if err := json.Unmarshal(b, &config); err != nil {
-		return fmt.Errorf("unmarshal config: %s", err.Error())
+	configPath := r.configPath()
+	fi, err := os.Stat(configPath)
+	if err != nil {
+		return fmt.Errorf("stat config at (%s): %s", configPath, err.Error())
 	}
 
 	// set value and write new config
@@ -89,6 +104,26 @@ func (r *OpenBazaarRunner) SetConfigValue(path string, value interface{}) error
 	return nil
 }
 
+func (r *OpenBazaarRunner) configPath() string {
+	return filepath.Join(r.dataPath, "config")
+}
+
+// readConfig loads the node's config as a generic JSON map
+func (r *OpenBazaarRunner) readConfig() (map[string]interface{}, error) {
+	configPath := r.configPath()
+	b, err := ioutil.ReadFile(configPath)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("could not find config at (%s)", configPath)
+	} else if err != nil {
+		return nil, fmt.Errorf("reading config: %s", err.Error())
+	}
+	var config map[string]interface{}
+	if err := json.Unmarshal(b, &config); err != nil {
+		return nil, fmt.Errorf("unmarshal config: %s", err.Error())
+	}
+	return config, nil
+}
+
 func walkAndSetJSON(path []string, value interface{}, jsonMap map[string]interface{}) error {
 	if len(path) > 1 {
 		jsonMsgRemain, ok := jsonMap[path[0]]
@@ -109,12 +144,17 @@ func walkAndSetJSON(path []string, value interface{}, jsonMap map[string]interfa
 // data found at the path provided.
 func (r *OpenBazaarRunner) SetCustomDataPath(path string) error {
 	if _, err := os.Stat(path); err == nil {
-		r.state = stateInitialized
+		r.initialized = true
 	}
 	r.dataPath = path
 	return nil
 }
 
+// DataPath returns the directory holding the node's state
+func (r *OpenBazaarRunner) DataPath() string {
+	return r.dataPath
+}
+
 // SetTestnetMode will ensure the running binary starts using the testnet
 // flag
 func (r *OpenBazaarRunner) SetTestnetMode(enabled bool) error {
@@ -123,8 +163,7 @@ func (r *OpenBazaarRunner) SetTestnetMode(enabled bool) error {
 }
 
 func (r *OpenBazaarRunner) BeginNodeStateTransaction() error {
-	switch r.state {
-	case stateRunning:
+	if s := r.State(); s.Running() || s == StateInitializing {
 		return ErrCannotStartStateTransactionWhileRunning
 	}
 	var tempStatePath = util.GenerateTempPath("openbazaard_state")
@@ -149,6 +188,12 @@ func (r *OpenBazaarRunner) WithArgs(args []string) *OpenBazaarRunner {
 	return r
 }
 
+// AddArgs appends arguments to those the running binary receives
+func (r *OpenBazaarRunner) AddArgs(args ...string) *OpenBazaarRunner {
+	r.additionalArgs = append(r.additionalArgs, args...)
+	return r
+}
+
 func (r *OpenBazaarRunner) filterAndApplyArgs(args []string) []string {
 	var startOpts struct {
 		DataPath      string `short:"d"`
@@ -172,12 +217,22 @@ func (r *OpenBazaarRunner) additionalArgsString() string {
 	return strings.Join(r.additionalArgs, " ")
 }
 
-// SplitOutput returns a io.ReadCloser which has the stdout and stderr
-// streams being sent to its in-memory pipe buffer immediately after
-// being started.
+// SplitOutput returns a io.ReadCloser which receives the combined stdout
+// and stderr streams of the node. The reader is independent of any other
+// consumer of the output and will miss lines if it is not read promptly.
 func (r *OpenBazaarRunner) SplitOutput() io.ReadCloser {
-	pr, pw := io.Pipe()
-	r.tee = pw
+	var (
+		sub    = r.Subscribe(0)
+		pr, pw = io.Pipe()
+	)
+	go func() {
+		defer pw.Close()
+		for line := range sub.Lines() {
+			if _, err := io.WriteString(pw, line+"\n"); err != nil {
+				sub.Close()
+			}
+		}
+	}()
 	return pr
 }
 
@@ -185,83 +240,185 @@ func (r *OpenBazaarRunner) SplitOutput() io.ReadCloser {
 // opportunity. It is the responsibility of the consumer to ensure
 // Cleanup is called when the runner is no longer used.
 func (r *OpenBazaarRunner) Cleanup() error {
-	var pErr, tErr error
+	var pErr, rErr, oErr error
 	if r.proc != nil {
 		pErr = r.Kill()
 		defer func() { r.proc = nil }()
 	}
-	if r.tee != nil {
-		tErr = r.tee.Close()
-		r.tee = nil
+	rErr = r.writeResourceReport()
+	if r.cgroupPath != "" {
+		if err := removeCgroup(r.cgroupPath); err != nil {
+			log.Warningf("removing cgroup (%s): %s", r.cgroupPath, err.Error())
+		}
+	}
+	if r.output != nil {
+		oErr = r.output.Close()
 	}
 	if pErr != nil {
 		return fmt.Errorf("proc cleanup: %s (%s)", pErr.Error(), r.proc.Error())
 	}
-	if tErr != nil {
-		return fmt.Errorf("tee cleanup: %s", tErr.Error())
+	if rErr != nil {
+		return fmt.Errorf("resource report cleanup: %s", rErr.Error())
+	}
+	if oErr != nil {
+		return fmt.Errorf("output cleanup: %s", oErr.Error())
 	}
 	return nil
 }
 
 func (r *OpenBazaarRunner) startCmd() *shell.Command {
-	var cmd = []interface{}{r.binaryPath, "start", "-v"}
+	var cmd = []interface{}{r.absPath(r.binaryPath), "start", "-v"}
 	if r.dataPath != "" {
-		cmd = append(cmd, "-d", r.dataPath)
+		cmd = append(cmd, "-d", r.absPath(r.dataPath))
 	}
 	if r.enableTestnet {
 		cmd = append(cmd, "-t")
 	}
+	cmd = append(cmd, r.walletStartArgs()...)
 	for _, a := range r.additionalArgs {
 		cmd = append(cmd, a)
 	}
-	return shell.Cmd(cmd...).Tee(r.tee)
+	return r.command(r.processCmd(cmd))
 }
 
 func (r *OpenBazaarRunner) initCmd() *shell.Command {
-	var cmd = []interface{}{r.binaryPath, "init", "-v"}
+	var cmd = []interface{}{r.absPath(r.binaryPath), "init", "-v"}
 	if r.dataPath != "" {
-		cmd = append(cmd, "-d", r.dataPath)
+		cmd = append(cmd, "-d", r.absPath(r.dataPath))
 	}
 	if r.enableTestnet {
 		cmd = append(cmd, "-t")
 	}
-	return shell.Cmd(cmd...).Tee(r.tee)
+	cmd = append(cmd, r.initArgs()...)
+	return r.command(r.envCmd(cmd))
 }
 
-// Init will synchronously initialize the node
-func (r *OpenBazaarRunner) Init() *OpenBazaarRunner {
-	if r.state >= stateInitialized {
-		return r
+// Init will synchronously initialize the node using any InitOptions.
+// Nodes which are already initialized are left untouched unless the
+// options force initialization.
+func (r *OpenBazaarRunner) Init() error {
+	if r.initialized && !r.forceInit() {
+		if ignored := r.ignoredInitOptions(); len(ignored) > 0 {
+			log.Warningf("node (%s) is already initialized, ignoring %s without force", r.dataPath, strings.Join(ignored, ", "))
+		}
+		return nil
 	}
-	r.proc = r.initCmd().Run()
-	if r.proc.ExitStatus == 0 {
-		r.state = stateInitialized
+	if err := r.transition(StateInitializing); err != nil {
+		return err
 	}
-	return r
+	r.prepareOutput()
+	r.proc = runProcess(r.initCmd())
+	if r.proc.ExitStatus != 0 {
+		r.transition(StateFailed)
+		return fmt.Errorf("initializing node: %s", r.proc.Error())
+	}
+	r.initialized = true
+	if err := r.applyInitConfig(); err != nil {
+		r.transition(StateFailed)
+		return err
+	}
+	return r.transition(StateStopped)
 }
 
 // AsyncStart will return immediately to allow other tasks to continue while
 // running.
-func (r *OpenBazaarRunner) AsyncStart() *OpenBazaarRunner {
+func (r *OpenBazaarRunner) AsyncStart() error {
+	if err := r.transition(StateStarting); err != nil {
+		return err
+	}
+	r.prepareOutput()
+	r.procMutex.Lock()
+	r.stopRequested = false
+	r.procMutex.Unlock()
+	os.Remove(r.pidPath())
 	r.proc = r.startCmd().Start()
-	r.state = stateRunning
-	return r
+	r.exited = make(chan struct{})
+	go r.waitForExit(r.proc, r.exited)
+	var applied, err = r.waitForLimits(r.exited)
+	r.writeRunInfo(r.startArgs, applied)
+	if err != nil {
+		return err
+	}
+	go r.probeReadiness(r.exited)
+	if r.resourceOpts != nil {
+		go r.sampleResources(r.resourceOpts.Interval, r.exited)
+	}
+	return nil
 }
 
 // RunStart will run synchronously and will return when the process finishes
-// running.
-func (r *OpenBazaarRunner) RunStart() *OpenBazaarRunner {
-	r.proc = r.startCmd().Run()
+// running. An error is returned if the node could not be started or did
+// not exit cleanly.
+func (r *OpenBazaarRunner) RunStart() error {
+	if err := r.AsyncStart(); err != nil {
+		return err
+	}
+	<-r.exited
+	if status, _ := r.LastExit(); r.State() == StateFailed {
+		return fmt.Errorf("node exited %s", status)
+	}
 	return nil
 }
 
-// Kill will ensure the binary process is stopped
+// Kill will ensure the binary process is stopped immediately
 func (r *OpenBazaarRunner) Kill() error {
-	r.state = stateReady
-	return r.proc.Kill()
+	return r.Stop(0)
 }
 
-// Version returns the version of the running binary
+// Stop interrupts the node and allows it the grace period to exit before
+// it is killed. A zero grace period kills the node immediately.
+func (r *OpenBazaarRunner) Stop(grace time.Duration) error {
+	if r.supervisor != nil {
+		return r.supervisor.stopWithGrace(grace)
+	}
+	return r.stopProcess(grace)
+}
+
+func (r *OpenBazaarRunner) stopProcess(grace time.Duration) error {
+	if r.exited == nil {
+		return nil
+	}
+	select {
+	case <-r.exited:
+		return nil
+	default:
+	}
+
+	r.procMutex.Lock()
+	r.stopRequested = true
+	r.procMutex.Unlock()
+	if err := r.transition(StateStopping); err != nil {
+		log.Debugf("node (%s) stopping: %s", r.dataPath, err.Error())
+	}
+
+	if grace > 0 {
+		switch err := r.signal(syscall.SIGINT); err {
+		case nil:
+			select {
+			case <-r.exited:
+				return nil
+			case <-time.After(grace):
+				log.Warningf("node (%s) did not stop within %s, killing", r.dataPath, grace)
+			}
+		case ErrSignalUnsupported:
+			log.Warningf("node (%s) cannot be stopped gracefully on this platform, killing", r.dataPath)
+		default:
+			return fmt.Errorf("signaling node: %s", err.Error())
+		}
+	}
+	if err := r.signal(syscall.SIGKILL); err != nil && err != ErrNotRunning {
+		return fmt.Errorf("signaling node: %s", err.Error())
+	}
+	select {
+	case <-r.exited:
+		return nil
+	case <-time.After(killWaitTimeout):
+		return fmt.Errorf("node did not exit within %s", killWaitTimeout)
+	}
+}
+
+// Version returns the raw version output of the binary. Use
+// SemanticVersion to compare versions.
 func (r *OpenBazaarRunner) Version() (string, error) {
 	var proc = shell.Cmd(r.binaryPath, "-v").Run()
 	if proc.ExitStatus != 0 {
@@ -270,6 +427,13 @@ func (r *OpenBazaarRunner) Version() (string, error) {
 	return proc.String(), nil
 }
 
+// IsFakeDaemon returns true when the binary is mason's fake daemon from
+// cmd/fakeobd, which follows its version with a tag
+func (r *OpenBazaarRunner) IsFakeDaemon() bool {
+	v, err := r.Version()
+	return err == nil && strings.Contains(v, FakeDaemonTag)
+}
+
 // ExitCodeAndErr returns the exit code and error state of the executed binary
 func (r *OpenBazaarRunner) ExitCodeAndErr() (int, error) {
 	if r.proc == nil {
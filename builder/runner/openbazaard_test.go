@@ -79,33 +79,37 @@ func TestSetCustomDataPathChecksInitialized(t *testing.T) {
 	if err := os.MkdirAll(filepath.Join(statePath, "extraDir"), 0755); err != nil {
 		t.Fatal(err)
 	}
-	if subject.state != stateReady {
-		t.Errorf("expected runner to be state (%d), but was (%d)", stateReady, subject.state)
+	if subject.initialized {
+		t.Error("expected runner to not be initialized, but was")
 	}
 	if err := subject.SetCustomDataPath(statePath); err != nil {
 		t.Fatal(err)
 	}
-	if subject.state != stateInitialized {
-		t.Errorf("expected runner to be state (%d), but was (%d)", stateInitialized, subject.state)
+	if !subject.initialized {
+		t.Error("expected runner to be initialized, but was not")
 	}
 
 }
 
 func TestBeginNodeStateTransactionRequiresReadyState(t *testing.T) {
 	var examples = []struct {
-		state       runnerState
+		state       State
 		expectedErr error
 	}{
 		{ // raw case, no error
-			state:       stateReady,
+			state:       StateStopped,
 			expectedErr: nil,
 		},
-		{ // init'd case, no error
-			state:       stateInitialized,
+		{ // previously run case, no error
+			state:       StateExited,
 			expectedErr: nil,
 		},
+		{ // cannot start state transaction while starting
+			state:       StateStarting,
+			expectedErr: ErrCannotStartStateTransactionWhileRunning,
+		},
 		{ // cannot start state transaction while running
-			state:       stateRunning,
+			state:       StateReady,
 			expectedErr: ErrCannotStartStateTransactionWhileRunning,
 		},
 	}
@@ -129,7 +133,7 @@ func TestBeginNodeStateTransactionRequiresReadyState(t *testing.T) {
 		s.state = e.state
 
 		if err := s.BeginNodeStateTransaction(); err != e.expectedErr {
-			t.Errorf("expected state (%d) to be error (%s), but was (%v)", e.state, e.expectedErr, err)
+			t.Errorf("expected state (%s) to be error (%s), but was (%v)", e.state, e.expectedErr, err)
 		}
 	}
 }
@@ -256,7 +260,7 @@ func TestSetConfigValue(t *testing.T) {
 }
 
 func TestSetConfigValueFailsIfNotInitialized(t *testing.T) {
-	var subject = &OpenBazaarRunner{state: stateReady}
+	var subject = &OpenBazaarRunner{}
 	if err := subject.SetConfigValue("", ""); err == nil {
 		t.Fatal("expected uninitialized runner to error, but did not")
 	} else if err != ErrInitNodeBeforeConfigValueSet {
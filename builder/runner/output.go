@@ -0,0 +1,328 @@
+package runner
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+
+	"github.com/OpenBazaar/mason/util"
+)
+
+const (
+	outputLogFilename = "output.log"
+
+	defaultMaxLogSize     = 10 << 20
+	defaultMaxLogBackups  = 5
+	defaultTailLines      = 500
+	defaultSubscriberSize = 1024
+)
+
+// OutputOptions configures how node output is retained
+type OutputOptions struct {
+	// MaxLogSize is the size in bytes a log file may reach before it
+	// is rotated
+	MaxLogSize int64
+	// MaxLogBackups is the number of rotated log files which are kept
+	MaxLogBackups int
+	// TailLines is the number of most recent lines kept in memory
+	TailLines int
+}
+
+func (o OutputOptions) withDefaults() OutputOptions {
+	if o.MaxLogSize <= 0 {
+		o.MaxLogSize = defaultMaxLogSize
+	}
+	if o.MaxLogBackups <= 0 {
+		o.MaxLogBackups = defaultMaxLogBackups
+	}
+	if o.TailLines <= 0 {
+		o.TailLines = defaultTailLines
+	}
+	return o
+}
+
+// outputHub receives the combined stdout and stderr of the node and
+// distributes it to the run's log files, an in-memory ring of recent
+// lines and any subscribers. Writes never block on consumers.
+type outputHub struct {
+	sync.Mutex
+
+	opts        OutputOptions
+	file        *rotatingFile
+	partial     []byte
+	ring        []string
+	ringNext    int
+	ringFull    bool
+	subscribers map[*OutputSubscription]struct{}
+}
+
+func newOutputHub(opts OutputOptions) *outputHub {
+	opts = opts.withDefaults()
+	return &outputHub{
+		opts:        opts,
+		ring:        make([]string, opts.TailLines),
+		subscribers: make(map[*OutputSubscription]struct{}),
+	}
+}
+
+// persistTo begins writing all output to a rotating log within dir
+func (h *outputHub) persistTo(dir string) error {
+	h.Lock()
+	defer h.Unlock()
+	if h.file != nil {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("creating run path (%s): %s", dir, err.Error())
+	}
+	f, err := openRotatingFile(filepath.Join(dir, outputLogFilename), h.opts.MaxLogSize, h.opts.MaxLogBackups)
+	if err != nil {
+		return err
+	}
+	h.file = f
+	return nil
+}
+
+func (h *outputHub) Write(p []byte) (int, error) {
+	h.Lock()
+	defer h.Unlock()
+
+	if h.file != nil {
+		if _, err := h.file.Write(p); err != nil {
+			log.Warningf("writing node output log: %s", err.Error())
+		}
+	}
+
+	h.partial = append(h.partial, p...)
+	for {
+		var i = bytes.IndexByte(h.partial, '\n')
+		if i < 0 {
+			break
+		}
+		h.publishLine(string(bytes.TrimRight(h.partial[:i], "\r")))
+		h.partial = h.partial[i+1:]
+	}
+	return len(p), nil
+}
+
+// publishLine must be called while holding the hub lock
+func (h *outputHub) publishLine(line string) {
+	h.ring[h.ringNext] = line
+	h.ringNext = (h.ringNext + 1) % len(h.ring)
+	if h.ringNext == 0 {
+		h.ringFull = true
+	}
+	for s := range h.subscribers {
+		select {
+		case s.lines <- line:
+		default:
+			atomic.AddUint64(&s.dropped, 1)
+		}
+	}
+}
+
+// tail returns up to n of the most recent complete lines, oldest first
+func (h *outputHub) tail(n int) []string {
+	h.Lock()
+	defer h.Unlock()
+
+	var available = h.ringNext
+	if h.ringFull {
+		available = len(h.ring)
+	}
+	if n <= 0 || n > available {
+		n = available
+	}
+	var lines = make([]string, 0, n)
+	for i := n; i > 0; i-- {
+		lines = append(lines, h.ring[(h.ringNext-i+len(h.ring))%len(h.ring)])
+	}
+	return lines
+}
+
+func (h *outputHub) subscribe(bufferLines int) *OutputSubscription {
+	if bufferLines <= 0 {
+		bufferLines = defaultSubscriberSize
+	}
+	var s = &OutputSubscription{
+		hub:   h,
+		lines: make(chan string, bufferLines),
+	}
+	h.Lock()
+	h.subscribers[s] = struct{}{}
+	h.Unlock()
+	return s
+}
+
+func (h *outputHub) unsubscribe(s *OutputSubscription) {
+	h.Lock()
+	defer h.Unlock()
+	if _, ok := h.subscribers[s]; ok {
+		delete(h.subscribers, s)
+		close(s.lines)
+	}
+}
+
+// Close flushes any incomplete line and detaches all subscribers
+func (h *outputHub) Close() error {
+	h.Lock()
+	defer h.Unlock()
+	if len(h.partial) > 0 {
+		h.publishLine(string(h.partial))
+		h.partial = nil
+	}
+	for s := range h.subscribers {
+		delete(h.subscribers, s)
+		close(s.lines)
+	}
+	if h.file != nil {
+		var err = h.file.Close()
+		h.file = nil
+		return err
+	}
+	return nil
+}
+
+// OutputSubscription receives each line of node output. Lines which
+// arrive while the subscription's buffer is full are dropped rather than
+// blocking the node.
+type OutputSubscription struct {
+	hub     *outputHub
+	lines   chan string
+	dropped uint64
+}
+
+// Lines returns the channel of output lines. It is closed when the
+// subscription is closed or the runner is cleaned up.
+func (s *OutputSubscription) Lines() <-chan string { return s.lines }
+
+// Dropped returns the number of lines which were discarded because the
+// subscriber was not keeping up
+func (s *OutputSubscription) Dropped() uint64 { return atomic.LoadUint64(&s.dropped) }
+
+// Close detaches the subscription from the node output
+func (s *OutputSubscription) Close() error {
+	s.hub.unsubscribe(s)
+	return nil
+}
+
+// rotatingFile is an io.WriteCloser which rotates the underlying file
+// once it exceeds maxSize, keeping maxBackups previous files as
+// <path>.1 (newest) through <path>.<maxBackups> (oldest)
+type rotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	file *os.File
+	size int64
+}
+
+func openRotatingFile(path string, maxSize int64, maxBackups int) (*rotatingFile, error) {
+	var f = &rotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("opening log (%s): %s", f.path, err.Error())
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("stat log (%s): %s", f.path, err.Error())
+	}
+	f.file = file
+	f.size = stat.Size()
+	return nil
+}
+
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	if f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *rotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("closing log (%s): %s", f.path, err.Error())
+	}
+	os.Remove(fmt.Sprintf("%s.%d", f.path, f.maxBackups))
+	for i := f.maxBackups - 1; i > 0; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", f.path, i), fmt.Sprintf("%s.%d", f.path, i+1))
+	}
+	if err := os.Rename(f.path, fmt.Sprintf("%s.1", f.path)); err != nil {
+		return fmt.Errorf("rotating log (%s): %s", f.path, err.Error())
+	}
+	return f.open()
+}
+
+func (f *rotatingFile) Close() error {
+	return f.file.Close()
+}
+
+// SetRunPath sets the directory where the output of this run is
+// persisted. If unset, a path is generated when the node is first
+// initialized or started.
+func (r *OpenBazaarRunner) SetRunPath(path string) {
+	r.runPath = path
+}
+
+// RunPath returns the directory where the output of this run is
+// persisted, or an empty string if one has not been chosen yet
+func (r *OpenBazaarRunner) RunPath() string {
+	return r.runPath
+}
+
+// SetOutputOptions configures log rotation and the in-memory tail of
+// node output. It must be called before the node produces any output.
+func (r *OpenBazaarRunner) SetOutputOptions(opts OutputOptions) {
+	r.output = newOutputHub(opts)
+}
+
+// Subscribe attaches a new consumer of node output which buffers up to
+// bufferLines lines. Consumers which fall behind lose lines instead of
+// blocking the node. The subscription should be closed when no longer
+// needed.
+func (r *OpenBazaarRunner) Subscribe(bufferLines int) *OutputSubscription {
+	return r.nodeOutput().subscribe(bufferLines)
+}
+
+// Tail returns up to n of the most recent lines of node output
+func (r *OpenBazaarRunner) Tail(n int) []string {
+	return r.nodeOutput().tail(n)
+}
+
+func (r *OpenBazaarRunner) nodeOutput() *outputHub {
+	if r.output == nil {
+		r.output = newOutputHub(OutputOptions{})
+	}
+	return r.output
+}
+
+// prepareOutput ensures a run path exists and node output is being
+// persisted there before a process is started
+func (r *OpenBazaarRunner) prepareOutput() {
+	if r.runPath == "" {
+		var label = "openbazaard"
+		if r.dataPath != "" {
+			label = filepath.Base(r.dataPath)
+		}
+		r.runPath = util.GenerateRunPath(label)
+	}
+	if err := r.nodeOutput().persistTo(r.runPath); err != nil {
+		log.Warningf("persisting node output: %s", err.Error())
+	}
+}
@@ -0,0 +1,121 @@
+package runner
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/OpenBazaar/mason/util"
+)
+
+func TestOutputHubTailKeepsMostRecentLines(t *testing.T) {
+	var subject = newOutputHub(OutputOptions{TailLines: 3})
+
+	if lines := subject.tail(10); len(lines) != 0 {
+		t.Errorf("expected empty tail, but was (%v)", lines)
+	}
+
+	subject.Write([]byte("one\ntwo\nthr"))
+	if expected, lines := []string{"one", "two"}, subject.tail(10); !reflect.DeepEqual(expected, lines) {
+		t.Errorf("expected tail to be (%v), but was (%v)", expected, lines)
+	}
+
+	subject.Write([]byte("ee\nfour\nfive\n"))
+	if expected, lines := []string{"three", "four", "five"}, subject.tail(10); !reflect.DeepEqual(expected, lines) {
+		t.Errorf("expected tail to be (%v), but was (%v)", expected, lines)
+	}
+	if expected, lines := []string{"four", "five"}, subject.tail(2); !reflect.DeepEqual(expected, lines) {
+		t.Errorf("expected tail to be (%v), but was (%v)", expected, lines)
+	}
+}
+
+func TestOutputHubSubscribersDoNotBlockWrites(t *testing.T) {
+	var (
+		subject = newOutputHub(OutputOptions{})
+		stalled = subject.subscribe(1)
+		active  = subject.subscribe(100)
+		written = make(chan struct{})
+	)
+
+	go func() {
+		for i := 0; i < 10; i++ {
+			fmt.Fprintf(subject, "line %d\n", i)
+		}
+		close(written)
+	}()
+	select {
+	case <-written:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected writes not to block on a stalled subscriber")
+	}
+
+	if dropped := stalled.Dropped(); dropped != 9 {
+		t.Errorf("expected stalled subscriber to drop (9) lines, but dropped (%d)", dropped)
+	}
+	if dropped := active.Dropped(); dropped != 0 {
+		t.Errorf("expected active subscriber to drop (0) lines, but dropped (%d)", dropped)
+	}
+
+	active.Close()
+	var received int
+	for range active.Lines() {
+		received++
+	}
+	if received != 10 {
+		t.Errorf("expected detached subscriber to have received (10) lines, but received (%d)", received)
+	}
+
+	fmt.Fprintln(subject, "after detach")
+	if dropped := active.Dropped(); dropped != 0 {
+		t.Errorf("expected detached subscriber to be skipped, but dropped (%d)", dropped)
+	}
+	subject.Close()
+	if _, ok := <-stalled.Lines(); !ok {
+		t.Error("expected buffered line to remain readable after close")
+	}
+	if _, ok := <-stalled.Lines(); ok {
+		t.Error("expected subscription to be closed with the hub")
+	}
+}
+
+func TestOutputHubPersistsAndRotatesLogs(t *testing.T) {
+	var (
+		runPath = util.GenerateTempPath("output_rotation")
+		subject = newOutputHub(OutputOptions{MaxLogSize: 20, MaxLogBackups: 2})
+		logPath = filepath.Join(runPath, outputLogFilename)
+	)
+	defer os.RemoveAll(runPath)
+
+	if err := subject.persistTo(runPath); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		fmt.Fprintf(subject, "line number %d\n", i)
+	}
+	if err := subject.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var expectedContent = map[string]string{
+		logPath:        "line number 4\n",
+		logPath + ".1": "line number 3\n",
+		logPath + ".2": "line number 2\n",
+	}
+	for path, expected := range expectedContent {
+		actual, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Errorf("reading (%s): %s", path, err.Error())
+			continue
+		}
+		if string(actual) != expected {
+			t.Errorf("expected (%s) to contain (%q), but was (%q)", path, expected, actual)
+		}
+	}
+	if _, err := os.Stat(logPath + ".3"); !os.IsNotExist(err) {
+		t.Errorf("expected only (2) backups to be kept, but found (%s.3)", logPath)
+	}
+}
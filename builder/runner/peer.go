@@ -0,0 +1,114 @@
+package runner
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	swarmKeyFilename = "swarm.key"
+	swarmKeyHeader   = "/key/swarm/psk/1.0.0/\n/base16/\n"
+	swarmKeyBytes    = 32
+)
+
+// PeerInfo identifies a node on the swarm
+type PeerInfo struct {
+	ID string
+	// Addresses are dialable multiaddrs for the node which include its
+	// peer ID, suitable for another node's Bootstrap list
+	Addresses []string
+}
+
+// PeerInfo reads the node's peer ID and swarm addresses from its config.
+// Wildcard listen addresses are translated to their loopback equivalent.
+func (r *OpenBazaarRunner) PeerInfo() (PeerInfo, error) {
+	var info PeerInfo
+	config, err := r.readConfig()
+	if err != nil {
+		return info, err
+	}
+	id, err := walkAndGetJSON([]string{"Identity", "PeerID"}, config)
+	if err != nil {
+		return info, fmt.Errorf("finding peer id: %s", err.Error())
+	}
+	if info.ID, _ = id.(string); info.ID == "" {
+		return info, fmt.Errorf("peer id is not a string (%v)", id)
+	}
+	swarm, err := walkAndGetJSON([]string{"Addresses", "Swarm"}, config)
+	if err != nil {
+		return info, fmt.Errorf("finding swarm addresses: %s", err.Error())
+	}
+	addrs, ok := swarm.([]interface{})
+	if !ok {
+		return info, fmt.Errorf("swarm addresses are not a list (%v)", swarm)
+	}
+	for _, a := range addrs {
+		if s, ok := a.(string); ok {
+			info.Addresses = append(info.Addresses, loopbackMultiaddr(s)+"/ipfs/"+info.ID)
+		}
+	}
+	return info, nil
+}
+
+// loopbackMultiaddr replaces a wildcard host in maddr with loopback
+func loopbackMultiaddr(maddr string) string {
+	var parts = strings.Split(maddr, "/")
+	if len(parts) > 2 {
+		switch {
+		case parts[1] == "ip4" && parts[2] == "0.0.0.0":
+			parts[2] = "127.0.0.1"
+		case parts[1] == "ip6" && parts[2] == "::":
+			parts[2] = "::1"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// SetBootstrapPeers replaces the peers the node connects to on start
+func (r *OpenBazaarRunner) SetBootstrapPeers(addrs []string) error {
+	var peers = make([]interface{}, 0, len(addrs))
+	for _, a := range addrs {
+		peers = append(peers, a)
+	}
+	return r.SetConfigValue("Bootstrap", peers)
+}
+
+// SetSwarmAddrFilters replaces the CIDR multiaddrs (ex:
+// /ip4/127.0.0.1/ipcidr/32) which the node will not dial or accept
+// connections from
+func (r *OpenBazaarRunner) SetSwarmAddrFilters(filters []string) error {
+	var values = make([]interface{}, 0, len(filters))
+	for _, f := range filters {
+		values = append(values, f)
+	}
+	return r.SetConfigValue("Swarm.AddrFilters", values)
+}
+
+// GenerateSwarmKey returns a random pre-shared key for a private swarm
+func GenerateSwarmKey() (string, error) {
+	var key = make([]byte, swarmKeyBytes)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("generating swarm key: %s", err.Error())
+	}
+	return hex.EncodeToString(key), nil
+}
+
+// SetSwarmKey writes the hex encoded pre-shared key to the node's data
+// path so it only connects to peers sharing the same key
+func (r *OpenBazaarRunner) SetSwarmKey(key string) error {
+	if b, err := hex.DecodeString(key); err != nil || len(b) != swarmKeyBytes {
+		return fmt.Errorf("swarm key must be %d hex encoded bytes", swarmKeyBytes)
+	}
+	if !r.initialized {
+		return ErrInitNodeBeforeConfigValueSet
+	}
+	var path = filepath.Join(r.dataPath, swarmKeyFilename)
+	if err := ioutil.WriteFile(path, []byte(swarmKeyHeader+strings.ToLower(key)+"\n"), 0600); err != nil {
+		return fmt.Errorf("writing swarm key: %s", err.Error())
+	}
+	return nil
+}
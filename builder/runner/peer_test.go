@@ -0,0 +1,74 @@
+package runner
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/OpenBazaar/mason/util"
+)
+
+func TestPeerInfoAndBootstrap(t *testing.T) {
+	var dataPath = util.GenerateTempPath("peer_info")
+	if err := os.MkdirAll(dataPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dataPath)
+	var config = `{"Identity":{"PeerID":"QmPeer"},"Addresses":{"Swarm":["/ip4/0.0.0.0/tcp/4001","/ip6/::/tcp/4001","/ip4/0.0.0.0/tcp/9005/ws"]},"Bootstrap":[]}`
+	if err := ioutil.WriteFile(filepath.Join(dataPath, "config"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var subject = &OpenBazaarRunner{}
+	subject.SetCustomDataPath(dataPath)
+
+	info, err := subject.PeerInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var expected = []string{
+		"/ip4/127.0.0.1/tcp/4001/ipfs/QmPeer",
+		"/ip6/::1/tcp/4001/ipfs/QmPeer",
+		"/ip4/127.0.0.1/tcp/9005/ws/ipfs/QmPeer",
+	}
+	if info.ID != "QmPeer" || !reflect.DeepEqual(info.Addresses, expected) {
+		t.Errorf("expected peer (QmPeer) at (%v), but was (%+v)", expected, info)
+	}
+
+	if err := subject.SetBootstrapPeers([]string{"/ip4/127.0.0.1/tcp/4101/ipfs/QmOther"}); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadFile(filepath.Join(dataPath, "config")); !strings.Contains(string(b), `"Bootstrap":["/ip4/127.0.0.1/tcp/4101/ipfs/QmOther"]`) {
+		t.Errorf("expected bootstrap to be replaced, but config was (%s)", b)
+	}
+}
+
+func TestSetSwarmKey(t *testing.T) {
+	var dataPath = util.GenerateTempPath("swarm_key")
+	if err := os.MkdirAll(dataPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dataPath)
+	var subject = &OpenBazaarRunner{}
+	subject.SetCustomDataPath(dataPath)
+
+	if err := subject.SetSwarmKey("not hex"); err == nil {
+		t.Error("expected invalid key to error, but did not")
+	}
+	key, err := GenerateSwarmKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := subject.SetSwarmKey(key); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dataPath, "swarm.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "/key/swarm/psk/1.0.0/\n/base16/\n" + key + "\n"; string(b) != expected {
+		t.Errorf("expected swarm key file (%q), but was (%q)", expected, b)
+	}
+}
@@ -0,0 +1,201 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	shell "github.com/placer14/go-shell"
+)
+
+const (
+	pidFilename = "openbazaard.pid"
+
+	pidWaitTimeout       = 5 * time.Second
+	killWaitTimeout      = 10 * time.Second
+	defaultExitTailLines = 50
+)
+
+var (
+	ErrNotRunning     = errors.New("node is not running")
+	ErrPidUnavailable = errors.New("node pid is unavailable")
+	// ErrSignalUnsupported is returned when a signal other than SIGKILL
+	// is delivered on a platform without signals
+	ErrSignalUnsupported = errors.New("signal is not supported on this platform")
+)
+
+// ExitReason classifies why a node process exited
+type ExitReason string
+
+const (
+	ExitClean   ExitReason = "clean"
+	ExitCrashed ExitReason = "crashed"
+	ExitKilled  ExitReason = "killed"
+)
+
+// ExitStatus describes a single exit of the node process
+type ExitStatus struct {
+	Reason ExitReason
+	// Code is the exit code of the process, or -1 if it was terminated
+	// by a signal
+	Code int
+	// Signal is set when the process was terminated by a signal
+	Signal syscall.Signal
+	Time   time.Time
+	// Tail contains the last lines of node output before exit
+	Tail []string
+}
+
+func (e ExitStatus) String() string {
+	if e.Signal != 0 {
+		return fmt.Sprintf("%s (signal: %s)", e.Reason, e.Signal)
+	}
+	return fmt.Sprintf("%s (exit code: %d)", e.Reason, e.Code)
+}
+
+// classifyExit determines the ExitStatus from the exit code and the
+// error returned while waiting on the process. Signals which are normally
+// used to terminate a process are considered killed while any others,
+// such as SIGSEGV or SIGABRT, are considered crashes.
+func classifyExit(code int, waitErr error, requestedStop bool) ExitStatus {
+	var status = ExitStatus{Reason: ExitClean, Code: code, Time: time.Now()}
+	if exitErr, ok := waitErr.(*exec.ExitError); ok {
+		if ws, ok := exitErr.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			status.Code = -1
+			status.Signal = ws.Signal()
+		}
+	}
+	// shells report children terminated by signal as 128+n
+	if status.Signal == 0 && status.Code > 128 && status.Code < 128+32 {
+		status.Signal = syscall.Signal(status.Code - 128)
+	}
+
+	switch {
+	case requestedStop:
+		status.Reason = ExitKilled
+	case status.Code == -1 && status.Signal == 0:
+		// terminated by a signal which could not be determined, openbazaard
+		// handles fatal signals itself so this is assumed to be external
+		status.Reason = ExitKilled
+	case status.Signal == syscall.SIGKILL, status.Signal == syscall.SIGTERM, status.Signal == syscall.SIGINT:
+		status.Reason = ExitKilled
+	case status.Code != 0 || status.Signal != 0:
+		status.Reason = ExitCrashed
+	}
+	return status
+}
+
+func (r *OpenBazaarRunner) pidPath() string {
+	return r.absPath(filepath.Join(r.runPath, pidFilename))
+}
+
+// processCmd wraps the command so the shell records its pid and applies
+// any resource limits before replacing itself with the node binary. The
+// run's metadata is recorded once the limits are known to be applied.
+func (r *OpenBazaarRunner) processCmd(args []interface{}) []interface{} {
+	r.startArgs = args
+	var cmd = r.limitsPrelude()
+	cmd = append(cmd, "echo", "$$", ">", shell.Quote(r.pidPath())+";")
+	return append(cmd, r.envCmd(args)...)
+}
+
+// Pid returns the process id of the running node
+func (r *OpenBazaarRunner) Pid() (int, error) {
+	if r.exited == nil {
+		return 0, ErrNotRunning
+	}
+	var deadline = time.Now().Add(pidWaitTimeout)
+	for {
+		select {
+		case <-r.exited:
+			return 0, ErrNotRunning
+		default:
+		}
+		if b, err := ioutil.ReadFile(r.pidPath()); err == nil {
+			if pid, err := strconv.Atoi(strings.TrimSpace(string(b))); err == nil && pid > 0 {
+				return pid, nil
+			}
+		}
+		if time.Now().After(deadline) {
+			return 0, ErrPidUnavailable
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// recoverShellPanic recovers the panic raised on non-zero exits when
+// shell.Panic is set, leaving the exit status on the process
+func recoverShellPanic() {
+	if p := recover(); p != nil {
+		if _, ok := p.(*shell.Process); !ok {
+			panic(p)
+		}
+	}
+}
+
+// runProcess runs cmd synchronously regardless of shell.Panic
+func runProcess(cmd *shell.Command) (proc *shell.Process) {
+	defer func() {
+		if p := recover(); p != nil {
+			var ok bool
+			if proc, ok = p.(*shell.Process); !ok {
+				panic(p)
+			}
+		}
+	}()
+	return cmd.Run()
+}
+
+// waitForExit blocks until proc exits, records how it exited and moves
+// the runner to its terminal state
+func (r *OpenBazaarRunner) waitForExit(proc *shell.Process, exited chan struct{}) {
+	var waitErr error
+	func() {
+		defer recoverShellPanic()
+		waitErr = proc.Wait()
+	}()
+
+	r.procMutex.Lock()
+	var (
+		stopRequested = r.stopRequested
+		status        = classifyExit(proc.ExitStatus, waitErr, stopRequested)
+	)
+	status.Tail = r.Tail(r.exitTailLines())
+	r.lastExit = &status
+	r.procMutex.Unlock()
+
+	os.Remove(r.pidPath())
+	var final = StateFailed
+	if status.Reason == ExitClean || stopRequested {
+		final = StateExited
+	}
+	if err := r.transition(final); err != nil {
+		log.Warningf("node (%s) exited: %s", r.dataPath, err.Error())
+	}
+	close(exited)
+}
+
+func (r *OpenBazaarRunner) exitTailLines() int {
+	if r.supervisor != nil {
+		return r.supervisor.opts.TailLines
+	}
+	return defaultExitTailLines
+}
+
+// LastExit returns the status of the most recent exit of a node which
+// was started asynchronously
+func (r *OpenBazaarRunner) LastExit() (ExitStatus, bool) {
+	r.procMutex.Lock()
+	defer r.procMutex.Unlock()
+	if r.lastExit == nil {
+		return ExitStatus{}, false
+	}
+	return *r.lastExit, true
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+// +build !windows
+
+package runner
+
+import "syscall"
+
+// signal delivers sig to the running node
+func (r *OpenBazaarRunner) signal(sig syscall.Signal) error {
+	pid, err := r.Pid()
+	if err != nil {
+		return err
+	}
+	return syscall.Kill(pid, sig)
+}
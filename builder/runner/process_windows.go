@@ -0,0 +1,27 @@
+//go:build windows
+// +build windows
+
+package runner
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// signal kills the running node. Windows has no other signals, so
+// ErrSignalUnsupported is returned for anything but SIGKILL.
+func (r *OpenBazaarRunner) signal(sig syscall.Signal) error {
+	if sig != syscall.SIGKILL {
+		return ErrSignalUnsupported
+	}
+	pid, err := r.Pid()
+	if err != nil {
+		return err
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return fmt.Errorf("finding node process: %s", err.Error())
+	}
+	return p.Kill()
+}
@@ -0,0 +1,157 @@
+package runner
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const defaultSampleInterval = 5 * time.Second
+
+var ErrResourceSamplingUnsupported = errors.New("resource sampling is not supported on this platform")
+
+// ResourceReportFormat selects the encoding of a resource report
+type ResourceReportFormat string
+
+const (
+	ResourceReportNone ResourceReportFormat = ""
+	ResourceReportCSV  ResourceReportFormat = "csv"
+	ResourceReportJSON ResourceReportFormat = "json"
+)
+
+// ResourceSample is a single measurement of the node process and its
+// data directory
+type ResourceSample struct {
+	Time           time.Time     `json:"time"`
+	CPUTime        time.Duration `json:"cpuTimeNs"`
+	RSSBytes       int64         `json:"rssBytes"`
+	OpenFiles      int           `json:"openFiles"`
+	Threads        int           `json:"threads"`
+	DiskUsageBytes int64         `json:"diskUsageBytes"`
+}
+
+// ResourceSamplingOptions configures sampling of node resource usage
+type ResourceSamplingOptions struct {
+	// Interval between samples, defaults to 5 seconds
+	Interval time.Duration
+	// Report, when set, writes all samples to resources.<format> in the
+	// run path during Cleanup
+	Report ResourceReportFormat
+}
+
+// SetResourceSampling enables sampling of the node's resource usage each
+// time it is started asynchronously
+func (r *OpenBazaarRunner) SetResourceSampling(opts ResourceSamplingOptions) error {
+	switch opts.Report {
+	case ResourceReportNone, ResourceReportCSV, ResourceReportJSON:
+	default:
+		return fmt.Errorf("unknown resource report format (%s)", opts.Report)
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = defaultSampleInterval
+	}
+	r.resourceOpts = &opts
+	return nil
+}
+
+// ResourceSamples returns the time series of resource samples taken
+// across all runs of the node
+func (r *OpenBazaarRunner) ResourceSamples() []ResourceSample {
+	r.procMutex.Lock()
+	defer r.procMutex.Unlock()
+	return append([]ResourceSample{}, r.resourceSamples...)
+}
+
+// sampleResources records a sample each interval until exited is closed
+func (r *OpenBazaarRunner) sampleResources(interval time.Duration, exited chan struct{}) {
+	var ticker = time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		pid, err := r.Pid()
+		if err == nil {
+			var sample ResourceSample
+			sample, err = sampleProcess(pid)
+			if err == nil {
+				sample.DiskUsageBytes = diskUsage(r.dataPath)
+				r.procMutex.Lock()
+				r.resourceSamples = append(r.resourceSamples, sample)
+				r.procMutex.Unlock()
+			}
+		}
+		if err == ErrResourceSamplingUnsupported {
+			log.Warningf("sampling resources: %s", err.Error())
+			return
+		}
+		select {
+		case <-exited:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// diskUsage returns the total size of regular files beneath path
+func diskUsage(path string) int64 {
+	if path == "" {
+		return 0
+	}
+	var total int64
+	filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			total += info.Size()
+		}
+		return nil
+	})
+	return total
+}
+
+// WriteResourceReport encodes the samples to w in the format provided
+func WriteResourceReport(w io.Writer, format ResourceReportFormat, samples []ResourceSample) error {
+	switch format {
+	case ResourceReportJSON:
+		var enc = json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(samples)
+	case ResourceReportCSV:
+		var c = csv.NewWriter(w)
+		c.Write([]string{"time", "cpu_seconds", "rss_bytes", "open_files", "threads", "disk_usage_bytes"})
+		for _, s := range samples {
+			c.Write([]string{
+				s.Time.Format(time.RFC3339Nano),
+				strconv.FormatFloat(s.CPUTime.Seconds(), 'f', 3, 64),
+				strconv.FormatInt(s.RSSBytes, 10),
+				strconv.Itoa(s.OpenFiles),
+				strconv.Itoa(s.Threads),
+				strconv.FormatInt(s.DiskUsageBytes, 10),
+			})
+		}
+		c.Flush()
+		return c.Error()
+	}
+	return fmt.Errorf("unknown resource report format (%s)", format)
+}
+
+// writeResourceReport persists the samples into the run path when a
+// report was requested
+func (r *OpenBazaarRunner) writeResourceReport() error {
+	if r.resourceOpts == nil || r.resourceOpts.Report == ResourceReportNone || r.runPath == "" {
+		return nil
+	}
+	var reportPath = filepath.Join(r.runPath, fmt.Sprintf("resources.%s", r.resourceOpts.Report))
+	f, err := os.Create(reportPath)
+	if err != nil {
+		return fmt.Errorf("creating resource report: %s", err.Error())
+	}
+	defer f.Close()
+	if err := WriteResourceReport(f, r.resourceOpts.Report, r.ResourceSamples()); err != nil {
+		return fmt.Errorf("writing resource report (%s): %s", reportPath, err.Error())
+	}
+	log.Infof("resource report written to %s", reportPath)
+	return nil
+}
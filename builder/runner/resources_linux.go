@@ -0,0 +1,54 @@
+package runner
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clockTicksPerSecond is USER_HZ, which is 100 on all supported Linux
+// architectures
+const clockTicksPerSecond = 100
+
+// sampleProcess reads the resource usage of pid from /proc
+func sampleProcess(pid int) (ResourceSample, error) {
+	var (
+		sample  = ResourceSample{Time: time.Now()}
+		procDir = filepath.Join("/proc", strconv.Itoa(pid))
+	)
+
+	stat, err := ioutil.ReadFile(filepath.Join(procDir, "stat"))
+	if err != nil {
+		return sample, fmt.Errorf("reading proc stat: %s", err.Error())
+	}
+	// the command name may contain spaces so fields are counted from the
+	// closing paren which ends it
+	var closeParen = strings.LastIndexByte(string(stat), ')')
+	if closeParen < 0 {
+		return sample, fmt.Errorf("malformed proc stat for pid (%d)", pid)
+	}
+	var fields = strings.Fields(string(stat[closeParen+1:]))
+	if len(fields) < 22 {
+		return sample, fmt.Errorf("malformed proc stat for pid (%d)", pid)
+	}
+	// fields are offset by 3 from the documented proc(5) numbering
+	utime, _ := strconv.ParseInt(fields[11], 10, 64)
+	stime, _ := strconv.ParseInt(fields[12], 10, 64)
+	threads, _ := strconv.Atoi(fields[17])
+	rssPages, _ := strconv.ParseInt(fields[21], 10, 64)
+
+	sample.CPUTime = time.Duration(utime+stime) * time.Second / clockTicksPerSecond
+	sample.Threads = threads
+	sample.RSSBytes = rssPages * int64(os.Getpagesize())
+
+	fds, err := ioutil.ReadDir(filepath.Join(procDir, "fd"))
+	if err != nil {
+		return sample, fmt.Errorf("reading proc fds: %s", err.Error())
+	}
+	sample.OpenFiles = len(fds)
+	return sample, nil
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+// +build !linux
+
+package runner
+
+func sampleProcess(pid int) (ResourceSample, error) {
+	return ResourceSample{}, ErrResourceSamplingUnsupported
+}
@@ -0,0 +1,102 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestWriteResourceReport(t *testing.T) {
+	var samples = []ResourceSample{
+		{Time: time.Unix(0, 0), CPUTime: 1500 * time.Millisecond, RSSBytes: 1024, OpenFiles: 3, Threads: 2, DiskUsageBytes: 42},
+	}
+
+	var csvBuf bytes.Buffer
+	if err := WriteResourceReport(&csvBuf, ResourceReportCSV, samples); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&csvBuf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("expected header and (1) row, but got (%d) rows", len(rows))
+	}
+	if rows[1][1] != "1.500" || rows[1][2] != "1024" || rows[1][5] != "42" {
+		t.Errorf("unexpected csv row: %v", rows[1])
+	}
+
+	var jsonBuf bytes.Buffer
+	if err := WriteResourceReport(&jsonBuf, ResourceReportJSON, samples); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []ResourceSample
+	if err := json.Unmarshal(jsonBuf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 1 || !decoded[0].Time.Equal(samples[0].Time) || decoded[0].CPUTime != samples[0].CPUTime {
+		t.Errorf("expected json report to round trip, but was (%v)", decoded)
+	}
+
+	if err := WriteResourceReport(&jsonBuf, "xml", samples); err == nil {
+		t.Error("expected unknown format to return an error, but did not")
+	}
+}
+
+func TestResourceSamplingOfRunningNode(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("resource sampling requires /proc")
+	}
+	var subject, cleanup = mustCreateScriptRunner(t, "resource_sampling", `exec sleep 30`)
+	defer cleanup()
+
+	var dataPath = filepath.Join(subject.RunPath(), "..", "data")
+	if err := os.MkdirAll(dataPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dataPath, "config"), make([]byte, 100), 0644); err != nil {
+		t.Fatal(err)
+	}
+	subject.dataPath = dataPath
+	if err := subject.SetResourceSampling(ResourceSamplingOptions{Interval: 10 * time.Millisecond, Report: ResourceReportCSV}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := subject.AsyncStart(); err != nil {
+		t.Fatal(err)
+	}
+	// early samples may be taken while the shell is still exec'ing the
+	// node, so wait for one of the running process
+	var (
+		deadline = time.Now().Add(5 * time.Second)
+		sample   ResourceSample
+	)
+	for sample.RSSBytes <= 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for a resource sample of the running node")
+		}
+		time.Sleep(10 * time.Millisecond)
+		if samples := subject.ResourceSamples(); len(samples) > 0 {
+			sample = samples[len(samples)-1]
+		}
+	}
+	if err := subject.Cleanup(); err != nil {
+		t.Fatal(err)
+	}
+
+	if sample.Threads < 1 || sample.OpenFiles < 1 {
+		t.Errorf("expected non-zero process usage, but was (%+v)", sample)
+	}
+	if sample.DiskUsageBytes != 100 {
+		t.Errorf("expected disk usage of (100), but was (%d)", sample.DiskUsageBytes)
+	}
+	if _, err := os.Stat(filepath.Join(subject.RunPath(), "resources.csv")); err != nil {
+		t.Errorf("expected resource report to be written: %s", err.Error())
+	}
+}
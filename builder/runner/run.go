@@ -0,0 +1,89 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+)
+
+const runInfoFilename = "run.json"
+
+// redacted replaces secrets in run.json, which is shared with reports
+const redacted = "[redacted]"
+
+// RunInfo records how the node was started so the run can be understood
+// and reproduced later. It is written to the run path on each start.
+type RunInfo struct {
+	Binary    string          `json:"binary"`
+	Args      []string        `json:"args"`
+	DataPath  string          `json:"dataPath,omitempty"`
+	Testnet   bool            `json:"testnet"`
+	StartedAt time.Time       `json:"startedAt"`
+	Limits    *ResourceLimits `json:"limits,omitempty"`
+	Cgroup    string          `json:"cgroup,omitempty"`
+	Env       *Environment    `json:"env,omitempty"`
+	WorkDir   string          `json:"workDir,omitempty"`
+	Init      *InitOptions    `json:"init,omitempty"`
+}
+
+// runInfo describes the run started with args, where limits are those
+// which were applied
+func (r *OpenBazaarRunner) runInfo(args []interface{}, limits *ResourceLimits) RunInfo {
+	var info = RunInfo{
+		Binary:    r.binaryPath,
+		DataPath:  r.dataPath,
+		Testnet:   r.enableTestnet,
+		StartedAt: time.Now(),
+		WorkDir:   r.workDir,
+		Limits:    limits,
+	}
+	for _, a := range args {
+		info.Args = append(info.Args, fmt.Sprint(a))
+	}
+	if limits != nil && limits.needsCgroup() {
+		info.Cgroup = r.cgroupPath
+	}
+	if r.initOpts != nil {
+		var opts = *r.initOpts
+		if opts.Mnemonic != "" {
+			opts.Mnemonic = redacted
+		}
+		info.Init = &opts
+	}
+	if r.env != nil {
+		var env = r.env.withSecretsRedacted()
+		info.Env = &env
+	}
+	return info
+}
+
+// writeRunInfo records the metadata of the run being started into the
+// run path
+func (r *OpenBazaarRunner) writeRunInfo(args []interface{}, limits *ResourceLimits) {
+	if r.runPath == "" {
+		return
+	}
+	b, err := json.MarshalIndent(r.runInfo(args, limits), "", "  ")
+	if err != nil {
+		log.Warningf("marshaling run info: %s", err.Error())
+		return
+	}
+	if err := ioutil.WriteFile(filepath.Join(r.runPath, runInfoFilename), b, 0644); err != nil {
+		log.Warningf("writing run info: %s", err.Error())
+	}
+}
+
+// ReadRunInfo loads the metadata recorded for the run at runPath
+func ReadRunInfo(runPath string) (RunInfo, error) {
+	var info RunInfo
+	b, err := ioutil.ReadFile(filepath.Join(runPath, runInfoFilename))
+	if err != nil {
+		return info, fmt.Errorf("reading run info: %s", err.Error())
+	}
+	if err := json.Unmarshal(b, &info); err != nil {
+		return info, fmt.Errorf("parsing run info: %s", err.Error())
+	}
+	return info, nil
+}
@@ -0,0 +1,182 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+)
+
+// State is a stage of the runner's lifecycle. The valid transitions are:
+//
+//	Stopped      -> Initializing, Starting
+//	Initializing -> Stopped (init succeeded), Failed
+//	Starting     -> Ready, Stopping, Exited, Failed
+//	Ready        -> Stopping, Exited, Failed
+//	Stopping     -> Exited, Failed
+//	Exited       -> Initializing, Starting
+//	Failed       -> Initializing, Starting
+//
+// A node reaches Ready once its gateway accepts connections. It reaches
+// Exited when it exits cleanly or after being asked to stop, and Failed
+// for any other exit or when initialization fails.
+type State int
+
+const (
+	StateStopped State = iota
+	StateInitializing
+	StateStarting
+	StateReady
+	StateStopping
+	StateExited
+	StateFailed
+)
+
+const readinessProbeInterval = 250 * time.Millisecond
+
+var stateNames = map[State]string{
+	StateStopped:      "stopped",
+	StateInitializing: "initializing",
+	StateStarting:     "starting",
+	StateReady:        "ready",
+	StateStopping:     "stopping",
+	StateExited:       "exited",
+	StateFailed:       "failed",
+}
+
+var validTransitions = map[State][]State{
+	StateStopped:      {StateInitializing, StateStarting},
+	StateInitializing: {StateStopped, StateFailed},
+	StateStarting:     {StateReady, StateStopping, StateExited, StateFailed},
+	StateReady:        {StateStopping, StateExited, StateFailed},
+	StateStopping:     {StateExited, StateFailed},
+	StateExited:       {StateInitializing, StateStarting},
+	StateFailed:       {StateInitializing, StateStarting},
+}
+
+func (s State) String() string {
+	if name, ok := stateNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("state(%d)", int(s))
+}
+
+// Running returns true when the state has a live process
+func (s State) Running() bool {
+	return s == StateStarting || s == StateReady || s == StateStopping
+}
+
+// Terminal returns true when the process has ended and may be started
+// again
+func (s State) Terminal() bool {
+	return s == StateExited || s == StateFailed
+}
+
+func (s State) canTransitionTo(to State) bool {
+	for _, valid := range validTransitions[s] {
+		if valid == to {
+			return true
+		}
+	}
+	return false
+}
+
+// StateTransitionError is returned when an operation requires a
+// transition which is not valid from the runner's current state
+type StateTransitionError struct {
+	From State
+	To   State
+}
+
+func (e *StateTransitionError) Error() string {
+	return fmt.Sprintf("invalid runner state transition (%s -> %s)", e.From, e.To)
+}
+
+// StateChange describes a single transition between states
+type StateChange struct {
+	From State
+	To   State
+	Time time.Time
+}
+
+// State returns the current lifecycle state of the runner
+func (r *OpenBazaarRunner) State() State {
+	r.stateMutex.Lock()
+	defer r.stateMutex.Unlock()
+	return r.state
+}
+
+// OnStateChange registers fn to be called after every state transition.
+// Callbacks are invoked in the order they were registered from the
+// goroutine which caused the transition, so they should not block.
+func (r *OpenBazaarRunner) OnStateChange(fn func(StateChange)) {
+	r.stateMutex.Lock()
+	defer r.stateMutex.Unlock()
+	r.stateCallbacks = append(r.stateCallbacks, fn)
+}
+
+// transition moves the runner to a new state if it is valid from the
+// current state, and notifies any callbacks
+func (r *OpenBazaarRunner) transition(to State) error {
+	r.stateMutex.Lock()
+	var from = r.state
+	if !from.canTransitionTo(to) {
+		r.stateMutex.Unlock()
+		return &StateTransitionError{From: from, To: to}
+	}
+	r.state = to
+	var (
+		change    = StateChange{From: from, To: to, Time: time.Now()}
+		callbacks = append([]func(StateChange){}, r.stateCallbacks...)
+	)
+	r.stateMutex.Unlock()
+
+	log.Debugf("node (%s) %s -> %s", r.dataPath, from, to)
+	for _, fn := range callbacks {
+		fn(change)
+	}
+	return nil
+}
+
+// WaitForReady blocks until the node is ready to serve its API. An error
+// is returned if the node exits or the context is done first.
+func (r *OpenBazaarRunner) WaitForReady(ctx context.Context) error {
+	var ticker = time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		switch s := r.State(); {
+		case s == StateReady:
+			return nil
+		case s.Terminal(), s == StateStopping, s == StateStopped:
+			return fmt.Errorf("node is %s", s)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// probeReadiness moves a starting node to ready once its gateway accepts
+// connections
+func (r *OpenBazaarRunner) probeReadiness(exited chan struct{}) {
+	var ticker = time.NewTicker(readinessProbeInterval)
+	defer ticker.Stop()
+	for {
+		if addr, err := r.GatewayAddress(); err == nil {
+			if conn, err := net.DialTimeout("tcp", addr, readinessProbeInterval); err == nil {
+				conn.Close()
+				if err := r.transition(StateReady); err != nil {
+					log.Debugf("node (%s) not marked ready: %s", r.dataPath, err.Error())
+				}
+				return
+			}
+		}
+		select {
+		case <-exited:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package runner
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStateTransitions(t *testing.T) {
+	var examples = []struct {
+		from, to State
+		valid    bool
+	}{
+		{from: StateStopped, to: StateStarting, valid: true},
+		{from: StateStopped, to: StateInitializing, valid: true},
+		{from: StateStopped, to: StateReady, valid: false},
+		{from: StateInitializing, to: StateStarting, valid: false},
+		{from: StateStarting, to: StateReady, valid: true},
+		{from: StateReady, to: StateStarting, valid: false},
+		{from: StateStopping, to: StateReady, valid: false},
+		{from: StateFailed, to: StateStarting, valid: true},
+		{from: StateExited, to: StateStopping, valid: false},
+	}
+	for _, e := range examples {
+		var subject = &OpenBazaarRunner{state: e.from}
+		var err = subject.transition(e.to)
+		if e.valid && err != nil {
+			t.Errorf("expected (%s -> %s) to be valid, but was (%s)", e.from, e.to, err.Error())
+		}
+		if !e.valid {
+			if _, ok := err.(*StateTransitionError); !ok {
+				t.Errorf("expected (%s -> %s) to return a StateTransitionError, but was (%v)", e.from, e.to, err)
+			}
+			if subject.State() != e.from {
+				t.Errorf("expected state to remain (%s), but was (%s)", e.from, subject.State())
+			}
+		}
+	}
+}
+
+func TestStateChangeCallbacksObserveLifecycle(t *testing.T) {
+	var subject, cleanup = mustCreateScriptRunner(t, "state_callbacks", `exit 0`)
+	defer cleanup()
+
+	var (
+		mutex   sync.Mutex
+		changes []State
+	)
+	subject.OnStateChange(func(c StateChange) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		changes = append(changes, c.To)
+	})
+	if err := subject.RunStart(); err != nil {
+		t.Fatal(err)
+	}
+	if err := subject.Kill(); err != nil {
+		t.Fatal(err)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(changes) != 2 || changes[0] != StateStarting || changes[1] != StateExited {
+		t.Errorf("expected transitions (starting, exited), but were (%v)", changes)
+	}
+}
+
+func TestRunStartReturnsErrorOnCrash(t *testing.T) {
+	var subject, cleanup = mustCreateScriptRunner(t, "state_crash", `exit 3`)
+	defer cleanup()
+
+	if err := subject.RunStart(); err == nil {
+		t.Error("expected crashed node to return an error, but did not")
+	}
+	if s := subject.State(); s != StateFailed {
+		t.Errorf("expected state (%s), but was (%s)", StateFailed, s)
+	}
+	if err := subject.RunStart(); err == nil {
+		t.Error("expected failed node to be restartable and fail again, but did not")
+	}
+}
+
+func TestWaitForReadyAndStop(t *testing.T) {
+	var listener, err = net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	var subject, cleanup = mustCreateScriptRunner(t, "state_ready", `exec sleep 30`)
+	defer cleanup()
+	var dataPath, cleanupData = mustWriteGatewayConfig(t, "state_ready_data", listener.Addr().String())
+	defer cleanupData()
+	subject.dataPath = dataPath
+
+	if err := subject.AsyncStart(); err != nil {
+		t.Fatal(err)
+	}
+	if err := subject.AsyncStart(); err == nil {
+		t.Error("expected starting a running node to error, but did not")
+	}
+
+	var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := subject.WaitForReady(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := subject.Stop(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if s := subject.State(); s != StateExited {
+		t.Errorf("expected state (%s), but was (%s)", StateExited, s)
+	}
+	if status, _ := subject.LastExit(); status.Reason != ExitKilled {
+		t.Errorf("expected killed exit, but was (%s)", status)
+	}
+}
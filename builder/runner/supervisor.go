@@ -0,0 +1,196 @@
+package runner
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RestartPolicy decides whether a supervised node is restarted after
+// it exits
+type RestartPolicy string
+
+const (
+	RestartNever     RestartPolicy = "never"
+	RestartOnFailure RestartPolicy = "on-failure"
+	RestartAlways    RestartPolicy = "always"
+)
+
+const (
+	defaultInitialBackoff = 1 * time.Second
+	defaultMaxBackoff     = 30 * time.Second
+)
+
+// ParseRestartPolicy returns the RestartPolicy named by s
+func ParseRestartPolicy(s string) (RestartPolicy, error) {
+	switch p := RestartPolicy(s); p {
+	case RestartNever, RestartOnFailure, RestartAlways:
+		return p, nil
+	case "":
+		return RestartNever, nil
+	}
+	return "", fmt.Errorf("unknown restart policy (%s)", s)
+}
+
+// SupervisorOptions configures how a Supervisor responds to exits
+type SupervisorOptions struct {
+	Policy RestartPolicy
+	// MaxRestarts limits the number of restarts, zero allows unlimited
+	// restarts
+	MaxRestarts int
+	// InitialBackoff is the delay before the first restart, which is
+	// doubled for each subsequent restart up to MaxBackoff
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// TailLines is the number of output lines captured on each exit
+	TailLines int
+}
+
+func (o SupervisorOptions) withDefaults() SupervisorOptions {
+	if o.Policy == "" {
+		o.Policy = RestartNever
+	}
+	if o.InitialBackoff <= 0 {
+		o.InitialBackoff = defaultInitialBackoff
+	}
+	if o.MaxBackoff < o.InitialBackoff {
+		o.MaxBackoff = defaultMaxBackoff
+		if o.MaxBackoff < o.InitialBackoff {
+			o.MaxBackoff = o.InitialBackoff
+		}
+	}
+	if o.TailLines <= 0 {
+		o.TailLines = defaultExitTailLines
+	}
+	return o
+}
+
+// Supervisor watches a node started with Supervise, records each exit
+// and restarts the node according to its RestartPolicy
+type Supervisor struct {
+	sync.Mutex
+
+	runner   *OpenBazaarRunner
+	opts     SupervisorOptions
+	restarts int
+	stopping bool
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// Supervise starts the node asynchronously and watches it until it exits
+// without being restarted, or until the supervisor is stopped.
+func (r *OpenBazaarRunner) Supervise(opts SupervisorOptions) *Supervisor {
+	var s = &Supervisor{
+		runner: r,
+		opts:   opts.withDefaults(),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	r.supervisor = s
+	s.Lock()
+	var err = r.AsyncStart()
+	s.Unlock()
+	if err != nil {
+		log.Errorf("node (%s) not started: %s", r.dataPath, err.Error())
+		close(s.done)
+		return s
+	}
+	go s.watch()
+	return s
+}
+
+func (s *Supervisor) watch() {
+	defer close(s.done)
+	var backoff = s.opts.InitialBackoff
+	for {
+		s.Lock()
+		var exited = s.runner.exited
+		s.Unlock()
+		<-exited
+
+		var status, _ = s.runner.LastExit()
+		if !s.shouldRestart(status) {
+			log.Infof("node (%s) exited %s, not restarting", s.runner.dataPath, status)
+			return
+		}
+		log.Warningf("node (%s) exited %s, restarting in %s", s.runner.dataPath, status, backoff)
+
+		select {
+		case <-s.stop:
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > s.opts.MaxBackoff {
+			backoff = s.opts.MaxBackoff
+		}
+
+		s.Lock()
+		if s.stopping {
+			s.Unlock()
+			return
+		}
+		s.restarts++
+		var err = s.runner.AsyncStart()
+		s.Unlock()
+		if err != nil {
+			log.Errorf("node (%s) not restarted: %s", s.runner.dataPath, err.Error())
+			return
+		}
+	}
+}
+
+func (s *Supervisor) shouldRestart(status ExitStatus) bool {
+	s.Lock()
+	defer s.Unlock()
+	if s.stopping {
+		return false
+	}
+	if s.opts.MaxRestarts > 0 && s.restarts >= s.opts.MaxRestarts {
+		log.Warningf("node (%s) reached maximum restarts (%d)", s.runner.dataPath, s.opts.MaxRestarts)
+		return false
+	}
+	switch s.opts.Policy {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return status.Reason != ExitClean
+	}
+	return false
+}
+
+// Stop prevents further restarts, kills the node if it is running and
+// waits for supervision to end
+func (s *Supervisor) Stop() error {
+	return s.stopWithGrace(0)
+}
+
+func (s *Supervisor) stopWithGrace(grace time.Duration) error {
+	s.Lock()
+	if !s.stopping {
+		s.stopping = true
+		close(s.stop)
+	}
+	s.Unlock()
+
+	var err = s.runner.stopProcess(grace)
+	<-s.done
+	return err
+}
+
+// Done is closed once the node has exited and will not be restarted
+func (s *Supervisor) Done() <-chan struct{} {
+	return s.done
+}
+
+// Restarts returns the number of times the node has been restarted
+func (s *Supervisor) Restarts() int {
+	s.Lock()
+	defer s.Unlock()
+	return s.restarts
+}
+
+// LastExit returns the status of the most recent exit of the node
+func (s *Supervisor) LastExit() (ExitStatus, bool) {
+	return s.runner.LastExit()
+}
@@ -0,0 +1,138 @@
+package runner
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/OpenBazaar/mason/util"
+)
+
+// mustCreateScriptRunner returns a runner whose binary is a shell script
+// with the provided body
+func mustCreateScriptRunner(t *testing.T, label, body string) (*OpenBazaarRunner, func()) {
+	var path = util.GenerateTempPath(label)
+	if err := os.MkdirAll(path, 0755); err != nil {
+		t.Fatal(err)
+	}
+	var binary = filepath.Join(path, "openbazaard")
+	if err := ioutil.WriteFile(binary, []byte("#!/bin/sh\n"+body+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	r, err := FromBinaryPath(binary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.SetRunPath(filepath.Join(path, "run"))
+	return r, func() {
+		r.Cleanup()
+		os.RemoveAll(path)
+	}
+}
+
+func waitForSupervisor(t *testing.T, s *Supervisor) {
+	select {
+	case <-s.Done():
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for supervisor to finish")
+	}
+}
+
+func TestClassifyExit(t *testing.T) {
+	var (
+		crashErr  = exec.Command("/bin/sh", "-c", "kill -SEGV $$").Run()
+		killedErr = exec.Command("/bin/sh", "-c", "kill -KILL $$").Run()
+	)
+	var examples = []struct {
+		code           int
+		err            error
+		requestedStop  bool
+		expectedReason ExitReason
+		expectedSignal syscall.Signal
+	}{
+		{code: 0, expectedReason: ExitClean},
+		{code: 2, err: errors.New("exit status 2"), expectedReason: ExitCrashed},
+		{code: 2, err: errors.New("exit status 2"), requestedStop: true, expectedReason: ExitKilled},
+		{code: -1, err: crashErr, expectedReason: ExitCrashed, expectedSignal: syscall.SIGSEGV},
+		{code: -1, err: killedErr, expectedReason: ExitKilled, expectedSignal: syscall.SIGKILL},
+		{code: -1, expectedReason: ExitKilled},
+		{code: 128 + 15, err: errors.New("exit status 143"), expectedReason: ExitKilled, expectedSignal: syscall.SIGTERM},
+	}
+	for i, e := range examples {
+		var status = classifyExit(e.code, e.err, e.requestedStop)
+		if status.Reason != e.expectedReason {
+			t.Errorf("example %d: expected reason (%s), but was (%s)", i, e.expectedReason, status.Reason)
+		}
+		if status.Signal != e.expectedSignal {
+			t.Errorf("example %d: expected signal (%s), but was (%s)", i, e.expectedSignal, status.Signal)
+		}
+	}
+}
+
+func TestSupervisorRestartsOnFailureUpToMax(t *testing.T) {
+	var subject, cleanup = mustCreateScriptRunner(t, "supervisor_crash", `echo "crashing now"; exit 3`)
+	defer cleanup()
+
+	var s = subject.Supervise(SupervisorOptions{
+		Policy:         RestartOnFailure,
+		MaxRestarts:    2,
+		InitialBackoff: 10 * time.Millisecond,
+	})
+	waitForSupervisor(t, s)
+
+	if restarts := s.Restarts(); restarts != 2 {
+		t.Errorf("expected (2) restarts, but was (%d)", restarts)
+	}
+	status, ok := s.LastExit()
+	if !ok {
+		t.Fatal("expected last exit to be recorded, but was not")
+	}
+	if status.Reason != ExitCrashed || status.Code != 3 {
+		t.Errorf("expected crash with exit code (3), but was (%s)", status)
+	}
+	if len(status.Tail) == 0 || !strings.Contains(status.Tail[len(status.Tail)-1], "crashing now") {
+		t.Errorf("expected exit tail to contain node output, but was (%v)", status.Tail)
+	}
+}
+
+func TestSupervisorDoesNotRestartCleanExitOnFailure(t *testing.T) {
+	var subject, cleanup = mustCreateScriptRunner(t, "supervisor_clean", `exit 0`)
+	defer cleanup()
+
+	var s = subject.Supervise(SupervisorOptions{Policy: RestartOnFailure, InitialBackoff: 10 * time.Millisecond})
+	waitForSupervisor(t, s)
+
+	if restarts := s.Restarts(); restarts != 0 {
+		t.Errorf("expected (0) restarts, but was (%d)", restarts)
+	}
+	if status, _ := s.LastExit(); status.Reason != ExitClean {
+		t.Errorf("expected clean exit, but was (%s)", status)
+	}
+}
+
+func TestSupervisorStopKillsWithoutRestart(t *testing.T) {
+	var subject, cleanup = mustCreateScriptRunner(t, "supervisor_stop", `exec sleep 30`)
+	defer cleanup()
+
+	var s = subject.Supervise(SupervisorOptions{Policy: RestartAlways, InitialBackoff: 10 * time.Millisecond})
+	if _, err := subject.Pid(); err != nil {
+		t.Fatalf("expected running node to have a pid: %s", err.Error())
+	}
+	if err := subject.Kill(); err != nil {
+		t.Fatal(err)
+	}
+	waitForSupervisor(t, s)
+
+	if restarts := s.Restarts(); restarts != 0 {
+		t.Errorf("expected (0) restarts, but was (%d)", restarts)
+	}
+	if status, _ := s.LastExit(); status.Reason != ExitKilled {
+		t.Errorf("expected killed exit, but was (%s)", status)
+	}
+}
@@ -0,0 +1,220 @@
+package runner
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	shell "github.com/placer14/go-shell"
+)
+
+// FakeDaemonTag follows the version printed by mason's fake daemon so it
+// can be told apart from openbazaard
+const FakeDaemonTag = "(mason fake daemon)"
+
+var semVerPattern = regexp.MustCompile(`v?(\d+)\.(\d+)\.(\d+)(?:-([0-9A-Za-z.-]+))?(?:\+([0-9A-Za-z.-]+))?`)
+
+// SemVer is a parsed semantic version such as 0.13.8 or 0.14.0-rc1
+type SemVer struct {
+	Major, Minor, Patch int
+	PreRelease          string
+	Build               string
+}
+
+// ParseSemVer finds the first semantic version within s, allowing for
+// output such as "openbazaard v0.13.8"
+func ParseSemVer(s string) (SemVer, error) {
+	var m = semVerPattern.FindStringSubmatch(s)
+	if m == nil {
+		return SemVer{}, fmt.Errorf("no semantic version found in (%s)", strings.TrimSpace(s))
+	}
+	var v = SemVer{PreRelease: m[4], Build: m[5]}
+	v.Major, _ = strconv.Atoi(m[1])
+	v.Minor, _ = strconv.Atoi(m[2])
+	v.Patch, _ = strconv.Atoi(m[3])
+	return v, nil
+}
+
+// MustParseSemVer is like ParseSemVer but panics if s has no version
+func MustParseSemVer(s string) SemVer {
+	v, err := ParseSemVer(s)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func (v SemVer) String() string {
+	var s = fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.PreRelease != "" {
+		s += "-" + v.PreRelease
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+	return s
+}
+
+// IsZero returns true for the zero version 0.0.0
+func (v SemVer) IsZero() bool {
+	return v == SemVer{}
+}
+
+// Compare returns -1, 0 or 1 when v is less than, equal to or greater
+// than o. Build metadata is ignored and pre-releases precede their
+// release as described by semver 2.0.
+func (v SemVer) Compare(o SemVer) int {
+	for _, d := range []int{v.Major - o.Major, v.Minor - o.Minor, v.Patch - o.Patch} {
+		if d < 0 {
+			return -1
+		} else if d > 0 {
+			return 1
+		}
+	}
+	return comparePreRelease(v.PreRelease, o.PreRelease)
+}
+
+// LessThan returns true when v precedes o
+func (v SemVer) LessThan(o SemVer) bool { return v.Compare(o) < 0 }
+
+// AtLeast returns true when v is equal to or later than o
+func (v SemVer) AtLeast(o SemVer) bool { return v.Compare(o) >= 0 }
+
+func comparePreRelease(a, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return 1
+	case b == "":
+		return -1
+	}
+	var as, bs = strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		var an, aErr = strconv.Atoi(as[i])
+		var bn, bErr = strconv.Atoi(bs[i])
+		switch {
+		case aErr == nil && bErr == nil:
+			if an != bn {
+				return compareInts(an, bn)
+			}
+		case aErr == nil:
+			// numeric identifiers precede alphanumeric ones
+			return -1
+		case bErr == nil:
+			return 1
+		case as[i] != bs[i]:
+			if as[i] < bs[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return compareInts(len(as), len(bs))
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// Capability is a feature of openbazaard which is only available in some
+// releases
+type Capability string
+
+const (
+	// CapabilityWalletCreationDate is the -w flag of the init command
+	CapabilityWalletCreationDate Capability = "init-wallet-creation-date"
+	// CapabilityBitcoinCashFlag is the --bitcoincash flag of the start
+	// command, which selected the wallet before multiwallet
+	CapabilityBitcoinCashFlag Capability = "start-bitcoincash"
+	// CapabilityMultiwallet is the Wallets config and the per-currency
+	// wallet API endpoints
+	CapabilityMultiwallet Capability = "multiwallet"
+)
+
+// VersionRange matches versions at least Min and less than Max. A zero
+// Max leaves the range unbounded.
+type VersionRange struct {
+	Min SemVer
+	Max SemVer
+}
+
+// Contains returns true when v is within the range
+func (r VersionRange) Contains(v SemVer) bool {
+	if v.LessThan(r.Min) {
+		return false
+	}
+	return r.Max.IsZero() || v.LessThan(r.Max)
+}
+
+// Capabilities maps each capability to the releases which support it. It
+// may be extended as new releases change flags, config or endpoints.
+var Capabilities = map[Capability]VersionRange{
+	CapabilityWalletCreationDate: {Min: MustParseSemVer("0.11.0")},
+	CapabilityBitcoinCashFlag:    {Min: MustParseSemVer("0.10.0"), Max: MustParseSemVer("0.13.0")},
+	CapabilityMultiwallet:        {Min: MustParseSemVer("0.13.0")},
+}
+
+// Supports returns true when v is within the range of releases which
+// support c. Unknown capabilities are not supported.
+func (v SemVer) Supports(c Capability) bool {
+	var r, ok = Capabilities[c]
+	return ok && r.Contains(v)
+}
+
+// UnsupportedCapabilityError is returned when an option requires a
+// capability which the binary's version does not have
+type UnsupportedCapabilityError struct {
+	Capability Capability
+	Version    SemVer
+}
+
+func (e *UnsupportedCapabilityError) Error() string {
+	return fmt.Sprintf("openbazaard %s does not support %s", e.Version, e.Capability)
+}
+
+// SemanticVersion returns the parsed version of the binary. The version
+// is detected once and cached for the life of the runner.
+func (r *OpenBazaarRunner) SemanticVersion() (SemVer, error) {
+	if r.version != nil {
+		return *r.version, nil
+	}
+	var proc = runProcess(shell.Cmd(r.binaryPath, "-v"))
+	if proc.ExitStatus != 0 {
+		return SemVer{}, fmt.Errorf("getting version: %s", proc.Error())
+	}
+	v, err := ParseSemVer(proc.String())
+	if err != nil {
+		return SemVer{}, err
+	}
+	r.version = &v
+	return v, nil
+}
+
+// Supports returns true when the binary's version supports c. Binaries
+// whose version cannot be determined, such as development builds, are
+// assumed to support everything.
+func (r *OpenBazaarRunner) Supports(c Capability) bool {
+	v, err := r.SemanticVersion()
+	if err != nil {
+		log.Debugf("assuming %s is supported: %s", c, err.Error())
+		return true
+	}
+	return v.Supports(c)
+}
+
+// requireCapability returns an UnsupportedCapabilityError when the
+// binary's known version lacks c
+func (r *OpenBazaarRunner) requireCapability(c Capability) error {
+	if r.Supports(c) {
+		return nil
+	}
+	return &UnsupportedCapabilityError{Capability: c, Version: *r.version}
+}
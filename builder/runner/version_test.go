@@ -0,0 +1,103 @@
+package runner
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseSemVer(t *testing.T) {
+	var examples = []struct {
+		input    string
+		expected SemVer
+	}{
+		{input: "0.13.8\n", expected: SemVer{Minor: 13, Patch: 8}},
+		{input: "openbazaard v0.14.0-rc.1+abc123", expected: SemVer{Minor: 14, PreRelease: "rc.1", Build: "abc123"}},
+		{input: "1.2.3-beta", expected: SemVer{Major: 1, Minor: 2, Patch: 3, PreRelease: "beta"}},
+	}
+	for _, e := range examples {
+		actual, err := ParseSemVer(e.input)
+		if err != nil {
+			t.Errorf("parsing (%s): %s", e.input, err.Error())
+			continue
+		}
+		if actual != e.expected {
+			t.Errorf("expected (%s) to parse as (%+v), but was (%+v)", e.input, e.expected, actual)
+		}
+	}
+	if _, err := ParseSemVer("development build"); err == nil {
+		t.Error("expected output without a version to error, but did not")
+	}
+}
+
+func TestSemVerCompare(t *testing.T) {
+	var ordered = []string{
+		"0.9.9",
+		"0.13.0-alpha",
+		"0.13.0-alpha.1",
+		"0.13.0-alpha.beta",
+		"0.13.0-beta.2",
+		"0.13.0-beta.11",
+		"0.13.0-rc.1",
+		"0.13.0",
+		"0.13.1",
+		"1.0.0",
+	}
+	for i := 0; i < len(ordered)-1; i++ {
+		var lower, higher = MustParseSemVer(ordered[i]), MustParseSemVer(ordered[i+1])
+		if !lower.LessThan(higher) || higher.LessThan(lower) {
+			t.Errorf("expected (%s) to precede (%s)", lower, higher)
+		}
+	}
+	if MustParseSemVer("0.13.0+build1").Compare(MustParseSemVer("0.13.0+build2")) != 0 {
+		t.Error("expected build metadata to be ignored")
+	}
+}
+
+func TestSemVerSupports(t *testing.T) {
+	var (
+		legacy  = MustParseSemVer("0.12.4")
+		current = MustParseSemVer("0.13.8")
+	)
+	if !legacy.Supports(CapabilityBitcoinCashFlag) || current.Supports(CapabilityBitcoinCashFlag) {
+		t.Error("expected only releases before 0.13.0 to support the --bitcoincash flag")
+	}
+	if legacy.Supports(CapabilityMultiwallet) || !current.Supports(CapabilityMultiwallet) {
+		t.Error("expected only releases from 0.13.0 to support multiwallet")
+	}
+	if current.Supports(Capability("unknown")) {
+		t.Error("expected unknown capability to be unsupported")
+	}
+}
+
+func TestSetInitOptionsRequiresCapability(t *testing.T) {
+	var subject, cleanup = mustCreateScriptRunner(t, "version_capability", `echo "0.12.4"`)
+	defer cleanup()
+
+	if v, err := subject.SemanticVersion(); err != nil || v != MustParseSemVer("0.12.4") {
+		t.Fatalf("expected version (0.12.4), but was (%s): %v", v, err)
+	}
+	err := subject.SetInitOptions(InitOptions{WalletCurrency: "ltc"})
+	if capErr, ok := err.(*UnsupportedCapabilityError); !ok || capErr.Capability != CapabilityMultiwallet {
+		t.Errorf("expected UnsupportedCapabilityError for wallet currency, but was (%v)", err)
+	}
+	if err := subject.SetInitOptions(InitOptions{WalletCurrency: "BTC", WalletType: "bitcoind", WalletCreationDate: time.Now()}); err != nil {
+		t.Errorf("expected bitcoin wallet options to be supported, but was (%s)", err.Error())
+	}
+}
+
+func TestWalletStartArgsFollowCapabilities(t *testing.T) {
+	for version, expected := range map[string][]interface{}{
+		"0.12.4": {"--bitcoincash"},
+		"0.13.8": nil,
+	} {
+		var subject, cleanup = mustCreateScriptRunner(t, "version_start_args", `echo "`+version+`"`)
+		defer cleanup()
+		if err := subject.SetInitOptions(InitOptions{WalletCurrency: "bch"}); err != nil {
+			t.Fatal(err)
+		}
+		if args := subject.walletStartArgs(); !reflect.DeepEqual(args, expected) {
+			t.Errorf("expected version (%s) to start with (%v), but was (%v)", version, expected, args)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package runner
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// websocketGUID is the fixed value from RFC 6455 used to derive
+// the Sec-WebSocket-Accept handshake response
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpBinary       = 0x2
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xa
+
+	wsMaxMessageSize = 16 << 20
+)
+
+var errWebsocketClosed = errors.New("websocket closed by peer")
+
+// wsConn is a minimal client-side websocket connection which is only
+// capable of what is needed to consume the openbazaard websocket API.
+type wsConn struct {
+	conn net.Conn
+	rw   *bufio.ReadWriter
+}
+
+// dialWebsocket performs the websocket opening handshake against the
+// provided host:port and path
+func dialWebsocket(addr, path, username, password string) (*wsConn, error) {
+	conn, err := net.DialTimeout("tcp", addr, 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	var keyBytes = make([]byte, 16)
+	if _, err := rand.Read(keyBytes); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("generating key: %s", err.Error())
+	}
+	var key = base64.StdEncoding.EncodeToString(keyBytes)
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("http://%s%s", addr, path), nil)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if username != "" || password != "" {
+		req.SetBasicAuth(username, password)
+	}
+
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("writing handshake: %s", err.Error())
+	}
+	var rw = bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+	resp, err := http.ReadResponse(rw.Reader, req)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("reading handshake: %s", err.Error())
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		conn.Close()
+		return nil, fmt.Errorf("unexpected handshake status (%s)", resp.Status)
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != websocketAccept(key) {
+		conn.Close()
+		return nil, errors.New("invalid handshake accept key")
+	}
+	conn.SetDeadline(time.Time{})
+	return &wsConn{conn: conn, rw: rw}, nil
+}
+
+func websocketAccept(key string) string {
+	var h = sha1.New()
+	io.WriteString(h, key+websocketGUID)
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// ReadMessage blocks until a complete text or binary message has been
+// received. Control frames are handled transparently.
+func (c *wsConn) ReadMessage() ([]byte, error) {
+	var message []byte
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+		switch opcode {
+		case wsOpPing:
+			if err := c.writeFrame(wsOpPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case wsOpPong:
+			continue
+		case wsOpClose:
+			c.writeFrame(wsOpClose, nil)
+			return nil, errWebsocketClosed
+		case wsOpText, wsOpBinary, wsOpContinuation:
+			message = append(message, payload...)
+			if len(message) > wsMaxMessageSize {
+				return nil, errors.New("websocket message too large")
+			}
+			if fin {
+				return message, nil
+			}
+		default:
+			return nil, fmt.Errorf("unknown websocket opcode (%x)", opcode)
+		}
+	}
+}
+
+func (c *wsConn) readFrame() (bool, byte, []byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.rw, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+	var (
+		fin    = header[0]&0x80 != 0
+		opcode = header[0] & 0x0f
+		masked = header[1]&0x80 != 0
+		length = uint64(header[1] & 0x7f)
+	)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.rw, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.rw, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if length > wsMaxMessageSize {
+		return false, 0, nil, errors.New("websocket frame too large")
+	}
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.rw, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+	var payload = make([]byte, length)
+	if _, err := io.ReadFull(c.rw, payload); err != nil {
+		return false, 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return fin, opcode, payload, nil
+}
+
+// writeFrame sends a single masked frame, as required of all
+// client-to-server frames
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	var frame = []byte{0x80 | opcode}
+	switch l := len(payload); {
+	case l < 126:
+		frame = append(frame, 0x80|byte(l))
+	case l <= 0xffff:
+		frame = append(frame, 0x80|126, byte(l>>8), byte(l))
+	default:
+		var ext [8]byte
+		binary.BigEndian.PutUint64(ext[:], uint64(l))
+		frame = append(append(frame, 0x80|127), ext[:]...)
+	}
+	var mask [4]byte
+	if _, err := rand.Read(mask[:]); err != nil {
+		return err
+	}
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	if _, err := c.rw.Write(frame); err != nil {
+		return err
+	}
+	return c.rw.Flush()
+}
+
+func (c *wsConn) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clockFileEnv names the file holding the offset of mason's faketime
+// clock, which openbazaard reads once built with the clock patch
+const clockFileEnv = "MASON_CLOCK_FILE"
+
+// now returns the current time advanced by the clock offset, if any
+func now() time.Time {
+	var path = os.Getenv(clockFileEnv)
+	if path == "" {
+		return time.Now()
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Warningf("reading clock: %s", err.Error())
+		return time.Now()
+	}
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(string(b)), 64)
+	if err != nil {
+		log.Warningf("invalid clock offset (%s)", strings.TrimSpace(string(b)))
+		return time.Now()
+	}
+	return time.Now().Add(time.Duration(seconds * float64(time.Second)))
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/OpenBazaar/mason/builder/runner"
+)
+
+const (
+	configFilename  = "config"
+	repoVerFilename = "repover"
+	profileFilename = "root/profile.json"
+
+	// repoVersion is written to repover like the real daemon's migrations
+	repoVersion = "29"
+)
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+type initCommand struct {
+	DataDir       string `short:"d" long:"datadir" description:"data directory"`
+	Testnet       bool   `short:"t" long:"testnet" description:"use the test network"`
+	Mnemonic      string `short:"m" long:"mnemonic" description:"seed the identity from this mnemonic"`
+	WalletCreated string `short:"w" long:"walletcreationdate" description:"date the mnemonic was created"`
+	Force         bool   `short:"f" long:"force" description:"overwrite an existing data directory"`
+}
+
+func (c *initCommand) Execute([]string) error {
+	hangIf("init")
+	var dataDir = dataDirOrDefault(c.DataDir, c.Testnet)
+	if _, err := os.Stat(filepath.Join(dataDir, configFilename)); err == nil && !c.Force {
+		return fmt.Errorf("repo already exists at %s, use -f to overwrite", dataDir)
+	}
+	if c.WalletCreated != "" {
+		if _, err := time.Parse(time.RFC3339, c.WalletCreated); err != nil {
+			return fmt.Errorf("invalid wallet creation date: %s", err.Error())
+		}
+	}
+	if err := initRepo(dataDir, c.Mnemonic); err != nil {
+		return err
+	}
+	fmt.Printf("OpenBazaar repo initialized at %s\n", dataDir)
+	return nil
+}
+
+func dataDirOrDefault(dataDir string, testnet bool) string {
+	if dataDir != "" {
+		return dataDir
+	}
+	var name = ".openbazaar"
+	if testnet {
+		name = ".openbazaar-testnet"
+	}
+	return filepath.Join(os.Getenv("HOME"), name)
+}
+
+// initRepo writes a config shaped like the one produced by the real
+// daemon. The identity is derived from the mnemonic when provided.
+func initRepo(dataDir, mnemonic string) error {
+	var seed = make([]byte, 32)
+	if mnemonic != "" {
+		var sum = sha256.Sum256([]byte(mnemonic))
+		seed = sum[:]
+	} else if _, err := rand.Read(seed); err != nil {
+		return fmt.Errorf("generating identity: %s", err.Error())
+	}
+	var config = map[string]interface{}{
+		"Addresses": map[string]interface{}{
+			"API":     "",
+			"Gateway": "/ip4/127.0.0.1/tcp/4002",
+			"Swarm":   []string{"/ip4/0.0.0.0/tcp/4001", "/ip6/::/tcp/4001", "/ip4/0.0.0.0/tcp/9005/ws", "/ip6/::/tcp/9005/ws"},
+		},
+		"Bootstrap": []string{
+			"/ip4/107.170.133.32/tcp/4001/ipfs/QmUZRGLhcKXF1JyuaHgKm23LvqcoMYwtb9jmh8CkP4og3K",
+			"/ip4/139.59.174.197/tcp/4001/ipfs/QmZfTbnpvPwxCjpCG3CXJ7pfexgkBZ2kgChAiRJrTK1HsM",
+		},
+		"Identity": map[string]interface{}{
+			"PeerID":  peerID(seed),
+			"PrivKey": base64.StdEncoding.EncodeToString(seed),
+		},
+		"JSON-API": map[string]interface{}{
+			"Enabled":       true,
+			"SSL":           false,
+			"Authenticated": false,
+			"Username":      "",
+			"Password":      "",
+			"AllowedIPs":    []string{},
+		},
+		"Datastore": map[string]interface{}{
+			"StorageMax": "10GB",
+			"Type":       "leveldb",
+		},
+		"Swarm": map[string]interface{}{
+			"AddrFilters":             nil,
+			"DisableBandwidthMetrics": false,
+			"DisableNatPortMap":       false,
+			"DisableRelay":            false,
+			"EnableRelayHop":          false,
+		},
+	}
+	if multiwallet() {
+		config["Wallets"] = multiwalletConfig()
+	} else {
+		config["Wallet"] = map[string]interface{}{
+			"Type":             "spvwallet",
+			"MaxFee":           2000,
+			"FeeAPI":           "https://btc.fees.openbazaar.org",
+			"HighFeeDefault":   160,
+			"MediumFeeDefault": 60,
+			"LowFeeDefault":    20,
+		}
+	}
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return fmt.Errorf("creating data directory: %s", err.Error())
+	}
+	b, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dataDir, configFilename), b, 0644); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dataDir, repoVerFilename), []byte(repoVersion), 0644)
+}
+
+// multiwallet returns true when the reported version is a release with a
+// wallet per currency. Versions which do not parse are treated like
+// development builds of the latest release.
+func multiwallet() bool {
+	v, err := runner.ParseSemVer(version)
+	return err != nil || v.Supports(runner.CapabilityMultiwallet)
+}
+
+// multiwalletConfig returns the Wallets config of a multiwallet release,
+// with each currency using its blockbook API
+func multiwalletConfig() map[string]interface{} {
+	var wallets = make(map[string]interface{})
+	for coin, name := range map[string]string{"BTC": "btc", "BCH": "bch", "LTC": "ltc", "ZEC": "zec"} {
+		wallets[coin] = map[string]interface{}{
+			"Type":             "API",
+			"API":              []string{fmt.Sprintf("https://%s.blockbook.api.openbazaar.org/api", name)},
+			"APITestnet":       []string{fmt.Sprintf("https://t%s.blockbook.api.openbazaar.org/api", name)},
+			"MaxFee":           200,
+			"FeeAPI":           "",
+			"HighFeeDefault":   50,
+			"MediumFeeDefault": 10,
+			"LowFeeDefault":    1,
+			"TrustedPeer":      "",
+			"WalletOptions":    nil,
+		}
+	}
+	return wallets
+}
+
+// peerID encodes the seed's hash as a base58 multihash-like identifier
+func peerID(seed []byte) string {
+	var sum = sha256.Sum256(seed)
+	var n = new(big.Int).SetBytes(append([]byte{0x12, 0x20}, sum[:]...))
+	var (
+		encoded []byte
+		base    = big.NewInt(58)
+		mod     = new(big.Int)
+	)
+	for n.Sign() > 0 {
+		n.DivMod(n, base, mod)
+		encoded = append([]byte{base58Alphabet[mod.Int64()]}, encoded...)
+	}
+	return string(encoded)
+}
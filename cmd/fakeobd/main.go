@@ -0,0 +1,54 @@
+// fakeobd imitates the openbazaard command line closely enough for mason
+// to build, initialize, start and stop it without the network. It accepts
+// `-v`, `init` and `start`, writes a realistic config and serves a minimal
+// JSON API gateway.
+//
+// Failures are injected with environment variables:
+//
+//	FAKEOBD_CRASH_AFTER=5s   exit with FAKEOBD_EXIT_CODE (default 1) after the gateway has served this long
+//	FAKEOBD_HANG=init|start  never complete the command and ignore interrupts
+//	FAKEOBD_MIGRATION_ERROR  log this reason as a failed repo migration on start
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/OpenBazaar/mason/builder/runner"
+	"github.com/jessevdk/go-flags"
+	"github.com/op/go-logging"
+)
+
+// version is set at build time with -ldflags "-X main.version=..."
+var version = "0.13.8"
+
+type opts struct {
+	Version bool `short:"v" long:"version" description:"print the version"`
+
+	*initCommand  `command:"init" description:"initialize a new data directory"`
+	*startCommand `command:"start" description:"start the node"`
+}
+
+var log = logging.MustGetLogger("core")
+
+func main() {
+	logging.SetBackend(logging.NewBackendFormatter(
+		logging.NewLogBackend(os.Stdout, "", 0),
+		logging.MustStringFormatter(`%{time:15:04:05.000} [%{level}] [%{module}/%{shortfunc}] %{message}`),
+	))
+
+	if len(os.Args) == 2 && (os.Args[1] == "-v" || os.Args[1] == "--version") {
+		fmt.Println(strings.TrimPrefix(version, "v"), runner.FakeDaemonTag)
+		return
+	}
+
+	var options opts
+	var parser = flags.NewParser(&options, flags.Default|flags.IgnoreUnknown)
+	if _, err := parser.Parse(); err != nil {
+		if flagsErr, ok := err.(*flags.Error); ok && flagsErr.Type == flags.ErrHelp {
+			os.Exit(0)
+		}
+		os.Exit(1)
+	}
+}
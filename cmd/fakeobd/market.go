@@ -0,0 +1,215 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	listingsFilename  = "root/listings.json"
+	followingFilename = "root/following.json"
+	chatFilename      = "root/chat.json"
+)
+
+// listing is a listing published by the node. The hash is derived from
+// its contents in place of an IPFS hash.
+type listing struct {
+	Slug    string          `json:"slug"`
+	Hash    string          `json:"hash"`
+	Title   string          `json:"title"`
+	Listing json.RawMessage `json:"listing"`
+}
+
+// chatMessage is a message sent by the node. Messages are not delivered
+// to other nodes.
+type chatMessage struct {
+	MessageID string    `json:"messageId"`
+	PeerID    string    `json:"peerId"`
+	Subject   string    `json:"subject"`
+	Message   string    `json:"message"`
+	Timestamp time.Time `json:"timestamp"`
+	Outgoing  bool      `json:"outgoing"`
+}
+
+// loadMarket reads the listings, follows and chat messages persisted
+// within the data directory
+func (g *gateway) loadMarket() error {
+	for name, v := range map[string]interface{}{
+		listingsFilename:  &g.listings,
+		followingFilename: &g.following,
+		chatFilename:      &g.messages,
+	} {
+		b, err := ioutil.ReadFile(filepath.Join(g.dataDir, name))
+		if err != nil {
+			continue
+		}
+		if err := json.Unmarshal(b, v); err != nil {
+			return fmt.Errorf("parsing %s: %s", name, err.Error())
+		}
+	}
+	return nil
+}
+
+// persist writes v as JSON within the data directory
+func (g *gateway) persist(name string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return g.writeRepoFile(name, b)
+}
+
+func (g *gateway) serveListing(w http.ResponseWriter, r *http.Request) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if slug := strings.TrimPrefix(r.URL.Path, "/ob/listing/"); slug != r.URL.Path {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		for _, l := range g.listings {
+			if l.Slug == slug {
+				writeJSON(w, map[string]interface{}{"listing": l.Listing, "hash": l.Hash})
+				return
+			}
+		}
+		writeError(w, http.StatusNotFound, "listing not found")
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var body struct {
+		Slug string `json:"slug"`
+		Item struct {
+			Title string `json:"title"`
+		} `json:"item"`
+	}
+	if err := json.Unmarshal(b, &body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var slug = body.Slug
+	if slug == "" {
+		slug = strings.ToLower(strings.Join(strings.Fields(body.Item.Title), "-"))
+	}
+	if slug == "" {
+		writeError(w, http.StatusBadRequest, "listing requires a slug or title")
+		return
+	}
+	for _, l := range g.listings {
+		if l.Slug == slug {
+			writeError(w, http.StatusConflict, "listing already exists")
+			return
+		}
+	}
+	var sum = sha256.Sum256(b)
+	var listings = append(g.listings, listing{
+		Slug:    slug,
+		Hash:    fmt.Sprintf("Qm%x", sum[:22]),
+		Title:   body.Item.Title,
+		Listing: b,
+	})
+	if err := g.persist(listingsFilename, listings); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	g.listings = listings
+	writeJSON(w, map[string]interface{}{"slug": slug})
+}
+
+func (g *gateway) serveListings(w http.ResponseWriter, r *http.Request) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	var index = make([]map[string]interface{}, len(g.listings))
+	for i, l := range g.listings {
+		index[i] = map[string]interface{}{"slug": l.Slug, "hash": l.Hash, "title": l.Title}
+	}
+	writeJSON(w, index)
+}
+
+func (g *gateway) serveFollow(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	var body struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.ID == "" {
+		writeError(w, http.StatusBadRequest, "follow requires an id")
+		return
+	}
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	for _, id := range g.following {
+		if id == body.ID {
+			writeJSON(w, map[string]interface{}{})
+			return
+		}
+	}
+	var following = append(g.following, body.ID)
+	if err := g.persist(followingFilename, following); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	g.following = following
+	writeJSON(w, map[string]interface{}{})
+}
+
+func (g *gateway) serveFollowing(w http.ResponseWriter, r *http.Request) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	writeJSON(w, append([]string{}, g.following...))
+}
+
+func (g *gateway) serveChat(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	var m chatMessage
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil || m.PeerID == "" {
+		writeError(w, http.StatusBadRequest, "chat requires a peerId")
+		return
+	}
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	var sum = sha256.Sum256([]byte(fmt.Sprintf("%d/%s/%s/%s", len(g.messages), m.PeerID, m.Subject, m.Message)))
+	m.MessageID = fmt.Sprintf("Qm%x", sum[:22])
+	m.Timestamp = now().UTC().Truncate(time.Second)
+	m.Outgoing = true
+	var messages = append(g.messages, m)
+	if err := g.persist(chatFilename, messages); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	g.messages = messages
+	writeJSON(w, map[string]interface{}{"messageId": m.MessageID})
+}
+
+func (g *gateway) serveChatMessages(w http.ResponseWriter, r *http.Request) {
+	var peerID = strings.TrimPrefix(r.URL.Path, "/ob/chatmessages/")
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	var messages = []chatMessage{}
+	for _, m := range g.messages {
+		if m.PeerID == peerID {
+			messages = append(messages, m)
+		}
+	}
+	writeJSON(w, messages)
+}
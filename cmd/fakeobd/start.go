@@ -0,0 +1,305 @@
+package main
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+type startCommand struct {
+	DataDir string `short:"d" long:"datadir" description:"data directory"`
+	Testnet bool   `short:"t" long:"testnet" description:"use the test network"`
+}
+
+// nodeConfig is the subset of the config used by the fake daemon
+type nodeConfig struct {
+	Addresses struct {
+		Gateway string
+		Swarm   []string
+	}
+	Bootstrap []string
+	Identity  struct {
+		PeerID string
+	}
+	JSONAPI struct {
+		Authenticated bool
+		Username      string
+		Password      string
+	} `json:"JSON-API"`
+	Wallet  walletConfig
+	Wallets map[string]walletConfig
+}
+
+type walletConfig struct {
+	Type    string
+	MockAPI string
+}
+
+func (c *startCommand) Execute([]string) error {
+	hangIf("start")
+	var dataDir = dataDirOrDefault(c.DataDir, c.Testnet)
+	if _, err := os.Stat(filepath.Join(dataDir, configFilename)); os.IsNotExist(err) {
+		log.Infof("initializing repo at %s", dataDir)
+		if err := initRepo(dataDir, ""); err != nil {
+			return err
+		}
+	}
+	config, err := readConfig(dataDir)
+	if err != nil {
+		return err
+	}
+
+	listener, err := listenMultiaddr(config.Addresses.Gateway)
+	if err != nil {
+		return fmt.Errorf("starting gateway: %s", err.Error())
+	}
+	log.Infof("fake openbazaard %s starting", strings.TrimPrefix(version, "v"))
+	log.Infof("Peer ID: %s", config.Identity.PeerID)
+	for _, addr := range config.Addresses.Swarm {
+		log.Infof("Swarm listening on %s", addr)
+	}
+	log.Infof("Gateway/API server listening on %s", config.Addresses.Gateway)
+
+	if reason := os.Getenv("FAKEOBD_MIGRATION_ERROR"); reason != "" {
+		log.Errorf("migration to repo version %s failed: %s", repoVersion, reason)
+	}
+
+	var g = &gateway{config: config, dataDir: dataDir, testnet: c.Testnet, shutdown: make(chan struct{})}
+	if b, err := ioutil.ReadFile(filepath.Join(dataDir, profileFilename)); err == nil {
+		g.profile = b
+	}
+	if err := g.loadMarket(); err != nil {
+		return err
+	}
+	go http.Serve(listener, g.handler())
+
+	var interrupted = make(chan os.Signal, 1)
+	signal.Notify(interrupted, syscall.SIGINT, syscall.SIGTERM)
+	var crash <-chan time.Time
+	if after := os.Getenv("FAKEOBD_CRASH_AFTER"); after != "" {
+		d, err := time.ParseDuration(after)
+		if err != nil {
+			return fmt.Errorf("invalid FAKEOBD_CRASH_AFTER: %s", err.Error())
+		}
+		crash = time.After(d)
+	}
+
+	select {
+	case <-interrupted:
+		log.Noticef("OpenBazaar shutting down...")
+	case <-g.shutdown:
+		log.Noticef("OpenBazaar shutting down...")
+	case <-crash:
+		log.Critical("fake crash after %s", os.Getenv("FAKEOBD_CRASH_AFTER"))
+		var code = 1
+		if s := os.Getenv("FAKEOBD_EXIT_CODE"); s != "" {
+			if code, err = strconv.Atoi(s); err != nil {
+				return fmt.Errorf("invalid FAKEOBD_EXIT_CODE: %s", err.Error())
+			}
+		}
+		os.Exit(code)
+	}
+	return nil
+}
+
+func readConfig(dataDir string) (nodeConfig, error) {
+	var config nodeConfig
+	b, err := ioutil.ReadFile(filepath.Join(dataDir, configFilename))
+	if err != nil {
+		return config, fmt.Errorf("reading config: %s", err.Error())
+	}
+	if err := json.Unmarshal(b, &config); err != nil {
+		return config, fmt.Errorf("parsing config: %s", err.Error())
+	}
+	return config, nil
+}
+
+// listenMultiaddr listens on a /ip4/<host>/tcp/<port> style address
+func listenMultiaddr(maddr string) (net.Listener, error) {
+	var parts = strings.Split(strings.Trim(maddr, "/"), "/")
+	if len(parts) < 4 || (parts[0] != "ip4" && parts[0] != "ip6") || parts[2] != "tcp" {
+		return nil, fmt.Errorf("unsupported multiaddr (%s)", maddr)
+	}
+	return net.Listen("tcp", net.JoinHostPort(parts[1], parts[3]))
+}
+
+// hangIf blocks forever, ignoring interrupts, when FAKEOBD_HANG names the
+// command being run
+func hangIf(command string) {
+	if os.Getenv("FAKEOBD_HANG") != command {
+		return
+	}
+	signal.Ignore(syscall.SIGINT, syscall.SIGTERM)
+	log.Warningf("hanging during %s", command)
+	select {}
+}
+
+// gateway serves the parts of the JSON API which mason relies on
+type gateway struct {
+	config  nodeConfig
+	dataDir string
+	testnet bool
+
+	mutex        sync.Mutex
+	profile      json.RawMessage
+	listings     []listing
+	following    []string
+	messages     []chatMessage
+	shutdownOnce sync.Once
+	shutdown     chan struct{}
+}
+
+func (g *gateway) handler() http.Handler {
+	var mux = http.NewServeMux()
+	mux.HandleFunc("/ob/config", g.serveConfig)
+	mux.HandleFunc("/ob/peers", g.servePeers)
+	mux.HandleFunc("/ob/profile", g.serveProfile)
+	mux.HandleFunc("/ob/listing", g.serveListing)
+	mux.HandleFunc("/ob/listing/", g.serveListing)
+	mux.HandleFunc("/ob/listings", g.serveListings)
+	mux.HandleFunc("/ob/follow", g.serveFollow)
+	mux.HandleFunc("/ob/following", g.serveFollowing)
+	mux.HandleFunc("/ob/chat", g.serveChat)
+	mux.HandleFunc("/ob/chatmessages/", g.serveChatMessages)
+	mux.HandleFunc("/ob/shutdown", g.serveShutdown)
+	mux.HandleFunc("/wallet/address", g.serveWalletAddress)
+	mux.HandleFunc("/wallet/address/", g.serveWalletAddress)
+	mux.HandleFunc("/wallet/balance", g.serveWalletBalance)
+	mux.HandleFunc("/wallet/balance/", g.serveWalletBalance)
+	mux.HandleFunc("/wallet/spend", g.serveWalletSpend)
+	mux.HandleFunc("/ws", g.serveWebsocket)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !g.authorized(r) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
+			writeError(w, http.StatusUnauthorized, "not authorized")
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// authorized checks basic auth credentials against the config, which
+// holds the hex sha256 of the password
+func (g *gateway) authorized(r *http.Request) bool {
+	if !g.config.JSONAPI.Authenticated {
+		return true
+	}
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+	var hash = sha256.Sum256([]byte(password))
+	return username == g.config.JSONAPI.Username && hex.EncodeToString(hash[:]) == g.config.JSONAPI.Password
+}
+
+func (g *gateway) serveConfig(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]interface{}{
+		"peerID":         g.config.Identity.PeerID,
+		"cryptoCurrency": "BTC",
+		"testnet":        g.testnet,
+		"tor":            false,
+	})
+}
+
+func (g *gateway) servePeers(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, []string{})
+}
+
+func (g *gateway) serveProfile(w http.ResponseWriter, r *http.Request) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	switch r.Method {
+	case http.MethodGet:
+		if g.profile == nil {
+			writeError(w, http.StatusNotFound, "profile not found")
+			return
+		}
+		w.Write(g.profile)
+	case http.MethodPost, http.MethodPut:
+		if r.Method == http.MethodPost && g.profile != nil {
+			writeError(w, http.StatusConflict, "profile already exists, use PUT")
+			return
+		}
+		var profile map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&profile); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		profile["peerID"] = g.config.Identity.PeerID
+		b, _ := json.Marshal(profile)
+		if err := g.writeRepoFile(profileFilename, b); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		g.profile = b
+		w.Write(b)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// writeRepoFile persists data within the data directory so it survives
+// restarts and upgrades
+func (g *gateway) writeRepoFile(name string, b []byte) error {
+	var path = filepath.Join(g.dataDir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0644)
+}
+
+func (g *gateway) serveShutdown(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	writeJSON(w, map[string]interface{}{})
+	g.shutdownOnce.Do(func() { close(g.shutdown) })
+}
+
+// serveWebsocket completes the websocket handshake and holds the
+// connection open without sending any events
+func (g *gateway) serveWebsocket(w http.ResponseWriter, r *http.Request) {
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "websocket unsupported")
+		return
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	var accept = sha1.Sum([]byte(r.Header.Get("Sec-WebSocket-Key") + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+		base64.StdEncoding.EncodeToString(accept[:]))
+	if err := rw.Flush(); err != nil {
+		return
+	}
+	ioutil.ReadAll(conn)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, reason string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "reason": reason})
+}
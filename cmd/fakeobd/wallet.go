@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// mockWalletType has the wallet use the ledger at MockAPI, served by
+// mason's regtest.Mock
+const mockWalletType = "mock"
+
+var walletClient = &http.Client{Timeout: 10 * time.Second}
+
+// walletAddress derives the node's only receiving address from its peer
+// ID
+func (g *gateway) walletAddress() string {
+	var sum = sha256.Sum256([]byte(g.config.Identity.PeerID))
+	return "mock1" + hex.EncodeToString(sum[:16])
+}
+
+// mockLedger returns the URL of the mock ledger, or writes an error when
+// no wallet uses one. Multiwallet configs use the ledger of the first
+// currency configured with it.
+func (g *gateway) mockLedger(w http.ResponseWriter) (string, bool) {
+	var wallets = []walletConfig{g.config.Wallet}
+	var coins = make([]string, 0, len(g.config.Wallets))
+	for coin := range g.config.Wallets {
+		coins = append(coins, coin)
+	}
+	sort.Strings(coins)
+	for _, coin := range coins {
+		wallets = append(wallets, g.config.Wallets[coin])
+	}
+	for _, wallet := range wallets {
+		if wallet.Type == mockWalletType && wallet.MockAPI != "" {
+			return wallet.MockAPI, true
+		}
+	}
+	writeError(w, http.StatusInternalServerError, "wallet backend is not available")
+	return "", false
+}
+
+func (g *gateway) serveWalletAddress(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]interface{}{"address": g.walletAddress()})
+}
+
+func (g *gateway) serveWalletBalance(w http.ResponseWriter, r *http.Request) {
+	ledger, ok := g.mockLedger(w)
+	if !ok {
+		return
+	}
+	var balance struct {
+		Confirmed   int64 `json:"confirmed"`
+		Unconfirmed int64 `json:"unconfirmed"`
+		Height      int   `json:"height"`
+	}
+	if err := ledgerRequest("GET", ledger+"/address/"+g.walletAddress(), nil, &balance); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, balance)
+}
+
+func (g *gateway) serveWalletSpend(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	ledger, ok := g.mockLedger(w)
+	if !ok {
+		return
+	}
+	// amounts are sent as numbers or, by newer clients, strings
+	var req struct {
+		Address string      `json:"address"`
+		Amount  json.Number `json:"amount"`
+		Memo    string      `json:"memo"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	amount, err := strconv.ParseInt(req.Amount.String(), 10, 64)
+	if err != nil || amount <= 0 {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid amount (%s)", req.Amount))
+		return
+	}
+	var spent struct {
+		TxID string `json:"txid"`
+	}
+	var body, _ = json.Marshal(map[string]interface{}{"from": g.walletAddress(), "to": req.Address, "amount": amount})
+	if err := ledgerRequest("POST", ledger+"/spend", body, &spent); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	log.Infof("spent %d to %s in %s", amount, req.Address, spent.TxID)
+	writeJSON(w, map[string]interface{}{
+		"txid":      spent.TxID,
+		"amount":    amount,
+		"timestamp": now().UTC().Format(time.RFC3339),
+		"memo":      req.Memo,
+	})
+}
+
+// ledgerRequest calls the mock ledger, decoding its response into v
+func ledgerRequest(method, url string, body []byte, v interface{}) error {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp, err := walletClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("requesting wallet backend: %s", err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var failure struct{ Error string }
+		json.NewDecoder(resp.Body).Decode(&failure)
+		return fmt.Errorf("wallet backend: %s", failure.Error)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -9,8 +9,13 @@ import (
 )
 
 type opts struct {
-	*subcommands.PrepareCommand `command:"prepare" alias:"p" description:"Prepare a cached version of openbazaar-go" long-description:"Build and cache the specified version of openbazaar-go on the local machine. This ensures future executions do not require building this version again."`
-	*subcommands.StartCommand   `command:"start" alias:"s" description:"Start a version of openbazaar-go" long-description:"Start a version of openbazaar-go which has been cached, or attempt to build it and then start it."`
+	*subcommands.InitCommand        `command:"init" alias:"i" description:"Initialize a node data directory" long-description:"Build or fetch the specified version of openbazaar-go and use it to initialize a node in the data directory, optionally from a fixed seed mnemonic. Existing nodes are left untouched unless --force-init is given."`
+	*subcommands.ConfigCommand      `command:"config" description:"Read and edit a node's config" long-description:"Get, set, unset or merge values in the config of the node in a data directory. Keys are dot-separated paths (ex: Addresses.Gateway) and values are parsed as JSON where possible. Changes are only written if the resulting config still loads."`
+	*subcommands.PrepareCommand     `command:"prepare" alias:"p" description:"Prepare a cached version of openbazaar-go" long-description:"Build and cache the specified version of openbazaar-go on the local machine. This ensures future executions do not require building this version again."`
+	*subcommands.BisectCommand      `command:"bisect" alias:"b" description:"Find the openbazaar-go commit which broke a check" long-description:"Bisect the commits between a good and bad git reference. Each candidate is built and cached, started on a copy of the data path, and judged by the check command: exit 0 is good, 125 skips the commit and anything else is bad. The check receives MASON_COMMIT, MASON_GATEWAY and MASON_DATA_PATH in its environment."`
+	*subcommands.CacheCommand       `command:"cache" alias:"c" description:"Inspect and manage cached versions of openbazaar-go" long-description:"List, describe, remove, prune and verify the binaries cached by prepare and start. Each version shows the commit it was built from, its size, when it was cached and when it was last used. Pass --json for machine-readable output."`
+	*subcommands.UpgradeTestCommand `command:"upgrade-test" alias:"u" description:"Check a data directory survives an upgrade between versions" long-description:"Prepare a node on the old version, from a copy of the data path or a new identity, and optionally populate it with a scenario. The stopped node is snapshotted and the new version is started on a copy of the snapshot. JSON API responses from both versions are compared and any errors logged by the new version are reported. Exits non-zero if anything changed."`
+	*subcommands.StartCommand       `command:"start" alias:"s" description:"Start a version of openbazaar-go" long-description:"Start a version of openbazaar-go which has been cached, or attempt to build it and then start it."`
 }
 
 func getStdoutBackend() logging.Backend {
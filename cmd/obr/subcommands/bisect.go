@@ -0,0 +1,148 @@
+package subcommands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/OpenBazaar/mason/builder"
+	"github.com/OpenBazaar/mason/builder/blueprints"
+	"github.com/OpenBazaar/mason/util"
+	"github.com/op/go-logging"
+	"github.com/otiai10/copy"
+	shell "github.com/placer14/go-shell"
+)
+
+// checkSkipExitCode is returned by a check which cannot judge a
+// candidate, following the convention of `git bisect run`
+const checkSkipExitCode = 125
+
+type BisectCommand struct {
+	DataPath     string        `short:"d" long:"data-path" description:"data directory copied for each candidate, a fresh node is used when omitted"`
+	Testnet      bool          `short:"t" long:"testnet" description:"start each candidate in testnet mode"`
+	MirrorPath   string        `long:"mirror" description:"openbazaar-go checkout to bisect, created if missing (default: $HOME/.mason/bisect)"`
+	ReadyTimeout time.Duration `long:"ready-timeout" default:"2m" description:"how long each candidate has to become ready"`
+	SkipUnready  bool          `long:"skip-unready" description:"skip candidates which do not become ready instead of marking them bad"`
+
+	Args struct {
+		Good  string   `description:"git reference known to pass the check" positional-arg-name:"good"`
+		Bad   string   `description:"git reference known to fail the check" positional-arg-name:"bad"`
+		Check []string `description:"command run against each started candidate" positional-arg-name:"check" required:"1"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func (c *BisectCommand) Execute(args []string) error {
+	var log = logging.MustGetLogger("bisect")
+
+	var mirrorPath = c.MirrorPath
+	if mirrorPath == "" {
+		mirrorPath = filepath.Join(os.Getenv("HOME"), ".mason", "bisect")
+	}
+	mirror, err := blueprints.InflateOpenBazaarDaemon(mirrorPath)
+	if err != nil {
+		return fmt.Errorf("preparing mirror: %s", err.Error())
+	}
+	if err := mirror.Fetch(); err != nil {
+		return err
+	}
+	good, err := mirror.ResolveCommit(c.Args.Good)
+	if err != nil {
+		return err
+	}
+	bad, err := mirror.ResolveCommit(c.Args.Bad)
+	if err != nil {
+		return err
+	}
+
+	status, err := mirror.BisectStart(good, bad)
+	if err != nil {
+		return err
+	}
+	defer mirror.BisectReset()
+
+	for status.FirstBad == "" {
+		var verdict = c.test(mirror, status.Candidate)
+		log.Infof("marking %s %s", status.Candidate, verdict)
+		if status, err = mirror.BisectMark(status.Candidate, verdict); err != nil {
+			return err
+		}
+	}
+
+	description, err := mirror.Describe(status.FirstBad)
+	if err != nil {
+		description = status.FirstBad
+	}
+	log.Infof("first bad commit is %s", description)
+	fmt.Println(status.FirstBad)
+	return nil
+}
+
+// test builds and starts the candidate on a copy of the data path and
+// judges it with the check command
+func (c *BisectCommand) test(mirror *blueprints.OpenBazaarSource, candidate string) blueprints.BisectVerdict {
+	var log = logging.MustGetLogger("bisect")
+
+	var obBuilder = builder.NewOpenBazaarDaemon("bisect", candidate)
+	obBuilder.SetSource(mirror.SourcePath())
+	defer obBuilder.MustClean()
+	obProc, err := obBuilder.Build()
+	if err != nil {
+		log.Warningf("skipping %s which did not build: %s", candidate, err.Error())
+		return blueprints.BisectSkip
+	}
+	defer obProc.Cleanup()
+
+	var dataPath = util.GenerateTempPath("bisect_data")
+	defer os.RemoveAll(dataPath)
+	if c.DataPath != "" {
+		if err := copy.Copy(c.DataPath, dataPath); err != nil {
+			log.Warningf("skipping %s, copying data path: %s", candidate, err.Error())
+			return blueprints.BisectSkip
+		}
+	}
+	obProc.SetCustomDataPath(dataPath)
+	obProc.SetTestnetMode(c.Testnet)
+
+	go logNodeOutput(obProc.Subscribe(0), candidate[:7])
+
+	var unready = blueprints.BisectBad
+	if c.SkipUnready {
+		unready = blueprints.BisectSkip
+	}
+	if err := obProc.AsyncStart(); err != nil {
+		log.Warningf("%s did not start: %s", candidate, err.Error())
+		return unready
+	}
+	defer obProc.Kill()
+	var ctx, cancel = context.WithTimeout(context.Background(), c.ReadyTimeout)
+	defer cancel()
+	if err := obProc.WaitForReady(ctx); err != nil {
+		log.Warningf("%s was not ready: %s", candidate, err.Error())
+		return unready
+	}
+	gateway, err := obProc.GatewayAddress()
+	if err != nil {
+		log.Warningf("skipping %s, finding gateway: %s", candidate, err.Error())
+		return blueprints.BisectSkip
+	}
+
+	var cmd = []interface{}{
+		"env",
+		shell.Quote("MASON_COMMIT=" + candidate),
+		shell.Quote("MASON_GATEWAY=" + gateway),
+		shell.Quote("MASON_DATA_PATH=" + dataPath),
+	}
+	for _, a := range c.Args.Check {
+		cmd = append(cmd, shell.Quote(a))
+	}
+	var proc = shell.Cmd(cmd...).Tee(os.Stdout).Run()
+	switch proc.ExitStatus {
+	case 0:
+		return blueprints.BisectGood
+	case checkSkipExitCode:
+		return blueprints.BisectSkip
+	}
+	return blueprints.BisectBad
+}
@@ -0,0 +1,245 @@
+package subcommands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/OpenBazaar/mason/builder"
+	"github.com/OpenBazaar/mason/builder/cacher"
+)
+
+type CacheCommand struct {
+	List   CacheListCommand   `command:"list" alias:"ls" description:"List cached versions"`
+	Info   CacheInfoCommand   `command:"info" description:"Describe a cached version"`
+	Remove CacheRemoveCommand `command:"rm" description:"Remove cached versions"`
+	Prune  CachePruneCommand  `command:"prune" description:"Remove versions which have not been used recently or whose binary is missing"`
+	Verify CacheVerifyCommand `command:"verify" description:"Check every cached binary exists and matches its checksum"`
+}
+
+// cacheManager is the part of the cacher used to inspect and manage
+// cached versions
+type cacheManager interface {
+	Entries() []cacher.Entry
+	Info(store, version string) (cacher.Entry, error)
+	Remove(store, version string) error
+	Prune(cutoff time.Time, dryRun bool) ([]cacher.Entry, error)
+	Verify() []cacher.VerifyResult
+}
+
+// cacheOptions are shared by every cache subcommand
+type cacheOptions struct {
+	CachePath string `long:"cache-path" description:"cache to operate on (default: $HOME/.mason/cache)"`
+	JSON      bool   `long:"json" description:"write JSON instead of a table"`
+}
+
+func (o cacheOptions) open() (cacheManager, error) {
+	var path = o.CachePath
+	if path == "" {
+		path = builder.DefaultCachePath()
+	}
+	c, err := cacher.OpenOrCreate(path)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// write encodes v as JSON when requested, otherwise calls table
+func (o cacheOptions) write(v interface{}, table func(w io.Writer)) error {
+	if o.JSON {
+		var encoder = json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	}
+	var w = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	table(w)
+	return w.Flush()
+}
+
+type CacheListCommand struct {
+	cacheOptions
+	Store string `long:"store" description:"only list versions in this store (ex: openbazaard, fakeobd)"`
+}
+
+func (c *CacheListCommand) Execute(args []string) error {
+	cache, err := c.open()
+	if err != nil {
+		return err
+	}
+	var entries = []cacher.Entry{}
+	for _, e := range cache.Entries() {
+		if c.Store == "" || e.Store == c.Store {
+			entries = append(entries, e)
+		}
+	}
+	return c.write(entries, func(w io.Writer) { writeEntryTable(w, entries) })
+}
+
+type CacheInfoCommand struct {
+	cacheOptions
+	Store string `long:"store" default:"openbazaard" description:"store holding the version"`
+
+	Args struct {
+		Version string `description:"cached version to describe" positional-arg-name:"version"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func (c *CacheInfoCommand) Execute(args []string) error {
+	cache, err := c.open()
+	if err != nil {
+		return err
+	}
+	e, err := cache.Info(c.Store, c.Args.Version)
+	if err != nil {
+		return fmt.Errorf("finding version (%s): %s", c.Args.Version, err.Error())
+	}
+	return c.write(e, func(w io.Writer) {
+		fmt.Fprintf(w, "Store:\t%s\n", e.Store)
+		fmt.Fprintf(w, "Version:\t%s\n", e.Version)
+		fmt.Fprintf(w, "Commit:\t%s\n", orUnknown(e.Commit))
+		fmt.Fprintf(w, "Path:\t%s\n", e.Path)
+		fmt.Fprintf(w, "Size:\t%s\n", formatSize(e.Size))
+		fmt.Fprintf(w, "Checksum:\t%s\n", orUnknown(e.Checksum))
+		fmt.Fprintf(w, "Cached:\t%s (%s)\n", formatTime(e.Cached), formatAge(e.Cached))
+		fmt.Fprintf(w, "Last used:\t%s (%s)\n", formatTime(e.LastUsed), formatAge(e.LastUsed))
+	})
+}
+
+type CacheRemoveCommand struct {
+	cacheOptions
+	Store string `long:"store" default:"openbazaard" description:"store holding the versions"`
+
+	Args struct {
+		Versions []string `description:"cached versions to remove" positional-arg-name:"version" required:"1"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func (c *CacheRemoveCommand) Execute(args []string) error {
+	cache, err := c.open()
+	if err != nil {
+		return err
+	}
+	for _, version := range c.Args.Versions {
+		if err := cache.Remove(c.Store, version); err != nil {
+			return fmt.Errorf("removing version (%s): %s", version, err.Error())
+		}
+		if !c.JSON {
+			fmt.Printf("removed %s\n", version)
+		}
+	}
+	if c.JSON {
+		return c.write(c.Args.Versions, nil)
+	}
+	return nil
+}
+
+type CachePruneCommand struct {
+	cacheOptions
+	UnusedFor time.Duration `long:"unused-for" default:"720h" description:"remove versions which have not been used for this long"`
+	DryRun    bool          `long:"dry-run" description:"list the versions which would be removed without removing them"`
+}
+
+func (c *CachePruneCommand) Execute(args []string) error {
+	cache, err := c.open()
+	if err != nil {
+		return err
+	}
+	pruned, err := cache.Prune(time.Now().Add(-c.UnusedFor), c.DryRun)
+	if pruned == nil {
+		pruned = []cacher.Entry{}
+	}
+	if writeErr := c.write(pruned, func(w io.Writer) { writeEntryTable(w, pruned) }); writeErr != nil && err == nil {
+		err = writeErr
+	}
+	return err
+}
+
+type CacheVerifyCommand struct {
+	cacheOptions
+}
+
+func (c *CacheVerifyCommand) Execute(args []string) error {
+	cache, err := c.open()
+	if err != nil {
+		return err
+	}
+	var failed = cache.Verify()
+	if failed == nil {
+		failed = []cacher.VerifyResult{}
+	}
+	err = c.write(failed, func(w io.Writer) {
+		fmt.Fprintln(w, "STORE\tVERSION\tPROBLEM")
+		for _, f := range failed {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", f.Store, f.Version, f.Problem)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d cached versions failed verification", len(failed))
+	}
+	return nil
+}
+
+func writeEntryTable(w io.Writer, entries []cacher.Entry) {
+	fmt.Fprintln(w, "STORE\tVERSION\tCOMMIT\tSIZE\tAGE\tLAST USED")
+	for _, e := range entries {
+		var commit = orUnknown(e.Commit)
+		if len(commit) > 10 {
+			commit = commit[:10]
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", e.Store, e.Version, commit, formatSize(e.Size), formatAge(e.Cached), formatAge(e.LastUsed))
+	}
+}
+
+func orUnknown(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func formatSize(n int64) string {
+	if n < 0 {
+		return "missing"
+	}
+	var units = []string{"B", "KB", "MB", "GB"}
+	var size = float64(n)
+	var i int
+	for size >= 1024 && i < len(units)-1 {
+		size /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%d B", n)
+	}
+	return fmt.Sprintf("%.1f %s", size, units[i])
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
+
+func formatAge(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	var d = time.Since(t)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d/time.Minute))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d/time.Hour))
+	}
+	return fmt.Sprintf("%dd ago", int(d/(24*time.Hour)))
+}
@@ -0,0 +1,130 @@
+package subcommands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/OpenBazaar/mason/builder/runner"
+)
+
+type ConfigCommand struct {
+	Get   ConfigGetCommand   `command:"get" description:"Print a config value"`
+	Set   ConfigSetCommand   `command:"set" description:"Set a config value"`
+	Unset ConfigUnsetCommand `command:"unset" description:"Remove a config value"`
+	Patch ConfigPatchCommand `command:"patch" description:"Merge a JSON object into the config"`
+}
+
+// configOptions are shared by every config subcommand
+type configOptions struct {
+	DataPath string `short:"d" long:"data-path" required:"true" description:"data directory of the node to configure"`
+}
+
+func (o configOptions) open() (*runner.OpenBazaarRunner, error) {
+	return runner.FromDataPath(o.DataPath)
+}
+
+type ConfigGetCommand struct {
+	configOptions
+
+	Args struct {
+		Key string `description:"dot-separated config key (ex: Addresses.Gateway)" positional-arg-name:"key"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func (c *ConfigGetCommand) Execute(args []string) error {
+	r, err := c.open()
+	if err != nil {
+		return err
+	}
+	value, err := r.ConfigValue(c.Args.Key)
+	if err != nil {
+		return err
+	}
+	if s, ok := value.(string); ok {
+		fmt.Println(s)
+		return nil
+	}
+	b, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling value: %s", err.Error())
+	}
+	fmt.Println(string(b))
+	return nil
+}
+
+type ConfigSetCommand struct {
+	configOptions
+
+	Args struct {
+		Key   string `description:"dot-separated config key, missing objects are created" positional-arg-name:"key"`
+		Value string `description:"value parsed as JSON, or used as a string when it is not valid JSON" positional-arg-name:"value"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func (c *ConfigSetCommand) Execute(args []string) error {
+	r, err := c.open()
+	if err != nil {
+		return err
+	}
+	return r.PutConfigValue(c.Args.Key, parseConfigValue(c.Args.Value))
+}
+
+type ConfigUnsetCommand struct {
+	configOptions
+
+	Args struct {
+		Key string `description:"dot-separated config key" positional-arg-name:"key"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func (c *ConfigUnsetCommand) Execute(args []string) error {
+	r, err := c.open()
+	if err != nil {
+		return err
+	}
+	return r.UnsetConfigValue(c.Args.Key)
+}
+
+type ConfigPatchCommand struct {
+	configOptions
+
+	Args struct {
+		Patch string `description:"JSON merge patch, @file to read it from a file or - to read it from stdin" positional-arg-name:"patch"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func (c *ConfigPatchCommand) Execute(args []string) error {
+	r, err := c.open()
+	if err != nil {
+		return err
+	}
+	var b []byte
+	switch {
+	case c.Args.Patch == "-":
+		b, err = ioutil.ReadAll(os.Stdin)
+	case strings.HasPrefix(c.Args.Patch, "@"):
+		b, err = ioutil.ReadFile(c.Args.Patch[1:])
+	default:
+		b = []byte(c.Args.Patch)
+	}
+	if err != nil {
+		return fmt.Errorf("reading patch: %s", err.Error())
+	}
+	var patch map[string]interface{}
+	if err := json.Unmarshal(b, &patch); err != nil {
+		return fmt.Errorf("patch must be a JSON object: %s", err.Error())
+	}
+	return r.PatchConfig(patch)
+}
+
+// parseConfigValue decodes s as JSON, falling back to the string itself
+func parseConfigValue(s string) interface{} {
+	var value interface{}
+	if err := json.Unmarshal([]byte(s), &value); err != nil {
+		return s
+	}
+	return value
+}
@@ -0,0 +1,67 @@
+package subcommands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/OpenBazaar/mason/builder"
+	"github.com/op/go-logging"
+)
+
+type InitCommand struct {
+	identityOptions
+	DataPath string `short:"d" long:"data-path" required:"true" description:"directory to initialize the node in"`
+	Testnet  bool   `short:"t" long:"testnet" description:"initialize the node in testnet mode"`
+
+	Args struct {
+		Version string `description:"git reference of the openbazaar-go version used to initialize" positional-arg-name:"version" required:"true"`
+	} `positional-args:"true"`
+}
+
+func (c *InitCommand) Execute(args []string) error {
+	var log = logging.MustGetLogger("")
+
+	opts, err := c.initOptions()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(c.DataPath, "config")); err == nil {
+		if !opts.Force {
+			return fmt.Errorf("node at (%s) is already initialized, use --force-init to overwrite its identity", c.DataPath)
+		}
+	} else if _, err := os.Stat(c.DataPath); err == nil {
+		// the runner treats an existing directory as initialized, so a
+		// directory without a node must be initialized forcibly
+		opts.Force = true
+	}
+
+	var obBuilder = builder.NewOpenBazaarDaemon(c.Args.Version, c.Args.Version)
+	defer obBuilder.MustClean()
+	obProc, err := obBuilder.Build()
+	if err != nil {
+		return fmt.Errorf("building: %s", err.Error())
+	}
+	defer obProc.Cleanup()
+
+	go logNodeOutput(obProc.Subscribe(0), c.Args.Version)
+
+	obProc.SetCustomDataPath(c.DataPath)
+	obProc.SetTestnetMode(c.Testnet)
+	if err := obProc.SetInitOptions(opts); err != nil {
+		return fmt.Errorf("setting init options: %s", err.Error())
+	}
+	if err := obProc.Init(); err != nil {
+		return fmt.Errorf("initializing: %s", err.Error())
+	}
+	if err := obProc.ValidateConfig(); err != nil {
+		return err
+	}
+
+	peerID, err := obProc.ConfigValue("Identity.PeerID")
+	if err != nil {
+		return fmt.Errorf("reading peer ID: %s", err.Error())
+	}
+	log.Infof("initialized %v at %s", peerID, c.DataPath)
+	return nil
+}
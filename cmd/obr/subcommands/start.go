@@ -1,15 +1,42 @@
 package subcommands
 
 import (
-	"bufio"
 	"fmt"
-	"io"
 
 	"github.com/OpenBazaar/mason/builder"
+	"github.com/OpenBazaar/mason/builder/runner"
 	"github.com/op/go-logging"
 )
 
+// identityOptions control the identity and wallet of initialized nodes
+type identityOptions struct {
+	Mnemonic       string `long:"mnemonic" description:"initialize the node from this seed mnemonic"`
+	WalletCurrency string `long:"wallet-currency" description:"coin of the initialized node's wallet, removing the others from multiwallet releases (ex: BTC, LTC)"`
+	WalletType     string `long:"wallet-type" description:"type of the initialized node's wallet (ex: SPV, API, or before 0.13.0 spvwallet, bitcoind)"`
+	WalletCreated  string `long:"wallet-created" description:"date the mnemonic was created as YYYY-MM-DD or RFC3339"`
+	ForceInit      bool   `long:"force-init" description:"reinitialize an existing node, overwriting its identity"`
+}
+
+func (o identityOptions) initOptions() (runner.InitOptions, error) {
+	var opts = runner.InitOptions{
+		Mnemonic:       o.Mnemonic,
+		WalletCurrency: o.WalletCurrency,
+		WalletType:     o.WalletType,
+		Force:          o.ForceInit,
+	}
+	if o.WalletCreated != "" {
+		created, err := runner.ParseWalletCreationDate(o.WalletCreated)
+		if err != nil {
+			return opts, err
+		}
+		opts.WalletCreationDate = created
+	}
+	return opts, nil
+}
+
 type StartCommand struct {
+	identityOptions
+
 	Args struct {
 		Version     string   `description:"specify the git reference to start" positional-arg-name:"version" required:"true"`
 		StartParams []string `description:"provide params to be passed to daemon on start" positional-arg-name:"start params"`
@@ -31,26 +58,40 @@ func (c *StartCommand) Execute(args []string) error {
 
 	obProc.WithArgs(c.Args.StartParams)
 
-	pr := obProc.SplitOutput()
-	go logNodeOutput(pr, c.Args.Version)
+	go logNodeOutput(obProc.Subscribe(0), c.Args.Version)
 
-	obProc.RunStart()
-	if exit, err := obProc.ExitCodeAndErr(); err != nil {
-		return fmt.Errorf("returned (%d): %s", exit, err.Error())
+	if err := c.initNode(obProc); err != nil {
+		return err
+	}
+
+	err = obProc.RunStart()
+	log.Infof("output was persisted at %s", obProc.RunPath())
+	return err
+}
+
+// initNode initializes the node before start when any init options are
+// provided
+func (c *StartCommand) initNode(r *runner.OpenBazaarRunner) error {
+	opts, err := c.initOptions()
+	if err != nil {
+		return err
+	}
+	if opts == (runner.InitOptions{}) {
+		return nil
+	}
+	if err := r.SetInitOptions(opts); err != nil {
+		return fmt.Errorf("setting init options: %s", err.Error())
+	}
+	if err := r.Init(); err != nil {
+		return fmt.Errorf("initializing: %s", err.Error())
 	}
 	return nil
 }
 
-func logNodeOutput(r io.ReadCloser, prefix string) {
-	defer r.Close()
-	var (
-		nodeLog = logging.MustGetLogger(prefix)
-		scanner = bufio.NewScanner(r)
-	)
-	for scanner.Scan() {
-		nodeLog.Infof(scanner.Text())
-	}
-	if err := scanner.Err(); err != nil {
-		nodeLog.Errorf("error reading node output (%s): %s", fmt.Sprintf("%010s", prefix), err.Error())
+func logNodeOutput(sub *runner.OutputSubscription, prefix string) {
+	defer sub.Close()
+	var nodeLog = logging.MustGetLogger(prefix)
+	for record := range runner.ParseLogs(sub.Lines(), runner.NewLogFilter(logging.DEBUG)) {
+		record.Emit(nodeLog)
 	}
 }
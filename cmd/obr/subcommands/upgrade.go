@@ -0,0 +1,85 @@
+package subcommands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/OpenBazaar/mason/builder/runner"
+	"github.com/OpenBazaar/mason/scenario"
+	"github.com/OpenBazaar/mason/upgrade"
+	"github.com/op/go-logging"
+)
+
+type UpgradeTestCommand struct {
+	SeedPath     string        `short:"d" long:"data-path" description:"data directory to upgrade, copied before use; a new node is initialized when omitted"`
+	Testnet      bool          `short:"t" long:"testnet" description:"start both versions in testnet mode"`
+	Mnemonic     string        `long:"mnemonic" description:"initialize the new node from this seed mnemonic"`
+	Populate     string        `long:"populate" description:"scenario run against the old version before comparing, with the node named \"node\""`
+	Endpoints    []string      `long:"endpoint" description:"JSON API path to compare, may be repeated (default: profile, settings, listings, orders, cases and follows)"`
+	Ignore       []string      `long:"ignore" description:"dot-separated response path expected to change, where * matches any key or index, may be repeated (ex: stats.lastSeen)"`
+	ReadyTimeout time.Duration `long:"ready-timeout" default:"2m" description:"how long each version has to become ready"`
+	StopGrace    time.Duration `long:"stop-grace" default:"30s" description:"how long each version has to shut down before it is killed"`
+	JSON         bool          `long:"json" description:"write the report as JSON instead of text"`
+
+	Args struct {
+		From string `description:"git reference of the version to upgrade from" positional-arg-name:"from"`
+		To   string `description:"git reference of the version to upgrade to" positional-arg-name:"to"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func (c *UpgradeTestCommand) Execute(args []string) error {
+	var log = logging.MustGetLogger("upgrade")
+
+	var opts = upgrade.Options{
+		From:         c.Args.From,
+		To:           c.Args.To,
+		SeedPath:     c.SeedPath,
+		Init:         runner.InitOptions{Mnemonic: c.Mnemonic},
+		Testnet:      c.Testnet,
+		Endpoints:    c.Endpoints,
+		Ignore:       c.Ignore,
+		ReadyTimeout: c.ReadyTimeout,
+		StopGrace:    c.StopGrace,
+	}
+	if c.Populate != "" {
+		s, err := scenario.Load(c.Populate)
+		if err != nil {
+			return err
+		}
+		opts.Populate = &s
+	}
+
+	report, err := upgrade.Run(context.Background(), opts)
+	if err != nil {
+		if report.RunPath != "" {
+			log.Infof("output was persisted at %s", report.RunPath)
+		}
+		return err
+	}
+
+	if c.JSON {
+		err = report.WriteJSON(os.Stdout)
+	} else {
+		err = report.WriteText(os.Stdout)
+	}
+	if err != nil {
+		return fmt.Errorf("printing report: %s", err.Error())
+	}
+	if f, err := os.Create(filepath.Join(report.RunPath, "report.json")); err != nil {
+		log.Errorf("writing report: %s", err.Error())
+	} else {
+		if err := report.WriteJSON(f); err != nil {
+			log.Errorf("writing report: %s", err.Error())
+		}
+		f.Close()
+	}
+	log.Infof("output was persisted at %s", report.RunPath)
+
+	if !report.Passed {
+		return fmt.Errorf("upgrade from %s to %s found %d differences and %d migration errors", c.Args.From, c.Args.To, report.Differences(), len(report.MigrationErrors))
+	}
+	return nil
+}
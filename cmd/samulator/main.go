@@ -1,54 +1,75 @@
 package main
 
 import (
-	"bufio"
+	"context"
 	"fmt"
-	"io"
 	"os"
 	"os/signal"
-	"sync"
+	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
-	"github.com/OpenBazaar/mason/builder"
+	"github.com/OpenBazaar/mason/builder/runner"
+	"github.com/OpenBazaar/mason/fixture"
+	"github.com/OpenBazaar/mason/matrix"
+	"github.com/OpenBazaar/mason/scenario"
+	"github.com/OpenBazaar/mason/simulation"
+	"github.com/OpenBazaar/mason/traffic"
+	"github.com/OpenBazaar/mason/util"
 	"github.com/jessevdk/go-flags"
 	"github.com/op/go-logging"
 	"github.com/placer14/go-shell"
 )
 
 type opts struct {
-	OverridePostmanQAConfig bool `long:"postman-config" description:"override ports for each node to work with postman QA test suite"`
-	EnableTestnet           bool `long:"testnet" short:"t" description:"start with testnet flag"`
+	TopologyPath   string `short:"f" long:"topology" required:"true" description:"path to a JSON topology describing the nodes to run"`
+	Version        string `long:"version" description:"version of nodes which do not specify one in the topology"`
+	FakeDaemon     bool   `long:"fake-daemon" description:"run the fake daemon from cmd/fakeobd instead of openbazaard"`
+	AutoPeer       bool   `long:"auto-peer" description:"bootstrap nodes from each other instead of public peers"`
+	Private        bool   `long:"private-network" description:"isolate nodes from other peers with a shared swarm key"`
+	Wallet         string `long:"wallet" choice:"bitcoind" choice:"mock" description:"run a local chain for the nodes' wallets: bitcoind regtest, or a mock ledger for the fake daemon"`
+	Clock          string `long:"clock" choice:"patch" choice:"libfaketime" description:"give the nodes a clock which advanceTime steps move forward: patch openbazaard's calls to time.Now, and optionally preload libfaketime"`
+	FaultProxy     bool   `long:"fault-proxy" description:"route traffic between nodes through a proxy which scenarios may slow or partition (implies --auto-peer)"`
+	FaultProxyHost string `long:"fault-proxy-host" description:"IPv4 address the fault proxy listens on, defaults to 127.0.0.2 which nodes must not listen on"`
 
-	BuyerConfigPath  string `short:"b" long:"buyer" description:"path to buyer configuration"`
-	VendorConfigPath string `short:"v" long:"vendor" description:"path to vendor configuration"`
-	ModConfigPath    string `short:"m" long:"mod" description:"path to mod configuration"`
+	FixturePaths  []string `long:"fixture" description:"seed nodes with this JSON fixture once they are ready, before any scenarios (may be repeated)"`
+	ScenarioPaths []string `long:"scenario" description:"run this JSON scenario once nodes are ready, then stop the nodes (may be repeated)"`
+	ReplayPaths   []string `long:"replay" description:"replay this recorded API traffic once nodes are ready, after any scenarios (may be repeated)"`
+	ReplayIgnore  []string `long:"replay-ignore" description:"JSON path not compared when replaying, ex: messages.*.timestamp (may be repeated)"`
+	Record        string   `long:"record" choice:"jsonl" choice:"har" description:"record each node's API traffic to its run path (jsonl, har)"`
+	SnapshotPath  string   `long:"snapshot" description:"stop the nodes once seeded and scenarios pass, then copy each node's data path to this directory"`
 
-	Version       string `long:"version" description:"version of buyer, vendor, or mod if each version is not specified"`
-	BuyerVersion  string `long:"bv" description:"set Buyer SHA to build (overrides --version)"`
-	VendorVersion string `long:"vv" description:"set Vendor SHA to build (overrides --version)"`
-	ModVersion    string `long:"mv" description:"set Buyer SHA to build (overrides --version)"`
-}
+	MatrixVersions []string `long:"matrix-version" description:"run the scenarios for every combination of these versions across the matrix roles (may be repeated)"`
+	MatrixRoles    []string `long:"matrix-role" description:"role whose nodes share a version in the matrix, defaults to each node used by the scenarios (may be repeated)"`
 
-func (o opts) pathEmpty() bool {
-	return o.BuyerConfigPath == "" && o.VendorConfigPath == "" && o.ModConfigPath == ""
-}
-func (o opts) versionEmpty() bool {
-	return o.Version == "" && o.BuyerVersion == "" && o.VendorVersion == "" && o.ModVersion == ""
-}
+	NodeLogLevel   string   `long:"log-level" default:"DEBUG" description:"least severe level of node logs to display"`
+	NodeLogModules []string `long:"log-module" description:"only display node logs from this module (may be repeated)"`
+	NodeLogJSON    bool     `long:"log-json" description:"display node logs as JSON lines"`
 
-const (
-	buyer     = "buy"
-	vendor    = "ven"
-	moderator = "mod"
-)
+	RestartPolicy string `long:"restart" default:"never" choice:"never" choice:"on-failure" choice:"always" description:"restart nodes which exit according to this policy"`
+	MaxRestarts   int    `long:"max-restarts" description:"maximum times each node is restarted, zero is unlimited"`
 
-var (
-	wg         sync.WaitGroup
-	closeMutex sync.RWMutex
-	closeFns   = make([]func(), 0)
-	log        = logging.MustGetLogger("samulator")
-)
+	SampleInterval time.Duration `long:"sample-interval" description:"sample node resource usage at this interval (ex: 5s)"`
+	ResourceReport string        `long:"resource-report" choice:"csv" choice:"json" description:"write sampled resource usage to each node's run path on exit"`
+
+	LimitAddressSpace uint64  `long:"limit-address-space" description:"limit each node's address space to this many bytes"`
+	LimitOpenFiles    uint64  `long:"limit-open-files" description:"limit each node's open file descriptors"`
+	LimitMemory       uint64  `long:"limit-memory" description:"limit each node's memory to this many bytes (requires cgroup v2 delegation)"`
+	LimitCPUs         float64 `long:"limit-cpus" description:"limit each node to this many CPUs, ex: 0.5 (requires cgroup v2 delegation)"`
+	CgroupParent      string  `long:"cgroup-parent" description:"delegated cgroup v2 directory without processes where memory and CPU limited nodes' cgroups are created"`
+
+	Env      []string `long:"env" description:"set an environment variable for each node, ex: GOLOG_LOG_LEVEL=debug (may be repeated)"`
+	CleanEnv bool     `long:"clean-env" description:"start nodes with only the variables provided by --env"`
+	WorkDir  string   `long:"work-dir" description:"working directory for each node"`
+
+	WalletCurrency string `long:"wallet-currency" description:"coin of each initialized node's wallet, removing the others from multiwallet releases (ex: BTC, LTC)"`
+	WalletType     string `long:"wallet-type" description:"type of each initialized node's wallet (ex: SPV, API, or before 0.13.0 spvwallet, bitcoind)"`
+	WalletCreated  string `long:"wallet-created" description:"date the mnemonics were created as YYYY-MM-DD or RFC3339"`
+	ForceInit      bool   `long:"force-init" description:"reinitialize nodes which already exist, overwriting their identity"`
+}
+
+var log = logging.MustGetLogger("samulator")
 
 func getStdoutBackend() logging.Backend {
 	var (
@@ -66,15 +87,9 @@ func main() {
 		options opts
 
 		parser         = flags.NewParser(&options, flags.Default)
-		heardInterrupt = make(chan os.Signal)
+		heardInterrupt = make(chan os.Signal, 1)
 	)
-	signal.Notify(heardInterrupt, syscall.SIGTERM)
-	signal.Notify(heardInterrupt, syscall.SIGINT)
-	go func() {
-		<-heardInterrupt
-		log.Infof("interrupted, killing nodes...")
-		closeNodes()
-	}()
+	signal.Notify(heardInterrupt, syscall.SIGTERM, syscall.SIGINT)
 	logging.SetBackend(getStdoutBackend())
 
 	if _, err := parser.Parse(); err != nil {
@@ -85,169 +100,346 @@ func main() {
 		}
 	}
 
-	if options.pathEmpty() {
-		log.Errorf("no config paths provided, exiting")
+	topology, err := simulation.LoadTopology(options.TopologyPath)
+	if err != nil {
+		log.Errorf("loading topology: %s", err.Error())
 		os.Exit(3)
 	}
+	if topology.Version == "" {
+		topology.Version = options.Version
+	}
+	topology.FaultProxy = topology.FaultProxy || options.FaultProxy
+	if options.FaultProxyHost != "" {
+		topology.FaultProxyHost = options.FaultProxyHost
+	}
+	if options.Wallet != "" {
+		topology.Wallet = options.Wallet
+	}
+	if options.Clock != "" {
+		topology.Clock = options.Clock
+	}
+	topology.AutoPeer = topology.AutoPeer || options.AutoPeer || topology.FaultProxy
+	topology.PrivateNetwork = topology.PrivateNetwork || options.Private
 
-	if options.versionEmpty() {
-		log.Errorf("no build version provided, exiting")
-		os.Exit(4)
+	var scenarios []scenario.Scenario
+	for _, path := range options.ScenarioPaths {
+		s, err := scenario.Load(path)
+		if err != nil {
+			log.Errorf("loading scenario: %s", err.Error())
+			os.Exit(3)
+		}
+		scenarios = append(scenarios, s)
 	}
 
-	var nodeOpts = nodeOptions{
-		enableTestnet:         options.EnableTestnet,
-		overridePostmanConfig: options.OverridePostmanQAConfig,
+	var fixtures []fixture.Fixture
+	for _, path := range options.FixturePaths {
+		f, err := fixture.Load(path)
+		if err != nil {
+			log.Errorf("loading fixture: %s", err.Error())
+			os.Exit(3)
+		}
+		fixtures = append(fixtures, f)
 	}
-	if options.BuyerConfigPath != "" {
-		wg.Add(1)
-		if options.BuyerVersion == "" {
-			options.BuyerVersion = options.Version
+
+	var replay []traffic.Entry
+	if len(options.ReplayPaths) > 0 {
+		if replay, err = traffic.Load(options.ReplayPaths...); err != nil {
+			log.Errorf("loading recording: %s", err.Error())
+			os.Exit(3)
 		}
-		nodeOpts.label = buyer
-		nodeOpts.configPath = options.BuyerConfigPath
-		nodeOpts.version = options.BuyerVersion
-		err := runNode(nodeOpts)
-		if err != nil {
-			log.Errorf("running node: %s", err.Error())
-			os.Exit(2)
+	}
+
+	nodeOpts, err := parseNodeOptions(options)
+	if err != nil {
+		log.Errorf("%s", err.Error())
+		os.Exit(1)
+	}
+	restartPolicy, err := runner.ParseRestartPolicy(options.RestartPolicy)
+	if err != nil {
+		log.Errorf("invalid restart policy: %s", err.Error())
+		os.Exit(1)
+	}
+
+	var simOpts = simulation.Options{
+		Configure: nodeOpts.configure,
+		Supervisor: runner.SupervisorOptions{
+			Policy:      restartPolicy,
+			MaxRestarts: options.MaxRestarts,
+		},
+		Record: options.Record,
+	}
+	if options.FakeDaemon {
+		simOpts.Build = simulation.FakeBuild
+	}
+	if len(options.MatrixVersions) > 0 {
+		if len(scenarios) == 0 {
+			log.Errorf("--matrix-version requires at least one --scenario")
+			os.Exit(1)
+		}
+		if len(replay) > 0 {
+			log.Errorf("--replay cannot be combined with --matrix-version")
+			os.Exit(1)
 		}
+		os.Exit(runMatrix(heardInterrupt, topology, scenarios, fixtures, options, simOpts))
+	}
+	sim, err := simulation.New(topology, simOpts)
+	if err != nil {
+		log.Errorf("preparing simulation: %s", err.Error())
+		os.Exit(3)
 	}
+	log.Infof("node output is persisted at %s", sim.RunPath())
 
-	if options.VendorConfigPath != "" {
-		wg.Add(1)
-		if options.VendorVersion == "" {
-			options.VendorVersion = options.Version
+	var ctx, cancel = context.WithCancel(context.Background())
+	go func() {
+		<-heardInterrupt
+		log.Infof("interrupted, killing nodes...")
+		cancel()
+		if err := sim.Stop(); err != nil {
+			log.Errorf("stopping nodes: %s", err.Error())
 		}
-		nodeOpts.label = vendor
-		nodeOpts.configPath = options.VendorConfigPath
-		nodeOpts.version = options.VendorVersion
-		err := runNode(nodeOpts)
-		if err != nil {
-			log.Errorf("running node: %s", err.Error())
+	}()
+
+	if err := sim.Start(ctx); err != nil {
+		log.Errorf("running simulation: %s", err.Error())
+		sim.Stop()
+		os.Exit(2)
+	}
+	for _, f := range fixtures {
+		if _, err := fixture.Apply(ctx, f, sim); err != nil {
+			log.Errorf("%s", err.Error())
+			sim.Stop()
 			os.Exit(2)
 		}
+		log.Infof("seeded fixture %s", f.Name)
+	}
+	if len(scenarios) > 0 || len(replay) > 0 || options.SnapshotPath != "" {
+		var passed = runScenarios(ctx, sim, scenarios)
+		if len(replay) > 0 {
+			passed = runReplay(ctx, sim, replay, options.ReplayIgnore) && passed
+		}
+		if err := sim.Stop(); err != nil {
+			log.Errorf("stopping nodes: %s", err.Error())
+		}
+		if !passed {
+			os.Exit(4)
+		}
+		if options.SnapshotPath != "" {
+			if err := sim.Snapshot(options.SnapshotPath); err != nil {
+				log.Errorf("taking snapshot: %s", err.Error())
+				os.Exit(1)
+			}
+			log.Infof("node data was snapshotted at %s", options.SnapshotPath)
+		}
+		return
+	}
+	<-sim.Done()
+	for _, n := range sim.Nodes() {
+		if status, ok := n.Supervisor.LastExit(); ok && status.Reason != runner.ExitKilled {
+			log.Warningf("%s exited %s after %d restarts", n.Name(), status, n.Supervisor.Restarts())
+			for _, line := range status.Tail {
+				log.Warningf("%s ▶ %s", n.Name(), line)
+			}
+		}
 	}
+}
 
-	if options.ModConfigPath != "" {
-		wg.Add(1)
-		if options.ModVersion == "" {
-			options.ModVersion = options.Version
+// runScenarios runs each scenario against the simulation, printing its
+// report and writing it as JSON to the simulation run path. Returns true
+// when every scenario passed.
+func runScenarios(ctx context.Context, sim *simulation.Simulation, scenarios []scenario.Scenario) bool {
+	var passed = true
+	for i, s := range scenarios {
+		var report = scenario.Run(ctx, s, sim)
+		passed = passed && report.Passed
+		if err := report.WriteText(os.Stdout); err != nil {
+			log.Errorf("printing scenario report: %s", err.Error())
 		}
-		nodeOpts.label = moderator
-		nodeOpts.configPath = options.ModConfigPath
-		nodeOpts.version = options.ModVersion
-		err := runNode(nodeOpts)
+		var reportPath = filepath.Join(sim.RunPath(), fmt.Sprintf("scenario-%d.json", i+1))
+		f, err := os.Create(reportPath)
 		if err != nil {
-			log.Errorf("running node: %s", err.Error())
-			os.Exit(2)
+			log.Errorf("writing scenario report: %s", err.Error())
+			continue
+		}
+		if err := report.WriteJSON(f); err != nil {
+			log.Errorf("writing scenario report: %s", err.Error())
 		}
+		f.Close()
 	}
-	wg.Wait()
+	return passed
 }
 
-type nodeOptions struct {
-	label      string
-	version    string
-	configPath string
-
-	enableTestnet         bool
-	overridePostmanConfig bool
+// runReplay replays the recorded requests against the simulation,
+// printing the differences and writing them as JSON to the simulation run
+// path. Returns true when every response matched.
+func runReplay(ctx context.Context, sim *simulation.Simulation, entries []traffic.Entry, ignore []string) bool {
+	var report = traffic.Replay(ctx, entries, sim, traffic.Options{Ignore: ignore})
+	if err := report.WriteText(os.Stdout); err != nil {
+		log.Errorf("printing replay report: %s", err.Error())
+	}
+	f, err := os.Create(filepath.Join(sim.RunPath(), "replay.json"))
+	if err != nil {
+		log.Errorf("writing replay report: %s", err.Error())
+		return report.Passed
+	}
+	defer f.Close()
+	if err := report.WriteJSON(f); err != nil {
+		log.Errorf("writing replay report: %s", err.Error())
+	}
+	return report.Passed
 }
 
-func runNode(opts nodeOptions) error {
-	var ob, err = builder.NewOpenBazaarDaemon(opts.label, opts.version).Build()
-	if err != nil {
-		return fmt.Errorf("building: %s", err.Error())
+// runMatrix runs the scenarios for every combination of versions and
+// writes the matrix report to its run path. Returns the exit code.
+func runMatrix(interrupt <-chan os.Signal, topology simulation.Topology, scenarios []scenario.Scenario, fixtures []fixture.Fixture, options opts, simOpts simulation.Options) int {
+	var ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-interrupt
+		log.Infof("interrupted, stopping matrix...")
+		cancel()
+	}()
+
+	var matrixOpts = matrix.Options{
+		Versions:   options.MatrixVersions,
+		Roles:      options.MatrixRoles,
+		Fixtures:   fixtures,
+		Simulation: simOpts,
+		RunPath:    util.GenerateRunPath("matrix"),
+	}
+	log.Infof("matrix output is persisted at %s", matrixOpts.RunPath)
+	report, err := matrix.Run(ctx, topology, scenarios, matrixOpts)
+	if err != nil && len(report.Cells) == 0 {
+		log.Errorf("running matrix: %s", err.Error())
+		return 3
+	} else if err != nil {
+		log.Errorf("matrix incomplete: %s", err.Error())
 	}
 
-	closeMutex.Lock()
-	defer closeMutex.Unlock()
+	if err := report.WriteText(os.Stdout); err != nil {
+		log.Errorf("printing matrix report: %s", err.Error())
+	}
+	var writeReport = func(name string, write func(*os.File) error) {
+		f, err := os.Create(filepath.Join(report.RunPath, name))
+		if err != nil {
+			log.Errorf("writing matrix report: %s", err.Error())
+			return
+		}
+		defer f.Close()
+		if err := write(f); err != nil {
+			log.Errorf("writing matrix report: %s", err.Error())
+		}
+	}
+	writeReport("matrix.json", func(f *os.File) error { return report.WriteJSON(f) })
+	writeReport("matrix.html", func(f *os.File) error { return report.WriteHTML(f, report.RunPath) })
+	log.Infof("matrix report is at %s", filepath.Join(report.RunPath, "matrix.html"))
 
-	pr := ob.SplitOutput()
-	go logNodeOutput(pr, opts.label)
+	if !report.Passed {
+		return 4
+	}
+	return 0
+}
 
-	ob.SetCustomDataPath(opts.configPath)
-	ob.SetTestnetMode(opts.enableTestnet)
+// nodeOptions are applied to every node in the topology
+type nodeOptions struct {
+	logFilter      runner.LogFilter
+	logJSON        bool
+	sampleInterval time.Duration
+	resourceReport runner.ResourceReportFormat
+	limits         runner.ResourceLimits
+	env            runner.Environment
+	workDir        string
+	initOpts       runner.InitOptions
+}
 
-	if opts.overridePostmanConfig {
-		ob.Init()
-		switch opts.label {
-		case buyer:
-			err := ob.SetConfigValue("Addresses.Gateway", "/ip4/127.0.0.1/tcp/4002")
-			if err != nil {
-				return fmt.Errorf("failed to set buyer Address.Gateway: %s", err.Error())
-			}
-			err = ob.SetConfigValue("Addresses.Swarm", []string{
-				"/ip4/0.0.0.0/tcp/4001",
-				"/ip6/::/tcp/4001",
-				"/ip4/0.0.0.0/tcp/9005/ws",
-				"/ip6/::/tcp/9005/ws",
-			})
-			if err != nil {
-				return fmt.Errorf("failed to set buyer Address.Swarm: %s", err.Error())
-			}
-		case vendor:
-			err := ob.SetConfigValue("Addresses.Gateway", "/ip4/127.0.0.1/tcp/4102")
-			if err != nil {
-				return fmt.Errorf("failed to set buyer Address.Gateway: %s", err.Error())
-			}
-			err = ob.SetConfigValue("Addresses.Swarm", []string{
-				"/ip4/0.0.0.0/tcp/4101",
-				"/ip6/::/tcp/4101",
-				"/ip4/0.0.0.0/tcp/9105/ws",
-				"/ip6/::/tcp/9105/ws",
-			})
-			if err != nil {
-				return fmt.Errorf("failed to set buyer Address.Swarm: %s", err.Error())
-			}
-		case moderator:
-			err := ob.SetConfigValue("Addresses.Gateway", "/ip4/127.0.0.1/tcp/4202")
-			if err != nil {
-				return fmt.Errorf("failed to set buyer Address.Gateway: %s", err.Error())
-			}
-			err = ob.SetConfigValue("Addresses.Swarm", []string{
-				"/ip4/0.0.0.0/tcp/4201",
-				"/ip6/::/tcp/4201",
-				"/ip4/0.0.0.0/tcp/9205/ws",
-				"/ip6/::/tcp/9205/ws",
-			})
-			if err != nil {
-				return fmt.Errorf("failed to set buyer Address.Swarm: %s", err.Error())
-			}
+func parseNodeOptions(options opts) (nodeOptions, error) {
+	logLevel, err := logging.LogLevel(options.NodeLogLevel)
+	if err != nil {
+		return nodeOptions{}, fmt.Errorf("invalid log level (%s): %s", options.NodeLogLevel, err.Error())
+	}
+	var env = runner.Environment{Vars: make(map[string]string), Replace: options.CleanEnv}
+	for _, kv := range options.Env {
+		var parts = strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			return nodeOptions{}, fmt.Errorf("invalid environment variable (%s), expected KEY=VALUE", kv)
 		}
+		env.Vars[parts[0]] = parts[1]
 	}
-
-	ob.AsyncStart()
-	closeFn := func() {
-		if err := ob.Cleanup(); err != nil {
-			log.Errorf("cleanup process: %s", err.Error())
+	var initOpts = runner.InitOptions{WalletCurrency: options.WalletCurrency, WalletType: options.WalletType, Force: options.ForceInit}
+	if options.WalletCreated != "" {
+		created, err := runner.ParseWalletCreationDate(options.WalletCreated)
+		if err != nil {
+			return nodeOptions{}, fmt.Errorf("invalid wallet creation date: %s", err.Error())
 		}
-		time.Sleep(1 * time.Second)
-		wg.Done()
+		initOpts.WalletCreationDate = created
 	}
-	closeFns = append(closeFns, closeFn)
-	return nil
+	return nodeOptions{
+		logFilter:      runner.NewLogFilter(logLevel, options.NodeLogModules...),
+		logJSON:        options.NodeLogJSON,
+		sampleInterval: options.SampleInterval,
+		resourceReport: runner.ResourceReportFormat(options.ResourceReport),
+		limits: runner.ResourceLimits{
+			AddressSpaceBytes: options.LimitAddressSpace,
+			OpenFiles:         options.LimitOpenFiles,
+			MemoryBytes:       options.LimitMemory,
+			CPUs:              options.LimitCPUs,
+			CgroupParent:      options.CgroupParent,
+		},
+		env:      env,
+		workDir:  options.WorkDir,
+		initOpts: initOpts,
+	}, nil
 }
 
-func logNodeOutput(r io.ReadCloser, prefix string) {
-	defer r.Close()
-	var (
-		nodeLog = logging.MustGetLogger(prefix)
-		scanner = bufio.NewScanner(r)
-	)
-	for scanner.Scan() {
-		nodeLog.Infof(scanner.Text())
+// configure applies the command line options to a node before it is
+// initialized and started
+func (opts nodeOptions) configure(n *simulation.Node) error {
+	var ob = n.Runner
+	go logNodeOutput(ob.Subscribe(0), n.Name(), opts)
+
+	if len(opts.env.Vars) > 0 || opts.env.Replace {
+		if err := ob.SetEnvironment(opts.env); err != nil {
+			return fmt.Errorf("setting environment: %s", err.Error())
+		}
 	}
-	if err := scanner.Err(); err != nil {
-		nodeLog.Errorf("error reading node output (%s): %s", fmt.Sprintf("%010s", prefix), err.Error())
+	if err := ob.SetWorkDir(opts.workDir); err != nil {
+		return fmt.Errorf("setting work dir: %s", err.Error())
+	}
+	if opts.initOpts != (runner.InitOptions{}) {
+		if err := ob.SetInitOptions(opts.initOpts); err != nil {
+			return fmt.Errorf("setting init options: %s", err.Error())
+		}
+	}
+	if opts.sampleInterval > 0 || opts.resourceReport != runner.ResourceReportNone {
+		err := ob.SetResourceSampling(runner.ResourceSamplingOptions{
+			Interval: opts.sampleInterval,
+			Report:   opts.resourceReport,
+		})
+		if err != nil {
+			return fmt.Errorf("configuring resource sampling: %s", err.Error())
+		}
 	}
+	if opts.limits != (runner.ResourceLimits{}) {
+		if err := ob.SetResourceLimits(opts.limits); err != nil {
+			return fmt.Errorf("applying resource limits: %s", err.Error())
+		}
+	}
+	return nil
 }
 
-func closeNodes() {
-	closeMutex.RLock()
-	defer closeMutex.RUnlock()
-	for _, c := range closeFns {
-		go c()
+func logNodeOutput(sub *runner.OutputSubscription, label string, opts nodeOptions) {
+	defer sub.Close()
+	var nodeLog = logging.MustGetLogger(label)
+	for record := range runner.ParseLogs(sub.Lines(), opts.logFilter) {
+		if opts.logJSON {
+			record.Node = label
+			if err := record.WriteJSONLine(os.Stdout); err != nil {
+				nodeLog.Errorf("writing node log: %s", err.Error())
+			}
+			continue
+		}
+		record.Emit(nodeLog)
+	}
+	if dropped := sub.Dropped(); dropped > 0 {
+		nodeLog.Warningf("%d lines of node output were not displayed", dropped)
 	}
 }
@@ -0,0 +1,134 @@
+// Package faketime gives simulated nodes a clock which runs ahead of the
+// real one by an offset that only moves forward, so timeouts which take
+// days, such as dispute windows and auto-confirmation, can be reached in
+// seconds. The offset is kept in a file which nodes read each time they
+// ask for the time: openbazaard built with the clock patch, the fake
+// daemon, and C code under libfaketime all understand it.
+package faketime
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EnvFile names the variable holding the path of the clock file
+const EnvFile = "MASON_CLOCK_FILE"
+
+// Clock modes of a simulation
+const (
+	// ModePatch builds openbazaard so its calls to time.Now, time.Since
+	// and time.Until read the clock file
+	ModePatch = "patch"
+	// ModeLibfaketime also preloads libfaketime so C code, such as
+	// SQLite, reads the clock file
+	ModeLibfaketime = "libfaketime"
+)
+
+// ValidMode returns true when mode is a known clock mode
+func ValidMode(mode string) bool {
+	return mode == ModePatch || mode == ModeLibfaketime
+}
+
+// Clock is the shared clock of a simulation's nodes
+type Clock struct {
+	path string
+
+	mutex  sync.Mutex
+	offset time.Duration
+}
+
+// New creates a clock file at path holding no offset
+func New(path string) (*Clock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("creating clock path: %s", err.Error())
+	}
+	var c = &Clock{path: path}
+	if err := c.write(0); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Path returns the location of the clock file
+func (c *Clock) Path() string {
+	return c.path
+}
+
+// Offset returns how far the clock has been advanced
+func (c *Clock) Offset() time.Duration {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.offset
+}
+
+// Now returns the time read by the nodes
+func (c *Clock) Now() time.Time {
+	return time.Now().Add(c.Offset())
+}
+
+// Advance moves the clock forward by d. Nodes read the new time from
+// their next call for it.
+func (c *Clock) Advance(d time.Duration) error {
+	if d <= 0 {
+		return fmt.Errorf("time can only be advanced by a positive duration")
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if err := c.write(c.offset + d); err != nil {
+		return err
+	}
+	c.offset += d
+	log.Infof("advanced clock by %s to %s", d, time.Now().Add(c.offset).Format(time.RFC3339))
+	return nil
+}
+
+// write replaces the clock file so readers never see a partial offset
+func (c *Clock) write(offset time.Duration) error {
+	var tmp = c.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(FormatOffset(offset)+"\n"), 0644); err != nil {
+		return fmt.Errorf("writing clock: %s", err.Error())
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		return fmt.Errorf("writing clock: %s", err.Error())
+	}
+	return nil
+}
+
+// Environment returns the variables which have a node read the clock.
+// When library is the path to libfaketime it is preloaded as well.
+func (c *Clock) Environment(library string) map[string]string {
+	var vars = map[string]string{EnvFile: c.path}
+	if library != "" {
+		for k, v := range preloadVars(library) {
+			vars[k] = v
+		}
+		vars["FAKETIME_TIMESTAMP_FILE"] = c.path
+		// the file is read on every call so advances apply immediately
+		vars["FAKETIME_NO_CACHE"] = "1"
+		// timeouts and sleeps keep real time
+		vars["FAKETIME_DONT_FAKE_MONOTONIC"] = "1"
+	}
+	return vars
+}
+
+// FormatOffset writes an offset as signed seconds (ex: +86400), which
+// libfaketime reads as a relative time
+func FormatOffset(d time.Duration) string {
+	return "+" + strconv.FormatFloat(d.Seconds(), 'f', -1, 64)
+}
+
+// ParseOffset reads an offset written by FormatOffset
+func ParseOffset(s string) (time.Duration, error) {
+	var seconds, err = strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || math.IsInf(seconds, 0) || math.IsNaN(seconds) {
+		return 0, fmt.Errorf("invalid clock offset (%s)", strings.TrimSpace(s))
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
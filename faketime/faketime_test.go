@@ -0,0 +1,94 @@
+package faketime
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClockAdvancesOffsetFile(t *testing.T) {
+	var dir, err = ioutil.TempDir("", "mason_faketime")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c, err := New(filepath.Join(dir, "run", "clock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var read = func() time.Duration {
+		b, err := ioutil.ReadFile(c.Path())
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := ParseOffset(string(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	if got := read(); got != 0 {
+		t.Errorf("expected a new clock to have no offset, but was (%s)", got)
+	}
+
+	if err := c.Advance(72 * time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Advance(1500 * time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	var expected = 72*time.Hour + 1500*time.Millisecond
+	if got := read(); got != expected || c.Offset() != expected {
+		t.Errorf("expected offset (%s), but file was (%s) and clock was (%s)", expected, got, c.Offset())
+	}
+	if ahead := c.Now().Sub(time.Now()); ahead < 72*time.Hour || ahead > 73*time.Hour {
+		t.Errorf("expected the clock to be three days ahead, but was (%s)", ahead)
+	}
+
+	if err := c.Advance(-time.Hour); err == nil {
+		t.Error("expected time to only move forward")
+	}
+	if c.Offset() != expected {
+		t.Errorf("expected a rejected advance to leave the offset, but was (%s)", c.Offset())
+	}
+}
+
+func TestOffsetFormat(t *testing.T) {
+	for d, s := range map[time.Duration]string{
+		0:                      "+0",
+		24 * time.Hour:         "+86400",
+		250 * time.Millisecond: "+0.25",
+	} {
+		if got := FormatOffset(d); got != s {
+			t.Errorf("expected (%s) to format as (%s), but was (%s)", d, s, got)
+		}
+		if got, err := ParseOffset(s + "\n"); err != nil || got != d {
+			t.Errorf("expected (%s) to parse as (%s), but was (%s, %v)", s, d, got, err)
+		}
+	}
+	if _, err := ParseOffset("tomorrow"); err == nil {
+		t.Error("expected an invalid offset to be rejected")
+	}
+}
+
+func TestEnvironmentPreloadsLibrary(t *testing.T) {
+	var c = &Clock{path: "/tmp/clock"}
+	if vars := c.Environment(""); len(vars) != 1 || vars[EnvFile] != "/tmp/clock" {
+		t.Errorf("expected only the clock file without libfaketime, but was (%v)", vars)
+	}
+	var vars = c.Environment("/usr/lib/faketime/libfaketime.so.1")
+	if vars["FAKETIME_TIMESTAMP_FILE"] != "/tmp/clock" || vars["FAKETIME_NO_CACHE"] != "1" {
+		t.Errorf("expected libfaketime to read the clock file, but was (%v)", vars)
+	}
+	var preloaded bool
+	for _, v := range vars {
+		preloaded = preloaded || strings.HasSuffix(v, "libfaketime.so.1")
+	}
+	if !preloaded {
+		t.Errorf("expected libfaketime to be preloaded, but was (%v)", vars)
+	}
+}
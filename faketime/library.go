@@ -0,0 +1,57 @@
+package faketime
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/op/go-logging"
+)
+
+var log = logging.MustGetLogger("faketime")
+
+// EnvLibrary overrides where libfaketime is found
+const EnvLibrary = "FAKETIME_LIBRARY"
+
+// libraryPaths are where packages install libfaketime
+var libraryPaths = map[string][]string{
+	"linux": {
+		"/usr/lib/x86_64-linux-gnu/faketime/libfaketime.so.1",
+		"/usr/lib/aarch64-linux-gnu/faketime/libfaketime.so.1",
+		"/usr/lib/faketime/libfaketime.so.1",
+		"/usr/lib64/faketime/libfaketime.so.1",
+		"/usr/local/lib/faketime/libfaketime.so.1",
+	},
+	"darwin": {
+		"/opt/homebrew/lib/faketime/libfaketime.1.dylib",
+		"/usr/local/lib/faketime/libfaketime.1.dylib",
+	},
+}
+
+// FindLibrary returns the path to libfaketime, preferring the path in
+// FAKETIME_LIBRARY, or an error when it is not installed
+func FindLibrary() (string, error) {
+	if path := os.Getenv(EnvLibrary); path != "" {
+		if _, err := os.Stat(path); err != nil {
+			return "", fmt.Errorf("finding libfaketime: %s", err.Error())
+		}
+		return path, nil
+	}
+	for _, path := range libraryPaths[runtime.GOOS] {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("libfaketime is not installed, set %s to its path", EnvLibrary)
+}
+
+// preloadVars returns the variables which load library into a process
+func preloadVars(library string) map[string]string {
+	if runtime.GOOS == "darwin" {
+		return map[string]string{
+			"DYLD_INSERT_LIBRARIES":     library,
+			"DYLD_FORCE_FLAT_NAMESPACE": "1",
+		}
+	}
+	return map[string]string{"LD_PRELOAD": library}
+}
@@ -0,0 +1,235 @@
+// Package fixture seeds the nodes of a simulation with a known
+// marketplace: profiles, listings, follows, chats and orders described in
+// a JSON file. Fixtures are applied through each node's JSON API in a
+// fixed order, so nodes with fixed identities reach the same state on
+// every run and may be snapshotted for reuse.
+package fixture
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"github.com/OpenBazaar/mason/scenario"
+)
+
+// Order stages, in the order they are reached
+const (
+	StagePurchased = "purchased"
+	StageFunded    = "funded"
+	StageConfirmed = "confirmed"
+	StageFulfilled = "fulfilled"
+	StageCompleted = "completed"
+)
+
+var stages = []string{StagePurchased, StageFunded, StageConfirmed, StageFulfilled, StageCompleted}
+
+// Fixture describes the marketplace state seeded onto named nodes
+type Fixture struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Nodes maps node names to the profile and listings they publish
+	Nodes   map[string]Node `json:"nodes,omitempty"`
+	Follows []Follow        `json:"follows,omitempty"`
+	Chats   []Chat          `json:"chats,omitempty"`
+	Orders  []Order         `json:"orders,omitempty"`
+	// Timeout limits how long each order waits for the other party to
+	// see its progress, 2m by default
+	Timeout scenario.Duration `json:"timeout,omitempty"`
+}
+
+// Node is the data published by a single node. Profiles and listings
+// are sent to the JSON API as written.
+type Node struct {
+	Profile json.RawMessage `json:"profile,omitempty"`
+	// Listings must each have a slug so orders can refer to them
+	Listings []json.RawMessage `json:"listings,omitempty"`
+}
+
+// Follow has Node follow the node named by Follows
+type Follow struct {
+	Node    string `json:"node"`
+	Follows string `json:"follows"`
+}
+
+// Chat sends a message between two nodes
+type Chat struct {
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Subject string `json:"subject,omitempty"`
+	Message string `json:"message"`
+}
+
+// Order has Buyer purchase a listing from Vendor and progresses the order
+// to Stage. Funding an order spends from the buyer's wallet, which must
+// hold enough to pay for it.
+type Order struct {
+	Buyer    string `json:"buyer"`
+	Vendor   string `json:"vendor"`
+	Listing  string `json:"listing"`
+	Quantity int    `json:"quantity,omitempty"`
+	// Coin is the payment coin sent with the purchase, when the version
+	// requires one (ex: TBTC)
+	Coin string `json:"coin,omitempty"`
+	// Stage is how far the order is taken, completed by default
+	Stage string `json:"stage,omitempty"`
+	// Fulfillment is sent by the vendor to fulfill the order, with its
+	// orderId set. A note is sent by default.
+	Fulfillment json.RawMessage `json:"fulfillment,omitempty"`
+	// Rating is given for every category when the order is completed,
+	// 5 by default
+	Rating int    `json:"rating,omitempty"`
+	Review string `json:"review,omitempty"`
+}
+
+// stage returns the index of the order's stage, or -1 when unknown
+func (o Order) stage() int {
+	var stage = o.Stage
+	if stage == "" {
+		stage = StageCompleted
+	}
+	return stageIndex(stage)
+}
+
+// reaches returns true when the order is taken at least as far as stage
+func (o Order) reaches(stage string) bool {
+	return o.stage() >= stageIndex(stage)
+}
+
+func stageIndex(stage string) int {
+	for i, s := range stages {
+		if s == stage {
+			return i
+		}
+	}
+	return -1
+}
+
+// Load reads a JSON fixture file
+func Load(path string) (Fixture, error) {
+	var f Fixture
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return f, fmt.Errorf("reading fixture: %s", err.Error())
+	}
+	if err := json.Unmarshal(b, &f); err != nil {
+		return f, fmt.Errorf("parsing fixture (%s): %s", path, err.Error())
+	}
+	if f.Name == "" {
+		f.Name = path
+	}
+	return f, f.Validate()
+}
+
+// Validate returns an error describing the first part of the fixture
+// which cannot be applied
+func (f Fixture) Validate() error {
+	var slugs = make(map[string]map[string]bool)
+	for _, name := range f.sortedNodes() {
+		var n = f.Nodes[name]
+		if n.Profile != nil {
+			if _, err := decodeObject(n.Profile); err != nil {
+				return fmt.Errorf("profile of (%s): %s", name, err.Error())
+			}
+		}
+		slugs[name] = make(map[string]bool)
+		for i, l := range n.Listings {
+			slug, err := listingSlug(l)
+			if err != nil {
+				return fmt.Errorf("listing %d of (%s): %s", i+1, name, err.Error())
+			}
+			if slugs[name][slug] {
+				return fmt.Errorf("listing %d of (%s): duplicate slug (%s)", i+1, name, slug)
+			}
+			slugs[name][slug] = true
+		}
+	}
+	for i, follow := range f.Follows {
+		if follow.Node == "" || follow.Follows == "" || follow.Node == follow.Follows {
+			return fmt.Errorf("follow %d: requires node and a different node to follow", i+1)
+		}
+	}
+	for i, chat := range f.Chats {
+		if chat.From == "" || chat.To == "" || chat.From == chat.To || chat.Message == "" {
+			return fmt.Errorf("chat %d: requires a message between two different nodes", i+1)
+		}
+	}
+	for i, o := range f.Orders {
+		var err error
+		switch {
+		case o.Buyer == "" || o.Vendor == "" || o.Buyer == o.Vendor:
+			err = fmt.Errorf("requires a buyer and a different vendor")
+		case !slugs[o.Vendor][o.Listing]:
+			err = fmt.Errorf("vendor (%s) has no listing (%s) in the fixture", o.Vendor, o.Listing)
+		case o.Quantity < 0:
+			err = fmt.Errorf("quantity cannot be negative")
+		case o.stage() < 0:
+			err = fmt.Errorf("unknown stage (%s)", o.Stage)
+		case o.Rating < 0 || o.Rating > 5:
+			err = fmt.Errorf("rating must be between 1 and 5")
+		}
+		if err == nil && o.Fulfillment != nil {
+			_, err = decodeObject(o.Fulfillment)
+		}
+		if err != nil {
+			return fmt.Errorf("order %d: %s", i+1, err.Error())
+		}
+	}
+	return nil
+}
+
+// NodeNames returns every node used by the fixture in sorted order
+func (f Fixture) NodeNames() []string {
+	var seen = make(map[string]bool)
+	for name := range f.Nodes {
+		seen[name] = true
+	}
+	for _, follow := range f.Follows {
+		seen[follow.Node], seen[follow.Follows] = true, true
+	}
+	for _, chat := range f.Chats {
+		seen[chat.From], seen[chat.To] = true, true
+	}
+	for _, o := range f.Orders {
+		seen[o.Buyer], seen[o.Vendor] = true, true
+	}
+	var names = make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (f Fixture) sortedNodes() []string {
+	var names = make([]string, 0, len(f.Nodes))
+	for name := range f.Nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func decodeObject(b json.RawMessage) (map[string]interface{}, error) {
+	var obj map[string]interface{}
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return nil, fmt.Errorf("must be a JSON object: %s", err.Error())
+	}
+	if obj == nil {
+		return nil, fmt.Errorf("must be a JSON object")
+	}
+	return obj, nil
+}
+
+func listingSlug(l json.RawMessage) (string, error) {
+	obj, err := decodeObject(l)
+	if err != nil {
+		return "", err
+	}
+	slug, _ := obj["slug"].(string)
+	if slug == "" {
+		return "", fmt.Errorf("requires a slug")
+	}
+	return slug, nil
+}
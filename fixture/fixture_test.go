@@ -0,0 +1,193 @@
+package fixture
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OpenBazaar/mason/simulation"
+	"github.com/OpenBazaar/mason/util"
+)
+
+const exampleFixture = `{
+	"name": "small market",
+	"nodes": {
+		"vendor": {
+			"profile": {"name": "Vendor", "vendor": true},
+			"listings": [
+				{"slug": "widget", "item": {"title": "Widget"}},
+				{"slug": "gadget", "item": {"title": "Gadget"}}
+			]
+		},
+		"buyer": {"profile": {"name": "Buyer"}}
+	},
+	"follows": [{"node": "buyer", "follows": "vendor"}],
+	"chats": [{"from": "buyer", "to": "vendor", "message": "is the widget in stock?"}]
+}`
+
+func mustLoadExample(t *testing.T) Fixture {
+	var path = util.GenerateTempPath("fixture") + ".json"
+	if err := ioutil.WriteFile(path, []byte(exampleFixture), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(path)
+	f, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestValidate(t *testing.T) {
+	var listings = map[string]Node{"vendor": {Listings: []json.RawMessage{[]byte(`{"slug":"widget"}`)}}}
+	var examples = []struct {
+		fixture Fixture
+		valid   bool
+	}{
+		{Fixture{Nodes: listings, Orders: []Order{{Buyer: "buyer", Vendor: "vendor", Listing: "widget"}}}, true},
+		{Fixture{Nodes: listings, Orders: []Order{{Buyer: "buyer", Vendor: "vendor", Listing: "gadget"}}}, false},
+		{Fixture{Nodes: listings, Orders: []Order{{Buyer: "buyer", Vendor: "vendor", Listing: "widget", Stage: "shipped"}}}, false},
+		{Fixture{Nodes: listings, Orders: []Order{{Buyer: "vendor", Vendor: "vendor", Listing: "widget"}}}, false},
+		{Fixture{Nodes: map[string]Node{"vendor": {Listings: []json.RawMessage{[]byte(`{"item":{}}`)}}}}, false},
+		{Fixture{Nodes: map[string]Node{"vendor": {Profile: []byte(`["vendor"]`)}}}, false},
+		{Fixture{Follows: []Follow{{Node: "buyer", Follows: "buyer"}}}, false},
+		{Fixture{Chats: []Chat{{From: "buyer", To: "vendor"}}}, false},
+	}
+	for i, e := range examples {
+		var err = e.fixture.Validate()
+		if (err == nil) != e.valid {
+			t.Errorf("expected example %d valid (%t), but error was (%v)", i+1, e.valid, err)
+		}
+	}
+}
+
+func TestScenarioTakesOrdersToTheirStage(t *testing.T) {
+	var f = mustLoadExample(t)
+	f.Orders = []Order{
+		{Buyer: "buyer", Vendor: "vendor", Listing: "widget", Stage: StageConfirmed},
+		{Buyer: "buyer", Vendor: "vendor", Listing: "gadget", Quantity: 2, Rating: 4},
+	}
+	s, err := f.Scenario()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, step := range s.Steps {
+		names = append(names, step.Name)
+	}
+	var expected = []string{
+		"buyer reads its peer ID",
+		"vendor reads its peer ID",
+		"buyer creates its profile",
+		"vendor creates its profile",
+		"vendor creates listing widget",
+		"vendor creates listing gadget",
+		"buyer follows vendor",
+		"buyer chats with vendor",
+		"order 1: vendor reads the hash of widget",
+		"order 1: buyer purchases 1 of widget",
+		"order 1: buyer funds the order",
+		"order 1: vendor sees PENDING",
+		"order 1: vendor confirms the order",
+		"order 1: buyer sees AWAITING_FULFILLMENT",
+		"order 2: vendor reads the hash of gadget",
+		"order 2: buyer purchases 2 of gadget",
+		"order 2: buyer funds the order",
+		"order 2: vendor sees PENDING",
+		"order 2: vendor confirms the order",
+		"order 2: buyer sees AWAITING_FULFILLMENT",
+		"order 2: vendor fulfills the order",
+		"order 2: buyer sees FULFILLED",
+		"order 2: buyer completes the order",
+		"order 2: vendor sees COMPLETED",
+	}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected steps:\n%s\nbut was:\n%s", strings.Join(expected, "\n"), strings.Join(names, "\n"))
+	}
+
+	var completion = s.Steps[len(s.Steps)-2]
+	if !strings.Contains(string(completion.Body), `"orderId":"${order2_id}"`) || !strings.Contains(string(completion.Body), `"overall":4`) {
+		t.Errorf("expected completion to rate the second order, but was (%s)", completion.Body)
+	}
+	if timeout := time.Duration(s.Steps[len(s.Steps)-1].Timeout); timeout != defaultOrderTimeout {
+		t.Errorf("expected order states to be awaited for (%s), but was (%s)", defaultOrderTimeout, timeout)
+	}
+}
+
+func TestApplyWithFakeDaemon(t *testing.T) {
+	var runPath = util.GenerateTempPath("fixture_test")
+	defer os.RemoveAll(runPath)
+	sim, err := simulation.New(simulation.Topology{
+		Version: "v0.1.0",
+		Nodes: []simulation.NodeSpec{
+			{Name: "vendor", Mnemonic: "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about", Config: map[string]interface{}{"Addresses.Gateway": mustFreeAddress(t)}},
+			{Name: "buyer", Mnemonic: "zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo wrong", Config: map[string]interface{}{"Addresses.Gateway": mustFreeAddress(t)}},
+		},
+	}, simulation.Options{Build: simulation.FakeBuild, ReadyTimeout: 30 * time.Second, RunPath: runPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sim.Stop()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := sim.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var f = mustLoadExample(t)
+	if _, err := Apply(ctx, f, sim); err != nil {
+		t.Fatal(err)
+	}
+	vendor, _ := sim.Runner("vendor")
+	buyer, _ := sim.Runner("buyer")
+	config, err := vendor.APIRequest(ctx, "GET", "/ob/config", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vendorConfig struct{ PeerID string }
+	json.Unmarshal(config.Body, &vendorConfig)
+	following, err := buyer.APIRequest(ctx, "GET", "/ob/following", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := fmt.Sprintf(`["%s"]`, vendorConfig.PeerID); strings.TrimSpace(string(following.Body)) != expected {
+		t.Errorf("expected buyer to follow (%s), but was (%s)", expected, following.Body)
+	}
+
+	// applying again fails as the profiles already exist
+	if _, err := Apply(ctx, f, sim); err == nil || !strings.Contains(err.Error(), "buyer creates its profile") {
+		t.Errorf("expected reapplying the fixture to fail on the first profile, but was (%v)", err)
+	}
+	if _, err := Apply(ctx, Fixture{Name: "extra", Nodes: map[string]Node{"moderator": {}}}, sim); err == nil {
+		t.Error("expected an error for a node which is not running")
+	}
+
+	if err := sim.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	var snapshot = filepath.Join(runPath, "snapshot")
+	if err := sim.Snapshot(snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(snapshot, "vendor", "root", "listings.json")); err != nil {
+		t.Errorf("expected snapshot to hold the vendor's listings, but was (%s)", err.Error())
+	}
+}
+
+func mustFreeAddress(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", l.Addr().(*net.TCPAddr).Port)
+}
@@ -0,0 +1,286 @@
+package fixture
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/OpenBazaar/mason/scenario"
+)
+
+const defaultOrderTimeout = 2 * time.Minute
+
+// Apply seeds the nodes with the fixture once they are ready, returning
+// the report of every API call made. An error describes the first call
+// which failed.
+func Apply(ctx context.Context, f Fixture, nodes scenario.Nodes) (scenario.Report, error) {
+	s, err := f.Scenario()
+	if err != nil {
+		return scenario.Report{}, err
+	}
+	for _, name := range f.NodeNames() {
+		if _, ok := nodes.Runner(name); !ok {
+			return scenario.Report{}, fmt.Errorf("fixture (%s) uses node (%s) which is not running", f.Name, name)
+		}
+	}
+	var report = scenario.Run(ctx, s, nodes)
+	for _, step := range report.Steps {
+		if !step.Passed && !step.Skipped {
+			return report, fmt.Errorf("seeding fixture (%s): %s: %s", f.Name, step.Name, step.Error)
+		}
+	}
+	return report, nil
+}
+
+// Scenario returns the steps which apply the fixture. Peer IDs are read
+// first, then profiles and listings are created, nodes are followed,
+// chats are sent and orders are placed, each in the order written and
+// nodes in name order. Orders wait for the other party to see each
+// stage before continuing.
+func (f Fixture) Scenario() (scenario.Scenario, error) {
+	if err := f.Validate(); err != nil {
+		return scenario.Scenario{}, err
+	}
+	var s = scenario.Scenario{Name: f.Name, Description: f.Description}
+	for _, name := range f.NodeNames() {
+		s.Steps = append(s.Steps, scenario.Step{
+			Name:   fmt.Sprintf("%s reads its peer ID", name),
+			Action: scenario.ActionCall,
+			Node:   name,
+			Method: "GET",
+			Path:   "/ob/config",
+			Save:   map[string]string{peerVar(name): "peerID"},
+		})
+	}
+	for _, name := range f.sortedNodes() {
+		var n = f.Nodes[name]
+		if n.Profile != nil {
+			s.Steps = append(s.Steps, scenario.Step{
+				Name:   fmt.Sprintf("%s creates its profile", name),
+				Action: scenario.ActionCall,
+				Node:   name,
+				Method: "POST",
+				Path:   "/ob/profile",
+				Body:   n.Profile,
+			})
+		}
+		for _, l := range n.Listings {
+			slug, _ := listingSlug(l)
+			s.Steps = append(s.Steps, scenario.Step{
+				Name:   fmt.Sprintf("%s creates listing %s", name, slug),
+				Action: scenario.ActionCall,
+				Node:   name,
+				Method: "POST",
+				Path:   "/ob/listing",
+				Body:   l,
+				Assert: []scenario.Assertion{{Path: "slug", Equals: mustMarshal(slug)}},
+			})
+		}
+	}
+	for _, follow := range f.Follows {
+		s.Steps = append(s.Steps, scenario.Step{
+			Name:   fmt.Sprintf("%s follows %s", follow.Node, follow.Follows),
+			Action: scenario.ActionCall,
+			Node:   follow.Node,
+			Method: "POST",
+			Path:   "/ob/follow",
+			Body:   mustMarshal(map[string]string{"id": ref(peerVar(follow.Follows))}),
+		})
+	}
+	for _, chat := range f.Chats {
+		s.Steps = append(s.Steps, scenario.Step{
+			Name:   fmt.Sprintf("%s chats with %s", chat.From, chat.To),
+			Action: scenario.ActionCall,
+			Node:   chat.From,
+			Method: "POST",
+			Path:   "/ob/chat",
+			Body: mustMarshal(map[string]string{
+				"peerId":  ref(peerVar(chat.To)),
+				"subject": chat.Subject,
+				"message": chat.Message,
+			}),
+		})
+	}
+	var timeout = f.Timeout
+	if timeout <= 0 {
+		timeout = scenario.Duration(defaultOrderTimeout)
+	}
+	for i, o := range f.Orders {
+		s.Steps = append(s.Steps, orderSteps(i+1, o, timeout)...)
+	}
+	return s, nil
+}
+
+// orderSteps places order n and takes it to its stage, waiting for the
+// other party to see each stage
+func orderSteps(n int, o Order, timeout scenario.Duration) []scenario.Step {
+	var (
+		listingVar = fmt.Sprintf("order%d_listing", n)
+		orderVar   = fmt.Sprintf("order%d_id", n)
+		addressVar = fmt.Sprintf("order%d_address", n)
+		amountVar  = fmt.Sprintf("order%d_amount", n)
+		orderPath  = "/ob/order/" + ref(orderVar)
+		quantity   = o.Quantity
+		rating     = o.Rating
+	)
+	if quantity == 0 {
+		quantity = 1
+	}
+	if rating == 0 {
+		rating = 5
+	}
+	var title = func(action string) string {
+		return fmt.Sprintf("order %d: %s", n, action)
+	}
+	var awaitState = func(node, state string) scenario.Step {
+		return scenario.Step{
+			Name:    title(fmt.Sprintf("%s sees %s", node, state)),
+			Action:  scenario.ActionCall,
+			Node:    node,
+			Method:  "GET",
+			Path:    orderPath,
+			Timeout: timeout,
+			Assert:  []scenario.Assertion{{Path: "state", Equals: mustMarshal(state)}},
+		}
+	}
+
+	var purchase = map[string]interface{}{
+		"items": []map[string]interface{}{{
+			"listingHash": ref(listingVar),
+			"quantity":    quantity,
+			"options":     []interface{}{},
+		}},
+		"moderator": "",
+	}
+	if o.Coin != "" {
+		purchase["paymentCoin"] = o.Coin
+	}
+	var steps = []scenario.Step{
+		{
+			Name:   title(fmt.Sprintf("%s reads the hash of %s", o.Vendor, o.Listing)),
+			Action: scenario.ActionCall,
+			Node:   o.Vendor,
+			Method: "GET",
+			Path:   "/ob/listing/" + url.PathEscape(o.Listing),
+			Save:   map[string]string{listingVar: "hash"},
+		},
+		{
+			Name:   title(fmt.Sprintf("%s purchases %d of %s", o.Buyer, quantity, o.Listing)),
+			Action: scenario.ActionCall,
+			Node:   o.Buyer,
+			Method: "POST",
+			Path:   "/ob/purchase",
+			Body:   mustMarshal(purchase),
+			Save:   map[string]string{orderVar: "orderId", addressVar: "paymentAddress", amountVar: "amount"},
+		},
+	}
+
+	if o.reaches(StageFunded) {
+		// the amount keeps the type the purchase response used, so the
+		// spend matches the release's wallet API
+		var spend = map[string]string{
+			"address":  ref(addressVar),
+			"amount":   ref(amountVar),
+			"feeLevel": "NORMAL",
+		}
+		if o.Coin != "" {
+			spend["wallet"] = o.Coin
+		}
+		steps = append(steps,
+			scenario.Step{
+				Name:   title(fmt.Sprintf("%s funds the order", o.Buyer)),
+				Action: scenario.ActionCall,
+				Node:   o.Buyer,
+				Method: "POST",
+				Path:   "/wallet/spend",
+				Body:   mustMarshal(spend),
+			},
+			awaitState(o.Vendor, "PENDING"),
+		)
+	}
+	if o.reaches(StageConfirmed) {
+		steps = append(steps,
+			scenario.Step{
+				Name:   title(fmt.Sprintf("%s confirms the order", o.Vendor)),
+				Action: scenario.ActionCall,
+				Node:   o.Vendor,
+				Method: "POST",
+				Path:   "/ob/orderconfirmation",
+				Body:   mustMarshal(map[string]interface{}{"orderId": ref(orderVar), "reject": false}),
+			},
+			awaitState(o.Buyer, "AWAITING_FULFILLMENT"),
+		)
+	}
+	if o.reaches(StageFulfilled) {
+		var fulfillment = map[string]interface{}{"note": "fulfilled by fixture"}
+		if o.Fulfillment != nil {
+			fulfillment, _ = decodeObject(o.Fulfillment)
+		}
+		fulfillment["orderId"] = ref(orderVar)
+		steps = append(steps,
+			scenario.Step{
+				Name:   title(fmt.Sprintf("%s fulfills the order", o.Vendor)),
+				Action: scenario.ActionCall,
+				Node:   o.Vendor,
+				Method: "POST",
+				Path:   "/ob/orderfulfillment",
+				Body:   mustMarshal(fulfillment),
+			},
+			awaitState(o.Buyer, "FULFILLED"),
+		)
+	}
+	if o.reaches(StageCompleted) {
+		var completion = map[string]interface{}{
+			"orderId": ref(orderVar),
+			"ratings": []map[string]interface{}{{
+				"slug":            o.Listing,
+				"overall":         rating,
+				"quality":         rating,
+				"description":     rating,
+				"deliverySpeed":   rating,
+				"customerService": rating,
+				"review":          o.Review,
+				"anonymous":       false,
+			}},
+		}
+		steps = append(steps,
+			scenario.Step{
+				Name:   title(fmt.Sprintf("%s completes the order", o.Buyer)),
+				Action: scenario.ActionCall,
+				Node:   o.Buyer,
+				Method: "POST",
+				Path:   "/ob/ordercompletion",
+				Body:   mustMarshal(completion),
+			},
+			awaitState(o.Vendor, "COMPLETED"),
+		)
+	}
+	return steps
+}
+
+// peerVar names the variable holding a node's peer ID
+func peerVar(node string) string {
+	return "peer_" + strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, node)
+}
+
+// ref returns a scenario reference to the named variable
+func ref(name string) string {
+	return "${" + name + "}"
+}
+
+func mustMarshal(v interface{}) json.RawMessage {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
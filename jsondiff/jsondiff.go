@@ -0,0 +1,133 @@
+// Package jsondiff lists the differences between two decoded JSON
+// documents, such as API responses captured before and after a change.
+package jsondiff
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Kinds of Difference
+const (
+	Added   = "added"
+	Removed = "removed"
+	Changed = "changed"
+)
+
+// Wildcard is a path segment which matches any key or array index
+const Wildcard = "*"
+
+// Difference describes a value which differs between two documents
+type Difference struct {
+	// Path is the dot-separated location of the value, with array
+	// indices as path segments. It is empty for the whole document.
+	Path   string
+	Change string
+	Before interface{}
+	After  interface{}
+}
+
+// Compare returns the differences between two documents decoded into
+// interface{}, ordered by path. Object keys are compared regardless of
+// order and array elements by index. Values at the ignored paths, and
+// everything beneath them, are not compared. A Wildcard segment in an
+// ignored path matches any key or index (ex: messages.*.timestamp).
+func Compare(before, after interface{}, ignore []string) []Difference {
+	var patterns = make([][]string, 0, len(ignore))
+	for _, p := range ignore {
+		patterns = append(patterns, strings.Split(p, "."))
+	}
+	var diffs []Difference
+	compareValue(nil, before, after, patterns, &diffs)
+	return diffs
+}
+
+func compareValue(path []string, before, after interface{}, ignore [][]string, diffs *[]Difference) {
+	switch b := before.(type) {
+	case map[string]interface{}:
+		if a, ok := after.(map[string]interface{}); ok {
+			compareObjects(path, b, a, ignore, diffs)
+			return
+		}
+	case []interface{}:
+		if a, ok := after.([]interface{}); ok {
+			compareArrays(path, b, a, ignore, diffs)
+			return
+		}
+	}
+	if !reflect.DeepEqual(before, after) {
+		*diffs = append(*diffs, Difference{Path: join(path), Change: Changed, Before: before, After: after})
+	}
+}
+
+func compareObjects(path []string, before, after map[string]interface{}, ignore [][]string, diffs *[]Difference) {
+	var keys = make(map[string]bool, len(before)+len(after))
+	for k := range before {
+		keys[k] = true
+	}
+	for k := range after {
+		keys[k] = true
+	}
+	var sorted = make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+	for _, k := range sorted {
+		var (
+			child  = append(path[:len(path):len(path)], k)
+			b, inB = before[k]
+			a, inA = after[k]
+		)
+		switch {
+		case ignored(child, ignore):
+		case !inA:
+			*diffs = append(*diffs, Difference{Path: join(child), Change: Removed, Before: b})
+		case !inB:
+			*diffs = append(*diffs, Difference{Path: join(child), Change: Added, After: a})
+		default:
+			compareValue(child, b, a, ignore, diffs)
+		}
+	}
+}
+
+func compareArrays(path []string, before, after []interface{}, ignore [][]string, diffs *[]Difference) {
+	for i := 0; i < len(before) || i < len(after); i++ {
+		var child = append(path[:len(path):len(path)], strconv.Itoa(i))
+		switch {
+		case ignored(child, ignore):
+		case i >= len(after):
+			*diffs = append(*diffs, Difference{Path: join(child), Change: Removed, Before: before[i]})
+		case i >= len(before):
+			*diffs = append(*diffs, Difference{Path: join(child), Change: Added, After: after[i]})
+		default:
+			compareValue(child, before[i], after[i], ignore, diffs)
+		}
+	}
+}
+
+// ignored returns true when path matches one of the ignored paths
+func ignored(path []string, ignore [][]string) bool {
+	for _, pattern := range ignore {
+		if len(pattern) != len(path) {
+			continue
+		}
+		var match = true
+		for i := range pattern {
+			if pattern[i] != Wildcard && pattern[i] != path[i] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+func join(path []string) string {
+	return strings.Join(path, ".")
+}
@@ -0,0 +1,50 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	var before, after interface{}
+	json.Unmarshal([]byte(`{"name":"shop","stats":{"lastSeen":1,"ratings":2},"tags":["a","b"],"old":true,"messages":[{"text":"hi","at":1},{"text":"yo","at":2}]}`), &before)
+	json.Unmarshal([]byte(`{"name":"shop!","stats":{"lastSeen":5,"ratings":2},"tags":["a"],"new":1,"messages":[{"text":"hi","at":3},{"text":"yo!","at":4}]}`), &after)
+
+	var examples = []struct {
+		ignore   []string
+		expected []Difference
+	}{
+		{
+			ignore: []string{"stats.lastSeen", "messages.*.at"},
+			expected: []Difference{
+				{Path: "messages.1.text", Change: Changed, Before: "yo", After: "yo!"},
+				{Path: "name", Change: Changed, Before: "shop", After: "shop!"},
+				{Path: "new", Change: Added, After: 1.0},
+				{Path: "old", Change: Removed, Before: true},
+				{Path: "tags.1", Change: Removed, Before: "b"},
+			},
+		},
+		{
+			ignore: []string{"*", "messages"},
+		},
+		{
+			ignore: []string{"messages", "name", "new", "old", "stats", "tags.*"},
+		},
+	}
+	for i, ex := range examples {
+		if diffs := Compare(before, after, ex.ignore); !reflect.DeepEqual(diffs, ex.expected) {
+			t.Errorf("example %d: expected differences (%v), but was (%v)", i, ex.expected, diffs)
+		}
+	}
+}
+
+func TestCompareWholeDocument(t *testing.T) {
+	var expected = []Difference{{Path: "", Change: Changed, Before: "not found", After: []interface{}{}}}
+	if diffs := Compare("not found", []interface{}{}, nil); !reflect.DeepEqual(diffs, expected) {
+		t.Errorf("expected differences (%v), but was (%v)", expected, diffs)
+	}
+	if diffs := Compare(map[string]interface{}{}, map[string]interface{}{}, nil); len(diffs) != 0 {
+		t.Errorf("expected no differences, but was (%v)", diffs)
+	}
+}
@@ -0,0 +1,268 @@
+// Package masontest starts openbazaard nodes from go tests. Nodes are
+// built or fetched from the cache, started, and awaited until their API is
+// ready. They are stopped when the test finishes, and their recent output
+// is logged if the test failed.
+package masontest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OpenBazaar/mason/builder/runner"
+	"github.com/OpenBazaar/mason/fixture"
+	"github.com/OpenBazaar/mason/simulation"
+	"github.com/OpenBazaar/mason/util"
+	"github.com/otiai10/copy"
+)
+
+const defaultTailLines = 100
+
+// Option customizes the nodes started by Node and Network
+type Option func(*config)
+
+type config struct {
+	sim         simulation.Options
+	specs       []func(*simulation.NodeSpec)
+	fixtures    []fixture.Fixture
+	snapshot    string
+	tailLines   int
+	keepRunPath bool
+}
+
+// WithBuild produces runners with build instead of building from source
+// or the cache
+func WithBuild(build simulation.BuildFunc) Option {
+	return func(c *config) { c.sim.Build = build }
+}
+
+// WithFakeDaemon runs the fake daemon in cmd/fakeobd instead of
+// openbazaard, so tests do not need the network
+func WithFakeDaemon() Option {
+	return WithBuild(simulation.FakeBuild)
+}
+
+// WithConfigure calls fn for each node before it is initialized and
+// started
+func WithConfigure(fn func(*simulation.Node) error) Option {
+	return func(c *config) { c.sim.Configure = fn }
+}
+
+// WithReadyTimeout limits how long nodes have to become ready
+func WithReadyTimeout(d time.Duration) Option {
+	return func(c *config) { c.sim.ReadyTimeout = d }
+}
+
+// WithStopGrace allows nodes to exit on their own before they are killed
+func WithStopGrace(d time.Duration) Option {
+	return func(c *config) { c.sim.StopGrace = d }
+}
+
+// WithConfig sets a dot-separated config key on every node
+func WithConfig(key string, value interface{}) Option {
+	return withSpec(func(spec *simulation.NodeSpec) {
+		spec.Config[key] = value
+	})
+}
+
+// WithTestnet starts every node in testnet mode
+func WithTestnet() Option {
+	return withSpec(func(spec *simulation.NodeSpec) {
+		var enabled = true
+		spec.Testnet = &enabled
+	})
+}
+
+// WithArgs passes additional arguments to every node on start
+func WithArgs(args ...string) Option {
+	return withSpec(func(spec *simulation.NodeSpec) {
+		spec.Args = append(spec.Args, args...)
+	})
+}
+
+// WithMnemonic initializes the node with a fixed identity. It should only
+// be used with Node, as peers cannot share an identity.
+func WithMnemonic(mnemonic string) Option {
+	return withSpec(func(spec *simulation.NodeSpec) {
+		spec.Mnemonic = mnemonic
+	})
+}
+
+// WithFixture seeds the nodes with f once they are ready. Fixtures are
+// applied in the order given.
+func WithFixture(f fixture.Fixture) Option {
+	return func(c *config) { c.fixtures = append(c.fixtures, f) }
+}
+
+// WithSnapshot starts each node from a copy of dir/<name>, such as a
+// snapshot taken with Simulation.Snapshot. Nodes without a directory in
+// the snapshot start as usual.
+func WithSnapshot(dir string) Option {
+	return func(c *config) { c.snapshot = dir }
+}
+
+// WithTailLines sets the number of output lines logged for each node
+// when the test fails
+func WithTailLines(n int) Option {
+	return func(c *config) { c.tailLines = n }
+}
+
+// KeepRunPath leaves the run path in place after a passing test. The run
+// path is always kept when the test fails.
+func KeepRunPath() Option {
+	return func(c *config) { c.keepRunPath = true }
+}
+
+func withSpec(fn func(*simulation.NodeSpec)) Option {
+	return func(c *config) { c.specs = append(c.specs, fn) }
+}
+
+// Node starts a single node of version and returns its runner once it is
+// ready. The test fails immediately if the node cannot be started.
+func Node(t testing.TB, version string, opts ...Option) *runner.OpenBazaarRunner {
+	t.Helper()
+	var sim = Network(t, simulation.Topology{
+		Version: version,
+		Nodes:   []simulation.NodeSpec{{Name: "node"}},
+	}, opts...)
+	n, _ := sim.Node("node")
+	return n.Runner
+}
+
+// Network starts every node of the topology and returns the simulation
+// once they are ready. Nodes without a configured gateway or swarm
+// address are given unused local ports so tests may run in parallel. The
+// test fails immediately if the network cannot be started.
+func Network(t testing.TB, topology simulation.Topology, opts ...Option) *simulation.Simulation {
+	t.Helper()
+	var c = config{tailLines: defaultTailLines}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	if c.sim.RunPath == "" {
+		c.sim.RunPath = util.GenerateRunPath(runPathLabel(t))
+	}
+
+	var nodes = make([]simulation.NodeSpec, len(topology.Nodes))
+	for i, spec := range topology.Nodes {
+		var err error
+		if spec, err = prepareSpec(spec, c.specs); err != nil {
+			t.Fatalf("preparing node (%s): %s", spec.Name, err.Error())
+		}
+		if c.snapshot != "" {
+			if spec, err = restoreSnapshot(spec, c.snapshot, c.sim.RunPath); err != nil {
+				t.Fatalf("restoring node (%s): %s", spec.Name, err.Error())
+			}
+		}
+		nodes[i] = spec
+	}
+	topology.Nodes = nodes
+
+	sim, err := simulation.New(topology, c.sim)
+	if err != nil {
+		t.Fatalf("preparing network: %s", err.Error())
+	}
+	t.Cleanup(func() { teardown(t, sim, c) })
+	if err := sim.Start(context.Background()); err != nil {
+		t.Fatalf("starting network: %s", err.Error())
+	}
+	for _, f := range c.fixtures {
+		if _, err := fixture.Apply(context.Background(), f, sim); err != nil {
+			t.Fatalf("applying fixture: %s", err.Error())
+		}
+	}
+	return sim
+}
+
+// prepareSpec applies the options to a copy of spec and assigns unused
+// ports to addresses which are not configured
+func prepareSpec(spec simulation.NodeSpec, specOpts []func(*simulation.NodeSpec)) (simulation.NodeSpec, error) {
+	var config = make(map[string]interface{}, len(spec.Config))
+	for k, v := range spec.Config {
+		config[k] = v
+	}
+	spec.Config = config
+	spec.Args = append([]string{}, spec.Args...)
+	for _, fn := range specOpts {
+		fn(&spec)
+	}
+
+	if _, ok := spec.Config["Addresses.Gateway"]; !ok {
+		port, err := freePort()
+		if err != nil {
+			return spec, err
+		}
+		spec.Config["Addresses.Gateway"] = fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", port)
+	}
+	if _, ok := spec.Config["Addresses.Swarm"]; !ok {
+		port, err := freePort()
+		if err != nil {
+			return spec, err
+		}
+		spec.Config["Addresses.Swarm"] = []string{fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", port)}
+	}
+	return spec, nil
+}
+
+// restoreSnapshot copies the node's data from the snapshot into the run
+// path, leaving the snapshot untouched
+func restoreSnapshot(spec simulation.NodeSpec, snapshot, runPath string) (simulation.NodeSpec, error) {
+	var src = filepath.Join(snapshot, spec.Name)
+	if _, err := os.Stat(src); os.IsNotExist(err) {
+		return spec, nil
+	}
+	var dataPath = filepath.Join(runPath, spec.Name, "data")
+	if err := copy.Copy(src, dataPath); err != nil {
+		return spec, fmt.Errorf("copying snapshot: %s", err.Error())
+	}
+	spec.DataPath = dataPath
+	return spec, nil
+}
+
+// teardown logs the output of each node if the test failed, then stops
+// the nodes
+func teardown(t testing.TB, sim *simulation.Simulation, c config) {
+	if t.Failed() {
+		for _, n := range sim.Nodes() {
+			var tail = n.Runner.Tail(c.tailLines)
+			t.Logf("%s (%s) output:\n%s", n.Name(), n.Runner.State(), strings.Join(tail, "\n"))
+		}
+	}
+	if err := sim.Stop(); err != nil {
+		t.Errorf("stopping network: %s", err.Error())
+	}
+	if t.Failed() || c.keepRunPath {
+		t.Logf("node output is persisted at %s", sim.RunPath())
+		return
+	}
+	if err := os.RemoveAll(sim.RunPath()); err != nil {
+		t.Logf("removing run path: %s", err.Error())
+	}
+}
+
+// freePort returns a local TCP port which was unused when checked
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, fmt.Errorf("finding unused port: %s", err.Error())
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// runPathLabel derives a run path label from the test name
+func runPathLabel(t testing.TB) string {
+	var label = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, t.Name())
+	return "masontest_" + label
+}
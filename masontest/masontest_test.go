@@ -0,0 +1,191 @@
+package masontest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/OpenBazaar/mason/builder/runner"
+	"github.com/OpenBazaar/mason/fixture"
+	"github.com/OpenBazaar/mason/simulation"
+	"github.com/OpenBazaar/mason/util"
+)
+
+// fakeNodeScript initializes an empty config and runs until it is killed
+// when started
+const fakeNodeScript = `case "$1" in
+init)
+	while [ $# -gt 0 ]; do [ "$1" = -d ] && data="$2"; shift; done
+	mkdir -p "$data"
+	echo '{"Addresses":{}}' > "$data/config"
+	;;
+start)
+	echo "fake node started"
+	exec sleep 30
+	;;
+esac`
+
+func mustCreateScriptBuild(t *testing.T, label string) (Option, func()) {
+	var path = util.GenerateTempPath(label)
+	if err := os.MkdirAll(path, 0755); err != nil {
+		t.Fatal(err)
+	}
+	var binary = filepath.Join(path, "openbazaard")
+	if err := ioutil.WriteFile(binary, []byte("#!/bin/sh\n"+fakeNodeScript+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	var build = func(simulation.NodeSpec) (*runner.OpenBazaarRunner, error) {
+		return runner.FromBinaryPath(binary)
+	}
+	return WithBuild(build), func() { os.RemoveAll(path) }
+}
+
+func mustListen(t *testing.T) (net.Listener, string) {
+	var l, err = net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l, fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", l.Addr().(*net.TCPAddr).Port)
+}
+
+func TestNodeIsStoppedAndRemovedAfterTest(t *testing.T) {
+	var build, cleanup = mustCreateScriptBuild(t, "masontest_node")
+	defer cleanup()
+	var gateway, gatewayAddr = mustListen(t)
+	defer gateway.Close()
+
+	var subject *runner.OpenBazaarRunner
+	t.Run("node", func(t *testing.T) {
+		subject = Node(t, "v0.13.8", build, WithConfig("Addresses.Gateway", gatewayAddr))
+		if subject.State() != runner.StateReady {
+			t.Errorf("expected node to be ready, but was (%s)", subject.State())
+		}
+	})
+	if subject == nil {
+		t.FailNow()
+	}
+	if s := subject.State(); s != runner.StateExited {
+		t.Errorf("expected node to be stopped after the test, but was (%s)", s)
+	}
+	if _, err := os.Stat(filepath.Dir(subject.RunPath())); !os.IsNotExist(err) {
+		t.Errorf("expected run path to be removed after a passing test, but was not (%v)", err)
+	}
+}
+
+func TestNetworkAssignsUnusedPorts(t *testing.T) {
+	var build, cleanup = mustCreateScriptBuild(t, "masontest_network")
+	defer cleanup()
+	var (
+		vendorGateway, vendorAddr = mustListen(t)
+		buyerGateway, buyerAddr   = mustListen(t)
+	)
+	defer vendorGateway.Close()
+	defer buyerGateway.Close()
+
+	var sim = Network(t, simulation.Topology{
+		Version: "v0.13.8",
+		Nodes: []simulation.NodeSpec{
+			{Name: "vendor", Config: map[string]interface{}{"Addresses.Gateway": vendorAddr}},
+			{Name: "buyer", Config: map[string]interface{}{"Addresses.Gateway": buyerAddr}},
+		},
+	}, build)
+
+	var swarms = make(map[string]bool)
+	for _, n := range sim.Nodes() {
+		var config struct {
+			Addresses struct{ Swarm []string }
+		}
+		b, err := ioutil.ReadFile(filepath.Join(n.Runner.RunPath(), "data", "config"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(b, &config); err != nil {
+			t.Fatal(err)
+		}
+		var swarm = config.Addresses.Swarm
+		if len(swarm) != 1 || !strings.HasPrefix(swarm[0], "/ip4/127.0.0.1/tcp/") {
+			t.Errorf("expected (%s) to be assigned a local swarm address, but was (%v)", n.Name(), swarm)
+			continue
+		}
+		swarms[swarm[0]] = true
+	}
+	if len(swarms) != 2 {
+		t.Errorf("expected each node to be assigned a distinct swarm address, but was (%v)", swarms)
+	}
+}
+
+// failedTB reports the test as failed and records logs and cleanups
+// instead of passing them to the underlying test
+type failedTB struct {
+	testing.TB
+	logs     []string
+	cleanups []func()
+}
+
+func (f *failedTB) Failed() bool      { return true }
+func (f *failedTB) Cleanup(fn func()) { f.cleanups = append(f.cleanups, fn) }
+func (f *failedTB) Logf(format string, args ...interface{}) {
+	f.logs = append(f.logs, fmt.Sprintf(format, args...))
+}
+
+func TestFailedTestLogsNodeOutput(t *testing.T) {
+	var build, cleanup = mustCreateScriptBuild(t, "masontest_failed")
+	defer cleanup()
+	var gateway, gatewayAddr = mustListen(t)
+	defer gateway.Close()
+
+	var tb = &failedTB{TB: t}
+	var subject = Node(tb, "v0.13.8", build, WithConfig("Addresses.Gateway", gatewayAddr))
+	for i := len(tb.cleanups) - 1; i >= 0; i-- {
+		tb.cleanups[i]()
+	}
+	defer os.RemoveAll(filepath.Dir(subject.RunPath()))
+
+	var logs = strings.Join(tb.logs, "\n")
+	if !strings.Contains(logs, "fake node started") {
+		t.Errorf("expected node output to be logged, but was (%s)", logs)
+	}
+	if _, err := os.Stat(subject.RunPath()); err != nil {
+		t.Errorf("expected run path to be kept after a failed test, but was (%s)", err.Error())
+	}
+}
+
+func TestFixtureSnapshotIsReused(t *testing.T) {
+	var snapshot = util.GenerateTempPath("masontest_snapshot")
+	defer os.RemoveAll(snapshot)
+	var seed = fixture.Fixture{
+		Name:  "listing",
+		Nodes: map[string]fixture.Node{"node": {Listings: []json.RawMessage{[]byte(`{"slug":"widget","item":{"title":"Widget"}}`)}}},
+	}
+
+	var sim *simulation.Simulation
+	t.Run("seed", func(t *testing.T) {
+		sim = Network(t, simulation.Topology{Version: "v0.1.0", Nodes: []simulation.NodeSpec{{Name: "node"}}},
+			WithFakeDaemon(), WithFixture(seed), KeepRunPath())
+	})
+	if sim == nil {
+		t.FailNow()
+	}
+	defer os.RemoveAll(sim.RunPath())
+	if err := sim.Snapshot(snapshot); err != nil {
+		t.Fatal(err)
+	}
+
+	var subject = Node(t, "v0.1.0", WithFakeDaemon(), WithSnapshot(snapshot))
+	resp, err := subject.APIRequest(context.Background(), "GET", "/ob/listing/widget", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 {
+		t.Errorf("expected listing from the snapshot, but status was (%d)", resp.StatusCode)
+	}
+	if subject.DataPath() == filepath.Join(snapshot, "node") {
+		t.Error("expected the snapshot to be copied, but it was used in place")
+	}
+}
@@ -0,0 +1,265 @@
+// Package matrix runs scenarios against every combination of versions
+// across the roles of a topology, such as a buyer on one release
+// purchasing from a vendor on another.
+package matrix
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/OpenBazaar/mason/fixture"
+	"github.com/OpenBazaar/mason/scenario"
+	"github.com/OpenBazaar/mason/simulation"
+	"github.com/OpenBazaar/mason/util"
+	"github.com/op/go-logging"
+	"github.com/otiai10/copy"
+)
+
+var log = logging.MustGetLogger("matrix")
+
+// Options controls how the matrix is run
+type Options struct {
+	// Versions are tried for each dimension of the matrix
+	Versions []string
+	// Roles name the dimensions of the matrix. Nodes tagged with a role
+	// share its version. When empty, each node used by the scenarios is
+	// its own dimension.
+	Roles []string
+	// Fixtures seed each combination's nodes before the scenarios run
+	Fixtures []fixture.Fixture
+	// Simulation is used for each combination. Its RunPath is replaced
+	// with a directory beneath RunPath.
+	Simulation simulation.Options
+	// RunPath holds the run path of each combination. One is generated
+	// when empty.
+	RunPath string
+}
+
+// Dimension is a set of nodes which always run the same version
+type Dimension struct {
+	Name  string   `json:"name"`
+	Nodes []string `json:"nodes"`
+}
+
+// Run runs the scenarios against a fresh simulation of the topology for
+// every combination of versions across the dimensions. Combinations run
+// one at a time so each may use the same ports and identities. Builds
+// are cached, so each version is only built once.
+func Run(ctx context.Context, t simulation.Topology, scenarios []scenario.Scenario, opts Options) (Report, error) {
+	if len(opts.Versions) == 0 {
+		return Report{}, fmt.Errorf("no versions to run")
+	}
+	if len(scenarios) == 0 {
+		return Report{}, fmt.Errorf("no scenarios to run")
+	}
+	dims, err := dimensions(t, scenarios, opts.Roles)
+	if err != nil {
+		return Report{}, err
+	}
+	if opts.RunPath == "" {
+		opts.RunPath = util.GenerateRunPath("matrix")
+	}
+	var report = Report{
+		Dimensions: dims,
+		Versions:   opts.Versions,
+		RunPath:    opts.RunPath,
+		Passed:     true,
+		Started:    time.Now(),
+	}
+	for _, s := range scenarios {
+		report.Scenarios = append(report.Scenarios, s.Name)
+	}
+
+	var combos = combinations(len(dims), opts.Versions)
+	for i, combo := range combos {
+		if ctx.Err() != nil {
+			report.Passed = false
+			report.Duration = time.Since(report.Started)
+			return report, ctx.Err()
+		}
+		var versions = make(map[string]string, len(dims))
+		for d, dim := range dims {
+			versions[dim.Name] = combo[d]
+		}
+		log.Infof("running combination %d of %d: %s", i+1, len(combos), describe(dims, versions))
+		var cell = runCell(ctx, t, scenarios, dims, versions, opts, filepath.Join(opts.RunPath, cellLabel(i, dims, versions)))
+		report.Passed = report.Passed && cell.Passed
+		report.Cells = append(report.Cells, cell)
+	}
+	report.Duration = time.Since(report.Started)
+	return report, nil
+}
+
+// dimensions returns the nodes of each role, or each node used by the
+// scenarios when no roles are given
+func dimensions(t simulation.Topology, scenarios []scenario.Scenario, roles []string) ([]Dimension, error) {
+	var dims []Dimension
+	if len(roles) == 0 {
+		var seen = make(map[string]bool)
+		for _, s := range scenarios {
+			for _, step := range s.Steps {
+				if step.Node != "" && !seen[step.Node] {
+					if !hasNode(t, step.Node) {
+						return nil, fmt.Errorf("scenario (%s) uses node (%s) which is not in the topology", s.Name, step.Node)
+					}
+					seen[step.Node] = true
+					dims = append(dims, Dimension{Name: step.Node, Nodes: []string{step.Node}})
+				}
+			}
+		}
+		if len(dims) == 0 {
+			return nil, fmt.Errorf("scenarios do not use any nodes")
+		}
+		return dims, nil
+	}
+	var claimed = make(map[string]string)
+	for _, role := range roles {
+		var dim = Dimension{Name: role}
+		for _, n := range t.Nodes {
+			if !n.HasRole(role) {
+				continue
+			}
+			if other, ok := claimed[n.Name]; ok {
+				return nil, fmt.Errorf("node (%s) has roles (%s) and (%s)", n.Name, other, role)
+			}
+			claimed[n.Name] = role
+			dim.Nodes = append(dim.Nodes, n.Name)
+		}
+		if len(dim.Nodes) == 0 {
+			return nil, fmt.Errorf("no nodes have role (%s)", role)
+		}
+		dims = append(dims, dim)
+	}
+	return dims, nil
+}
+
+func hasNode(t simulation.Topology, name string) bool {
+	for _, n := range t.Nodes {
+		if n.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// combinations returns every assignment of versions to n dimensions,
+// varying the last dimension fastest
+func combinations(n int, versions []string) [][]string {
+	var combos = [][]string{{}}
+	for d := 0; d < n; d++ {
+		var next [][]string
+		for _, combo := range combos {
+			for _, v := range versions {
+				next = append(next, append(append([]string{}, combo...), v))
+			}
+		}
+		combos = next
+	}
+	return combos
+}
+
+// runCell runs the scenarios against a simulation where each dimension's
+// nodes use the assigned version
+func runCell(ctx context.Context, t simulation.Topology, scenarios []scenario.Scenario, dims []Dimension, versions map[string]string, opts Options, runPath string) Cell {
+	var cell = Cell{Versions: versions, RunPath: runPath, Logs: make(map[string]string)}
+	var started = time.Now()
+	var fail = func(err error) Cell {
+		log.Errorf("%s: %s", describe(dims, versions), err.Error())
+		cell.Error = err.Error()
+		cell.Duration = time.Since(started)
+		return cell
+	}
+
+	var nodeVersions = make(map[string]string)
+	for _, dim := range dims {
+		for _, name := range dim.Nodes {
+			nodeVersions[name] = versions[dim.Name]
+		}
+	}
+	var nodes = make([]simulation.NodeSpec, len(t.Nodes))
+	for i, spec := range t.Nodes {
+		if v, ok := nodeVersions[spec.Name]; ok {
+			spec.Version = v
+		} else if spec.Version == "" && t.Version == "" {
+			// nodes outside the matrix run the oldest version
+			spec.Version = opts.Versions[0]
+		}
+		if spec.DataPath != "" {
+			// each combination starts from the original data
+			var dataPath = filepath.Join(runPath, spec.Name, "data")
+			if err := copy.Copy(spec.DataPath, dataPath); err != nil {
+				return fail(fmt.Errorf("copying data path of (%s): %s", spec.Name, err.Error()))
+			}
+			spec.DataPath = dataPath
+		}
+		nodes[i] = spec
+		cell.Logs[spec.Name] = filepath.Join(runPath, spec.Name, "output.log")
+	}
+	t.Nodes = nodes
+
+	var simOpts = opts.Simulation
+	simOpts.RunPath = runPath
+	sim, err := simulation.New(t, simOpts)
+	if err != nil {
+		return fail(err)
+	}
+	defer func() {
+		if err := sim.Stop(); err != nil {
+			log.Warningf("stopping nodes: %s", err.Error())
+		}
+	}()
+	if err := sim.Start(ctx); err != nil {
+		return fail(err)
+	}
+	for _, f := range opts.Fixtures {
+		if _, err := fixture.Apply(ctx, f, sim); err != nil {
+			return fail(err)
+		}
+	}
+
+	cell.Passed = true
+	for i, s := range scenarios {
+		var r = scenario.Run(ctx, s, sim)
+		cell.Passed = cell.Passed && r.Passed
+		cell.Scenarios = append(cell.Scenarios, r)
+		if err := writeScenarioReport(r, filepath.Join(runPath, fmt.Sprintf("scenario-%d.json", i+1))); err != nil {
+			log.Errorf("writing scenario report: %s", err.Error())
+		}
+	}
+	cell.Duration = time.Since(started)
+	return cell
+}
+
+func writeScenarioReport(r scenario.Report, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return r.WriteJSON(f)
+}
+
+// describe renders the versions of each dimension for logs
+func describe(dims []Dimension, versions map[string]string) string {
+	var parts = make([]string, len(dims))
+	for i, d := range dims {
+		parts[i] = d.Name + "=" + versions[d.Name]
+	}
+	return strings.Join(parts, " ")
+}
+
+// cellLabel names the run path of a combination
+func cellLabel(i int, dims []Dimension, versions map[string]string) string {
+	var label = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '.', r == '=':
+			return r
+		}
+		return '_'
+	}, describe(dims, versions))
+	return fmt.Sprintf("%03d_%s", i+1, label)
+}
@@ -0,0 +1,136 @@
+package matrix
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OpenBazaar/mason/builder/runner"
+	"github.com/OpenBazaar/mason/scenario"
+	"github.com/OpenBazaar/mason/simulation"
+	"github.com/OpenBazaar/mason/util"
+)
+
+func TestCombinations(t *testing.T) {
+	var combos = combinations(2, []string{"v1", "v2"})
+	var expected = [][]string{{"v1", "v1"}, {"v1", "v2"}, {"v2", "v1"}, {"v2", "v2"}}
+	if !reflect.DeepEqual(combos, expected) {
+		t.Errorf("expected combinations (%v), but was (%v)", expected, combos)
+	}
+}
+
+func TestDimensions(t *testing.T) {
+	var topology = simulation.Topology{Nodes: []simulation.NodeSpec{
+		{Name: "vendor", Roles: []string{"vendor"}},
+		{Name: "buyer1", Roles: []string{"buyer"}},
+		{Name: "buyer2", Roles: []string{"buyer"}},
+		{Name: "moderator"},
+	}}
+	var s = scenario.Scenario{Name: "purchase", Steps: []scenario.Step{
+		{Action: scenario.ActionCall, Node: "vendor"},
+		{Action: scenario.ActionCall, Node: "buyer1"},
+		{Action: scenario.ActionSleep},
+		{Action: scenario.ActionCall, Node: "vendor"},
+	}}
+
+	dims, err := dimensions(topology, []scenario.Scenario{s}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var expected = []Dimension{{Name: "vendor", Nodes: []string{"vendor"}}, {Name: "buyer1", Nodes: []string{"buyer1"}}}
+	if !reflect.DeepEqual(dims, expected) {
+		t.Errorf("expected scenario nodes as dimensions (%v), but was (%v)", expected, dims)
+	}
+
+	dims, err = dimensions(topology, []scenario.Scenario{s}, []string{"buyer", "vendor"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []Dimension{{Name: "buyer", Nodes: []string{"buyer1", "buyer2"}}, {Name: "vendor", Nodes: []string{"vendor"}}}
+	if !reflect.DeepEqual(dims, expected) {
+		t.Errorf("expected roles as dimensions (%v), but was (%v)", expected, dims)
+	}
+
+	if _, err := dimensions(topology, []scenario.Scenario{s}, []string{"arbiter"}); err == nil {
+		t.Error("expected an error for a role without nodes")
+	}
+}
+
+func TestRunWithFakeDaemon(t *testing.T) {
+	var runPath = util.GenerateTempPath("matrix_test")
+	defer os.RemoveAll(runPath)
+
+	var topology = simulation.Topology{Nodes: []simulation.NodeSpec{
+		{Name: "vendor", Config: map[string]interface{}{"Addresses.Gateway": mustFreeAddress(t)}},
+		{Name: "buyer", Config: map[string]interface{}{"Addresses.Gateway": mustFreeAddress(t)}},
+	}}
+	var s = scenario.Scenario{Name: "config", Steps: []scenario.Step{
+		{Action: scenario.ActionCall, Node: "vendor", Method: "GET", Path: "/ob/config"},
+		{Action: scenario.ActionCall, Node: "buyer", Method: "GET", Path: "/ob/config"},
+	}}
+	// the buyer never starts on v0.2.0
+	var build = func(spec simulation.NodeSpec) (*runner.OpenBazaarRunner, error) {
+		r, err := simulation.FakeBuild(spec)
+		if err != nil || spec.Name != "buyer" || spec.Version != "v0.2.0" {
+			return r, err
+		}
+		return r, r.SetEnvironment(runner.Environment{Vars: map[string]string{"FAKEOBD_HANG": "start"}})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	report, err := Run(ctx, topology, []scenario.Scenario{s}, Options{
+		Versions:   []string{"v0.1.0", "v0.2.0"},
+		Simulation: simulation.Options{Build: build, ReadyTimeout: 5 * time.Second},
+		RunPath:    runPath,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var results []string
+	for _, c := range report.Cells {
+		results = append(results, c.Result())
+	}
+	var expected = []string{"PASS", "ERROR", "PASS", "ERROR"}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("expected results (%v), but was (%v)", expected, results)
+	}
+	if report.Passed || report.Failed() != 2 {
+		t.Errorf("expected 2 failed combinations, but was (%d)", report.Failed())
+	}
+	if _, err := os.Stat(report.Cells[0].Logs["vendor"]); err != nil {
+		t.Errorf("expected vendor log to exist, but was (%s)", err.Error())
+	}
+
+	var text bytes.Buffer
+	if err := report.WriteText(&text); err != nil {
+		t.Fatal(err)
+	}
+	if !regexp.MustCompile(`(?m)^v0\.1\.0\s+PASS\s+ERROR$`).MatchString(text.String()) {
+		t.Errorf("expected grid with vendor versions as rows, but was:\n%s", text.String())
+	}
+	var html bytes.Buffer
+	if err := report.WriteHTML(&html, runPath); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(html.String(), `href="001_vendor=v0.1.0_buyer=v0.1.0/vendor/output.log"`) {
+		t.Errorf("expected html to link to node logs, but was:\n%s", html.String())
+	}
+}
+
+func mustFreeAddress(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", l.Addr().(*net.TCPAddr).Port)
+}
@@ -0,0 +1,199 @@
+package matrix
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/OpenBazaar/mason/scenario"
+)
+
+// Report describes the outcome of every combination in the matrix
+type Report struct {
+	Scenarios  []string      `json:"scenarios"`
+	Dimensions []Dimension   `json:"dimensions"`
+	Versions   []string      `json:"versions"`
+	RunPath    string        `json:"runPath"`
+	Passed     bool          `json:"passed"`
+	Started    time.Time     `json:"started"`
+	Duration   time.Duration `json:"duration"`
+	Cells      []Cell        `json:"cells"`
+}
+
+// Cell describes the outcome of a single combination of versions
+type Cell struct {
+	// Versions maps each dimension to the version its nodes ran
+	Versions map[string]string `json:"versions"`
+	Passed   bool              `json:"passed"`
+	// Error is set when the nodes could not be started or seeded
+	Error   string `json:"error,omitempty"`
+	RunPath string `json:"runPath"`
+	// Logs maps each node to its output log
+	Logs      map[string]string `json:"logs"`
+	Scenarios []scenario.Report `json:"scenarios,omitempty"`
+	Duration  time.Duration     `json:"duration"`
+}
+
+// Result returns PASS, FAIL or ERROR when the nodes could not be started
+func (c Cell) Result() string {
+	switch {
+	case c.Error != "":
+		return "ERROR"
+	case c.Passed:
+		return "PASS"
+	}
+	return "FAIL"
+}
+
+// Failed returns the number of combinations which did not pass
+func (r Report) Failed() int {
+	var failed int
+	for _, c := range r.Cells {
+		if !c.Passed {
+			failed++
+		}
+	}
+	return failed
+}
+
+// grid returns the cells as rows of the first dimension and columns of
+// the second, when the matrix has exactly two dimensions
+func (r Report) grid() ([][]Cell, bool) {
+	if len(r.Dimensions) != 2 || len(r.Cells) != len(r.Versions)*len(r.Versions) {
+		return nil, false
+	}
+	var rows = make([][]Cell, len(r.Versions))
+	for i := range rows {
+		rows[i] = r.Cells[i*len(r.Versions) : (i+1)*len(r.Versions)]
+	}
+	return rows, true
+}
+
+// WriteText writes the matrix to w. Matrices of two dimensions are drawn
+// as a grid, others as a list of combinations. Combinations which did
+// not pass are listed with their run path.
+func (r Report) WriteText(w io.Writer) error {
+	var result = "PASS"
+	if !r.Passed {
+		result = "FAIL"
+	}
+	fmt.Fprintf(w, "%s %s (%d of %d combinations failed, %s)\n", result, strings.Join(r.Scenarios, ", "), r.Failed(), len(r.Cells), r.Duration.Round(time.Second))
+
+	var tw = tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	if rows, ok := r.grid(); ok {
+		fmt.Fprintf(tw, "%s \\ %s\t%s\n", r.Dimensions[0].Name, r.Dimensions[1].Name, strings.Join(r.Versions, "\t"))
+		for i, row := range rows {
+			var results = make([]string, len(row))
+			for j, c := range row {
+				results[j] = c.Result()
+			}
+			fmt.Fprintf(tw, "%s\t%s\n", r.Versions[i], strings.Join(results, "\t"))
+		}
+	} else {
+		for _, c := range r.Cells {
+			fmt.Fprintf(tw, "%s\t%s\n", c.Result(), describe(r.Dimensions, c.Versions))
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	for _, c := range r.Cells {
+		if c.Passed {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s %s\n  %s\n", c.Result(), describe(r.Dimensions, c.Versions), c.RunPath)
+		if c.Error != "" {
+			fmt.Fprintf(w, "  %s\n", c.Error)
+		}
+		for _, s := range c.Scenarios {
+			for _, step := range s.Steps {
+				if !step.Passed && !step.Skipped {
+					fmt.Fprintf(w, "  %s: %s: %s\n", s.Scenario, step.Name, step.Error)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// WriteJSON encodes the report to w as JSON
+func (r Report) WriteJSON(w io.Writer) error {
+	var encoder = json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+var htmlReport = template.Must(template.New("matrix").Funcs(template.FuncMap{
+	"describe": describe,
+	"join":     strings.Join,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{join .Report.Scenarios ", "}} matrix</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+.PASS { background: #dfd; } .FAIL { background: #fdd; } .ERROR { background: #fec; }
+small a { margin-right: 4px; }
+</style>
+</head>
+<body>
+<h1>{{join .Report.Scenarios ", "}}</h1>
+<p>{{.Report.Failed}} of {{len .Report.Cells}} combinations failed in {{.Report.Duration}}.</p>
+{{define "cell"}}<td class="{{.Cell.Result}}"><a href="{{.Dir}}">{{.Cell.Result}}</a><br><small>{{range $node, $log := .Logs}}<a href="{{$log}}">{{$node}}</a>{{end}}</small>{{if .Cell.Error}}<br><small>{{.Cell.Error}}</small>{{end}}</td>{{end}}
+{{if .Grid}}<table>
+<tr><th>{{(index .Report.Dimensions 0).Name}} \ {{(index .Report.Dimensions 1).Name}}</th>{{range .Report.Versions}}<th>{{.}}</th>{{end}}</tr>
+{{range $i, $row := .Grid}}<tr><th>{{index $.Report.Versions $i}}</th>{{range $row}}{{template "cell" .}}{{end}}</tr>
+{{end}}</table>
+{{else}}<table>
+<tr><th>versions</th><th>result</th></tr>
+{{range .Cells}}<tr><td>{{describe $.Report.Dimensions .Cell.Versions}}</td>{{template "cell" .}}</tr>
+{{end}}</table>
+{{end}}</body>
+</html>
+`))
+
+type htmlCell struct {
+	Cell Cell
+	Dir  string
+	Logs map[string]string
+}
+
+// WriteHTML writes the matrix to w as an HTML page linking to the run
+// path and node logs of each combination. Links are relative to dir,
+// where the page is expected to be saved.
+func (r Report) WriteHTML(w io.Writer, dir string) error {
+	var cells = make([]htmlCell, len(r.Cells))
+	for i, c := range r.Cells {
+		cells[i] = htmlCell{Cell: c, Dir: relativeTo(dir, c.RunPath), Logs: make(map[string]string)}
+		for node, path := range c.Logs {
+			cells[i].Logs[node] = relativeTo(dir, path)
+		}
+	}
+	var data = struct {
+		Report Report
+		Cells  []htmlCell
+		Grid   [][]htmlCell
+	}{Report: r, Cells: cells}
+	if len(r.Dimensions) == 2 && len(cells) == len(r.Versions)*len(r.Versions) {
+		for i := range r.Versions {
+			data.Grid = append(data.Grid, cells[i*len(r.Versions):(i+1)*len(r.Versions)])
+		}
+	}
+	return htmlReport.Execute(w, data)
+}
+
+func relativeTo(dir, path string) string {
+	if rel, err := filepath.Rel(dir, path); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
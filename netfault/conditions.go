@@ -0,0 +1,96 @@
+package netfault
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Conditions describe how data is carried in one direction of a link
+type Conditions struct {
+	// Latency delays every chunk of data
+	Latency time.Duration
+	// Jitter adds up to this much random delay to each chunk. Data is
+	// never reordered.
+	Jitter time.Duration
+	// Bandwidth caps throughput in bytes per second, unlimited when zero
+	Bandwidth int64
+	// DropRate is the probability, from 0 to 1, that a chunk is lost. As
+	// the proxy carries TCP, a lost chunk is delivered after a
+	// retransmission timeout of twice the latency, and at least 200ms,
+	// holding back the data behind it.
+	DropRate float64
+	// Partitioned closes the link's connections and refuses new ones
+	Partitioned bool
+}
+
+const minRetransmitTimeout = 200 * time.Millisecond
+
+// Validate returns an error if the conditions cannot be applied
+func (c Conditions) Validate() error {
+	switch {
+	case c.Latency < 0 || c.Jitter < 0:
+		return fmt.Errorf("latency and jitter cannot be negative")
+	case c.Bandwidth < 0:
+		return fmt.Errorf("bandwidth cannot be negative")
+	case c.DropRate < 0 || c.DropRate > 1:
+		return fmt.Errorf("drop rate must be between 0 and 1")
+	}
+	return nil
+}
+
+// retransmitTimeout is the delay added to a lost chunk
+func (c Conditions) retransmitTimeout() time.Duration {
+	if rto := 2 * c.Latency; rto > minRetransmitTimeout {
+		return rto
+	}
+	return minRetransmitTimeout
+}
+
+// conditionsJSON writes durations as strings (ex: 50ms)
+type conditionsJSON struct {
+	Latency     string  `json:"latency,omitempty"`
+	Jitter      string  `json:"jitter,omitempty"`
+	Bandwidth   int64   `json:"bandwidth,omitempty"`
+	DropRate    float64 `json:"dropRate,omitempty"`
+	Partitioned bool    `json:"partitioned,omitempty"`
+}
+
+// MarshalJSON writes durations as strings
+func (c Conditions) MarshalJSON() ([]byte, error) {
+	var j = conditionsJSON{Bandwidth: c.Bandwidth, DropRate: c.DropRate, Partitioned: c.Partitioned}
+	if c.Latency != 0 {
+		j.Latency = c.Latency.String()
+	}
+	if c.Jitter != 0 {
+		j.Jitter = c.Jitter.String()
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON parses durations written as strings (ex: 50ms)
+func (c *Conditions) UnmarshalJSON(b []byte) error {
+	var j conditionsJSON
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	var parsed = Conditions{Bandwidth: j.Bandwidth, DropRate: j.DropRate, Partitioned: j.Partitioned}
+	for _, d := range []struct {
+		name  string
+		value string
+		into  *time.Duration
+	}{
+		{"latency", j.Latency, &parsed.Latency},
+		{"jitter", j.Jitter, &parsed.Jitter},
+	} {
+		if d.value == "" {
+			continue
+		}
+		var err error
+		if *d.into, err = time.ParseDuration(d.value); err != nil {
+			return fmt.Errorf("invalid %s: %s", d.name, err.Error())
+		}
+	}
+	*c = parsed
+	return nil
+}
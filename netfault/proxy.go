@@ -0,0 +1,411 @@
+// Package netfault emulates unreliable networks between local nodes with
+// a userspace TCP proxy. Each directed link between two named nodes has
+// its own listener, so a node reaches its peers through the proxy once
+// their addresses are rewritten. Links may add latency and jitter, cap
+// bandwidth, lose data or be partitioned, and their conditions may change
+// while connections are open. No privileges are needed.
+package netfault
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/op/go-logging"
+)
+
+// DefaultHost is the loopback address proxies listen on. It differs from
+// 127.0.0.1 so nodes may be prevented from dialing each other directly.
+// Linux routes all of 127.0.0.0/8 to loopback, but other systems such as
+// macOS only assign 127.0.0.1 unless an alias is added.
+const DefaultHost = "127.0.0.2"
+
+// CheckHost returns an error describing how to proceed when host cannot
+// be listened on
+func CheckHost(host string) error {
+	l, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
+	if err != nil {
+		return fmt.Errorf("fault proxy cannot listen on (%s): %s. Add it to the loopback interface (ex: sudo ifconfig lo0 alias %s up) or choose another address which the nodes do not listen on", host, err.Error(), host)
+	}
+	return l.Close()
+}
+
+// Any matches every node when setting conditions
+const Any = "*"
+
+const (
+	chunkSize   = 16 * 1024
+	chunkBuffer = 64
+	dialTimeout = 5 * time.Second
+)
+
+var log = logging.MustGetLogger("netfault")
+
+type link struct {
+	from, to string
+}
+
+func (l link) String() string {
+	return l.from + "->" + l.to
+}
+
+type route struct {
+	link
+	target string
+}
+
+// Proxy carries connections between nodes under the conditions of each
+// link. Links without conditions forward data as fast as possible.
+type Proxy struct {
+	host string
+
+	mutex      sync.Mutex
+	nodes      map[string]bool
+	conditions map[link]Conditions
+	routes     map[route]string
+	listeners  []net.Listener
+	conns      map[*proxyConn]bool
+	random     *rand.Rand
+	closed     bool
+}
+
+// New returns a proxy which listens on host, such as DefaultHost
+func New(host string) *Proxy {
+	return &Proxy{
+		host:       host,
+		nodes:      make(map[string]bool),
+		conditions: make(map[link]Conditions),
+		routes:     make(map[route]string),
+		conns:      make(map[*proxyConn]bool),
+		random:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Host returns the address the proxy listens on
+func (p *Proxy) Host() string {
+	return p.host
+}
+
+// Route returns an address which from may dial to reach target, a
+// host:port belonging to to. Routes are created once and reused.
+func (p *Proxy) Route(from, to, target string) (string, error) {
+	if from == Any || to == Any || from == to {
+		return "", fmt.Errorf("cannot route from (%s) to (%s)", from, to)
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.closed {
+		return "", fmt.Errorf("proxy is closed")
+	}
+	var r = route{link: link{from, to}, target: target}
+	if addr, ok := p.routes[r]; ok {
+		return addr, nil
+	}
+	l, err := net.Listen("tcp", net.JoinHostPort(p.host, "0"))
+	if err != nil {
+		return "", fmt.Errorf("listening for %s: %s", r.link, err.Error())
+	}
+	p.listeners = append(p.listeners, l)
+	p.routes[r] = l.Addr().String()
+	p.nodes[from], p.nodes[to] = true, true
+	go p.serve(l, r)
+	log.Debugf("routing %s (%s) through %s", r.link, target, l.Addr())
+	return l.Addr().String(), nil
+}
+
+// RouteMultiaddr rewrites an /ip4/<host>/tcp/<port> multiaddr of to, with
+// any suffix such as /ipfs/<id>, so from dials it through the proxy
+func (p *Proxy) RouteMultiaddr(from, to, maddr string) (string, error) {
+	var parts = strings.Split(maddr, "/")
+	if len(parts) < 5 || parts[0] != "" || parts[1] != "ip4" || parts[3] != "tcp" {
+		return "", fmt.Errorf("unsupported multiaddr (%s)", maddr)
+	}
+	addr, err := p.Route(from, to, net.JoinHostPort(parts[2], parts[4]))
+	if err != nil {
+		return "", err
+	}
+	host, port, _ := net.SplitHostPort(addr)
+	parts[2], parts[4] = host, port
+	return strings.Join(parts, "/"), nil
+}
+
+// Set changes the conditions of data sent from one node to another. Any
+// may be used for either node to change every link to or from the other.
+// Open connections are affected immediately.
+func (p *Proxy) Set(from, to string, c Conditions) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	links, err := p.expand(from, to)
+	if err != nil {
+		return err
+	}
+	for _, l := range links {
+		p.conditions[l] = c
+	}
+	p.closePartitioned()
+	return nil
+}
+
+// Conditions returns the conditions of data sent from one node to another
+func (p *Proxy) Conditions(from, to string) Conditions {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.conditions[link{from, to}]
+}
+
+// Partition separates each group of nodes from the others in both
+// directions, leaving other conditions in place. Nodes which are not in
+// any group are unaffected.
+func (p *Proxy) Partition(groups [][]string) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	var groupOf = make(map[string]int)
+	for i, group := range groups {
+		for _, name := range group {
+			if !p.nodes[name] {
+				return fmt.Errorf("unknown node (%s)", name)
+			}
+			if _, ok := groupOf[name]; ok {
+				return fmt.Errorf("node (%s) is in more than one group", name)
+			}
+			groupOf[name] = i
+		}
+	}
+	for a, ga := range groupOf {
+		for b, gb := range groupOf {
+			if ga != gb {
+				var c = p.conditions[link{a, b}]
+				c.Partitioned = true
+				p.conditions[link{a, b}] = c
+			}
+		}
+	}
+	p.closePartitioned()
+	return nil
+}
+
+// Heal removes every partition, leaving other conditions in place
+func (p *Proxy) Heal() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for l, c := range p.conditions {
+		c.Partitioned = false
+		p.conditions[l] = c
+	}
+}
+
+// Close stops every listener and closes open connections
+func (p *Proxy) Close() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+	var firstErr error
+	for _, l := range p.listeners {
+		if err := l.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	for c := range p.conns {
+		c.close()
+	}
+	return firstErr
+}
+
+// expand returns the links matched by from and to, where either may be
+// Any
+func (p *Proxy) expand(from, to string) ([]link, error) {
+	var names = make([]string, 0, len(p.nodes))
+	for name := range p.nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var match = func(pattern string) ([]string, error) {
+		if pattern == Any {
+			return names, nil
+		}
+		if !p.nodes[pattern] {
+			return nil, fmt.Errorf("unknown node (%s)", pattern)
+		}
+		return []string{pattern}, nil
+	}
+	froms, err := match(from)
+	if err != nil {
+		return nil, err
+	}
+	tos, err := match(to)
+	if err != nil {
+		return nil, err
+	}
+	var links []link
+	for _, f := range froms {
+		for _, t := range tos {
+			if f != t {
+				links = append(links, link{f, t})
+			}
+		}
+	}
+	return links, nil
+}
+
+// partitioned returns true when either direction between the nodes of l
+// is partitioned, as TCP cannot carry data one way
+func (p *Proxy) partitioned(l link) bool {
+	return p.conditions[l].Partitioned || p.conditions[link{l.to, l.from}].Partitioned
+}
+
+// closePartitioned closes connections across partitioned links. The
+// mutex must be held.
+func (p *Proxy) closePartitioned() {
+	for c := range p.conns {
+		if p.partitioned(c.link) {
+			c.close()
+		}
+	}
+}
+
+func (p *Proxy) serve(l net.Listener, r route) {
+	for {
+		client, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go p.handle(client, r)
+	}
+}
+
+// handle dials the target for a client and forwards data both ways. Data
+// from the client follows the conditions of the route's link, and data
+// back follows the reverse link.
+func (p *Proxy) handle(client net.Conn, r route) {
+	p.mutex.Lock()
+	var refuse = p.closed || p.partitioned(r.link)
+	p.mutex.Unlock()
+	if refuse {
+		client.Close()
+		return
+	}
+	var dialer = net.Dialer{Timeout: dialTimeout, LocalAddr: &net.TCPAddr{IP: net.ParseIP(p.host)}}
+	upstream, err := dialer.Dial("tcp", r.target)
+	if err != nil {
+		log.Warningf("dialing %s (%s): %s", r.link, r.target, err.Error())
+		client.Close()
+		return
+	}
+
+	var c = &proxyConn{link: r.link, client: client, upstream: upstream}
+	p.mutex.Lock()
+	if p.closed || p.partitioned(r.link) {
+		p.mutex.Unlock()
+		c.close()
+		return
+	}
+	p.conns[c] = true
+	p.mutex.Unlock()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		p.pipe(upstream, client, r.link)
+	}()
+	go func() {
+		defer wg.Done()
+		p.pipe(client, upstream, link{r.to, r.from})
+	}()
+	wg.Wait()
+	c.close()
+	p.mutex.Lock()
+	delete(p.conns, c)
+	p.mutex.Unlock()
+}
+
+type chunk struct {
+	data []byte
+	due  time.Time
+}
+
+// pipe copies src to dst under the current conditions of l. Chunks are
+// read as they arrive and delivered in order once due.
+func (p *Proxy) pipe(dst, src net.Conn, l link) {
+	var chunks = make(chan chunk, chunkBuffer)
+	go func() {
+		defer close(chunks)
+		var last time.Time
+		for {
+			var buf = make([]byte, chunkSize)
+			n, err := src.Read(buf)
+			if n > 0 {
+				var due = time.Now().Add(p.delay(l))
+				if due.Before(last) {
+					due = last
+				}
+				last = due
+				chunks <- chunk{data: buf[:n], due: due}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	var failed bool
+	for c := range chunks {
+		if failed {
+			continue
+		}
+		time.Sleep(time.Until(c.due))
+		// the chunk arrives once it has been transmitted at the capped rate
+		if bandwidth := p.Conditions(l.from, l.to).Bandwidth; bandwidth > 0 {
+			time.Sleep(time.Duration(int64(len(c.data)) * int64(time.Second) / bandwidth))
+		}
+		if _, err := dst.Write(c.data); err != nil {
+			// drain the reader so it may exit
+			failed = true
+			src.Close()
+		}
+	}
+	if tcp, ok := dst.(*net.TCPConn); ok && !failed {
+		tcp.CloseWrite()
+	}
+}
+
+// delay returns how long a chunk read now is held before delivery
+func (p *Proxy) delay(l link) time.Duration {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	var c = p.conditions[l]
+	var d = c.Latency
+	if c.Jitter > 0 {
+		d += time.Duration(p.random.Int63n(int64(c.Jitter) + 1))
+	}
+	if c.DropRate > 0 && p.random.Float64() < c.DropRate {
+		d += c.retransmitTimeout()
+	}
+	return d
+}
+
+// proxyConn is a client connection and its upstream
+type proxyConn struct {
+	link     link
+	client   io.Closer
+	upstream io.Closer
+	once     sync.Once
+}
+
+func (c *proxyConn) close() {
+	c.once.Do(func() {
+		c.client.Close()
+		c.upstream.Close()
+	})
+}
@@ -0,0 +1,235 @@
+package netfault
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// mustEchoServer returns the address of a server which echoes each
+// connection back to itself
+func mustEchoServer(t *testing.T) (string, func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(conn, conn)
+				conn.Close()
+			}()
+		}
+	}()
+	return l.Addr().String(), func() { l.Close() }
+}
+
+func mustProxy(t *testing.T) (*Proxy, string, func()) {
+	target, stopEcho := mustEchoServer(t)
+	var p = New(DefaultHost)
+	addr, err := p.Route("a", "b", target)
+	if err != nil {
+		stopEcho()
+		t.Fatal(err)
+	}
+	return p, addr, func() {
+		p.Close()
+		stopEcho()
+	}
+}
+
+// roundTrip sends msg through conn and returns how long the echo took
+func roundTrip(t *testing.T, conn net.Conn, msg []byte) time.Duration {
+	var started = time.Now()
+	if _, err := conn.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+	var echo = make([]byte, len(msg))
+	if _, err := io.ReadFull(conn, echo); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(echo, msg) {
+		t.Fatalf("expected echo (%q), but was (%q)", msg, echo)
+	}
+	return time.Since(started)
+}
+
+func TestProxyAppliesLatencyToOpenConnections(t *testing.T) {
+	var p, addr, cleanup = mustProxy(t)
+	defer cleanup()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if d := roundTrip(t, conn, []byte("ping")); d > 100*time.Millisecond {
+		t.Errorf("expected an unconditioned link to be fast, but round trip took (%s)", d)
+	}
+	if err := p.Set("a", "b", Conditions{Latency: 150 * time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Set("b", "a", Conditions{Latency: 50 * time.Millisecond, Jitter: 10 * time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+	if d := roundTrip(t, conn, []byte("ping")); d < 200*time.Millisecond {
+		t.Errorf("expected latency of both directions, but round trip took (%s)", d)
+	}
+}
+
+func TestProxyCapsBandwidth(t *testing.T) {
+	var p, addr, cleanup = mustProxy(t)
+	defer cleanup()
+	if err := p.Set("a", "b", Conditions{Bandwidth: 64 * 1024}); err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	go conn.Write(make([]byte, 32*1024))
+	var started = time.Now()
+	if _, err := io.ReadFull(conn, make([]byte, 32*1024)); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(started); d < 400*time.Millisecond {
+		t.Errorf("expected 32KiB at 64KiB/s to take about 500ms, but took (%s)", d)
+	}
+}
+
+func TestProxyDelaysDroppedData(t *testing.T) {
+	var p, addr, cleanup = mustProxy(t)
+	defer cleanup()
+	if err := p.Set(Any, Any, Conditions{DropRate: 1}); err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if d := roundTrip(t, conn, []byte("ping")); d < 2*minRetransmitTimeout {
+		t.Errorf("expected each direction to wait for retransmission, but round trip took (%s)", d)
+	}
+}
+
+func TestProxyPartitions(t *testing.T) {
+	var p, addr, cleanup = mustProxy(t)
+	defer cleanup()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	roundTrip(t, conn, []byte("ping"))
+
+	if err := p.Partition([][]string{{"a"}, {"b"}}); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Error("expected open connection to be closed by the partition")
+	}
+	refused, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer refused.Close()
+	refused.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := refused.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("expected new connection to be closed while partitioned, but was (%v)", err)
+	}
+
+	p.Heal()
+	healed, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer healed.Close()
+	roundTrip(t, healed, []byte("ping"))
+}
+
+func TestSetExpandsWildcards(t *testing.T) {
+	var p = New(DefaultHost)
+	defer p.Close()
+	for _, r := range [][2]string{{"a", "b"}, {"b", "c"}} {
+		if _, err := p.Route(r[0], r[1], "127.0.0.1:1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var slow = Conditions{Latency: time.Second}
+	if err := p.Set(Any, "c", slow); err != nil {
+		t.Fatal(err)
+	}
+	for _, from := range []string{"a", "b"} {
+		if c := p.Conditions(from, "c"); c != slow {
+			t.Errorf("expected (%s->c) to be slow, but was (%+v)", from, c)
+		}
+	}
+	if c := p.Conditions("c", "a"); c != (Conditions{}) {
+		t.Errorf("expected (c->a) to be unchanged, but was (%+v)", c)
+	}
+	if err := p.Set("a", "z", slow); err == nil {
+		t.Error("expected an error for an unknown node")
+	}
+	if err := p.Set("a", "b", Conditions{DropRate: 2}); err == nil {
+		t.Error("expected an error for an invalid drop rate")
+	}
+}
+
+func TestCheckHost(t *testing.T) {
+	if err := CheckHost(DefaultHost); err != nil {
+		t.Errorf("expected default host to be bindable, but was (%s)", err)
+	}
+	// TEST-NET-1 is reserved for documentation and never assigned
+	if err := CheckHost("192.0.2.1"); err == nil {
+		t.Error("expected unassigned address to fail, but did not")
+	}
+}
+
+func TestRouteMultiaddr(t *testing.T) {
+	var p = New(DefaultHost)
+	defer p.Close()
+	maddr, err := p.RouteMultiaddr("a", "b", "/ip4/127.0.0.1/tcp/4001/ipfs/QmB")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr, _ := p.Route("a", "b", "127.0.0.1:4001")
+	host, port, _ := net.SplitHostPort(addr)
+	if expected := "/ip4/" + host + "/tcp/" + port + "/ipfs/QmB"; maddr != expected {
+		t.Errorf("expected multiaddr (%s), but was (%s)", expected, maddr)
+	}
+	if _, err := p.RouteMultiaddr("a", "b", "/ip6/::1/tcp/4001"); err == nil {
+		t.Error("expected an error for an ip6 multiaddr")
+	}
+}
+
+func TestConditionsJSON(t *testing.T) {
+	var c Conditions
+	if err := json.Unmarshal([]byte(`{"latency":"50ms","jitter":"5ms","bandwidth":1024,"dropRate":0.1}`), &c); err != nil {
+		t.Fatal(err)
+	}
+	var expected = Conditions{Latency: 50 * time.Millisecond, Jitter: 5 * time.Millisecond, Bandwidth: 1024, DropRate: 0.1}
+	if !reflect.DeepEqual(c, expected) {
+		t.Errorf("expected conditions (%+v), but was (%+v)", expected, c)
+	}
+	b, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"latency":"50ms","jitter":"5ms","bandwidth":1024,"dropRate":0.1}` {
+		t.Errorf("expected durations to be written as strings, but was (%s)", b)
+	}
+	if err := json.Unmarshal([]byte(`{"latency":"soon"}`), &c); err == nil {
+		t.Error("expected an error for an invalid duration")
+	}
+}
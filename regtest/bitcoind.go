@@ -0,0 +1,310 @@
+package regtest
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/OpenBazaar/mason/builder/runner"
+)
+
+const (
+	// coinbaseMaturity blocks are mined on start so the miner may spend
+	coinbaseMaturity   = 101
+	bitcoindStartup    = time.Minute
+	bitcoindShutdown   = 10 * time.Second
+	bitcoindWalletName = "mason"
+)
+
+// rpc error codes which are expected while starting
+const (
+	rpcMethodNotFound    = -32601
+	rpcWalletNotFound    = -18
+	rpcWalletAlreadyOpen = -35
+)
+
+// BitcoindOptions controls how a bitcoind regtest process is run
+type BitcoindOptions struct {
+	// Binary is the path to bitcoind, found on PATH when empty
+	Binary string
+	// DataPath holds the chain and bitcoind.log. A temporary directory
+	// is used when empty.
+	DataPath string
+}
+
+// Bitcoind runs a bitcoind regtest node which funds and confirms
+// transactions. Nodes use it as the trusted peer of an SPV wallet and are
+// started with --regtest.
+type Bitcoind struct {
+	binary   string
+	dataPath string
+	p2pPort  int
+	rpcPort  int
+	password string
+
+	cmd    *exec.Cmd
+	exited chan struct{}
+	miner  string
+}
+
+// NewBitcoind prepares a regtest process, returning an error when
+// bitcoind is not installed
+func NewBitcoind(opts BitcoindOptions) (*Bitcoind, error) {
+	var binary = opts.Binary
+	if binary == "" {
+		var err error
+		if binary, err = exec.LookPath("bitcoind"); err != nil {
+			return nil, fmt.Errorf("bitcoind is not installed: %s", err.Error())
+		}
+	}
+	var dataPath = opts.DataPath
+	if dataPath == "" {
+		var err error
+		if dataPath, err = ioutil.TempDir("", "mason_regtest"); err != nil {
+			return nil, err
+		}
+	}
+	var secret = make([]byte, 16)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return &Bitcoind{binary: binary, dataPath: dataPath, password: hex.EncodeToString(secret)}, nil
+}
+
+// TrustedPeer returns the host:port nodes connect to
+func (b *Bitcoind) TrustedPeer() string {
+	return "127.0.0.1:" + strconv.Itoa(b.p2pPort)
+}
+
+// Start runs bitcoind and mines enough blocks for the miner to spend
+func (b *Bitcoind) Start(ctx context.Context) error {
+	if err := os.MkdirAll(b.dataPath, 0755); err != nil {
+		return fmt.Errorf("creating bitcoind data path: %s", err.Error())
+	}
+	var err error
+	if b.p2pPort, err = freePort(); err != nil {
+		return err
+	}
+	if b.rpcPort, err = freePort(); err != nil {
+		return err
+	}
+	logFile, err := os.Create(filepath.Join(b.dataPath, "bitcoind.log"))
+	if err != nil {
+		return fmt.Errorf("creating bitcoind log: %s", err.Error())
+	}
+	defer logFile.Close()
+
+	b.cmd = exec.Command(b.binary,
+		"-regtest",
+		"-datadir="+b.dataPath,
+		"-printtoconsole",
+		"-server",
+		"-listen",
+		"-bind=127.0.0.1",
+		"-port="+strconv.Itoa(b.p2pPort),
+		"-rpcbind=127.0.0.1",
+		"-rpcallowip=127.0.0.1",
+		"-rpcport="+strconv.Itoa(b.rpcPort),
+		"-rpcuser=mason",
+		"-rpcpassword="+b.password,
+		// SPV wallets load bloom filters, which are disabled by default
+		"-peerbloomfilters=1",
+		"-fallbackfee=0.0002",
+		"-txindex",
+	)
+	b.cmd.Stdout, b.cmd.Stderr = logFile, logFile
+	if err := b.cmd.Start(); err != nil {
+		return fmt.Errorf("starting bitcoind: %s", err.Error())
+	}
+	b.exited = make(chan struct{})
+	go func() {
+		b.cmd.Wait()
+		close(b.exited)
+	}()
+	log.Infof("bitcoind regtest listening on %s, output is persisted at %s", b.TrustedPeer(), logFile.Name())
+
+	if err := b.waitForRPC(ctx); err != nil {
+		b.Stop()
+		return err
+	}
+	if err := b.loadWallet(ctx); err != nil {
+		b.Stop()
+		return err
+	}
+	if err := b.call(ctx, &b.miner, "getnewaddress"); err != nil {
+		b.Stop()
+		return fmt.Errorf("creating miner address: %s", err.Error())
+	}
+	return b.Mine(ctx, coinbaseMaturity)
+}
+
+// Configure has the node's bitcoin wallet use bitcoind as its only peer
+// and start on the regtest network
+func (b *Bitcoind) Configure(r *runner.OpenBazaarRunner) error {
+	if b.p2pPort == 0 {
+		return fmt.Errorf("bitcoind is not started")
+	}
+	var prefix, walletType = "Wallet.", "spvwallet"
+	if r.Supports(runner.CapabilityMultiwallet) {
+		prefix, walletType = "Wallets.BTC.", "SPV"
+	}
+	if err := r.SetConfigValue(prefix+"Type", walletType); err != nil {
+		return fmt.Errorf("setting wallet type: %s", err.Error())
+	}
+	if err := r.SetConfigValue(prefix+"TrustedPeer", b.TrustedPeer()); err != nil {
+		return fmt.Errorf("setting trusted peer: %s", err.Error())
+	}
+	r.AddArgs("--regtest")
+	return nil
+}
+
+// Fund sends amount satoshis from the miner to address
+func (b *Bitcoind) Fund(ctx context.Context, address string, amount int64) (string, error) {
+	if amount <= 0 {
+		return "", fmt.Errorf("amount must be positive")
+	}
+	var txid string
+	if err := b.call(ctx, &txid, "sendtoaddress", address, btc(amount)); err != nil {
+		return "", fmt.Errorf("funding (%s): %s", address, err.Error())
+	}
+	return txid, nil
+}
+
+// Mine generates blocks paying the miner
+func (b *Bitcoind) Mine(ctx context.Context, blocks int) error {
+	if blocks <= 0 {
+		return fmt.Errorf("blocks must be positive")
+	}
+	if err := b.call(ctx, nil, "generatetoaddress", blocks, b.miner); err != nil {
+		return fmt.Errorf("mining: %s", err.Error())
+	}
+	return nil
+}
+
+// Stop asks bitcoind to shut down, killing it if it does not exit in time
+func (b *Bitcoind) Stop() error {
+	if b.cmd == nil || b.exited == nil {
+		return nil
+	}
+	select {
+	case <-b.exited:
+		return nil
+	default:
+	}
+	var ctx, cancel = context.WithTimeout(context.Background(), bitcoindShutdown)
+	defer cancel()
+	if err := b.call(ctx, nil, "stop"); err != nil {
+		log.Warningf("stopping bitcoind: %s", err.Error())
+	}
+	select {
+	case <-b.exited:
+		return nil
+	case <-ctx.Done():
+		return b.cmd.Process.Kill()
+	}
+}
+
+// waitForRPC waits until bitcoind answers RPC requests
+func (b *Bitcoind) waitForRPC(ctx context.Context) error {
+	var deadline = time.Now().Add(bitcoindStartup)
+	for {
+		var err = b.call(ctx, nil, "getblockchaininfo")
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("waiting for bitcoind: %s", err.Error())
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-b.exited:
+			return fmt.Errorf("bitcoind exited, see %s", filepath.Join(b.dataPath, "bitcoind.log"))
+		case <-time.After(250 * time.Millisecond):
+		}
+	}
+}
+
+// loadWallet creates the miner's wallet on versions which do not load a
+// default wallet
+func (b *Bitcoind) loadWallet(ctx context.Context) error {
+	var err = b.call(ctx, nil, "getwalletinfo")
+	if rpcErr, ok := err.(*rpcError); !ok || rpcErr.Code != rpcWalletNotFound {
+		return err
+	}
+	err = b.call(ctx, nil, "createwallet", bitcoindWalletName)
+	if rpcErr, ok := err.(*rpcError); ok && (rpcErr.Code == rpcWalletAlreadyOpen || rpcErr.Code == rpcMethodNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("creating wallet: %s", err.Error())
+	}
+	return nil
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+// call makes a JSON-RPC request, decoding the result into result unless
+// it is nil
+func (b *Bitcoind) call(ctx context.Context, result interface{}, method string, params ...interface{}) error {
+	if params == nil {
+		params = []interface{}{}
+	}
+	body, err := json.Marshal(map[string]interface{}{"jsonrpc": "1.0", "id": "mason", "method": method, "params": params})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", fmt.Sprintf("http://127.0.0.1:%d/", b.rpcPort), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.SetBasicAuth("mason", b.password)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// errors are returned with a non-200 status and a JSON body, except
+	// for authentication failures
+	var reply struct {
+		Result json.RawMessage `json:"result"`
+		Error  *rpcError       `json:"error"`
+	}
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, &reply); err != nil {
+		return fmt.Errorf("%s: status %d: %s", method, resp.StatusCode, bytes.TrimSpace(raw))
+	}
+	if reply.Error != nil {
+		return reply.Error
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(reply.Result, result)
+}
+
+// btc formats satoshis as a decimal amount of bitcoin
+func btc(satoshis int64) json.Number {
+	return json.Number(fmt.Sprintf("%d.%08d", satoshis/1e8, satoshis%1e8))
+}
@@ -0,0 +1,59 @@
+package regtest
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/OpenBazaar/mason/util"
+)
+
+func TestBitcoindFundsAndMines(t *testing.T) {
+	if _, err := exec.LookPath("bitcoind"); err != nil {
+		t.Skip("bitcoind is not installed")
+	}
+	if testing.Short() {
+		t.Skip("skipping bitcoind in short mode")
+	}
+	var dataPath = util.GenerateTempPath("regtest_bitcoind")
+	defer os.RemoveAll(dataPath)
+	b, err := NewBitcoind(BitcoindOptions{DataPath: dataPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ctx, cancel = context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	if err := b.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Stop()
+
+	var address string
+	if err := b.call(ctx, &address, "getnewaddress"); err != nil {
+		t.Fatal(err)
+	}
+	txid, err := b.Fund(ctx, address, 150000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Mine(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	var tx struct{ Confirmations int }
+	if err := b.call(ctx, &tx, "gettransaction", txid); err != nil {
+		t.Fatal(err)
+	}
+	if tx.Confirmations != 1 {
+		t.Errorf("expected funding to have (1) confirmation, but was (%d)", tx.Confirmations)
+	}
+}
+
+func TestBtc(t *testing.T) {
+	for satoshis, expected := range map[int64]string{1: "0.00000001", 150000000: "1.50000000", 2100000000000000: "21000000.00000000"} {
+		if s := btc(satoshis).String(); s != expected {
+			t.Errorf("expected (%d) satoshis as (%s), but was (%s)", satoshis, expected, s)
+		}
+	}
+}
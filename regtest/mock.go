@@ -0,0 +1,269 @@
+package regtest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/OpenBazaar/mason/builder/runner"
+)
+
+// MockWalletType is the wallet Type which has the fake daemon use a Mock
+// at the URL in the wallet's MockAPI
+const MockWalletType = "mock"
+
+// Tx is a transfer recorded by a Mock
+type Tx struct {
+	TxID   string `json:"txid"`
+	From   string `json:"from,omitempty"`
+	To     string `json:"to"`
+	Amount int64  `json:"amount"`
+	// Height is the block which confirmed the transaction, zero while
+	// it is pending
+	Height        int `json:"height"`
+	Confirmations int `json:"confirmations"`
+}
+
+// Balance of an address in satoshis
+type Balance struct {
+	Address     string `json:"address"`
+	Confirmed   int64  `json:"confirmed"`
+	Unconfirmed int64  `json:"unconfirmed"`
+	Height      int    `json:"height"`
+}
+
+// Mock is an in process ledger of addresses and balances served over HTTP.
+// It does not validate addresses or sign anything, so only the fake daemon
+// can use it.
+//
+// The API is:
+//
+//	GET  /status          {"height": 0}
+//	GET  /address/<addr>  a Balance
+//	GET  /tx/<txid>       a Tx
+//	POST /spend           {"from", "to", "amount"} returning {"txid"}
+type Mock struct {
+	mutex    sync.Mutex
+	height   int
+	txs      []*Tx
+	byID     map[string]*Tx
+	listener net.Listener
+	server   *http.Server
+}
+
+// NewMock returns a ledger with no funds
+func NewMock() *Mock {
+	return &Mock{byID: make(map[string]*Tx)}
+}
+
+// Start serves the mock's API on a loopback port
+func (m *Mock) Start(context.Context) error {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return fmt.Errorf("starting mock wallet backend: %s", err.Error())
+	}
+	m.mutex.Lock()
+	m.listener = l
+	m.server = &http.Server{Handler: m.handler()}
+	m.mutex.Unlock()
+	go m.server.Serve(l)
+	log.Infof("mock wallet backend listening on %s", l.Addr())
+	return nil
+}
+
+// URL returns the base URL of the mock's API once started
+func (m *Mock) URL() string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.listener == nil {
+		return ""
+	}
+	return "http://" + m.listener.Addr().String()
+}
+
+// Configure points a fake daemon's wallet at the mock. openbazaard cannot
+// use the mock's ledger, so real nodes must use Bitcoind instead.
+func (m *Mock) Configure(r *runner.OpenBazaarRunner) error {
+	var url = m.URL()
+	if url == "" {
+		return fmt.Errorf("mock wallet backend is not started")
+	}
+	if !r.IsFakeDaemon() {
+		return fmt.Errorf("mock wallet backend only works with the fake daemon, use bitcoind for openbazaard")
+	}
+	var prefix = "Wallet."
+	if r.Supports(runner.CapabilityMultiwallet) {
+		prefix = "Wallets.BTC."
+	}
+	if err := r.SetConfigValue(prefix+"Type", MockWalletType); err != nil {
+		return fmt.Errorf("setting wallet type: %s", err.Error())
+	}
+	if err := r.SetConfigValue(prefix+"MockAPI", url); err != nil {
+		return fmt.Errorf("setting wallet API: %s", err.Error())
+	}
+	return nil
+}
+
+// Fund creates amount satoshis at address in a pending transaction
+func (m *Mock) Fund(_ context.Context, address string, amount int64) (string, error) {
+	if amount <= 0 {
+		return "", fmt.Errorf("amount must be positive")
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.record("", address, amount), nil
+}
+
+// Spend moves amount satoshis between addresses in a pending transaction.
+// Pending funds may be spent.
+func (m *Mock) Spend(from, to string, amount int64) (string, error) {
+	if amount <= 0 {
+		return "", fmt.Errorf("amount must be positive")
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	var b = m.balance(from)
+	if available := b.Confirmed + b.Unconfirmed; available < amount {
+		return "", fmt.Errorf("insufficient funds: (%d) available, (%d) requested", available, amount)
+	}
+	return m.record(from, to, amount), nil
+}
+
+// Mine confirms every pending transaction in the first of the blocks
+func (m *Mock) Mine(_ context.Context, blocks int) error {
+	if blocks <= 0 {
+		return fmt.Errorf("blocks must be positive")
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, tx := range m.txs {
+		if tx.Height == 0 {
+			tx.Height = m.height + 1
+		}
+	}
+	m.height += blocks
+	return nil
+}
+
+// Balance returns the balance of address
+func (m *Mock) Balance(address string) Balance {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.balance(address)
+}
+
+// Tx returns the transaction with the given ID
+func (m *Mock) Tx(txid string) (Tx, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	tx, ok := m.byID[txid]
+	if !ok {
+		return Tx{}, false
+	}
+	return m.withConfirmations(*tx), true
+}
+
+// Stop stops serving the API. The ledger is kept in memory.
+func (m *Mock) Stop() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.server == nil {
+		return nil
+	}
+	var err = m.server.Close()
+	m.server, m.listener = nil, nil
+	return err
+}
+
+// record adds a pending transaction. The mutex must be held.
+func (m *Mock) record(from, to string, amount int64) string {
+	var sum = sha256.Sum256([]byte(fmt.Sprintf("%d:%s:%s:%d", len(m.txs), from, to, amount)))
+	var tx = &Tx{TxID: hex.EncodeToString(sum[:]), From: from, To: to, Amount: amount}
+	m.txs = append(m.txs, tx)
+	m.byID[tx.TxID] = tx
+	return tx.TxID
+}
+
+// balance sums the transactions of address. The mutex must be held.
+func (m *Mock) balance(address string) Balance {
+	var b = Balance{Address: address, Height: m.height}
+	for _, tx := range m.txs {
+		var change int64
+		if tx.To == address {
+			change += tx.Amount
+		}
+		if tx.From == address {
+			change -= tx.Amount
+		}
+		if tx.Height == 0 {
+			b.Unconfirmed += change
+		} else {
+			b.Confirmed += change
+		}
+	}
+	return b
+}
+
+// withConfirmations sets the confirmations of tx at the current height.
+// The mutex must be held.
+func (m *Mock) withConfirmations(tx Tx) Tx {
+	if tx.Height > 0 {
+		tx.Confirmations = m.height - tx.Height + 1
+	}
+	return tx
+}
+
+func (m *Mock) handler() http.Handler {
+	var mux = http.NewServeMux()
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		m.mutex.Lock()
+		var height = m.height
+		m.mutex.Unlock()
+		writeJSON(w, http.StatusOK, map[string]int{"height": height})
+	})
+	mux.HandleFunc("/address/", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, m.Balance(strings.TrimPrefix(r.URL.Path, "/address/")))
+	})
+	mux.HandleFunc("/tx/", func(w http.ResponseWriter, r *http.Request) {
+		tx, ok := m.Tx(strings.TrimPrefix(r.URL.Path, "/tx/"))
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "transaction not found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, tx)
+	})
+	mux.HandleFunc("/spend", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		var req struct {
+			From   string `json:"from"`
+			To     string `json:"to"`
+			Amount int64  `json:"amount"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		txid, err := m.Spend(req.From, req.To, req.Amount)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"txid": txid})
+	})
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,121 @@
+package regtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/OpenBazaar/mason/builder/runner"
+	"github.com/OpenBazaar/mason/util"
+)
+
+func TestMockConfirmsTransactionsWhenMined(t *testing.T) {
+	var m = NewMock()
+	var ctx = context.Background()
+	txid, err := m.Fund(ctx, "buyer", 5000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := m.Balance("buyer"); b.Confirmed != 0 || b.Unconfirmed != 5000 {
+		t.Errorf("expected funds to be unconfirmed, but was (%+v)", b)
+	}
+	if _, err := m.Spend("buyer", "vendor", 2000); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Spend("buyer", "vendor", 4000); err == nil {
+		t.Error("expected an error spending more than the balance")
+	}
+	if err := m.Mine(ctx, 3); err != nil {
+		t.Fatal(err)
+	}
+	if b := m.Balance("buyer"); b.Confirmed != 3000 || b.Unconfirmed != 0 || b.Height != 3 {
+		t.Errorf("expected (3000) confirmed at height (3), but was (%+v)", b)
+	}
+	if tx, ok := m.Tx(txid); !ok || tx.Height != 1 || tx.Confirmations != 3 {
+		t.Errorf("expected funding to be confirmed in the first block, but was (%+v)", tx)
+	}
+	if err := m.Mine(ctx, 0); err == nil {
+		t.Error("expected an error mining no blocks")
+	}
+}
+
+func TestMockServesLedger(t *testing.T) {
+	var m = NewMock()
+	if err := m.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer m.Stop()
+	m.Fund(context.Background(), "buyer", 5000)
+
+	resp, err := http.Post(m.URL()+"/spend", "application/json", bytes.NewReader([]byte(`{"from":"buyer","to":"vendor","amount":1500}`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected spend to succeed, but status was (%d)", resp.StatusCode)
+	}
+	resp, err = http.Get(m.URL() + "/address/vendor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var b Balance
+	if err := json.NewDecoder(resp.Body).Decode(&b); err != nil {
+		t.Fatal(err)
+	}
+	if b.Unconfirmed != 1500 {
+		t.Errorf("expected vendor to receive (1500), but was (%+v)", b)
+	}
+
+	resp, err = http.Post(m.URL()+"/spend", "application/json", bytes.NewReader([]byte(`{"from":"vendor","to":"buyer","amount":9999}`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected overspending to be rejected, but status was (%d)", resp.StatusCode)
+	}
+}
+
+func TestMockOnlyConfiguresFakeDaemon(t *testing.T) {
+	var path = util.GenerateTempPath("mock_configure")
+	if err := os.MkdirAll(filepath.Join(path, "data"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+	if err := ioutil.WriteFile(filepath.Join(path, "data", "config"), []byte(`{"Wallets":{"BTC":{}}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var m = NewMock()
+	if err := m.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer m.Stop()
+
+	for version, accepted := range map[string]bool{
+		"0.13.8":                         false,
+		"0.13.8 " + runner.FakeDaemonTag: true,
+	} {
+		var binary = filepath.Join(path, "openbazaard")
+		if err := ioutil.WriteFile(binary, []byte("#!/bin/sh\necho '"+version+"'\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		r, err := runner.FromBinaryPath(binary)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.SetCustomDataPath(filepath.Join(path, "data"))
+		if err := m.Configure(r); (err == nil) != accepted {
+			t.Errorf("expected version (%s) accepted (%t), but was (%v)", version, accepted, err)
+		}
+		if walletType, _ := r.ConfigValue("Wallets.BTC.Type"); accepted && walletType != MockWalletType {
+			t.Errorf("expected the bitcoin wallet to use the mock, but was (%v)", walletType)
+		}
+	}
+}
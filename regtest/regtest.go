@@ -0,0 +1,95 @@
+// Package regtest runs a local chain which simulated nodes use in place of
+// public wallet infrastructure, so purchases can be funded and confirmed
+// offline and on demand. Bitcoind runs a bitcoind regtest process which
+// real nodes connect to as their trusted SPV peer, and Mock is an in
+// process ledger served to the fake daemon in cmd/fakeobd.
+package regtest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+
+	"github.com/OpenBazaar/mason/builder/runner"
+	"github.com/op/go-logging"
+)
+
+// Backend kinds
+const (
+	KindBitcoind = "bitcoind"
+	KindMock     = "mock"
+)
+
+var log = logging.MustGetLogger("regtest")
+
+// Backend is a local chain which nodes' wallets are configured to use
+type Backend interface {
+	// Start runs the backend until Stop is called
+	Start(ctx context.Context) error
+	// Configure points an initialized node's wallet at the backend
+	Configure(r *runner.OpenBazaarRunner) error
+	// Fund sends amount satoshis to address, returning the transaction ID.
+	// The transaction is unconfirmed until blocks are mined.
+	Fund(ctx context.Context, address string, amount int64) (string, error)
+	// Mine confirms pending transactions in the given number of blocks
+	Mine(ctx context.Context, blocks int) error
+	// Stop stops the backend and releases its resources
+	Stop() error
+}
+
+// New returns a backend of kind which keeps any data beneath dataPath
+func New(kind, dataPath string) (Backend, error) {
+	switch kind {
+	case KindBitcoind:
+		return NewBitcoind(BitcoindOptions{DataPath: dataPath})
+	case KindMock:
+		return NewMock(), nil
+	}
+	return nil, fmt.Errorf("unknown wallet backend (%s)", kind)
+}
+
+// ValidKind returns true when kind names a backend
+func ValidKind(kind string) bool {
+	return kind == KindBitcoind || kind == KindMock
+}
+
+// NodeAddress asks a running node for a receiving address of its bitcoin
+// wallet
+func NodeAddress(ctx context.Context, r *runner.OpenBazaarRunner) (string, error) {
+	var path = "/wallet/address"
+	if r.Supports(runner.CapabilityMultiwallet) {
+		path = "/wallet/address/BTC"
+	}
+	resp, err := r.APIRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("requesting wallet address: status %d: %s", resp.StatusCode, resp.Body)
+	}
+	var body struct{ Address string }
+	if err := json.Unmarshal(resp.Body, &body); err != nil || body.Address == "" {
+		return "", fmt.Errorf("parsing wallet address (%s)", resp.Body)
+	}
+	return body.Address, nil
+}
+
+// FundNode sends amount satoshis from the backend to a node's wallet
+func FundNode(ctx context.Context, b Backend, r *runner.OpenBazaarRunner, amount int64) (string, error) {
+	address, err := NodeAddress(ctx, r)
+	if err != nil {
+		return "", err
+	}
+	return b.Fund(ctx, address, amount)
+}
+
+// freePort returns a loopback port which is not in use
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
@@ -0,0 +1,114 @@
+package scenario
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Assertion checks a value within a JSON document. Path is dot-separated
+// with array elements addressed by index (ex: items.0.slug), and an empty
+// path refers to the whole document.
+type Assertion struct {
+	Path string `json:"path,omitempty"`
+	// Equals requires the value to equal this JSON value
+	Equals json.RawMessage `json:"equals,omitempty"`
+	// Contains requires the value, as text, to contain this string
+	Contains string `json:"contains,omitempty"`
+	// Exists requires the value to be present, or absent when false
+	Exists *bool `json:"exists,omitempty"`
+}
+
+// check returns an error describing why doc does not satisfy the
+// assertion
+func (a Assertion) check(doc interface{}, vars map[string]string) error {
+	value, found := lookup(doc, a.Path)
+	if a.Exists != nil {
+		if found != *a.Exists {
+			return fmt.Errorf("expected (%s) to exist (%t), but did not", a.Path, *a.Exists)
+		}
+		if !found {
+			return nil
+		}
+	}
+	if !found {
+		return fmt.Errorf("(%s) not found", a.Path)
+	}
+	if a.Equals != nil {
+		raw, err := expandJSON(string(a.Equals), vars, nil)
+		if err != nil {
+			return err
+		}
+		var expected interface{}
+		if err := json.Unmarshal([]byte(raw), &expected); err != nil {
+			return fmt.Errorf("parsing expected value of (%s): %s", a.Path, err.Error())
+		}
+		if !reflect.DeepEqual(expected, value) {
+			return fmt.Errorf("expected (%s) to equal (%s), but was (%s)", a.Path, raw, text(value))
+		}
+	}
+	if a.Contains != "" {
+		contains, err := expand(a.Contains, vars)
+		if err != nil {
+			return err
+		}
+		if !strings.Contains(text(value), contains) {
+			return fmt.Errorf("expected (%s) to contain (%s), but was (%s)", a.Path, contains, text(value))
+		}
+	}
+	return nil
+}
+
+// lookup finds the value at path within a decoded JSON document
+func lookup(doc interface{}, path string) (interface{}, bool) {
+	if path == "" {
+		return doc, true
+	}
+	var value = doc
+	for _, segment := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			next, ok := v[segment]
+			if !ok {
+				return nil, false
+			}
+			value = next
+		case []interface{}:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			value = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// text renders a decoded JSON value for comparison and messages. Strings
+// are returned without quotes.
+func text(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(b)
+}
+
+// decode parses a JSON document, treating an empty document as null
+func decode(b []byte) (interface{}, error) {
+	var doc interface{}
+	if len(strings.TrimSpace(string(b))) == 0 {
+		return nil, nil
+	}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("response is not JSON: %s", err.Error())
+	}
+	return doc, nil
+}
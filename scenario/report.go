@@ -0,0 +1,73 @@
+package scenario
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Report describes the outcome of running a scenario
+type Report struct {
+	Scenario string        `json:"scenario"`
+	Passed   bool          `json:"passed"`
+	Started  time.Time     `json:"started"`
+	Duration time.Duration `json:"duration"`
+	Steps    []StepResult  `json:"steps"`
+}
+
+// StepResult describes the outcome of a single step
+type StepResult struct {
+	Name     string        `json:"name"`
+	Action   string        `json:"action"`
+	Node     string        `json:"node,omitempty"`
+	Passed   bool          `json:"passed"`
+	Skipped  bool          `json:"skipped,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Failed returns the number of steps which did not pass, excluding those
+// which were skipped
+func (r Report) Failed() int {
+	var failed int
+	for _, s := range r.Steps {
+		if !s.Passed && !s.Skipped {
+			failed++
+		}
+	}
+	return failed
+}
+
+// WriteText writes a human-readable summary of the report to w
+func (r Report) WriteText(w io.Writer) error {
+	var result = "PASS"
+	if !r.Passed {
+		result = "FAIL"
+	}
+	if _, err := fmt.Fprintf(w, "%s %s (%s)\n", result, r.Scenario, r.Duration.Round(time.Millisecond)); err != nil {
+		return err
+	}
+	for _, s := range r.Steps {
+		var line string
+		switch {
+		case s.Skipped:
+			line = fmt.Sprintf("  skip %s\n", s.Name)
+		case s.Passed:
+			line = fmt.Sprintf("  ok   %s (%s)\n", s.Name, s.Duration.Round(time.Millisecond))
+		default:
+			line = fmt.Sprintf("  fail %s (%s)\n       %s\n", s.Name, s.Duration.Round(time.Millisecond), s.Error)
+		}
+		if _, err := io.WriteString(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteJSON encodes the report to w as JSON
+func (r Report) WriteJSON(w io.Writer) error {
+	var encoder = json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
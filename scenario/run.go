@@ -0,0 +1,359 @@
+package scenario
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OpenBazaar/mason/builder/runner"
+	"github.com/OpenBazaar/mason/netfault"
+	"github.com/op/go-logging"
+)
+
+var log = logging.MustGetLogger("scenario")
+
+// Nodes resolves the node names used by a scenario to their runners
+type Nodes interface {
+	Runner(name string) (*runner.OpenBazaarRunner, bool)
+}
+
+// Links is implemented by Nodes which carry their traffic through a fault
+// proxy, such as a simulation.Simulation, to run link, partition and heal
+// steps
+type Links interface {
+	SetLink(from, to string, c netfault.Conditions) error
+	Partition(groups [][]string) error
+	Heal() error
+}
+
+// Wallet is implemented by Nodes whose wallets use a local chain, such as
+// a simulation.Simulation with a wallet backend, to run fund and mine
+// steps
+type Wallet interface {
+	Fund(ctx context.Context, node string, amount int64) (string, error)
+	Mine(ctx context.Context, blocks int) error
+}
+
+// Clock is implemented by Nodes which share a clock that can be moved
+// forward, such as a simulation.Simulation with a clock, to run
+// advanceTime steps
+type Clock interface {
+	AdvanceTime(d time.Duration) error
+}
+
+// NodeMap is a Nodes backed by a map
+type NodeMap map[string]*runner.OpenBazaarRunner
+
+// Runner returns the runner with the given name
+func (m NodeMap) Runner(name string) (*runner.OpenBazaarRunner, bool) {
+	r, ok := m[name]
+	return r, ok
+}
+
+// execution holds the state of a single run of a scenario
+type execution struct {
+	nodes Nodes
+	vars  map[string]string
+	// literals are the variables saved from JSON values other than strings
+	literals map[string]bool
+	events   map[string]*eventLog
+}
+
+// Run executes each step of the scenario in order and reports the
+// outcome. Steps after the first failure are skipped.
+func Run(ctx context.Context, s Scenario, nodes Nodes) Report {
+	var report = Report{Scenario: s.Name, Started: time.Now(), Passed: true}
+	var ctx2, cancel = context.WithCancel(ctx)
+	defer cancel()
+
+	var e = &execution{
+		nodes:    nodes,
+		vars:     make(map[string]string),
+		literals: make(map[string]bool),
+		events:   make(map[string]*eventLog),
+	}
+	// events are collected from the start so none are missed while
+	// earlier steps run
+	for _, step := range s.Steps {
+		if step.Action != ActionWaitEvent || e.events[step.Node] != nil {
+			continue
+		}
+		if r, ok := nodes.Runner(step.Node); ok {
+			e.events[step.Node] = collectEvents(r.Events(ctx2))
+		}
+	}
+
+	for i, step := range s.Steps {
+		var result = StepResult{Name: step.title(i), Action: step.Action, Node: step.Node}
+		if !report.Passed {
+			result.Skipped = true
+			report.Steps = append(report.Steps, result)
+			continue
+		}
+		var started = time.Now()
+		if err := e.run(ctx2, step); err != nil {
+			result.Error = err.Error()
+			report.Passed = false
+			log.Errorf("%s: %s failed: %s", s.Name, result.Name, result.Error)
+		} else {
+			result.Passed = true
+			log.Infof("%s: %s passed", s.Name, result.Name)
+		}
+		result.Duration = time.Since(started)
+		report.Steps = append(report.Steps, result)
+	}
+	report.Duration = time.Since(report.Started)
+	return report
+}
+
+func (e *execution) run(ctx context.Context, step Step) error {
+	if step.Action == ActionSleep {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(step.Duration)):
+			return nil
+		}
+	}
+	switch step.Action {
+	case ActionLink, ActionPartition, ActionHeal:
+		return e.changeLinks(step)
+	case ActionFund, ActionMine:
+		return e.useWallet(ctx, step)
+	case ActionAdvanceTime:
+		clock, ok := e.nodes.(Clock)
+		if !ok {
+			return fmt.Errorf("advanceTime requires nodes with a clock")
+		}
+		return clock.AdvanceTime(time.Duration(step.Duration))
+	}
+	r, ok := e.nodes.Runner(step.Node)
+	if !ok {
+		return fmt.Errorf("unknown node (%s)", step.Node)
+	}
+	switch step.Action {
+	case ActionCall:
+		if step.Timeout > 0 {
+			return e.retryCall(ctx, r, step)
+		}
+		return e.call(ctx, r, step)
+	case ActionWaitEvent:
+		return e.waitEvent(ctx, step)
+	case ActionState:
+		return e.waitState(ctx, r, step)
+	}
+	return fmt.Errorf("unknown action (%s)", step.Action)
+}
+
+// useWallet runs a fund or mine step against the wallet backend
+func (e *execution) useWallet(ctx context.Context, step Step) error {
+	wallet, ok := e.nodes.(Wallet)
+	if !ok {
+		return fmt.Errorf("%s requires nodes with a wallet backend", step.Action)
+	}
+	if step.Action == ActionMine {
+		return wallet.Mine(ctx, step.blocks())
+	}
+	txid, err := wallet.Fund(ctx, step.Node, step.Amount)
+	if err != nil {
+		return err
+	}
+	return e.checkAndSave(map[string]interface{}{"txid": txid}, step)
+}
+
+// changeLinks runs a link, partition or heal step against the fault proxy
+func (e *execution) changeLinks(step Step) error {
+	links, ok := e.nodes.(Links)
+	if !ok {
+		return fmt.Errorf("%s requires nodes behind a fault proxy", step.Action)
+	}
+	switch step.Action {
+	case ActionLink:
+		if step.Conditions == nil {
+			return fmt.Errorf("link requires conditions")
+		}
+		return links.SetLink(step.From, step.To, *step.Conditions)
+	case ActionPartition:
+		return links.Partition(step.Groups)
+	}
+	return links.Heal()
+}
+
+func (e *execution) call(ctx context.Context, r *runner.OpenBazaarRunner, step Step) error {
+	path, err := expand(step.Path, e.vars)
+	if err != nil {
+		return err
+	}
+	var body []byte
+	if step.Body != nil {
+		expanded, err := expandJSON(string(step.Body), e.vars, e.literals)
+		if err != nil {
+			return err
+		}
+		body = []byte(expanded)
+	}
+	resp, err := r.APIRequest(ctx, strings.ToUpper(step.Method), path, body)
+	if err != nil {
+		return err
+	}
+	var expectedStatus = step.Status
+	if expectedStatus == 0 {
+		expectedStatus = http.StatusOK
+	}
+	if resp.StatusCode != expectedStatus {
+		return fmt.Errorf("expected status (%d), but was (%d): %s", expectedStatus, resp.StatusCode, strings.TrimSpace(string(resp.Body)))
+	}
+	if len(step.Assert) == 0 && len(step.Save) == 0 {
+		return nil
+	}
+	doc, err := decode(resp.Body)
+	if err != nil {
+		return err
+	}
+	return e.checkAndSave(doc, step)
+}
+
+// retryCall repeats a call until it passes or its timeout elapses, for
+// changes which other nodes make asynchronously
+func (e *execution) retryCall(ctx context.Context, r *runner.OpenBazaarRunner, step Step) error {
+	var timeout = time.Duration(step.Timeout)
+	var deadline = time.Now().Add(timeout)
+	for {
+		var err = e.call(ctx, r, step)
+		if err == nil {
+			return nil
+		} else if time.Now().After(deadline) {
+			return fmt.Errorf("call did not pass within %s, last failure: %s", timeout, err.Error())
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
+
+func (e *execution) waitEvent(ctx context.Context, step Step) error {
+	var log = e.events[step.Node]
+	var timeout = time.Duration(step.Timeout)
+	if timeout <= 0 {
+		timeout = defaultEventTimeout
+	}
+	var ctx2, cancel = context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var lastErr error
+	var match = func(event runner.Event) bool {
+		if event.Kind != step.Kind || (step.Type != "" && event.Type != step.Type) {
+			return false
+		}
+		doc, err := decode(event.Payload)
+		if err != nil {
+			lastErr = err
+			return false
+		}
+		if err := e.checkAndSave(doc, step); err != nil {
+			lastErr = err
+			return false
+		}
+		return true
+	}
+	if log.take(ctx2, match) {
+		return nil
+	}
+	if lastErr != nil {
+		return fmt.Errorf("no matching event within %s, last mismatch: %s", timeout, lastErr.Error())
+	}
+	return fmt.Errorf("no matching event within %s", timeout)
+}
+
+func (e *execution) waitState(ctx context.Context, r *runner.OpenBazaarRunner, step Step) error {
+	var timeout = time.Duration(step.Timeout)
+	if timeout <= 0 {
+		timeout = defaultStateTimeout
+	}
+	var deadline = time.Now().Add(timeout)
+	for {
+		if s := r.State().String(); s == step.State {
+			return nil
+		} else if time.Now().After(deadline) {
+			return fmt.Errorf("expected state (%s), but was (%s) after %s", step.State, s, timeout)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// checkAndSave verifies the step's assertions against doc and saves any
+// requested variables from it
+func (e *execution) checkAndSave(doc interface{}, step Step) error {
+	for _, a := range step.Assert {
+		if err := a.check(doc, e.vars); err != nil {
+			return err
+		}
+	}
+	for name, path := range step.Save {
+		value, ok := lookup(doc, path)
+		if !ok {
+			return fmt.Errorf("cannot save (%s), (%s) not found", name, path)
+		}
+		_, isString := value.(string)
+		e.vars[name] = text(value)
+		e.literals[name] = !isString
+	}
+	return nil
+}
+
+// eventLog buffers the events received from a node so steps may wait on
+// events which arrived before the step began. Each event satisfies at
+// most one step.
+type eventLog struct {
+	sync.Mutex
+	events  []runner.Event
+	updated chan struct{}
+}
+
+func collectEvents(events <-chan runner.Event) *eventLog {
+	var l = &eventLog{updated: make(chan struct{})}
+	go func() {
+		for event := range events {
+			l.Lock()
+			l.events = append(l.events, event)
+			close(l.updated)
+			l.updated = make(chan struct{})
+			l.Unlock()
+		}
+	}()
+	return l
+}
+
+// take removes and returns true for the first buffered event which
+// matches, waiting for new events until the context is done
+func (l *eventLog) take(ctx context.Context, match func(runner.Event) bool) bool {
+	var checked int
+	for {
+		l.Lock()
+		for i := checked; i < len(l.events); i++ {
+			if match(l.events[i]) {
+				l.events = append(l.events[:i], l.events[i+1:]...)
+				l.Unlock()
+				return true
+			}
+		}
+		checked = len(l.events)
+		var updated = l.updated
+		l.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-updated:
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package scenario
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"time"
+
+	"github.com/OpenBazaar/mason/builder/runner"
+	"github.com/OpenBazaar/mason/netfault"
+)
+
+// Step actions
+const (
+	ActionCall        = "call"
+	ActionWaitEvent   = "waitEvent"
+	ActionState       = "state"
+	ActionSleep       = "sleep"
+	ActionLink        = "link"
+	ActionPartition   = "partition"
+	ActionHeal        = "heal"
+	ActionFund        = "fund"
+	ActionMine        = "mine"
+	ActionAdvanceTime = "advanceTime"
+)
+
+const (
+	defaultEventTimeout = 30 * time.Second
+	defaultStateTimeout = 30 * time.Second
+)
+
+var variablePattern = regexp.MustCompile(`\$\{([A-Za-z0-9_]+)\}`)
+
+// quotedVariablePattern matches a JSON string which is only a reference
+var quotedVariablePattern = regexp.MustCompile(`"\$\{([A-Za-z0-9_]+)\}"`)
+
+// Scenario is an ordered list of steps executed against the named nodes
+// of a simulation
+type Scenario struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Steps       []Step `json:"steps"`
+}
+
+// Step is a single action within a scenario. Strings within Path, Body
+// and assertion values may reference variables saved by earlier steps as
+// ${name}.
+type Step struct {
+	Name   string `json:"name,omitempty"`
+	Action string `json:"action"`
+	Node   string `json:"node,omitempty"`
+
+	// Method, Path and Body describe the JSON API request of a call
+	Method string          `json:"method,omitempty"`
+	Path   string          `json:"path,omitempty"`
+	Body   json.RawMessage `json:"body,omitempty"`
+	// Status is the expected response status of a call, 200 by default
+	Status int `json:"status,omitempty"`
+
+	// Kind and Type select the event awaited by waitEvent
+	Kind runner.EventKind `json:"kind,omitempty"`
+	Type string           `json:"type,omitempty"`
+
+	// State is the runner state awaited by a state step
+	State string `json:"state,omitempty"`
+
+	// From, To and Conditions change a link of the fault proxy. Either
+	// node may be "*" to match every node.
+	From       string               `json:"from,omitempty"`
+	To         string               `json:"to,omitempty"`
+	Conditions *netfault.Conditions `json:"conditions,omitempty"`
+	// Groups are the nodes separated by a partition
+	Groups [][]string `json:"groups,omitempty"`
+
+	// Amount is the satoshis sent to the node's wallet by a fund step.
+	// The transaction ID may be saved as txid.
+	Amount int64 `json:"amount,omitempty"`
+	// Blocks is the number of blocks mined by a mine step, 1 by default
+	Blocks int `json:"blocks,omitempty"`
+
+	// Duration is the length of a sleep, or how far an advanceTime step
+	// moves the nodes' clock
+	Duration Duration `json:"duration,omitempty"`
+	// Timeout limits waitEvent and state steps, 30s by default. A call
+	// with a timeout is repeated until its status and assertions pass.
+	Timeout Duration `json:"timeout,omitempty"`
+
+	// Assert is checked against the call response or event payload
+	Assert []Assertion `json:"assert,omitempty"`
+	// Save maps variable names to paths within the call response or
+	// event payload
+	Save map[string]string `json:"save,omitempty"`
+}
+
+// title returns the name of the step for reports
+func (s Step) title(i int) string {
+	if s.Name != "" {
+		return s.Name
+	}
+	switch s.Action {
+	case ActionCall:
+		return fmt.Sprintf("%d: %s %s %s", i+1, s.Node, s.Method, s.Path)
+	case ActionWaitEvent:
+		return fmt.Sprintf("%d: %s waits for %s %s", i+1, s.Node, s.Kind, s.Type)
+	case ActionState:
+		return fmt.Sprintf("%d: %s is %s", i+1, s.Node, s.State)
+	case ActionLink:
+		return fmt.Sprintf("%d: link %s->%s", i+1, s.From, s.To)
+	case ActionPartition:
+		return fmt.Sprintf("%d: partition %v", i+1, s.Groups)
+	case ActionHeal:
+		return fmt.Sprintf("%d: heal", i+1)
+	case ActionFund:
+		return fmt.Sprintf("%d: fund %s with %d", i+1, s.Node, s.Amount)
+	case ActionMine:
+		return fmt.Sprintf("%d: mine %d", i+1, s.blocks())
+	case ActionAdvanceTime:
+		return fmt.Sprintf("%d: advance time by %s", i+1, time.Duration(s.Duration))
+	}
+	return fmt.Sprintf("%d: %s %s", i+1, s.Action, time.Duration(s.Duration))
+}
+
+// blocks returns the number of blocks mined by a mine step
+func (s Step) blocks() int {
+	if s.Blocks > 0 {
+		return s.Blocks
+	}
+	return 1
+}
+
+// Duration is a time.Duration which is written as a string (ex: 5s) in
+// scenario files
+type Duration time.Duration
+
+// UnmarshalJSON parses a duration string
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string (ex: 5s): %s", err.Error())
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalJSON writes the duration as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Load reads a JSON scenario file
+func Load(path string) (Scenario, error) {
+	var s Scenario
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return s, fmt.Errorf("reading scenario: %s", err.Error())
+	}
+	if err := json.Unmarshal(b, &s); err != nil {
+		return s, fmt.Errorf("parsing scenario (%s): %s", path, err.Error())
+	}
+	if s.Name == "" {
+		s.Name = path
+	}
+	return s, s.Validate()
+}
+
+// Validate returns an error describing the first step which cannot be
+// executed
+func (s Scenario) Validate() error {
+	if len(s.Steps) == 0 {
+		return fmt.Errorf("scenario (%s) has no steps", s.Name)
+	}
+	for i, step := range s.Steps {
+		var err error
+		switch step.Action {
+		case ActionCall:
+			if step.Node == "" || step.Method == "" || step.Path == "" {
+				err = fmt.Errorf("call requires node, method and path")
+			}
+		case ActionWaitEvent:
+			if step.Node == "" || step.Kind == "" {
+				err = fmt.Errorf("waitEvent requires node and kind")
+			}
+		case ActionState:
+			if step.Node == "" || step.State == "" {
+				err = fmt.Errorf("state requires node and state")
+			}
+		case ActionSleep:
+			if step.Duration <= 0 {
+				err = fmt.Errorf("sleep requires a duration")
+			}
+		case ActionLink:
+			if step.From == "" || step.To == "" || step.Conditions == nil {
+				err = fmt.Errorf("link requires from, to and conditions")
+			} else {
+				err = step.Conditions.Validate()
+			}
+		case ActionPartition:
+			if len(step.Groups) < 2 {
+				err = fmt.Errorf("partition requires at least two groups")
+			}
+		case ActionHeal:
+		case ActionFund:
+			if step.Node == "" || step.Amount <= 0 {
+				err = fmt.Errorf("fund requires node and a positive amount")
+			}
+		case ActionMine:
+			if step.Blocks < 0 {
+				err = fmt.Errorf("mine requires a positive number of blocks")
+			}
+		case ActionAdvanceTime:
+			if step.Duration <= 0 {
+				err = fmt.Errorf("advanceTime requires a duration")
+			}
+		default:
+			err = fmt.Errorf("unknown action (%s)", step.Action)
+		}
+		if err != nil {
+			return fmt.Errorf("step %d: %s", i+1, err.Error())
+		}
+	}
+	return nil
+}
+
+// expandJSON is like expand for a JSON document, except that a string
+// which is only a reference to a variable saved from a number, boolean,
+// object or array is replaced by that value, so it keeps its type. Other
+// references are within JSON strings, so their values are escaped.
+func expandJSON(s string, vars map[string]string, literals map[string]bool) (string, error) {
+	s = quotedVariablePattern.ReplaceAllStringFunc(s, func(ref string) string {
+		var name = quotedVariablePattern.FindStringSubmatch(ref)[1]
+		if value, ok := vars[name]; ok && literals[name] {
+			return value
+		}
+		return ref
+	})
+	return expandWith(s, vars, escapeJSONString)
+}
+
+// escapeJSONString returns s encoded as the contents of a JSON string
+func escapeJSONString(s string) string {
+	var b, _ = json.Marshal(s)
+	return string(b[1 : len(b)-1])
+}
+
+// expand replaces ${name} references in s with saved variables
+func expand(s string, vars map[string]string) (string, error) {
+	return expandWith(s, vars, func(value string) string { return value })
+}
+
+// expandWith replaces ${name} references in s with saved variables
+// transformed by escape
+func expandWith(s string, vars map[string]string, escape func(string) string) (string, error) {
+	var missing string
+	var expanded = variablePattern.ReplaceAllStringFunc(s, func(ref string) string {
+		var name = variablePattern.FindStringSubmatch(ref)[1]
+		value, ok := vars[name]
+		if !ok && missing == "" {
+			missing = name
+		}
+		return escape(value)
+	})
+	if missing != "" {
+		return "", fmt.Errorf("variable (%s) is not set", missing)
+	}
+	return expanded, nil
+}
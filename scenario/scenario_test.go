@@ -0,0 +1,422 @@
+package scenario
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/OpenBazaar/mason/builder/runner"
+	"github.com/OpenBazaar/mason/netfault"
+	"github.com/OpenBazaar/mason/util"
+)
+
+// serveTestWebsocket completes the server side of the websocket handshake
+// and writes each message as an unmasked text frame
+func serveTestWebsocket(w http.ResponseWriter, r *http.Request, messages ...string) error {
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		return fmt.Errorf("response cannot be hijacked")
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	var accept = sha1.Sum([]byte(r.Header.Get("Sec-WebSocket-Key") + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+		base64.StdEncoding.EncodeToString(accept[:]))
+	for _, msg := range messages {
+		rw.Write(append([]byte{0x81, byte(len(msg))}, msg...))
+	}
+	if err := rw.Flush(); err != nil {
+		return err
+	}
+	// hold the connection open until the client goes away
+	_, err = ioutil.ReadAll(bufio.NewReader(conn))
+	return err
+}
+
+func mustCreateNode(t *testing.T, label string, handler http.Handler) (*runner.OpenBazaarRunner, func()) {
+	var server = httptest.NewServer(handler)
+	var dataPath = util.GenerateTempPath(label)
+	if err := os.MkdirAll(dataPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	host, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var config = fmt.Sprintf(`{"Addresses":{"Gateway":"/ip4/%s/tcp/%s"},"JSON-API":{"SSL":false}}`, host, port)
+	if err := ioutil.WriteFile(filepath.Join(dataPath, "config"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var r = &runner.OpenBazaarRunner{}
+	r.SetCustomDataPath(dataPath)
+	return r, func() {
+		server.Close()
+		os.RemoveAll(dataPath)
+	}
+}
+
+func TestRunPassesVariablesBetweenNodes(t *testing.T) {
+	vendor, cleanupVendor := mustCreateNode(t, "scenario_vendor", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/ob/listing":
+			body, _ := ioutil.ReadAll(r.Body)
+			if !strings.Contains(string(body), `"title":"Widget"`) {
+				http.Error(w, "bad listing", http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, `{"slug":"widget"}`)
+		case r.URL.Path == "/ws":
+			serveTestWebsocket(w, r, `{"notification":{"type":"order","slug":"widget"}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer cleanupVendor()
+	buyer, cleanupBuyer := mustCreateNode(t, "scenario_buyer", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ob/purchase/widget" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"orderId":"Qm1","items":[{"slug":"widget","quantity":1}]}`)
+	}))
+	defer cleanupBuyer()
+
+	var subject = Scenario{
+		Name: "purchase",
+		Steps: []Step{
+			{Action: ActionCall, Node: "vendor", Method: "post", Path: "/ob/listing", Body: []byte(`{"title":"Widget"}`), Save: map[string]string{"slug": "slug"}},
+			{Action: ActionCall, Node: "buyer", Method: "post", Path: "/ob/purchase/${slug}", Assert: []Assertion{
+				{Path: "items.0.slug", Equals: []byte(`"${slug}"`)},
+				{Path: "items.0.quantity", Equals: []byte(`1`)},
+				{Path: "orderId", Contains: "Qm"},
+			}},
+			{Action: ActionWaitEvent, Node: "vendor", Kind: runner.EventNotification, Type: "order", Timeout: Duration(5 * time.Second), Assert: []Assertion{
+				{Path: "slug", Equals: []byte(`"${slug}"`)},
+			}},
+			{Action: ActionState, Node: "buyer", State: runner.StateStopped.String()},
+		},
+	}
+	if err := subject.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var report = Run(ctx, subject, NodeMap{"vendor": vendor, "buyer": buyer})
+	if !report.Passed {
+		var buf bytes.Buffer
+		report.WriteText(&buf)
+		t.Fatalf("expected scenario to pass, but was:\n%s", buf.String())
+	}
+	if len(report.Steps) != len(subject.Steps) {
+		t.Errorf("expected (%d) step results, but was (%d)", len(subject.Steps), len(report.Steps))
+	}
+}
+
+func TestRunSkipsStepsAfterFailure(t *testing.T) {
+	node, cleanup := mustCreateNode(t, "scenario_failure", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "listing not found", http.StatusNotFound)
+	}))
+	defer cleanup()
+
+	var report = Run(context.Background(), Scenario{
+		Name: "failure",
+		Steps: []Step{
+			{Action: ActionCall, Node: "vendor", Method: "GET", Path: "/ob/listing/missing"},
+			{Action: ActionCall, Node: "vendor", Method: "GET", Path: "/ob/listing/missing", Status: http.StatusNotFound},
+		},
+	}, NodeMap{"vendor": node})
+
+	if report.Passed || report.Failed() != 1 {
+		t.Errorf("expected one failed step, but was (%+v)", report)
+	}
+	if !strings.Contains(report.Steps[0].Error, "listing not found") {
+		t.Errorf("expected failure to include the response, but was (%s)", report.Steps[0].Error)
+	}
+	if !report.Steps[1].Skipped {
+		t.Error("expected step after failure to be skipped, but was not")
+	}
+
+	var buf bytes.Buffer
+	if err := report.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "FAIL failure") {
+		t.Errorf("expected text report to begin with the result, but was (%s)", buf.String())
+	}
+}
+
+func TestRunRetriesCallsWithTimeout(t *testing.T) {
+	var calls int32
+	node, cleanup := mustCreateNode(t, "scenario_retry", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			fmt.Fprint(w, `{"state":"PENDING"}`)
+			return
+		}
+		fmt.Fprint(w, `{"state":"COMPLETED"}`)
+	}))
+	defer cleanup()
+
+	var step = Step{
+		Action:  ActionCall,
+		Node:    "vendor",
+		Method:  "GET",
+		Path:    "/ob/order/Qm1",
+		Timeout: Duration(10 * time.Second),
+		Assert:  []Assertion{{Path: "state", Equals: []byte(`"COMPLETED"`)}},
+	}
+	var report = Run(context.Background(), Scenario{Name: "retry", Steps: []Step{step}}, NodeMap{"vendor": node})
+	if !report.Passed {
+		t.Errorf("expected call to pass once the order completed, but was (%+v)", report.Steps)
+	}
+	if n := atomic.LoadInt32(&calls); n != 3 {
+		t.Errorf("expected (3) calls, but was (%d)", n)
+	}
+
+	step.Timeout = Duration(time.Second)
+	step.Assert = []Assertion{{Path: "state", Equals: []byte(`"DISPUTED"`)}}
+	report = Run(context.Background(), Scenario{Name: "retry", Steps: []Step{step}}, NodeMap{"vendor": node})
+	if report.Passed || !strings.Contains(report.Steps[0].Error, "did not pass within 1s") {
+		t.Errorf("expected call to time out, but was (%+v)", report.Steps)
+	}
+}
+
+// fakeLinks records changes made by link steps
+type fakeLinks struct {
+	NodeMap
+	changes []string
+}
+
+func (f *fakeLinks) SetLink(from, to string, c netfault.Conditions) error {
+	f.changes = append(f.changes, fmt.Sprintf("%s->%s %s", from, to, c.Latency))
+	return nil
+}
+
+func (f *fakeLinks) Partition(groups [][]string) error {
+	f.changes = append(f.changes, fmt.Sprintf("partition %v", groups))
+	return nil
+}
+
+func (f *fakeLinks) Heal() error {
+	f.changes = append(f.changes, "heal")
+	return nil
+}
+
+func TestRunChangesLinks(t *testing.T) {
+	var steps = []Step{
+		{Action: ActionLink, From: "a", To: "b", Conditions: &netfault.Conditions{Latency: 50 * time.Millisecond}},
+		{Action: ActionPartition, Groups: [][]string{{"a"}, {"b"}}},
+		{Action: ActionHeal},
+	}
+	var links = &fakeLinks{}
+	var report = Run(context.Background(), Scenario{Name: "links", Steps: steps}, links)
+	if !report.Passed {
+		t.Fatalf("expected link steps to pass, but was (%+v)", report.Steps)
+	}
+	var expected = []string{"a->b 50ms", "partition [[a] [b]]", "heal"}
+	if !reflect.DeepEqual(links.changes, expected) {
+		t.Errorf("expected changes (%v), but was (%v)", expected, links.changes)
+	}
+
+	report = Run(context.Background(), Scenario{Name: "links", Steps: steps}, NodeMap{})
+	if report.Passed || !strings.Contains(report.Steps[0].Error, "fault proxy") {
+		t.Errorf("expected link steps to fail without a fault proxy, but was (%+v)", report.Steps[0])
+	}
+}
+
+// fakeWallet records funding and mining
+type fakeWallet struct {
+	NodeMap
+	funded map[string]int64
+	mined  int
+}
+
+func (f *fakeWallet) Fund(_ context.Context, node string, amount int64) (string, error) {
+	f.funded[node] += amount
+	return "tx-" + node, nil
+}
+
+func (f *fakeWallet) Mine(_ context.Context, blocks int) error {
+	f.mined += blocks
+	return nil
+}
+
+func TestRunFundsAndMines(t *testing.T) {
+	var steps = []Step{
+		{Action: ActionFund, Node: "buyer", Amount: 100000, Save: map[string]string{"funding": "txid"}},
+		{Action: ActionMine},
+		{Action: ActionMine, Blocks: 5},
+	}
+	var wallet = &fakeWallet{funded: make(map[string]int64)}
+	var report = Run(context.Background(), Scenario{Name: "wallet", Steps: steps}, wallet)
+	if !report.Passed {
+		t.Fatalf("expected wallet steps to pass, but was (%+v)", report.Steps)
+	}
+	if wallet.funded["buyer"] != 100000 || wallet.mined != 6 {
+		t.Errorf("expected buyer funded with (100000) and (6) blocks mined, but was (%v) and (%d)", wallet.funded, wallet.mined)
+	}
+	if report.Steps[1].Name != "2: mine 1" {
+		t.Errorf("expected mine to default to one block, but was (%s)", report.Steps[1].Name)
+	}
+
+	report = Run(context.Background(), Scenario{Name: "wallet", Steps: steps}, NodeMap{})
+	if report.Passed || !strings.Contains(report.Steps[0].Error, "wallet backend") {
+		t.Errorf("expected wallet steps to fail without a backend, but was (%+v)", report.Steps[0])
+	}
+}
+
+type fakeClock struct {
+	NodeMap
+	advanced time.Duration
+}
+
+func (f *fakeClock) AdvanceTime(d time.Duration) error {
+	f.advanced += d
+	return nil
+}
+
+func TestRunAdvancesTime(t *testing.T) {
+	var steps = []Step{
+		{Action: ActionAdvanceTime, Duration: Duration(72 * time.Hour)},
+		{Action: ActionAdvanceTime, Duration: Duration(time.Minute)},
+	}
+	var clock = &fakeClock{}
+	var report = Run(context.Background(), Scenario{Name: "clock", Steps: steps}, clock)
+	if !report.Passed {
+		t.Fatalf("expected advanceTime steps to pass, but was (%+v)", report.Steps)
+	}
+	if clock.advanced != 72*time.Hour+time.Minute {
+		t.Errorf("expected the clock to advance (72h1m0s), but was (%s)", clock.advanced)
+	}
+	if report.Steps[0].Name != "1: advance time by 72h0m0s" {
+		t.Errorf("unexpected step name (%s)", report.Steps[0].Name)
+	}
+
+	report = Run(context.Background(), Scenario{Name: "clock", Steps: steps}, NodeMap{})
+	if report.Passed || !strings.Contains(report.Steps[0].Error, "clock") {
+		t.Errorf("expected advanceTime to fail without a clock, but was (%+v)", report.Steps[0])
+	}
+}
+
+func TestExpandJSONKeepsSavedTypes(t *testing.T) {
+	var (
+		vars     = map[string]string{"amount": "1500", "id": "1500", "address": "mx1"}
+		literals = map[string]bool{"amount": true}
+	)
+	expanded, err := expandJSON(`{"amount":"${amount}","id":"${id}","address":"${address}","memo":"${amount} to ${address}"}`, vars, literals)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var expected = `{"amount":1500,"id":"1500","address":"mx1","memo":"1500 to mx1"}`
+	if expanded != expected {
+		t.Errorf("expected (%s), but was (%s)", expected, expanded)
+	}
+	vars["memo"] = `say "hi"\`
+	expanded, err = expandJSON(`{"memo":"${memo}","note":"re: ${memo}"}`, vars, literals)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]string
+	if err := json.Unmarshal([]byte(expanded), &decoded); err != nil {
+		t.Fatalf("expected valid JSON, but was (%s): %s", expanded, err)
+	}
+	if decoded["memo"] != vars["memo"] || decoded["note"] != "re: "+vars["memo"] || len(decoded) != 2 {
+		t.Errorf("expected quotes and backslashes to be escaped, but was (%s)", expanded)
+	}
+	if _, err := expandJSON(`{"amount":"${missing}"}`, vars, literals); err == nil {
+		t.Error("expected an unset variable to error")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	var examples = []struct {
+		step  Step
+		valid bool
+	}{
+		{Step{Action: ActionCall, Node: "a", Method: "GET", Path: "/ob/profile"}, true},
+		{Step{Action: ActionCall, Node: "a", Method: "GET"}, false},
+		{Step{Action: ActionWaitEvent, Node: "a", Kind: runner.EventNotification}, true},
+		{Step{Action: ActionWaitEvent, Node: "a"}, false},
+		{Step{Action: ActionState, Node: "a", State: "ready"}, true},
+		{Step{Action: ActionSleep}, false},
+		{Step{Action: ActionLink, From: "a", To: "*", Conditions: &netfault.Conditions{Latency: time.Second}}, true},
+		{Step{Action: ActionLink, From: "a", To: "b", Conditions: &netfault.Conditions{DropRate: 2}}, false},
+		{Step{Action: ActionLink, From: "a", To: "b"}, false},
+		{Step{Action: ActionPartition, Groups: [][]string{{"a"}}}, false},
+		{Step{Action: ActionHeal}, true},
+		{Step{Action: ActionFund, Node: "a", Amount: 1}, true},
+		{Step{Action: ActionFund, Node: "a"}, false},
+		{Step{Action: ActionMine}, true},
+		{Step{Action: ActionAdvanceTime, Duration: Duration(time.Hour)}, true},
+		{Step{Action: ActionAdvanceTime}, false},
+		{Step{Action: "teleport"}, false},
+	}
+	for _, e := range examples {
+		var err = Scenario{Name: "example", Steps: []Step{e.step}}.Validate()
+		if (err == nil) != e.valid {
+			t.Errorf("expected step (%+v) valid (%t), but error was (%v)", e.step, e.valid, err)
+		}
+	}
+}
+
+func TestLoadParsesDurations(t *testing.T) {
+	var path = util.GenerateTempPath("scenario_load") + ".json"
+	defer os.Remove(path)
+	var doc = `{"steps":[{"action":"sleep","duration":"1500ms"}]}`
+	if err := ioutil.WriteFile(path, []byte(doc), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Name != path {
+		t.Errorf("expected name to default to the path, but was (%s)", s.Name)
+	}
+	if time.Duration(s.Steps[0].Duration) != 1500*time.Millisecond {
+		t.Errorf("expected 1.5s sleep, but was (%s)", time.Duration(s.Steps[0].Duration))
+	}
+}
+
+func TestAssertionCheck(t *testing.T) {
+	doc, err := decode([]byte(`{"listing":{"price":{"amount":500},"tags":["a","b"]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var no = false
+	var examples = []struct {
+		assertion Assertion
+		passes    bool
+	}{
+		{Assertion{Path: "listing.price.amount", Equals: []byte(`500`)}, true},
+		{Assertion{Path: "listing.price.amount", Equals: []byte(`501`)}, false},
+		{Assertion{Path: "listing.tags.1", Equals: []byte(`"b"`)}, true},
+		{Assertion{Path: "listing.tags.2"}, false},
+		{Assertion{Path: "listing.tags", Contains: `"a"`}, true},
+		{Assertion{Path: "listing.missing", Exists: &no}, true},
+		{Assertion{Path: "listing", Exists: &no}, false},
+	}
+	for _, e := range examples {
+		if err := e.assertion.check(doc, nil); (err == nil) != e.passes {
+			t.Errorf("expected (%+v) to pass (%t), but error was (%v)", e.assertion, e.passes, err)
+		}
+	}
+}
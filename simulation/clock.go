@@ -0,0 +1,67 @@
+package simulation
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/OpenBazaar/mason/builder/runner"
+	"github.com/OpenBazaar/mason/faketime"
+)
+
+// startClock creates the clock file read by every node when the topology
+// has a clock. The mutex must be held.
+func (s *Simulation) startClock() error {
+	if s.topology.Clock == "" || s.clock != nil {
+		return nil
+	}
+	if s.topology.Clock == faketime.ModeLibfaketime {
+		library, err := faketime.FindLibrary()
+		if err != nil {
+			return err
+		}
+		s.preload = library
+	}
+	c, err := faketime.New(filepath.Join(s.opts.RunPath, "clock"))
+	if err != nil {
+		return err
+	}
+	s.clock = c
+	return nil
+}
+
+// useClock adds the clock's variables to the node's environment, keeping
+// any set by Options.Configure
+func (s *Simulation) useClock(r *runner.OpenBazaarRunner) error {
+	var env, _ = r.Environment()
+	var vars = make(map[string]string)
+	for k, v := range env.Vars {
+		vars[k] = v
+	}
+	for k, v := range s.clock.Environment(s.preload) {
+		vars[k] = v
+	}
+	env.Vars = vars
+	if err := r.SetEnvironment(env); err != nil {
+		return fmt.Errorf("setting clock environment: %s", err.Error())
+	}
+	return nil
+}
+
+// Clock returns the clock the nodes read, or nil unless the topology sets
+// one and the nodes are built
+func (s *Simulation) Clock() *faketime.Clock {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.clock
+}
+
+// AdvanceTime moves every node's clock forward by d. Checks which run on
+// a timer, such as order expiry, see the new time when they next run.
+func (s *Simulation) AdvanceTime(d time.Duration) error {
+	var c = s.Clock()
+	if c == nil {
+		return fmt.Errorf("clock is not enabled")
+	}
+	return c.Advance(d)
+}
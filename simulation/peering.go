@@ -0,0 +1,164 @@
+package simulation
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/OpenBazaar/mason/builder/runner"
+	"github.com/OpenBazaar/mason/netfault"
+)
+
+// peer bootstraps every node from the others and shares a swarm key
+// between them as requested by the topology. Nodes must be built and
+// initialized.
+func (s *Simulation) peer() error {
+	if s.topology.private() {
+		var key = s.topology.SwarmKey
+		if key == "" {
+			var err error
+			if key, err = runner.GenerateSwarmKey(); err != nil {
+				return err
+			}
+		}
+		for _, n := range s.nodes {
+			if err := n.Runner.SetSwarmKey(key); err != nil {
+				return fmt.Errorf("node (%s): %s", n.Name(), err.Error())
+			}
+		}
+	}
+	if !s.topology.AutoPeer {
+		return nil
+	}
+
+	var addrs = make(map[string][]string, len(s.nodes))
+	for _, n := range s.nodes {
+		info, err := n.Runner.PeerInfo()
+		if err != nil {
+			return fmt.Errorf("reading peer info of node (%s): %s", n.Name(), err.Error())
+		}
+		addrs[n.Name()] = info.Addresses
+	}
+	if s.topology.FaultProxy {
+		var host = s.topology.FaultProxyHost
+		if host == "" {
+			host = netfault.DefaultHost
+		}
+		if err := netfault.CheckHost(host); err != nil {
+			return err
+		}
+		s.proxy = netfault.New(host)
+	}
+	for _, n := range s.nodes {
+		var peers = make(map[string][]string)
+		for _, other := range s.nodes {
+			if other != n {
+				peers[other.Name()] = addrs[other.Name()]
+			}
+		}
+		var bootstrap = []string{}
+		if s.proxy != nil {
+			var err error
+			if bootstrap, err = s.routeThroughProxy(n, peers); err != nil {
+				return err
+			}
+		} else {
+			for _, other := range s.nodes {
+				bootstrap = append(bootstrap, peers[other.Name()]...)
+			}
+		}
+		if err := n.Runner.SetBootstrapPeers(bootstrap); err != nil {
+			return fmt.Errorf("bootstrapping node (%s): %s", n.Name(), err.Error())
+		}
+		log.Debugf("%s bootstraps from %d local addresses", n.Name(), len(bootstrap))
+	}
+	if s.proxy == nil {
+		return nil
+	}
+	for _, l := range s.topology.Links {
+		if err := s.proxy.Set(l.From, l.To, l.Conditions); err != nil {
+			return fmt.Errorf("setting link from (%s) to (%s): %s", l.From, l.To, err.Error())
+		}
+	}
+	return nil
+}
+
+// routeThroughProxy returns the addresses n dials to reach each peer
+// through the fault proxy, and stops n dialing the peers' own addresses,
+// which it may learn from the network. IPv6 is filtered entirely as the
+// proxy only carries IPv4.
+func (s *Simulation) routeThroughProxy(n *Node, peers map[string][]string) ([]string, error) {
+	var (
+		routed  = []string{}
+		filters = []string{"/ip6/::/ipcidr/0"}
+		hosts   = make(map[string]bool)
+	)
+	for _, other := range s.nodes {
+		for _, addr := range peers[other.Name()] {
+			var parts = strings.Split(addr, "/")
+			if len(parts) < 3 || parts[1] != "ip4" {
+				continue
+			}
+			if parts[2] == s.proxy.Host() {
+				return nil, fmt.Errorf("node (%s) cannot listen on the fault proxy's address (%s)", other.Name(), addr)
+			}
+			maddr, err := s.proxy.RouteMultiaddr(n.Name(), other.Name(), addr)
+			if err != nil {
+				return nil, fmt.Errorf("routing from (%s) to (%s): %s", n.Name(), other.Name(), err.Error())
+			}
+			routed = append(routed, maddr)
+			if !hosts[parts[2]] {
+				hosts[parts[2]] = true
+				filters = append(filters, "/ip4/"+parts[2]+"/ipcidr/32")
+			}
+		}
+	}
+	if err := n.Runner.SetSwarmAddrFilters(filters); err != nil {
+		return nil, fmt.Errorf("filtering addresses of node (%s): %s", n.Name(), err.Error())
+	}
+	return routed, nil
+}
+
+// FaultProxy returns the proxy carrying traffic between the nodes, or nil
+// unless the topology enables it and the nodes are built
+func (s *Simulation) FaultProxy() *netfault.Proxy {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.proxy
+}
+
+func (s *Simulation) requireProxy() (*netfault.Proxy, error) {
+	var p = s.FaultProxy()
+	if p == nil {
+		return nil, fmt.Errorf("fault proxy is not enabled")
+	}
+	return p, nil
+}
+
+// SetLink changes the conditions of data sent from one node to another,
+// where either may be netfault.Any
+func (s *Simulation) SetLink(from, to string, c netfault.Conditions) error {
+	p, err := s.requireProxy()
+	if err != nil {
+		return err
+	}
+	return p.Set(from, to, c)
+}
+
+// Partition separates each group of nodes from the others
+func (s *Simulation) Partition(groups [][]string) error {
+	p, err := s.requireProxy()
+	if err != nil {
+		return err
+	}
+	return p.Partition(groups)
+}
+
+// Heal removes every partition between the nodes
+func (s *Simulation) Heal() error {
+	p, err := s.requireProxy()
+	if err != nil {
+		return err
+	}
+	p.Heal()
+	return nil
+}
@@ -0,0 +1,82 @@
+package simulation
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/OpenBazaar/mason/traffic"
+)
+
+// record moves the node's gateway to a free loopback port and places a
+// recording proxy on its configured address, so every client of the API,
+// including scenarios and fixtures, is recorded
+func (s *Simulation) record(n *Node) error {
+	var r = n.Runner
+	public, err := r.GatewayAddress()
+	if err != nil {
+		return err
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	var port = l.Addr().(*net.TCPAddr).Port
+	l.Close()
+	if err := r.SetConfigValue("Addresses.Gateway", "/ip4/127.0.0.1/tcp/"+strconv.Itoa(port)); err != nil {
+		return fmt.Errorf("moving gateway: %s", err.Error())
+	}
+
+	if err := os.MkdirAll(r.RunPath(), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(filepath.Join(r.RunPath(), "api.jsonl"))
+	if err != nil {
+		return fmt.Errorf("creating recording: %s", err.Error())
+	}
+	rec, err := traffic.NewRecorder(n.Name(), public, net.JoinHostPort("127.0.0.1", strconv.Itoa(port)), f)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	n.recorder, n.recording = rec, f
+	r.SetAPIAddress(rec.Addr())
+	return nil
+}
+
+// Recording returns the API traffic recorded for the named node so far
+func (s *Simulation) Recording(name string) ([]traffic.Entry, error) {
+	n, ok := s.Node(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown node (%s)", name)
+	}
+	if n.recorder == nil {
+		return nil, fmt.Errorf("node (%s) is not recorded", name)
+	}
+	return n.recorder.Entries(), nil
+}
+
+// stopRecording closes the node's recorder once and writes its HAR
+// archive if requested
+func (s *Simulation) stopRecording(n *Node) error {
+	if n.recorder == nil || n.recording == nil {
+		return nil
+	}
+	var rec, f = n.recorder, n.recording
+	n.recording = nil
+	var err = rec.Close()
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil || s.opts.Record != traffic.FormatHAR {
+		return err
+	}
+	har, err := os.Create(filepath.Join(n.Runner.RunPath(), "api.har"))
+	if err != nil {
+		return err
+	}
+	defer har.Close()
+	return traffic.WriteHAR(har, rec.Entries())
+}
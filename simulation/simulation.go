@@ -0,0 +1,409 @@
+package simulation
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/OpenBazaar/mason/builder"
+	"github.com/OpenBazaar/mason/builder/blueprints"
+	"github.com/OpenBazaar/mason/builder/runner"
+	"github.com/OpenBazaar/mason/faketime"
+	"github.com/OpenBazaar/mason/netfault"
+	"github.com/OpenBazaar/mason/regtest"
+	"github.com/OpenBazaar/mason/traffic"
+	"github.com/OpenBazaar/mason/util"
+	"github.com/op/go-logging"
+	"github.com/otiai10/copy"
+)
+
+const defaultReadyTimeout = 2 * time.Minute
+
+var log = logging.MustGetLogger("simulation")
+
+// BuildFunc produces a runner for the node described by spec
+type BuildFunc func(spec NodeSpec) (*runner.OpenBazaarRunner, error)
+
+// Options controls how a Simulation builds and runs its nodes
+type Options struct {
+	// Build produces each node's runner. Nodes are built from source or
+	// the cache by default.
+	Build BuildFunc
+	// Configure is called for each node after the topology is applied
+	// and before it is initialized and started
+	Configure func(*Node) error
+	// Supervisor controls how nodes are restarted after exiting
+	Supervisor runner.SupervisorOptions
+	// ReadyTimeout limits how long each start group has to become ready
+	// before the next group is started. Defaults to 2 minutes.
+	ReadyTimeout time.Duration
+	// StopGrace is how long nodes may take to exit when interrupted
+	// before they are killed. Nodes are killed immediately by default.
+	StopGrace time.Duration
+	// RunPath is the directory holding each node's run path. One is
+	// generated when empty.
+	RunPath string
+	// Record places a recording proxy in front of each node's gateway
+	// and writes the API traffic to api.jsonl in its run path, and to
+	// api.har as well when set to traffic.FormatHAR
+	Record string
+}
+
+// Node is a member of a running simulation
+type Node struct {
+	Spec       NodeSpec
+	Runner     *runner.OpenBazaarRunner
+	Supervisor *runner.Supervisor
+
+	recorder  *traffic.Recorder
+	recording *os.File
+
+	cleanupOnce sync.Once
+	cleanupErr  error
+}
+
+// Name returns the node's name from the topology
+func (n *Node) Name() string {
+	return n.Spec.Name
+}
+
+// cleanup releases the node's resources once, regardless of how many
+// times it is called
+func (n *Node) cleanup() error {
+	n.cleanupOnce.Do(func() {
+		n.cleanupErr = n.Runner.Cleanup()
+	})
+	return n.cleanupErr
+}
+
+// Simulation builds, configures and runs every node of a Topology
+type Simulation struct {
+	topology Topology
+	opts     Options
+
+	mutex   sync.Mutex
+	nodes   []*Node
+	byName  map[string]*Node
+	started []*Node
+	proxy   *netfault.Proxy
+	wallet  regtest.Backend
+	clock   *faketime.Clock
+	preload string
+	done    chan struct{}
+}
+
+// New prepares a simulation of the topology. Nothing is built until
+// Build or Start is called.
+func New(t Topology, opts Options) (*Simulation, error) {
+	t = t.withDefaults()
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+	if opts.Record != "" && opts.Record != traffic.FormatJSONLines && opts.Record != traffic.FormatHAR {
+		return nil, fmt.Errorf("unknown recording format (%s)", opts.Record)
+	}
+	if opts.Build == nil {
+		// only the fake daemon understands the mock's ledger
+		if t.Wallet == regtest.KindMock {
+			return nil, fmt.Errorf("the mock wallet backend only works with the fake daemon, use bitcoind for openbazaard")
+		}
+		opts.Build = buildNode
+	}
+	if opts.ReadyTimeout <= 0 {
+		opts.ReadyTimeout = defaultReadyTimeout
+	}
+	if opts.RunPath == "" {
+		opts.RunPath = util.GenerateRunPath("simulation")
+	}
+	return &Simulation{
+		topology: t,
+		opts:     opts,
+		byName:   make(map[string]*Node),
+		done:     make(chan struct{}),
+	}, nil
+}
+
+func buildNode(spec NodeSpec) (*runner.OpenBazaarRunner, error) {
+	var b = builder.NewOpenBazaarDaemon(spec.Name, spec.Version)
+	defer b.MustClean()
+	for _, name := range spec.Patches {
+		p, err := blueprints.LookupPatch(name)
+		if err != nil {
+			return nil, err
+		}
+		b.AddPatch(p)
+	}
+	return b.Build()
+}
+
+// FakeBuild is a BuildFunc which produces the fake daemon in cmd/fakeobd,
+// reporting each node's version, so simulations can run without the
+// network. The fake daemon reads the simulation's clock without the clock
+// patch and cannot be built with any other.
+func FakeBuild(spec NodeSpec) (*runner.OpenBazaarRunner, error) {
+	for _, name := range spec.Patches {
+		if name != blueprints.ClockPatch.Name {
+			return nil, fmt.Errorf("the fake daemon cannot be built with the %s patch", name)
+		}
+	}
+	var b = builder.NewFakeOpenBazaarDaemon(spec.Name, spec.Version)
+	defer b.MustClean()
+	return b.Build()
+}
+
+// RunPath returns the directory holding the run path of each node
+func (s *Simulation) RunPath() string {
+	return s.opts.RunPath
+}
+
+// Build produces and configures a runner for each node in the topology.
+// Nodes are built in order so each version is only built once.
+func (s *Simulation) Build() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.nodes != nil {
+		return nil
+	}
+	if err := s.startClock(); err != nil {
+		return err
+	}
+	if err := s.startWallet(); err != nil {
+		return err
+	}
+	var nodes []*Node
+	for _, spec := range s.topology.Nodes {
+		log.Infof("building %s (%s)", spec.Name, spec.Version)
+		r, err := s.opts.Build(spec)
+		if err != nil {
+			return fmt.Errorf("building node (%s): %s", spec.Name, err.Error())
+		}
+		var node = &Node{Spec: spec, Runner: r}
+		if err := s.configure(node); err != nil {
+			return fmt.Errorf("configuring node (%s): %s", spec.Name, err.Error())
+		}
+		nodes = append(nodes, node)
+	}
+	s.nodes = nodes
+	for _, n := range nodes {
+		s.byName[n.Name()] = n
+	}
+	return s.peer()
+}
+
+// configure applies the node's spec to its runner
+func (s *Simulation) configure(n *Node) error {
+	var (
+		spec    = n.Spec
+		r       = n.Runner
+		runPath = filepath.Join(s.opts.RunPath, spec.Name)
+	)
+	r.SetRunPath(runPath)
+	var dataPath = spec.DataPath
+	if dataPath == "" {
+		dataPath = filepath.Join(runPath, "data")
+	}
+	if err := r.SetCustomDataPath(dataPath); err != nil {
+		return err
+	}
+	if err := r.SetTestnetMode(*spec.Testnet); err != nil {
+		return err
+	}
+	r.WithArgs(spec.Args)
+	if s.opts.Configure != nil {
+		if err := s.opts.Configure(n); err != nil {
+			return err
+		}
+	}
+	if s.clock != nil {
+		if err := s.useClock(r); err != nil {
+			return err
+		}
+	}
+
+	if spec.Mnemonic != "" {
+		var opts, _ = r.InitOptions()
+		opts.Mnemonic = spec.Mnemonic
+		if err := r.SetInitOptions(opts); err != nil {
+			return fmt.Errorf("setting init options: %s", err.Error())
+		}
+	}
+	if _, hasInitOpts := r.InitOptions(); hasInitOpts || len(spec.Config) > 0 || s.topology.AutoPeer || s.topology.private() || s.wallet != nil || s.opts.Record != "" {
+		if err := r.Init(); err != nil {
+			return err
+		}
+	}
+	if s.wallet != nil {
+		if err := s.wallet.Configure(r); err != nil {
+			return fmt.Errorf("configuring wallet: %s", err.Error())
+		}
+	}
+
+	var keys = make([]string, 0, len(spec.Config))
+	for k := range spec.Config {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := r.SetConfigValue(k, spec.Config[k]); err != nil {
+			return fmt.Errorf("setting config (%s): %s", k, err.Error())
+		}
+	}
+	if s.opts.Record != "" {
+		return s.record(n)
+	}
+	return nil
+}
+
+// Start builds the nodes if needed and starts them in start order. Each
+// group of nodes must be ready before the next group is started. Nodes
+// which were started are left running if an error is returned so they
+// may be inspected before calling Stop.
+func (s *Simulation) Start(ctx context.Context) error {
+	if err := s.Build(); err != nil {
+		return err
+	}
+	for _, group := range s.topology.startGroups() {
+		var nodes []*Node
+		for _, spec := range group {
+			var n = s.byName[spec.Name]
+			log.Infof("starting %s, output is persisted at %s", n.Name(), n.Runner.RunPath())
+			n.Supervisor = n.Runner.Supervise(s.opts.Supervisor)
+			s.mutex.Lock()
+			s.started = append(s.started, n)
+			s.mutex.Unlock()
+			nodes = append(nodes, n)
+		}
+		if err := s.waitForReady(ctx, nodes); err != nil {
+			return err
+		}
+	}
+	go s.watch()
+	return nil
+}
+
+func (s *Simulation) waitForReady(ctx context.Context, nodes []*Node) error {
+	var ctx2, cancel = context.WithTimeout(ctx, s.opts.ReadyTimeout)
+	defer cancel()
+	for _, n := range nodes {
+		if err := n.Runner.WaitForReady(ctx2); err != nil {
+			return fmt.Errorf("waiting for node (%s) to be ready: %s", n.Name(), err.Error())
+		}
+	}
+	return nil
+}
+
+// watch cleans up each node once it will no longer be restarted and
+// closes done when every node has finished
+func (s *Simulation) watch() {
+	var wg sync.WaitGroup
+	for _, n := range s.Nodes() {
+		wg.Add(1)
+		go func(n *Node) {
+			defer wg.Done()
+			<-n.Supervisor.Done()
+			if err := n.cleanup(); err != nil {
+				log.Errorf("cleaning up node (%s): %s", n.Name(), err.Error())
+			}
+		}(n)
+	}
+	wg.Wait()
+	close(s.done)
+}
+
+// Done is closed once every node has exited and will not be restarted
+func (s *Simulation) Done() <-chan struct{} {
+	return s.done
+}
+
+// Stop stops the started nodes in reverse start order and releases
+// their resources, then closes the fault proxy and wallet backend
+func (s *Simulation) Stop() error {
+	s.mutex.Lock()
+	var (
+		started = append([]*Node{}, s.started...)
+		proxy   = s.proxy
+		wallet  = s.wallet
+	)
+	s.mutex.Unlock()
+
+	var firstErr error
+	for i := len(started) - 1; i >= 0; i-- {
+		var n = started[i]
+		if err := n.Runner.Stop(s.opts.StopGrace); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("stopping node (%s): %s", n.Name(), err.Error())
+		}
+		if err := n.cleanup(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("cleaning up node (%s): %s", n.Name(), err.Error())
+		}
+	}
+	if proxy != nil {
+		if err := proxy.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("closing fault proxy: %s", err.Error())
+		}
+	}
+	for _, n := range s.Nodes() {
+		if err := s.stopRecording(n); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("recording node (%s): %s", n.Name(), err.Error())
+		}
+	}
+	if wallet != nil {
+		if err := wallet.Stop(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("stopping wallet backend: %s", err.Error())
+		}
+	}
+	return firstErr
+}
+
+// Snapshot copies the data path of every node to dir/<name>, so the
+// state they reached may be reused as the DataPath of later topologies.
+// Nodes must not be running, so their data is consistent.
+func (s *Simulation) Snapshot(dir string) error {
+	for _, n := range s.Nodes() {
+		if state := n.Runner.State(); state.Running() || state == runner.StateInitializing {
+			return fmt.Errorf("cannot snapshot node (%s) while %s", n.Name(), state)
+		}
+		if err := copy.Copy(n.Runner.DataPath(), filepath.Join(dir, n.Name())); err != nil {
+			return fmt.Errorf("copying data path of (%s): %s", n.Name(), err.Error())
+		}
+	}
+	return nil
+}
+
+// Nodes returns every node in topology order
+func (s *Simulation) Nodes() []*Node {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]*Node{}, s.nodes...)
+}
+
+// Node returns the node with the given name
+func (s *Simulation) Node(name string) (*Node, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	n, ok := s.byName[name]
+	return n, ok
+}
+
+// Runner returns the runner of the node with the given name
+func (s *Simulation) Runner(name string) (*runner.OpenBazaarRunner, bool) {
+	n, ok := s.Node(name)
+	if !ok {
+		return nil, false
+	}
+	return n.Runner, true
+}
+
+// NodesWithRole returns every node tagged with role in topology order
+func (s *Simulation) NodesWithRole(role string) []*Node {
+	var nodes []*Node
+	for _, n := range s.Nodes() {
+		if n.Spec.HasRole(role) {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes
+}
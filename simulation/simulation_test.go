@@ -0,0 +1,427 @@
+package simulation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/OpenBazaar/mason/builder/runner"
+	"github.com/OpenBazaar/mason/faketime"
+	"github.com/OpenBazaar/mason/netfault"
+	"github.com/OpenBazaar/mason/regtest"
+	"github.com/OpenBazaar/mason/traffic"
+	"github.com/OpenBazaar/mason/util"
+)
+
+// fakeNodeScript initializes a config with an unused gateway and runs
+// until it is killed when started
+const fakeNodeScript = `case "$1" in
+init)
+	while [ $# -gt 0 ]; do [ "$1" = -d ] && data="$2"; shift; done
+	mkdir -p "$data"
+	name=$(basename "$(dirname "$data")")
+	echo '{"Identity":{"PeerID":"Qm'$name'"},"Addresses":{"Gateway":"/ip4/127.0.0.1/tcp/1","Swarm":["/ip4/0.0.0.0/tcp/4001"]},"Bootstrap":["/dnsaddr/public"],"Swarm":{}}' > "$data/config"
+	;;
+start)
+	exec sleep 30
+	;;
+esac`
+
+// mustCreateScriptBuild returns a BuildFunc which produces runners for a
+// shell script with the provided body
+func mustCreateScriptBuild(t *testing.T, label, body string) (BuildFunc, func()) {
+	var path = util.GenerateTempPath(label)
+	if err := os.MkdirAll(path, 0755); err != nil {
+		t.Fatal(err)
+	}
+	var binary = filepath.Join(path, "openbazaard")
+	if err := ioutil.WriteFile(binary, []byte("#!/bin/sh\n"+body+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	var build = func(NodeSpec) (*runner.OpenBazaarRunner, error) {
+		return runner.FromBinaryPath(binary)
+	}
+	return build, func() { os.RemoveAll(path) }
+}
+
+func mustListen(t *testing.T) net.Listener {
+	var l, err = net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func gatewayConfig(l net.Listener) map[string]interface{} {
+	var addr = l.Addr().(*net.TCPAddr)
+	return map[string]interface{}{"Addresses.Gateway": fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", addr.Port)}
+}
+
+func TestSimulationStartsGroupsInOrder(t *testing.T) {
+	var build, cleanup = mustCreateScriptBuild(t, "simulation_order", fakeNodeScript)
+	defer cleanup()
+	var runPath = util.GenerateTempPath("simulation_order_runs")
+	defer os.RemoveAll(runPath)
+
+	var vendorGateway, buyerGateway = mustListen(t), mustListen(t)
+	defer vendorGateway.Close()
+	defer buyerGateway.Close()
+
+	var (
+		mutex  sync.Mutex
+		events []string
+	)
+	subject, err := New(Topology{
+		Version: "v0.13.8",
+		Nodes: []NodeSpec{
+			{Name: "buyer", Roles: []string{"buyer"}, StartOrder: 1, Config: gatewayConfig(buyerGateway)},
+			{Name: "vendor", Roles: []string{"vendor"}, Config: gatewayConfig(vendorGateway)},
+		},
+	}, Options{
+		Build:   build,
+		RunPath: runPath,
+		Configure: func(n *Node) error {
+			n.Runner.OnStateChange(func(c runner.StateChange) {
+				mutex.Lock()
+				defer mutex.Unlock()
+				events = append(events, fmt.Sprintf("%s:%s", n.Name(), c.To))
+			})
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := subject.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if vendors := subject.NodesWithRole("vendor"); len(vendors) != 1 || vendors[0].Runner.State() != runner.StateReady {
+		t.Errorf("expected one ready vendor, but was (%v)", vendors)
+	}
+	if err := subject.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-subject.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for simulation to finish")
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	var vendorReady, buyerStarting = -1, -1
+	for i, e := range events {
+		switch e {
+		case "vendor:ready":
+			vendorReady = i
+		case "buyer:starting":
+			buyerStarting = i
+		}
+	}
+	if vendorReady < 0 || buyerStarting < vendorReady {
+		t.Errorf("expected vendor to be ready before buyer started, but events were (%v)", events)
+	}
+	if n, ok := subject.Node("buyer"); !ok || n.Runner.RunPath() != filepath.Join(runPath, "buyer") {
+		t.Errorf("expected buyer run path within simulation run path, but was (%v)", n)
+	}
+}
+
+func TestSimulationStartFailsWhenNodeIsNotReady(t *testing.T) {
+	var build, cleanup = mustCreateScriptBuild(t, "simulation_crash", `exit 1`)
+	defer cleanup()
+	var runPath = util.GenerateTempPath("simulation_crash_runs")
+	defer os.RemoveAll(runPath)
+
+	subject, err := New(Topology{Version: "v0.13.8", Nodes: []NodeSpec{{Name: "crasher"}}}, Options{Build: build, RunPath: runPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := subject.Start(context.Background()); err == nil {
+		t.Error("expected node which exits to fail start, but did not")
+	}
+	if err := subject.Stop(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSimulationAutoPeersPrivateNetwork(t *testing.T) {
+	var build, cleanup = mustCreateScriptBuild(t, "simulation_peer", fakeNodeScript)
+	defer cleanup()
+	var runPath = util.GenerateTempPath("simulation_peer_runs")
+	defer os.RemoveAll(runPath)
+
+	subject, err := New(Topology{
+		Version:        "v0.13.8",
+		AutoPeer:       true,
+		PrivateNetwork: true,
+		Nodes:          []NodeSpec{{Name: "a"}, {Name: "b"}, {Name: "c"}},
+	}, Options{Build: build, RunPath: runPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := subject.Build(); err != nil {
+		t.Fatal(err)
+	}
+
+	var keys = make(map[string]bool)
+	for _, n := range subject.Nodes() {
+		var config struct{ Bootstrap []string }
+		b, err := ioutil.ReadFile(filepath.Join(runPath, n.Name(), "data", "config"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(b, &config); err != nil {
+			t.Fatal(err)
+		}
+		if len(config.Bootstrap) != 2 {
+			t.Errorf("expected (%s) to bootstrap from the (2) other nodes, but was (%v)", n.Name(), config.Bootstrap)
+		}
+		for _, addr := range config.Bootstrap {
+			if addr == "/ip4/127.0.0.1/tcp/4001/ipfs/Qm"+n.Name() || addr == "/dnsaddr/public" {
+				t.Errorf("expected (%s) to only bootstrap from other local nodes, but was (%v)", n.Name(), config.Bootstrap)
+			}
+		}
+		key, err := ioutil.ReadFile(filepath.Join(runPath, n.Name(), "data", "swarm.key"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[string(key)] = true
+	}
+	if len(keys) != 1 {
+		t.Errorf("expected every node to share one swarm key, but found (%d)", len(keys))
+	}
+}
+
+func TestSimulationRoutesPeersThroughFaultProxy(t *testing.T) {
+	var build, cleanup = mustCreateScriptBuild(t, "simulation_proxy", fakeNodeScript)
+	defer cleanup()
+	var runPath = util.GenerateTempPath("simulation_proxy_runs")
+	defer os.RemoveAll(runPath)
+
+	var slow = netfault.Conditions{Latency: 100 * time.Millisecond}
+	subject, err := New(Topology{
+		Version:    "v0.13.8",
+		AutoPeer:   true,
+		FaultProxy: true,
+		Links:      []LinkSpec{{From: "a", To: netfault.Any, Conditions: slow}},
+		Nodes:      []NodeSpec{{Name: "a"}, {Name: "b"}},
+	}, Options{Build: build, RunPath: runPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := subject.Heal(); err == nil {
+		t.Error("expected an error healing before the proxy is started")
+	}
+	if err := subject.Build(); err != nil {
+		t.Fatal(err)
+	}
+	defer subject.Stop()
+
+	for _, n := range subject.Nodes() {
+		var config struct {
+			Bootstrap []string
+			Swarm     struct{ AddrFilters []string }
+		}
+		b, err := ioutil.ReadFile(filepath.Join(runPath, n.Name(), "data", "config"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(b, &config); err != nil {
+			t.Fatal(err)
+		}
+		if len(config.Bootstrap) != 1 || !strings.HasPrefix(config.Bootstrap[0], "/ip4/"+netfault.DefaultHost+"/tcp/") {
+			t.Errorf("expected (%s) to bootstrap through the proxy, but was (%v)", n.Name(), config.Bootstrap)
+		}
+		var expected = []string{"/ip6/::/ipcidr/0", "/ip4/127.0.0.1/ipcidr/32"}
+		if !reflect.DeepEqual(config.Swarm.AddrFilters, expected) {
+			t.Errorf("expected (%s) to filter direct addresses (%v), but was (%v)", n.Name(), expected, config.Swarm.AddrFilters)
+		}
+	}
+	if c := subject.FaultProxy().Conditions("a", "b"); c != slow {
+		t.Errorf("expected link from the topology to be applied, but was (%+v)", c)
+	}
+	if err := subject.Partition([][]string{{"a"}, {"b"}}); err != nil {
+		t.Fatal(err)
+	}
+	if c := subject.FaultProxy().Conditions("b", "a"); !c.Partitioned {
+		t.Error("expected b->a to be partitioned")
+	}
+}
+
+func TestSimulationFundsNodesFromMockWallet(t *testing.T) {
+	if _, err := New(Topology{Version: "v0.13.8", Wallet: regtest.KindMock, Nodes: []NodeSpec{{Name: "buyer"}}}, Options{}); err == nil {
+		t.Error("expected the mock wallet backend to be rejected for openbazaard")
+	}
+
+	var runPath = util.GenerateTempPath("simulation_wallet_runs")
+	defer os.RemoveAll(runPath)
+	var gateways = []net.Listener{mustListen(t), mustListen(t)}
+	var configs = []map[string]interface{}{gatewayConfig(gateways[0]), gatewayConfig(gateways[1])}
+	for _, l := range gateways {
+		l.Close()
+	}
+	subject, err := New(Topology{
+		Version: "v0.13.8",
+		Wallet:  regtest.KindMock,
+		Nodes:   []NodeSpec{{Name: "buyer", Config: configs[0]}, {Name: "vendor", Config: configs[1]}},
+	}, Options{Build: FakeBuild, ReadyTimeout: 30 * time.Second, RunPath: runPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer subject.Stop()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := subject.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := subject.Fund(ctx, "buyer", 100000); err != nil {
+		t.Fatal(err)
+	}
+	if err := subject.Mine(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	buyer, _ := subject.Runner("buyer")
+	vendor, _ := subject.Runner("vendor")
+	vendorAddress, err := regtest.NodeAddress(ctx, vendor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spend, err := buyer.APIRequest(ctx, "POST", "/wallet/spend", []byte(`{"wallet":"BTC","address":"`+vendorAddress+`","amount":"40000","feeLevel":"NORMAL"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spend.StatusCode != 200 {
+		t.Fatalf("expected buyer to spend its funds, but was (%d): %s", spend.StatusCode, spend.Body)
+	}
+	for name, expected := range map[string]string{"buyer": `"confirmed":100000,"unconfirmed":-40000`, "vendor": `"confirmed":0,"unconfirmed":40000`} {
+		r, _ := subject.Runner(name)
+		balance, err := r.APIRequest(ctx, "GET", "/wallet/balance/BTC", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(balance.Body), expected) {
+			t.Errorf("expected (%s) balance (%s), but was (%s)", name, expected, balance.Body)
+		}
+	}
+}
+
+func TestSimulationRecordsNodeAPITraffic(t *testing.T) {
+	var runPath = util.GenerateTempPath("simulation_record_runs")
+	defer os.RemoveAll(runPath)
+	var gateway = mustListen(t)
+	var config = gatewayConfig(gateway)
+	gateway.Close()
+	subject, err := New(Topology{
+		Version: "v0.13.8",
+		Nodes:   []NodeSpec{{Name: "vendor", Config: config}},
+	}, Options{Build: FakeBuild, ReadyTimeout: 30 * time.Second, RunPath: runPath, Record: traffic.FormatHAR})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := subject.Start(ctx); err != nil {
+		subject.Stop()
+		t.Fatal(err)
+	}
+
+	vendor, _ := subject.Runner("vendor")
+	if _, err := vendor.APIRequest(ctx, "GET", "/ob/profile", nil); err != nil {
+		subject.Stop()
+		t.Fatal(err)
+	}
+	entries, err := subject.Recording("vendor")
+	if err != nil {
+		subject.Stop()
+		t.Fatal(err)
+	}
+	var recorded bool
+	for _, e := range entries {
+		recorded = recorded || (e.Node == "vendor" && e.Request.URL == "/ob/profile")
+	}
+	if !recorded {
+		t.Errorf("expected the profile request to be recorded, but was (%+v)", entries)
+	}
+	if err := subject.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := traffic.Load(filepath.Join(vendor.RunPath(), "api.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded) != len(entries) {
+		t.Errorf("expected (%d) entries in api.jsonl, but was (%d)", len(entries), len(loaded))
+	}
+	if _, err := os.Stat(filepath.Join(vendor.RunPath(), "api.har")); err != nil {
+		t.Errorf("expected a HAR archive: %s", err)
+	}
+}
+
+func TestSimulationAdvancesNodeClocks(t *testing.T) {
+	var runPath = util.GenerateTempPath("simulation_clock_runs")
+	defer os.RemoveAll(runPath)
+	var gateway = mustListen(t)
+	var config = gatewayConfig(gateway)
+	gateway.Close()
+	subject, err := New(Topology{
+		Version: "v0.13.8",
+		Clock:   faketime.ModePatch,
+		Nodes:   []NodeSpec{{Name: "buyer", Config: config}},
+	}, Options{Build: FakeBuild, ReadyTimeout: 30 * time.Second, RunPath: runPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer subject.Stop()
+	if err := subject.AdvanceTime(time.Hour); err == nil {
+		t.Error("expected advancing time before the nodes are built to error")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := subject.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	buyer, _ := subject.Runner("buyer")
+	var chat = func() time.Time {
+		if _, err := buyer.APIRequest(ctx, "POST", "/ob/chat", []byte(`{"peerId":"QmVendor","message":"hello"}`)); err != nil {
+			t.Fatal(err)
+		}
+		resp, err := buyer.APIRequest(ctx, "GET", "/ob/chatmessages/QmVendor", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var messages []struct {
+			Timestamp time.Time `json:"timestamp"`
+		}
+		if err := json.Unmarshal(resp.Body, &messages); err != nil || len(messages) == 0 {
+			t.Fatalf("unexpected chat messages (%s): %v", resp.Body, err)
+		}
+		return messages[len(messages)-1].Timestamp
+	}
+
+	var before = chat()
+	if err := subject.AdvanceTime(72 * time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	var after = chat()
+	if elapsed := after.Sub(before); elapsed < 72*time.Hour || elapsed > 72*time.Hour+time.Minute {
+		t.Errorf("expected the node's clock to advance by three days, but messages were (%s) apart", elapsed)
+	}
+	if offset := subject.Clock().Offset(); offset != 72*time.Hour {
+		t.Errorf("expected the clock offset to be (72h0m0s), but was (%s)", offset)
+	}
+}
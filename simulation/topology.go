@@ -0,0 +1,254 @@
+package simulation
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"github.com/OpenBazaar/mason/builder/blueprints"
+	"github.com/OpenBazaar/mason/faketime"
+	"github.com/OpenBazaar/mason/netfault"
+	"github.com/OpenBazaar/mason/regtest"
+)
+
+var nodeNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// Topology describes a network of nodes to build and run together. Values
+// set at the top level are defaults for every node.
+type Topology struct {
+	Version string `json:"version,omitempty"`
+	Testnet bool   `json:"testnet,omitempty"`
+	// AutoPeer replaces each node's Bootstrap list with the other nodes
+	// so they form a local mesh without public bootstrap peers
+	AutoPeer bool `json:"autoPeer,omitempty"`
+	// PrivateNetwork isolates the nodes from any other peers with a
+	// shared swarm key, which is generated unless SwarmKey is set
+	PrivateNetwork bool `json:"privateNetwork,omitempty"`
+	// SwarmKey is a hex encoded 32 byte key for the private network
+	SwarmKey string `json:"swarmKey,omitempty"`
+	// FaultProxy routes every connection between auto peered nodes
+	// through a proxy which can slow, lose or partition the traffic of
+	// each link while the simulation runs
+	FaultProxy bool `json:"faultProxy,omitempty"`
+	// FaultProxyHost is the IPv4 address the fault proxy listens on,
+	// netfault.DefaultHost unless set. Nodes must not listen on it.
+	FaultProxyHost string `json:"faultProxyHost,omitempty"`
+	// Links are the conditions applied when the fault proxy starts
+	Links []LinkSpec `json:"links,omitempty"`
+	// Wallet runs a local chain which every node's wallet uses in place
+	// of public infrastructure: bitcoind for a bitcoind regtest process,
+	// or mock for an in process ledger which only the fake daemon
+	// understands
+	Wallet string `json:"wallet,omitempty"`
+	// Clock gives the nodes a clock which Simulation.AdvanceTime moves
+	// forward: patch builds each node with the clock patch, and
+	// libfaketime also preloads libfaketime for C code such as SQLite
+	Clock string     `json:"clock,omitempty"`
+	Nodes []NodeSpec `json:"nodes"`
+}
+
+// LinkSpec sets the conditions of data sent from one node to another.
+// Either node may be "*" to match every node.
+type LinkSpec struct {
+	From       string              `json:"from"`
+	To         string              `json:"to"`
+	Conditions netfault.Conditions `json:"conditions"`
+}
+
+func (t Topology) private() bool {
+	return t.PrivateNetwork || t.SwarmKey != ""
+}
+
+// NodeSpec describes a single node within a Topology
+type NodeSpec struct {
+	// Name identifies the node in logs and run paths and must be unique
+	Name string `json:"name"`
+	// Version is the git reference of openbazaar-go to build
+	Version string `json:"version,omitempty"`
+	// DataPath is the node's data directory. Relative paths are resolved
+	// from the topology file. A fresh directory in the simulation's run
+	// path is used when empty.
+	DataPath string `json:"dataPath,omitempty"`
+	// Roles are free-form tags (ex: buyer, vendor, moderator) used to
+	// find nodes within a simulation
+	Roles []string `json:"roles,omitempty"`
+	// Config maps dot-separated config keys to the values they are set
+	// to before the node starts
+	Config map[string]interface{} `json:"config,omitempty"`
+	// Testnet overrides the topology's testnet default
+	Testnet *bool `json:"testnet,omitempty"`
+	// StartOrder groups nodes which start together. Lower groups start
+	// first and each group is ready before the next is started.
+	StartOrder int `json:"startOrder,omitempty"`
+	// Args are passed to the node on start
+	Args []string `json:"args,omitempty"`
+	// Mnemonic initializes the node with a fixed identity
+	Mnemonic string `json:"mnemonic,omitempty"`
+	// Patches name the blueprint patches applied to the source before
+	// the node is built (ex: clock)
+	Patches []string `json:"patches,omitempty"`
+}
+
+// HasPatch returns true when the node is built with the named patch
+func (n NodeSpec) HasPatch(name string) bool {
+	for _, p := range n.Patches {
+		if p == name {
+			return true
+		}
+	}
+	return false
+}
+
+// HasRole returns true when the node is tagged with role
+func (n NodeSpec) HasRole(role string) bool {
+	for _, r := range n.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadTopology reads a JSON topology file and applies its defaults. The
+// topology is validated when a Simulation is created from it.
+func LoadTopology(path string) (Topology, error) {
+	var t Topology
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return t, fmt.Errorf("reading topology: %s", err.Error())
+	}
+	if err := json.Unmarshal(b, &t); err != nil {
+		return t, fmt.Errorf("parsing topology (%s): %s", path, err.Error())
+	}
+	var base = filepath.Dir(path)
+	for i := range t.Nodes {
+		if p := t.Nodes[i].DataPath; p != "" && !filepath.IsAbs(p) {
+			t.Nodes[i].DataPath = filepath.Join(base, p)
+		}
+	}
+	return t.withDefaults(), nil
+}
+
+// withDefaults returns a copy of the topology where every node has its
+// version and testnet mode set, and the clock patch when the topology
+// has a clock
+func (t Topology) withDefaults() Topology {
+	var nodes = make([]NodeSpec, len(t.Nodes))
+	for i, n := range t.Nodes {
+		if n.Version == "" {
+			n.Version = t.Version
+		}
+		if n.Testnet == nil {
+			var testnet = t.Testnet
+			n.Testnet = &testnet
+		}
+		if t.Clock != "" && !n.HasPatch(blueprints.ClockPatch.Name) {
+			n.Patches = append(append([]string{}, n.Patches...), blueprints.ClockPatch.Name)
+		}
+		nodes[i] = n
+	}
+	t.Nodes = nodes
+	return t
+}
+
+// Validate returns an error if the topology cannot be run
+func (t Topology) Validate() error {
+	if len(t.Nodes) == 0 {
+		return fmt.Errorf("topology has no nodes")
+	}
+	if t.SwarmKey != "" {
+		if b, err := hex.DecodeString(t.SwarmKey); err != nil || len(b) != 32 {
+			return fmt.Errorf("swarm key must be 32 hex encoded bytes")
+		}
+	}
+	var (
+		names     = make(map[string]bool)
+		dataPaths = make(map[string]string)
+	)
+	for _, n := range t.Nodes {
+		if !nodeNamePattern.MatchString(n.Name) {
+			return fmt.Errorf("invalid node name (%s)", n.Name)
+		}
+		if names[n.Name] {
+			return fmt.Errorf("duplicate node name (%s)", n.Name)
+		}
+		names[n.Name] = true
+		if n.Version == "" && t.Version == "" {
+			return fmt.Errorf("node (%s) has no version", n.Name)
+		}
+		if n.DataPath != "" {
+			if other, ok := dataPaths[n.DataPath]; ok {
+				return fmt.Errorf("nodes (%s) and (%s) share data path (%s)", other, n.Name, n.DataPath)
+			}
+			dataPaths[n.DataPath] = n.Name
+		}
+		for _, name := range n.Patches {
+			if _, err := blueprints.LookupPatch(name); err != nil {
+				return fmt.Errorf("node (%s): %s", n.Name, err.Error())
+			}
+		}
+	}
+	if t.Clock != "" && !faketime.ValidMode(t.Clock) {
+		return fmt.Errorf("unknown clock (%s)", t.Clock)
+	}
+	if t.Wallet != "" && !regtest.ValidKind(t.Wallet) {
+		return fmt.Errorf("unknown wallet backend (%s)", t.Wallet)
+	}
+	if t.Wallet == regtest.KindBitcoind {
+		for _, n := range t.Nodes {
+			if (n.Testnet != nil && *n.Testnet) || (n.Testnet == nil && t.Testnet) {
+				return fmt.Errorf("node (%s) cannot use testnet with a regtest wallet", n.Name)
+			}
+		}
+	}
+	if t.FaultProxy && !t.AutoPeer {
+		return fmt.Errorf("fault proxy requires auto peering")
+	}
+	if t.FaultProxyHost != "" {
+		if !t.FaultProxy {
+			return fmt.Errorf("fault proxy host requires the fault proxy")
+		}
+		if ip := net.ParseIP(t.FaultProxyHost); ip == nil || ip.To4() == nil {
+			return fmt.Errorf("fault proxy host (%s) is not an IPv4 address", t.FaultProxyHost)
+		}
+	}
+	if len(t.Links) > 0 && !t.FaultProxy {
+		return fmt.Errorf("links require the fault proxy")
+	}
+	for _, l := range t.Links {
+		for _, name := range []string{l.From, l.To} {
+			if name != netfault.Any && !names[name] {
+				return fmt.Errorf("link from (%s) to (%s) uses unknown node (%s)", l.From, l.To, name)
+			}
+		}
+		if err := l.Conditions.Validate(); err != nil {
+			return fmt.Errorf("link from (%s) to (%s): %s", l.From, l.To, err.Error())
+		}
+	}
+	return nil
+}
+
+// startGroups returns the nodes grouped by start order, lowest first
+func (t Topology) startGroups() [][]NodeSpec {
+	var (
+		byOrder = make(map[int][]NodeSpec)
+		orders  []int
+	)
+	for _, n := range t.Nodes {
+		if _, ok := byOrder[n.StartOrder]; !ok {
+			orders = append(orders, n.StartOrder)
+		}
+		byOrder[n.StartOrder] = append(byOrder[n.StartOrder], n)
+	}
+	sort.Ints(orders)
+	var groups = make([][]NodeSpec, 0, len(orders))
+	for _, o := range orders {
+		groups = append(groups, byOrder[o])
+	}
+	return groups
+}
@@ -0,0 +1,97 @@
+package simulation
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/OpenBazaar/mason/netfault"
+	"github.com/OpenBazaar/mason/util"
+)
+
+func TestLoadTopologyAppliesDefaults(t *testing.T) {
+	var dir = util.GenerateTempPath("topology_load")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var path = filepath.Join(dir, "topology.json")
+	var topology = `{
+		"version": "v0.13.8",
+		"testnet": true,
+		"nodes": [
+			{"name": "vendor1", "roles": ["vendor"], "dataPath": "data/vendor1", "config": {"Addresses.Gateway": "/ip4/127.0.0.1/tcp/4102"}},
+			{"name": "buyer1", "roles": ["buyer"], "version": "v0.13.7", "testnet": false, "startOrder": 1}
+		]
+	}`
+	if err := ioutil.WriteFile(path, []byte(topology), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	subject, err := LoadTopology(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := subject.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	var vendor, buyer = subject.Nodes[0], subject.Nodes[1]
+	if vendor.Version != "v0.13.8" || buyer.Version != "v0.13.7" {
+		t.Errorf("expected default version to apply only when unset, but was (%s, %s)", vendor.Version, buyer.Version)
+	}
+	if !*vendor.Testnet || *buyer.Testnet {
+		t.Errorf("expected default testnet to apply only when unset, but was (%t, %t)", *vendor.Testnet, *buyer.Testnet)
+	}
+	if expected := filepath.Join(dir, "data", "vendor1"); vendor.DataPath != expected {
+		t.Errorf("expected data path relative to topology (%s), but was (%s)", expected, vendor.DataPath)
+	}
+	if !vendor.HasRole("vendor") || vendor.HasRole("buyer") {
+		t.Errorf("expected vendor role only, but was (%v)", vendor.Roles)
+	}
+	if groups := subject.startGroups(); len(groups) != 2 || groups[0][0].Name != "vendor1" || groups[1][0].Name != "buyer1" {
+		t.Errorf("expected vendor to start before buyer, but groups were (%v)", groups)
+	}
+}
+
+func TestTopologyValidate(t *testing.T) {
+	var examples = []Topology{
+		{},
+		{Version: "v1", Nodes: []NodeSpec{{Name: "has space"}}},
+		{Version: "v1", Nodes: []NodeSpec{{Name: "a"}, {Name: "a"}}},
+		{Nodes: []NodeSpec{{Name: "a"}}},
+		{Version: "v1", Nodes: []NodeSpec{{Name: "a", DataPath: "/x"}, {Name: "b", DataPath: "/x"}}},
+		{Version: "v1", FaultProxy: true, Nodes: []NodeSpec{{Name: "a"}}},
+		{Version: "v1", AutoPeer: true, FaultProxyHost: "127.0.0.3", Nodes: []NodeSpec{{Name: "a"}}},
+		{Version: "v1", AutoPeer: true, FaultProxy: true, FaultProxyHost: "localhost", Nodes: []NodeSpec{{Name: "a"}}},
+		{Version: "v1", AutoPeer: true, Links: []LinkSpec{{From: "a", To: "*"}}, Nodes: []NodeSpec{{Name: "a"}}},
+		{Version: "v1", AutoPeer: true, FaultProxy: true, Links: []LinkSpec{{From: "a", To: "z"}}, Nodes: []NodeSpec{{Name: "a"}}},
+		{Version: "v1", AutoPeer: true, FaultProxy: true, Links: []LinkSpec{{From: "a", To: "*", Conditions: netfault.Conditions{Bandwidth: -1}}}, Nodes: []NodeSpec{{Name: "a"}}},
+		{Version: "v1", Wallet: "electrum", Nodes: []NodeSpec{{Name: "a"}}},
+		{Version: "v1", Wallet: "bitcoind", Testnet: true, Nodes: []NodeSpec{{Name: "a"}}},
+		{Version: "v1", Clock: "sundial", Nodes: []NodeSpec{{Name: "a"}}},
+		{Version: "v1", Nodes: []NodeSpec{{Name: "a", Patches: []string{"calendar"}}}},
+	}
+	for i, e := range examples {
+		if err := e.Validate(); err == nil {
+			t.Errorf("example %d: expected invalid topology to error, but did not", i)
+		}
+	}
+}
+
+func TestTopologyClockAddsPatch(t *testing.T) {
+	var subject = Topology{
+		Version: "v1",
+		Clock:   "patch",
+		Nodes:   []NodeSpec{{Name: "a"}, {Name: "b", Patches: []string{"clock"}}},
+	}.withDefaults()
+	if err := subject.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range subject.Nodes {
+		if len(n.Patches) != 1 || !n.HasPatch("clock") {
+			t.Errorf("expected node (%s) to be built with the clock patch once, but was (%v)", n.Name, n.Patches)
+		}
+	}
+}
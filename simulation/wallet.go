@@ -0,0 +1,59 @@
+package simulation
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/OpenBazaar/mason/regtest"
+)
+
+// startWallet starts the topology's wallet backend, if any, before nodes
+// are configured to use it. The mutex must be held.
+func (s *Simulation) startWallet() error {
+	if s.topology.Wallet == "" || s.wallet != nil {
+		return nil
+	}
+	b, err := regtest.New(s.topology.Wallet, filepath.Join(s.opts.RunPath, "wallet"))
+	if err != nil {
+		return err
+	}
+	var ctx, cancel = context.WithTimeout(context.Background(), s.opts.ReadyTimeout)
+	defer cancel()
+	if err := b.Start(ctx); err != nil {
+		return fmt.Errorf("starting wallet backend: %s", err.Error())
+	}
+	s.wallet = b
+	return nil
+}
+
+// Wallet returns the backend the nodes' wallets use, or nil unless the
+// topology sets one and the nodes are built
+func (s *Simulation) Wallet() regtest.Backend {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.wallet
+}
+
+// Fund sends amount satoshis to the wallet of a running node, returning
+// the transaction ID. Mine confirms it.
+func (s *Simulation) Fund(ctx context.Context, node string, amount int64) (string, error) {
+	var b = s.Wallet()
+	if b == nil {
+		return "", fmt.Errorf("wallet backend is not enabled")
+	}
+	r, ok := s.Runner(node)
+	if !ok {
+		return "", fmt.Errorf("unknown node (%s)", node)
+	}
+	return regtest.FundNode(ctx, b, r, amount)
+}
+
+// Mine confirms pending transactions in the given number of blocks
+func (s *Simulation) Mine(ctx context.Context, blocks int) error {
+	var b = s.Wallet()
+	if b == nil {
+		return fmt.Errorf("wallet backend is not enabled")
+	}
+	return b.Mine(ctx, blocks)
+}
@@ -0,0 +1,153 @@
+package traffic
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+)
+
+// HAR 1.2 types, limited to the fields written by WriteHAR
+type (
+	harLog struct {
+		Log harContent `json:"log"`
+	}
+	harContent struct {
+		Version string     `json:"version"`
+		Creator harCreator `json:"creator"`
+		Entries []harEntry `json:"entries"`
+	}
+	harCreator struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+	harEntry struct {
+		Started  string      `json:"startedDateTime"`
+		Time     float64     `json:"time"`
+		Request  harRequest  `json:"request"`
+		Response harResponse `json:"response"`
+		Cache    struct{}    `json:"cache"`
+		Timings  harTimings  `json:"timings"`
+		Comment  string      `json:"comment,omitempty"`
+	}
+	harRequest struct {
+		Method      string         `json:"method"`
+		URL         string         `json:"url"`
+		HTTPVersion string         `json:"httpVersion"`
+		Headers     []harNameValue `json:"headers"`
+		QueryString []harNameValue `json:"queryString"`
+		Cookies     []harNameValue `json:"cookies"`
+		PostData    *harPostData   `json:"postData,omitempty"`
+		HeadersSize int            `json:"headersSize"`
+		BodySize    int            `json:"bodySize"`
+	}
+	harResponse struct {
+		Status      int            `json:"status"`
+		StatusText  string         `json:"statusText"`
+		HTTPVersion string         `json:"httpVersion"`
+		Headers     []harNameValue `json:"headers"`
+		Cookies     []harNameValue `json:"cookies"`
+		Content     harContentBody `json:"content"`
+		RedirectURL string         `json:"redirectURL"`
+		HeadersSize int            `json:"headersSize"`
+		BodySize    int            `json:"bodySize"`
+	}
+	harNameValue struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}
+	harPostData struct {
+		MimeType string `json:"mimeType"`
+		Text     string `json:"text"`
+	}
+	harContentBody struct {
+		Size     int    `json:"size"`
+		MimeType string `json:"mimeType"`
+		Text     string `json:"text,omitempty"`
+	}
+	harTimings struct {
+		Send    float64 `json:"send"`
+		Wait    float64 `json:"wait"`
+		Receive float64 `json:"receive"`
+	}
+)
+
+// WriteHAR writes entries to w as a HAR 1.2 archive for browser and
+// proxy tools. Each URL's host is the node's name, which is also set as
+// the entry's comment.
+func WriteHAR(w io.Writer, entries []Entry) error {
+	var archive = harLog{Log: harContent{
+		Version: "1.2",
+		Creator: harCreator{Name: "mason", Version: "1"},
+		Entries: make([]harEntry, 0, len(entries)),
+	}}
+	for _, e := range entries {
+		var ms = float64(e.Duration) / float64(time.Millisecond)
+		var req = harRequest{
+			Method:      e.Request.Method,
+			URL:         "http://" + e.Node + e.Request.URL,
+			HTTPVersion: "HTTP/1.1",
+			Headers:     harHeaders(e.Request.Header),
+			QueryString: harQuery(e.Request.URL),
+			Cookies:     []harNameValue{},
+			HeadersSize: -1,
+			BodySize:    len(e.Request.Body),
+		}
+		if e.Request.Body != "" {
+			req.PostData = &harPostData{MimeType: mimeType(e.Request.Header), Text: e.Request.Body}
+		}
+		archive.Log.Entries = append(archive.Log.Entries, harEntry{
+			Started: e.Started.Format(time.RFC3339Nano),
+			Time:    ms,
+			Request: req,
+			Response: harResponse{
+				Status:      e.Response.Status,
+				StatusText:  http.StatusText(e.Response.Status),
+				HTTPVersion: "HTTP/1.1",
+				Headers:     harHeaders(e.Response.Header),
+				Cookies:     []harNameValue{},
+				Content:     harContentBody{Size: len(e.Response.Body), MimeType: mimeType(e.Response.Header), Text: e.Response.Body},
+				HeadersSize: -1,
+				BodySize:    len(e.Response.Body),
+			},
+			Timings: harTimings{Wait: ms},
+			Comment: e.Node,
+		})
+	}
+	var encoder = json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(archive)
+}
+
+// harHeaders lists every value in name order
+func harHeaders(h map[string][]string) []harNameValue {
+	var pairs = []harNameValue{}
+	var names = make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range h[name] {
+			pairs = append(pairs, harNameValue{Name: name, Value: value})
+		}
+	}
+	return pairs
+}
+
+func harQuery(rawURL string) []harNameValue {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return []harNameValue{}
+	}
+	return harHeaders(u.Query())
+}
+
+func mimeType(h http.Header) string {
+	if t := h.Get("Content-Type"); t != "" {
+		return t
+	}
+	return "application/json"
+}
@@ -0,0 +1,238 @@
+// Package traffic records the requests made to a node's JSON API gateway
+// and replays them against other nodes. A Recorder is a reverse proxy
+// which writes each exchange as a line of JSON, and Replay sends recorded
+// requests to fresh nodes in the same order and reports where their
+// responses differ.
+package traffic
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/op/go-logging"
+)
+
+var log = logging.MustGetLogger("traffic")
+
+// Recording formats
+const (
+	// FormatJSONLines writes an Entry per line as each exchange completes
+	FormatJSONLines = "jsonl"
+	// FormatHAR also writes a HAR archive once recording stops
+	FormatHAR = "har"
+)
+
+// redactedHeaders are not recorded as they hold credentials
+var redactedHeaders = []string{"Authorization", "Cookie", "Set-Cookie"}
+
+// Entry is a single request to a node's API and its response
+type Entry struct {
+	Node     string        `json:"node"`
+	Started  time.Time     `json:"started"`
+	Duration time.Duration `json:"duration"`
+	Request  Request       `json:"request"`
+	Response Response      `json:"response"`
+}
+
+// Request is a recorded API request. URL is the path and query.
+type Request struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// Response is a recorded API response
+type Response struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// Upgrade returns true when the request opened a websocket, which is
+// recorded once it closes but cannot be replayed
+func (e Entry) Upgrade() bool {
+	return e.Response.Status == http.StatusSwitchingProtocols
+}
+
+// Recorder is a reverse proxy in front of a node's gateway which records
+// every exchange
+type Recorder struct {
+	node     string
+	proxy    *httputil.ReverseProxy
+	listener net.Listener
+	server   *http.Server
+
+	mutex   sync.Mutex
+	out     io.Writer
+	entries []Entry
+	err     error
+	closed  bool
+}
+
+// NewRecorder listens on addr and forwards requests to the node's gateway
+// at target, writing each exchange to out as a line of JSON
+func NewRecorder(node, addr, target string, out io.Writer) (*Recorder, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listening for node (%s): %s", node, err.Error())
+	}
+	var rec = &Recorder{
+		node:     node,
+		proxy:    httputil.NewSingleHostReverseProxy(&url.URL{Scheme: "http", Host: target}),
+		listener: l,
+		out:      out,
+	}
+	rec.server = &http.Server{Handler: rec}
+	go rec.server.Serve(l)
+	log.Debugf("recording %s API traffic on %s", node, l.Addr())
+	return rec, nil
+}
+
+// Addr returns the host:port the recorder listens on
+func (rec *Recorder) Addr() string {
+	return rec.listener.Addr().String()
+}
+
+// Entries returns every exchange recorded so far
+func (rec *Recorder) Entries() []Entry {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+	return append([]Entry{}, rec.entries...)
+}
+
+// Close stops the recorder, returning the first error writing entries.
+// Exchanges which complete afterwards, such as websockets, are dropped.
+func (rec *Recorder) Close() error {
+	var err = rec.server.Close()
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+	rec.closed = true
+	if rec.err != nil {
+		return rec.err
+	}
+	return err
+}
+
+// ServeHTTP forwards the request to the node and records the exchange
+func (rec *Recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var started = time.Now()
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	var entry = Entry{
+		Node:    rec.node,
+		Started: started,
+		Request: Request{Method: r.Method, URL: r.URL.RequestURI(), Header: redact(r.Header), Body: string(body)},
+	}
+
+	var cw = &captureWriter{ResponseWriter: w, status: http.StatusOK}
+	rec.proxy.ServeHTTP(cw, r)
+	entry.Duration = time.Since(started)
+	entry.Response = Response{Status: cw.status, Header: redact(w.Header()), Body: cw.body.String()}
+	rec.add(entry)
+}
+
+func (rec *Recorder) add(e Entry) {
+	b, err := json.Marshal(e)
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+	if rec.closed {
+		return
+	}
+	rec.entries = append(rec.entries, e)
+	if err == nil {
+		_, err = rec.out.Write(append(b, '\n'))
+	}
+	if err != nil && rec.err == nil {
+		rec.err = fmt.Errorf("recording %s %s: %s", e.Request.Method, e.Request.URL, err.Error())
+		log.Errorf("%s: %s", rec.node, rec.err.Error())
+	}
+}
+
+func redact(h http.Header) http.Header {
+	var c = h.Clone()
+	for _, name := range redactedHeaders {
+		if c.Get(name) != "" {
+			c.Set(name, "REDACTED")
+		}
+	}
+	if len(c) == 0 {
+		return nil
+	}
+	return c
+}
+
+// captureWriter keeps a copy of the response. Websocket upgrades reach the
+// underlying writer through Unwrap.
+type captureWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (c *captureWriter) WriteHeader(status int) {
+	if !c.wroteHeader {
+		c.status, c.wroteHeader = status, true
+	}
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *captureWriter) Write(b []byte) (int, error) {
+	c.wroteHeader = true
+	c.body.Write(b)
+	return c.ResponseWriter.Write(b)
+}
+
+func (c *captureWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+// Load reads recorded entries from JSON lines files. Entries from several
+// files, such as one per node, are merged in the order they started.
+func Load(paths ...string) ([]Entry, error) {
+	var entries []Entry
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading recording: %s", err.Error())
+		}
+		var scanner = bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+		for line := 1; scanner.Scan(); line++ {
+			if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+				continue
+			}
+			var e Entry
+			if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+				f.Close()
+				return nil, fmt.Errorf("parsing recording (%s) line %d: %s", path, line, err.Error())
+			}
+			entries = append(entries, e)
+		}
+		f.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("reading recording (%s): %s", path, err.Error())
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Started.Before(entries[j].Started)
+	})
+	return entries, nil
+}
@@ -0,0 +1,156 @@
+package traffic
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecorderCapturesExchanges(t *testing.T) {
+	var node = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "OpenBazaar_Auth_Cookie=secret")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"path":%q,"received":%q}`, r.URL.Path, body)
+	}))
+	defer node.Close()
+
+	var out bytes.Buffer
+	rec, err := NewRecorder("vendor", "127.0.0.1:0", node.Listener.Addr().String(), &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest("POST", "http://"+rec.Addr()+"/ob/listing?draft=true", strings.NewReader(`{"slug":"tea"}`))
+	req.SetBasicAuth("user", "password")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	respBody, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || !strings.Contains(string(respBody), `"path":"/ob/listing"`) {
+		t.Fatalf("expected the node's response through the recorder, but was (%d %s)", resp.StatusCode, respBody)
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var entries = rec.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("expected one entry, but was (%+v)", entries)
+	}
+	var e = entries[0]
+	if e.Node != "vendor" || e.Request.Method != "POST" || e.Request.URL != "/ob/listing?draft=true" || e.Request.Body != `{"slug":"tea"}` {
+		t.Errorf("unexpected request (%+v)", e.Request)
+	}
+	if e.Response.Status != http.StatusCreated || e.Response.Body != string(respBody) {
+		t.Errorf("unexpected response (%+v)", e.Response)
+	}
+	if got := e.Request.Header.Get("Authorization"); got != "REDACTED" {
+		t.Errorf("expected authorization to be redacted, but was (%s)", got)
+	}
+	if got := e.Response.Header.Get("Set-Cookie"); got != "REDACTED" {
+		t.Errorf("expected cookie to be redacted, but was (%s)", got)
+	}
+	if strings.Contains(out.String(), "secret") || strings.Contains(out.String(), "cGFzc3dvcmQ") {
+		t.Errorf("expected credentials to be left out of the recording, but was (%s)", out.String())
+	}
+
+	var written Entry
+	if err := json.Unmarshal(bytes.TrimSpace(out.Bytes()), &written); err != nil {
+		t.Fatalf("expected a line of JSON, but was (%s): %s", out.String(), err)
+	}
+	if written.Request.URL != e.Request.URL || written.Response.Status != e.Response.Status {
+		t.Errorf("expected the written entry to match (%+v), but was (%+v)", e, written)
+	}
+}
+
+func TestLoadMergesRecordingsInOrder(t *testing.T) {
+	var dir, err = ioutil.TempDir("", "mason_traffic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var start = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	var write = func(name string, entries ...Entry) string {
+		var buf bytes.Buffer
+		for _, e := range entries {
+			b, _ := json.Marshal(e)
+			buf.Write(append(b, '\n'))
+		}
+		var path = filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	var vendor = write("vendor.jsonl",
+		Entry{Node: "vendor", Started: start, Request: Request{URL: "/ob/listing"}},
+		Entry{Node: "vendor", Started: start.Add(2 * time.Second), Request: Request{URL: "/ob/orders"}},
+	)
+	var buyer = write("buyer.jsonl",
+		Entry{Node: "buyer", Started: start.Add(time.Second), Request: Request{URL: "/ob/purchase"}},
+	)
+
+	entries, err := Load(vendor, buyer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var urls []string
+	for _, e := range entries {
+		urls = append(urls, e.Node+e.Request.URL)
+	}
+	if got := strings.Join(urls, " "); got != "vendor/ob/listing buyer/ob/purchase vendor/ob/orders" {
+		t.Errorf("expected entries in the order they started, but was (%s)", got)
+	}
+
+	var broken = filepath.Join(dir, "broken.jsonl")
+	ioutil.WriteFile(broken, []byte("{\n"), 0644)
+	if _, err := Load(broken); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("expected a parse error naming the line, but was (%v)", err)
+	}
+}
+
+func TestWriteHAR(t *testing.T) {
+	var entries = []Entry{{
+		Node:     "vendor",
+		Started:  time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		Duration: 1500 * time.Microsecond,
+		Request:  Request{Method: "POST", URL: "/ob/listing?draft=true", Body: `{"slug":"tea"}`},
+		Response: Response{Status: 200, Header: http.Header{"Content-Type": {"application/json"}}, Body: `{"slug":"tea"}`},
+	}}
+	var buf bytes.Buffer
+	if err := WriteHAR(&buf, entries); err != nil {
+		t.Fatal(err)
+	}
+	var archive harLog
+	if err := json.Unmarshal(buf.Bytes(), &archive); err != nil {
+		t.Fatal(err)
+	}
+	if archive.Log.Version != "1.2" || len(archive.Log.Entries) != 1 {
+		t.Fatalf("unexpected archive (%s)", buf.String())
+	}
+	var e = archive.Log.Entries[0]
+	if e.Request.URL != "http://vendor/ob/listing?draft=true" || e.Time != 1.5 || e.Comment != "vendor" {
+		t.Errorf("unexpected entry (%+v)", e)
+	}
+	if len(e.Request.QueryString) != 1 || e.Request.QueryString[0] != (harNameValue{Name: "draft", Value: "true"}) {
+		t.Errorf("unexpected query string (%+v)", e.Request.QueryString)
+	}
+	if e.Request.PostData == nil || e.Request.PostData.Text != `{"slug":"tea"}` {
+		t.Errorf("unexpected post data (%+v)", e.Request.PostData)
+	}
+	if e.Response.StatusText != "OK" || e.Response.Content.Text != `{"slug":"tea"}` {
+		t.Errorf("unexpected response (%+v)", e.Response)
+	}
+}
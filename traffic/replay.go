@@ -0,0 +1,180 @@
+package traffic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/OpenBazaar/mason/jsondiff"
+	"github.com/OpenBazaar/mason/scenario"
+)
+
+// maxDifferences limits the differences listed for a single response
+const maxDifferences = 10
+
+// Options controls how recorded requests are replayed
+type Options struct {
+	// Ignore lists dot-separated paths within JSON responses which are
+	// not compared, such as timestamps. A * segment matches any key or
+	// array index (ex: messages.*.timestamp).
+	Ignore []string
+}
+
+// Report describes how the responses of a replay differed from the
+// recording
+type Report struct {
+	Passed   bool          `json:"passed"`
+	Started  time.Time     `json:"started"`
+	Duration time.Duration `json:"duration"`
+	Results  []Result      `json:"results"`
+}
+
+// Result compares the replayed response to a single recorded request
+type Result struct {
+	Node           string   `json:"node"`
+	Method         string   `json:"method"`
+	URL            string   `json:"url"`
+	RecordedStatus int      `json:"recordedStatus"`
+	Status         int      `json:"status,omitempty"`
+	Differences    []string `json:"differences,omitempty"`
+	Error          string   `json:"error,omitempty"`
+	// Skipped requests opened websockets, which are not replayed
+	Skipped bool `json:"skipped,omitempty"`
+}
+
+// Matched returns true when the replayed response matched the recording
+func (r Result) Matched() bool {
+	return r.Skipped || (r.Error == "" && len(r.Differences) == 0)
+}
+
+// Differed returns the number of requests whose responses did not match
+func (r Report) Differed() int {
+	var differed int
+	for _, result := range r.Results {
+		if !result.Matched() {
+			differed++
+		}
+	}
+	return differed
+}
+
+// Replay sends every recorded request, in order, to the node of the same
+// name and compares each response with the one recorded. Every request is
+// sent even after responses differ. Nodes should start from the same
+// state as those recorded, such as the same mnemonic and fixture, so IDs
+// in later requests still resolve.
+func Replay(ctx context.Context, entries []Entry, nodes scenario.Nodes, opts Options) Report {
+	var report = Report{Started: time.Now(), Passed: true}
+	for _, e := range entries {
+		var result = Result{Node: e.Node, Method: e.Request.Method, URL: e.Request.URL, RecordedStatus: e.Response.Status}
+		if e.Upgrade() {
+			result.Skipped = true
+			report.Results = append(report.Results, result)
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			result.Error = err.Error()
+		} else if r, ok := nodes.Runner(e.Node); !ok {
+			result.Error = fmt.Sprintf("unknown node (%s)", e.Node)
+		} else {
+			var body []byte
+			if e.Request.Body != "" {
+				body = []byte(e.Request.Body)
+			}
+			resp, err := r.APIRequest(ctx, e.Request.Method, e.Request.URL, body)
+			if err != nil {
+				result.Error = err.Error()
+			} else {
+				result.Status = resp.StatusCode
+				result.Differences = compare(e.Response, resp.StatusCode, resp.Body, opts.Ignore)
+			}
+		}
+		if !result.Matched() {
+			report.Passed = false
+			log.Warningf("%s %s %s differs from the recording", e.Node, e.Request.Method, e.Request.URL)
+		}
+		report.Results = append(report.Results, result)
+	}
+	report.Duration = time.Since(report.Started)
+	return report
+}
+
+// compare lists the differences between a recorded response and a
+// replayed one
+func compare(recorded Response, status int, body []byte, ignore []string) []string {
+	var diffs []string
+	if status != recorded.Status {
+		diffs = append(diffs, fmt.Sprintf("status: recorded %d, replayed %d", recorded.Status, status))
+	}
+	var want, got interface{}
+	if decodeJSON([]byte(recorded.Body), &want) != nil || decodeJSON(body, &got) != nil {
+		if strings.TrimSpace(recorded.Body) != strings.TrimSpace(string(body)) {
+			diffs = append(diffs, "body: recorded and replayed text differ")
+		}
+		return diffs
+	}
+	for _, d := range jsondiff.Compare(want, got, ignore) {
+		var path = d.Path
+		if path == "" {
+			path = "body"
+		}
+		diffs = append(diffs, fmt.Sprintf("%s: recorded %s, replayed %s", path, encode(d.Before), encode(d.After)))
+	}
+	if len(diffs) > maxDifferences {
+		diffs = append(diffs[:maxDifferences], fmt.Sprintf("and %d more", len(diffs)-maxDifferences))
+	}
+	return diffs
+}
+
+func decodeJSON(b []byte, v *interface{}) error {
+	var decoder = json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+func encode(v interface{}) string {
+	if v == nil {
+		return "nothing"
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// WriteText writes a human-readable summary of the report to w
+func (r Report) WriteText(w io.Writer) error {
+	var result = "PASS"
+	if !r.Passed {
+		result = "FAIL"
+	}
+	if _, err := fmt.Fprintf(w, "%s replay of %d requests, %d differed (%s)\n", result, len(r.Results), r.Differed(), r.Duration.Round(time.Millisecond)); err != nil {
+		return err
+	}
+	for _, res := range r.Results {
+		var line string
+		switch {
+		case res.Skipped:
+			line = fmt.Sprintf("  skip %s %s %s\n", res.Node, res.Method, res.URL)
+		case res.Error != "":
+			line = fmt.Sprintf("  fail %s %s %s\n       %s\n", res.Node, res.Method, res.URL, res.Error)
+		case len(res.Differences) > 0:
+			line = fmt.Sprintf("  diff %s %s %s\n       %s\n", res.Node, res.Method, res.URL, strings.Join(res.Differences, "\n       "))
+		default:
+			line = fmt.Sprintf("  ok   %s %s %s\n", res.Node, res.Method, res.URL)
+		}
+		if _, err := io.WriteString(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteJSON encodes the report to w as JSON
+func (r Report) WriteJSON(w io.Writer) error {
+	var encoder = json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
@@ -0,0 +1,141 @@
+package traffic
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/OpenBazaar/mason/builder/runner"
+	"github.com/OpenBazaar/mason/scenario"
+	"github.com/OpenBazaar/mason/util"
+)
+
+func mustCreateNode(t *testing.T, label string, handler http.Handler) (*runner.OpenBazaarRunner, func()) {
+	var server = httptest.NewServer(handler)
+	var dataPath = util.GenerateTempPath(label)
+	if err := os.MkdirAll(dataPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	host, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var config = fmt.Sprintf(`{"Addresses":{"Gateway":"/ip4/%s/tcp/%s"},"JSON-API":{"SSL":false}}`, host, port)
+	if err := ioutil.WriteFile(filepath.Join(dataPath, "config"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var r = &runner.OpenBazaarRunner{}
+	r.SetCustomDataPath(dataPath)
+	return r, func() {
+		server.Close()
+		os.RemoveAll(dataPath)
+	}
+}
+
+func TestCompareIgnoresPaths(t *testing.T) {
+	var recorded = Response{Status: 200, Body: `{"slug":"tea","messages":[{"text":"hi","timestamp":"2020-01-01"}],"price":{"amount":100}}`}
+	var examples = []struct {
+		name   string
+		status int
+		body   string
+		ignore []string
+		diffs  []string
+	}{
+		{
+			name:   "matched",
+			status: 200,
+			body:   `{"price":{"amount":100},"slug":"tea","messages":[{"timestamp":"2020-01-01","text":"hi"}]}`,
+		},
+		{
+			name:   "ignored timestamp",
+			status: 200,
+			body:   `{"slug":"tea","messages":[{"text":"hi","timestamp":"2021-06-01"}],"price":{"amount":100}}`,
+			ignore: []string{"messages.*.timestamp"},
+		},
+		{
+			name:   "changed values",
+			status: 200,
+			body:   `{"slug":"tea","messages":[{"text":"hi","timestamp":"2021-06-01"}],"price":{"amount":100.5}}`,
+			diffs: []string{
+				`messages.0.timestamp: recorded "2020-01-01", replayed "2021-06-01"`,
+				`price.amount: recorded 100, replayed 100.5`,
+			},
+		},
+		{
+			name:   "missing key and extra element",
+			status: 500,
+			body:   `{"messages":[{"text":"hi","timestamp":"2020-01-01"},{}],"price":{"amount":100}}`,
+			diffs: []string{
+				"status: recorded 200, replayed 500",
+				"messages.1: recorded nothing, replayed {}",
+				`slug: recorded "tea", replayed nothing`,
+			},
+		},
+		{
+			name:   "text body",
+			status: 200,
+			body:   "not found",
+			diffs:  []string{"body: recorded and replayed text differ"},
+		},
+	}
+	for _, ex := range examples {
+		if diffs := compare(recorded, ex.status, []byte(ex.body), ex.ignore); !reflect.DeepEqual(diffs, ex.diffs) {
+			t.Errorf("%s: expected differences (%q), but was (%q)", ex.name, ex.diffs, diffs)
+		}
+	}
+}
+
+func TestReplayReportsDifferences(t *testing.T) {
+	var requests []string
+	node, cleanup := mustCreateNode(t, "traffic_replay", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests = append(requests, r.Method+" "+r.URL.RequestURI()+" "+string(body))
+		switch r.URL.Path {
+		case "/ob/profile":
+			fmt.Fprint(w, `{"name":"vendor","lastModified":"now"}`)
+		case "/ob/listing":
+			fmt.Fprint(w, `{"slug":"coffee"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer cleanup()
+
+	var entries = []Entry{
+		{Node: "vendor", Request: Request{Method: "GET", URL: "/ob/profile"}, Response: Response{Status: 200, Body: `{"name":"vendor","lastModified":"then"}`}},
+		{Node: "vendor", Request: Request{Method: "POST", URL: "/ob/listing?draft=true", Body: `{"slug":"tea"}`}, Response: Response{Status: 200, Body: `{"slug":"tea"}`}},
+		{Node: "vendor", Request: Request{Method: "GET", URL: "/ws"}, Response: Response{Status: http.StatusSwitchingProtocols}},
+		{Node: "buyer", Request: Request{Method: "GET", URL: "/ob/profile"}, Response: Response{Status: 200}},
+	}
+	var report = Replay(context.Background(), entries, scenario.NodeMap{"vendor": node}, Options{Ignore: []string{"lastModified"}})
+
+	if report.Passed || report.Differed() != 2 || len(report.Results) != 4 {
+		var buf bytes.Buffer
+		report.WriteText(&buf)
+		t.Fatalf("expected two of four requests to differ, but was:\n%s", buf.String())
+	}
+	if !report.Results[0].Matched() {
+		t.Errorf("expected the profile to match once lastModified was ignored, but was (%+v)", report.Results[0])
+	}
+	if r := report.Results[1]; !reflect.DeepEqual(r.Differences, []string{`slug: recorded "tea", replayed "coffee"`}) {
+		t.Errorf("unexpected listing differences (%+v)", r)
+	}
+	if r := report.Results[2]; !r.Skipped || !r.Matched() {
+		t.Errorf("expected the websocket to be skipped, but was (%+v)", r)
+	}
+	if r := report.Results[3]; r.Error != "unknown node (buyer)" {
+		t.Errorf("expected an unknown node error, but was (%+v)", r)
+	}
+	var want = []string{"GET /ob/profile ", `POST /ob/listing?draft=true {"slug":"tea"}`}
+	if !reflect.DeepEqual(requests, want) {
+		t.Errorf("expected the recorded requests (%q), but was (%q)", want, requests)
+	}
+}
@@ -0,0 +1,118 @@
+package upgrade
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/OpenBazaar/mason/jsondiff"
+)
+
+// Kinds of Difference
+const (
+	ChangeStatus  = "status"
+	ChangeAdded   = jsondiff.Added
+	ChangeRemoved = jsondiff.Removed
+	ChangeValue   = jsondiff.Changed
+)
+
+// Difference describes how a value within a response changed across the
+// upgrade
+type Difference struct {
+	// Path is the dot-separated location of the value, with array
+	// indices as path segments. It is empty for the whole response.
+	Path   string      `json:"path"`
+	Change string      `json:"change"`
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+}
+
+func (d Difference) String() string {
+	var path = d.Path
+	if path == "" {
+		path = "(response)"
+	}
+	switch d.Change {
+	case ChangeAdded:
+		return fmt.Sprintf("%s added: %s", path, brief(d.After))
+	case ChangeRemoved:
+		return fmt.Sprintf("%s removed: %s", path, brief(d.Before))
+	}
+	return fmt.Sprintf("%s %s: %s -> %s", path, d.Change, brief(d.Before), brief(d.After))
+}
+
+// EndpointResult compares the responses to a single endpoint
+type EndpointResult struct {
+	Path         string `json:"path"`
+	StatusBefore int    `json:"statusBefore"`
+	StatusAfter  int    `json:"statusAfter"`
+	// ErrorBefore and ErrorAfter are set when the request could not be
+	// made, in which case the status is 0
+	ErrorBefore string       `json:"errorBefore,omitempty"`
+	ErrorAfter  string       `json:"errorAfter,omitempty"`
+	Differences []Difference `json:"differences,omitempty"`
+}
+
+// response is a captured API response. err is set when the request
+// could not be made.
+type response struct {
+	status int
+	body   []byte
+	err    string
+}
+
+// value decodes the body as JSON, or returns it as a string if it is not
+// JSON
+func (r response) value() interface{} {
+	if r.err != "" {
+		return "request failed: " + r.err
+	}
+	var v interface{}
+	if err := json.Unmarshal(r.body, &v); err != nil {
+		return strings.TrimSpace(string(r.body))
+	}
+	return v
+}
+
+func compareAll(endpoints []string, before, after map[string]response, ignore []string) []EndpointResult {
+	var results = make([]EndpointResult, 0, len(endpoints))
+	for _, path := range endpoints {
+		var b, a = before[path], after[path]
+		var result = EndpointResult{
+			Path:         path,
+			StatusBefore: b.status,
+			StatusAfter:  a.status,
+			ErrorBefore:  b.err,
+			ErrorAfter:   a.err,
+		}
+		if b.status != a.status {
+			result.Differences = []Difference{{Change: ChangeStatus, Before: b.status, After: a.status}}
+		} else {
+			result.Differences = compare(b.value(), a.value(), ignore)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// compare returns the differences between two decoded JSON documents,
+// skipping the ignored paths
+func compare(before, after interface{}, ignore []string) []Difference {
+	var diffs []Difference
+	for _, d := range jsondiff.Compare(before, after, ignore) {
+		diffs = append(diffs, Difference{Path: d.Path, Change: d.Change, Before: d.Before, After: d.After})
+	}
+	return diffs
+}
+
+// brief renders a value for a single line of text
+func brief(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	if len(b) > 80 {
+		return string(b[:77]) + "..."
+	}
+	return string(b)
+}
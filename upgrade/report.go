@@ -0,0 +1,87 @@
+package upgrade
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/OpenBazaar/mason/scenario"
+)
+
+// Report describes the outcome of an upgrade test
+type Report struct {
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Passed  bool   `json:"passed"`
+	RunPath string `json:"runPath"`
+	// SnapshotPath holds the data directory as the old version left it
+	SnapshotPath string `json:"snapshotPath"`
+	// RepoVersionBefore and RepoVersionAfter are read from the repover
+	// file of each data directory, when present
+	RepoVersionBefore string `json:"repoVersionBefore,omitempty"`
+	RepoVersionAfter  string `json:"repoVersionAfter,omitempty"`
+	// Populate is the report of the populate scenario, if one was run
+	Populate *scenario.Report `json:"populate,omitempty"`
+	// MigrationErrors are the errors logged by the new version, and its
+	// failure to become ready
+	MigrationErrors []string         `json:"migrationErrors,omitempty"`
+	Endpoints       []EndpointResult `json:"endpoints"`
+	Started         time.Time        `json:"started"`
+	Duration        time.Duration    `json:"duration"`
+}
+
+// Differences returns the number of differences across all endpoints
+func (r Report) Differences() int {
+	var n int
+	for _, e := range r.Endpoints {
+		n += len(e.Differences)
+	}
+	return n
+}
+
+// WriteText writes a human-readable summary of the report to w
+func (r Report) WriteText(w io.Writer) error {
+	var result = "PASS"
+	if !r.Passed {
+		result = "FAIL"
+	}
+	var lines = []string{fmt.Sprintf("%s upgrade %s -> %s (%s)", result, r.From, r.To, r.Duration.Round(time.Millisecond))}
+	if r.RepoVersionBefore != "" || r.RepoVersionAfter != "" {
+		lines = append(lines, fmt.Sprintf("  repo version %s -> %s", orNone(r.RepoVersionBefore), orNone(r.RepoVersionAfter)))
+	}
+	for _, e := range r.MigrationErrors {
+		lines = append(lines, "  error "+e)
+	}
+	for _, e := range r.Endpoints {
+		if len(e.Differences) == 0 {
+			lines = append(lines, fmt.Sprintf("  same %s (%d)", e.Path, e.StatusAfter))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("  diff %s (%d -> %d)", e.Path, e.StatusBefore, e.StatusAfter))
+		for _, d := range e.Differences {
+			lines = append(lines, "       "+d.String())
+		}
+	}
+	lines = append(lines, "  snapshot "+r.SnapshotPath)
+	for _, l := range lines {
+		if _, err := io.WriteString(w, l+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteJSON encodes the report to w as JSON
+func (r Report) WriteJSON(w io.Writer) error {
+	var encoder = json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
@@ -0,0 +1,291 @@
+// Package upgrade checks that a node's data directory survives an upgrade
+// between openbazaard versions. A node is prepared and populated on the
+// old version and snapshotted, then the new version is started on a copy
+// of the snapshot. The JSON API responses of both versions are compared
+// and any errors logged by the new version are reported.
+package upgrade
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/OpenBazaar/mason/builder"
+	"github.com/OpenBazaar/mason/builder/runner"
+	"github.com/OpenBazaar/mason/scenario"
+	"github.com/OpenBazaar/mason/simulation"
+	"github.com/OpenBazaar/mason/util"
+	"github.com/op/go-logging"
+	"github.com/otiai10/copy"
+)
+
+const (
+	defaultReadyTimeout = 2 * time.Minute
+
+	// PopulateNode is the name of the node in the populate scenario
+	PopulateNode = "node"
+)
+
+var log = logging.MustGetLogger("upgrade")
+
+// DefaultEndpoints are the JSON API paths compared when Options does not
+// specify any
+var DefaultEndpoints = []string{
+	"/ob/profile",
+	"/ob/settings",
+	"/ob/listings",
+	"/ob/purchases",
+	"/ob/sales",
+	"/ob/cases",
+	"/ob/following",
+	"/ob/followers",
+}
+
+// Options controls how an upgrade is tested
+type Options struct {
+	// From and To are the versions upgraded from and to
+	From string
+	To   string
+	// Build produces the runner for each version. Versions are built from
+	// source or the cache by default.
+	Build simulation.BuildFunc
+	// SeedPath is a data directory copied as the node's starting state.
+	// A new node is initialized on the old version when empty.
+	SeedPath string
+	// Init controls how a new node is initialized when SeedPath is empty
+	Init runner.InitOptions
+	// Config maps dot-separated config keys to values set before the old
+	// version starts
+	Config map[string]interface{}
+	// Testnet starts both versions in testnet mode
+	Testnet bool
+	// Populate is run against the old version, as PopulateNode, before
+	// responses are captured
+	Populate *scenario.Scenario
+	// Endpoints are the JSON API paths requested from both versions.
+	// DefaultEndpoints are used when empty.
+	Endpoints []string
+	// Ignore lists dot-separated paths within responses which are
+	// expected to change and are not compared. A * segment matches any
+	// key or array index (ex: stats.lastSeen, listings.*.stats).
+	Ignore []string
+	// ReadyTimeout limits how long each version has to become ready.
+	// Defaults to 2 minutes.
+	ReadyTimeout time.Duration
+	// StopGrace is how long each version may take to exit when
+	// interrupted before it is killed. Nodes are killed immediately by
+	// default, which may lose writes the old version has not flushed.
+	StopGrace time.Duration
+	// RunPath holds the output, data directories and snapshot. One is
+	// generated when empty.
+	RunPath string
+}
+
+// Run tests the upgrade described by opts. An error is returned when the
+// test could not be carried out, such as when a version fails to build or
+// the old version does not start. Problems caused by the upgrade are
+// recorded in the report.
+func Run(ctx context.Context, opts Options) (Report, error) {
+	if opts.From == "" || opts.To == "" {
+		return Report{}, fmt.Errorf("both versions are required")
+	}
+	if opts.Build == nil {
+		opts.Build = buildVersion
+	}
+	if len(opts.Endpoints) == 0 {
+		opts.Endpoints = DefaultEndpoints
+	}
+	if opts.ReadyTimeout <= 0 {
+		opts.ReadyTimeout = defaultReadyTimeout
+	}
+	if opts.RunPath == "" {
+		opts.RunPath = util.GenerateRunPath("upgrade")
+	}
+	var report = Report{
+		From:         opts.From,
+		To:           opts.To,
+		RunPath:      opts.RunPath,
+		SnapshotPath: filepath.Join(opts.RunPath, "snapshot"),
+		Started:      time.Now(),
+	}
+
+	from, err := prepare(opts, "from", opts.From)
+	if err != nil {
+		return report, err
+	}
+	defer from.Cleanup()
+	to, err := prepare(opts, "to", opts.To)
+	if err != nil {
+		return report, err
+	}
+	defer to.Cleanup()
+
+	var fromData = filepath.Join(opts.RunPath, "from", "data")
+	if opts.SeedPath != "" {
+		if err := copy.Copy(opts.SeedPath, fromData); err != nil {
+			return report, fmt.Errorf("copying seed: %s", err.Error())
+		}
+	}
+	from.SetCustomDataPath(fromData)
+	if opts.SeedPath == "" {
+		if err := from.SetInitOptions(opts.Init); err != nil {
+			return report, fmt.Errorf("setting init options: %s", err.Error())
+		}
+		if err := from.Init(); err != nil {
+			return report, err
+		}
+	}
+	if err := applyConfig(from, opts.Config); err != nil {
+		return report, err
+	}
+
+	log.Infof("starting %s", opts.From)
+	if err := startAndWait(ctx, from, opts.ReadyTimeout); err != nil {
+		from.Kill()
+		return report, fmt.Errorf("starting %s: %s", opts.From, err.Error())
+	}
+	if opts.Populate != nil {
+		var populated = scenario.Run(ctx, *opts.Populate, scenario.NodeMap{PopulateNode: from})
+		report.Populate = &populated
+		if !populated.Passed {
+			from.Kill()
+			return report, fmt.Errorf("populating %s: scenario (%s) failed", opts.From, populated.Scenario)
+		}
+	}
+	var before = capture(ctx, from, opts.Endpoints)
+	if err := from.Stop(opts.StopGrace); err != nil {
+		log.Warningf("stopping %s: %s", opts.From, err.Error())
+	}
+	report.RepoVersionBefore = repoVersion(fromData)
+
+	if err := copy.Copy(fromData, report.SnapshotPath); err != nil {
+		return report, fmt.Errorf("snapshotting data: %s", err.Error())
+	}
+	var toData = filepath.Join(opts.RunPath, "to", "data")
+	if err := copy.Copy(report.SnapshotPath, toData); err != nil {
+		return report, fmt.Errorf("copying snapshot: %s", err.Error())
+	}
+	to.SetCustomDataPath(toData)
+
+	log.Infof("upgrading to %s", opts.To)
+	var (
+		errs     = collectErrors(to)
+		problems []string
+	)
+	if err := startAndWait(ctx, to, opts.ReadyTimeout); err != nil {
+		problems = append(problems, fmt.Sprintf("%s did not become ready: %s", opts.To, err.Error()))
+	} else {
+		var after = capture(ctx, to, opts.Endpoints)
+		report.Endpoints = compareAll(opts.Endpoints, before, after, opts.Ignore)
+	}
+	if err := to.Stop(opts.StopGrace); err != nil {
+		log.Warningf("stopping %s: %s", opts.To, err.Error())
+	}
+	report.RepoVersionAfter = repoVersion(toData)
+	report.MigrationErrors = errs.stop(problems...)
+
+	report.Passed = len(report.MigrationErrors) == 0
+	for _, e := range report.Endpoints {
+		if len(e.Differences) > 0 {
+			report.Passed = false
+		}
+	}
+	report.Duration = time.Since(report.Started)
+	return report, nil
+}
+
+func buildVersion(spec simulation.NodeSpec) (*runner.OpenBazaarRunner, error) {
+	var b = builder.NewOpenBazaarDaemon(spec.Name, spec.Version)
+	defer b.MustClean()
+	return b.Build()
+}
+
+// prepare builds the version and directs its output into the run path
+func prepare(opts Options, name, version string) (*runner.OpenBazaarRunner, error) {
+	log.Infof("building %s", version)
+	r, err := opts.Build(simulation.NodeSpec{Name: name, Version: version})
+	if err != nil {
+		return nil, fmt.Errorf("building %s: %s", version, err.Error())
+	}
+	r.SetRunPath(filepath.Join(opts.RunPath, name))
+	r.SetTestnetMode(opts.Testnet)
+	return r, nil
+}
+
+func applyConfig(r *runner.OpenBazaarRunner, config map[string]interface{}) error {
+	var keys = make([]string, 0, len(config))
+	for k := range config {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := r.SetConfigValue(k, config[k]); err != nil {
+			return fmt.Errorf("setting config (%s): %s", k, err.Error())
+		}
+	}
+	return nil
+}
+
+func startAndWait(ctx context.Context, r *runner.OpenBazaarRunner, timeout time.Duration) error {
+	if err := r.AsyncStart(); err != nil {
+		return err
+	}
+	var readyCtx, cancel = context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return r.WaitForReady(readyCtx)
+}
+
+// capture requests each endpoint from the node
+func capture(ctx context.Context, r *runner.OpenBazaarRunner, endpoints []string) map[string]response {
+	var responses = make(map[string]response, len(endpoints))
+	for _, path := range endpoints {
+		resp, err := r.APIRequest(ctx, "GET", path, nil)
+		if err != nil {
+			responses[path] = response{err: err.Error()}
+			continue
+		}
+		responses[path] = response{status: resp.StatusCode, body: resp.Body}
+	}
+	return responses
+}
+
+// repoVersion returns the repo version recorded by openbazaard's
+// migrations, or an empty string if there is none
+func repoVersion(dataPath string) string {
+	b, err := ioutil.ReadFile(filepath.Join(dataPath, "repover"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// errorLog collects the errors logged by a node
+type errorLog struct {
+	sub    *runner.OutputSubscription
+	errors []string
+	done   chan struct{}
+}
+
+func collectErrors(r *runner.OpenBazaarRunner) *errorLog {
+	var l = &errorLog{sub: r.Subscribe(0), done: make(chan struct{})}
+	var records = runner.ParseLogs(l.sub.Lines(), runner.NewLogFilter(logging.ERROR))
+	go func() {
+		defer close(l.done)
+		for record := range records {
+			l.errors = append(l.errors, record.Message)
+		}
+	}()
+	return l
+}
+
+// stop ends collection and returns the errors logged along with any
+// extra problems
+func (l *errorLog) stop(extra ...string) []string {
+	l.sub.Close()
+	<-l.done
+	return append(l.errors, extra...)
+}
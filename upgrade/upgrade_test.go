@@ -0,0 +1,143 @@
+package upgrade
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/OpenBazaar/mason/builder/runner"
+	"github.com/OpenBazaar/mason/scenario"
+	"github.com/OpenBazaar/mason/simulation"
+	"github.com/OpenBazaar/mason/util"
+)
+
+func TestCompare(t *testing.T) {
+	var before, after interface{}
+	json.Unmarshal([]byte(`{"name":"shop","stats":{"lastSeen":1,"ratings":2},"tags":["a","b"],"old":true}`), &before)
+	json.Unmarshal([]byte(`{"name":"shop!","stats":{"lastSeen":5,"ratings":2},"tags":["a"],"new":1}`), &after)
+
+	var diffs = compare(before, after, []string{"stats.lastSeen"})
+	var expected = []Difference{
+		{Path: "name", Change: ChangeValue, Before: "shop", After: "shop!"},
+		{Path: "new", Change: ChangeAdded, After: 1.0},
+		{Path: "old", Change: ChangeRemoved, Before: true},
+		{Path: "tags.1", Change: ChangeRemoved, Before: "b"},
+	}
+	if !reflect.DeepEqual(diffs, expected) {
+		t.Errorf("expected differences (%v), but was (%v)", expected, diffs)
+	}
+}
+
+func TestCompareAllReportsStatusChanges(t *testing.T) {
+	var results = compareAll(
+		[]string{"/ob/profile", "/ob/listings"},
+		map[string]response{
+			"/ob/profile":  {status: 200, body: []byte(`{"name":"shop"}`)},
+			"/ob/listings": {status: 200, body: []byte(`[]`)},
+		},
+		map[string]response{
+			"/ob/profile":  {status: 200, body: []byte(`{"name":"shop"}`)},
+			"/ob/listings": {status: 500, body: []byte(`{"success":false}`)},
+		},
+		nil,
+	)
+	if len(results[0].Differences) != 0 {
+		t.Errorf("expected profile to be unchanged, but was (%v)", results[0].Differences)
+	}
+	var expected = []Difference{{Change: ChangeStatus, Before: 200, After: 500}}
+	if !reflect.DeepEqual(results[1].Differences, expected) {
+		t.Errorf("expected differences (%v), but was (%v)", expected, results[1].Differences)
+	}
+}
+
+func TestRunWithFakeDaemon(t *testing.T) {
+	var report, cleanup = mustRunFakeUpgrade(t, nil)
+	defer cleanup()
+
+	if !report.Passed {
+		t.Errorf("expected upgrade to pass, but was (%+v)", report)
+	}
+	if report.Populate == nil || !report.Populate.Passed {
+		t.Errorf("expected populate scenario to pass, but was (%+v)", report.Populate)
+	}
+	if report.RepoVersionBefore == "" || report.RepoVersionBefore != report.RepoVersionAfter {
+		t.Errorf("expected repo version to be recorded and unchanged, but was (%s -> %s)", report.RepoVersionBefore, report.RepoVersionAfter)
+	}
+	if len(report.Endpoints) != 1 || report.Endpoints[0].StatusAfter != 200 {
+		t.Errorf("expected populated profile to be served after upgrade, but was (%+v)", report.Endpoints)
+	}
+	if _, err := os.Stat(report.SnapshotPath); err != nil {
+		t.Errorf("expected snapshot to be kept, but was (%s)", err.Error())
+	}
+}
+
+func TestRunReportsMigrationErrors(t *testing.T) {
+	var report, cleanup = mustRunFakeUpgrade(t, map[string]string{"FAKEOBD_MIGRATION_ERROR": "listings index corrupt"})
+	defer cleanup()
+
+	if report.Passed {
+		t.Error("expected upgrade to fail")
+	}
+	if len(report.MigrationErrors) != 1 {
+		t.Errorf("expected a single migration error, but was (%v)", report.MigrationErrors)
+	}
+}
+
+// mustRunFakeUpgrade upgrades between two versions of the fake daemon
+// after creating a profile. env is set on the new version.
+func mustRunFakeUpgrade(t *testing.T, env map[string]string) (Report, func()) {
+	var runPath = util.GenerateTempPath("upgrade_test")
+	var build = func(spec simulation.NodeSpec) (*runner.OpenBazaarRunner, error) {
+		r, err := simulation.FakeBuild(spec)
+		if err != nil || spec.Name != "to" || env == nil {
+			return r, err
+		}
+		return r, r.SetEnvironment(runner.Environment{Vars: env})
+	}
+	port, err := freePort()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var populate = scenario.Scenario{
+		Name: "profile",
+		Steps: []scenario.Step{{
+			Action: scenario.ActionCall,
+			Node:   PopulateNode,
+			Method: "POST",
+			Path:   "/ob/profile",
+			Body:   json.RawMessage(`{"name":"upgrade test"}`),
+		}},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	report, err := Run(ctx, Options{
+		From:         "v0.1.0",
+		To:           "v0.2.0",
+		Build:        build,
+		Config:       map[string]interface{}{"Addresses.Gateway": fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", port)},
+		Populate:     &populate,
+		Endpoints:    []string{"/ob/profile"},
+		ReadyTimeout: 30 * time.Second,
+		StopGrace:    5 * time.Second,
+		RunPath:      runPath,
+	})
+	if err != nil {
+		os.RemoveAll(runPath)
+		t.Fatal(err)
+	}
+	return report, func() { os.RemoveAll(runPath) }
+}
+
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
@@ -47,6 +47,13 @@ func GenerateTempBuildPath(label string) string {
 	return GenerateTempPath(fmt.Sprintf("build_%s", label))
 }
 
+// GenerateRunPath provides a well-labeled location to persist the
+// output and metadata of a single run of an application
+func GenerateRunPath(label string) string {
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	return filepath.Join(workDir(), "runs", fmt.Sprintf("%s_%s_%d", label, time.Now().Format("20060102-150405"), r.Intn(9999)))
+}
+
 // GetXGoBuildTarget returns the appropriate os/arch for the current system's use
 func GetXGoBuildTarget() string {
 	if targetOS == "" {